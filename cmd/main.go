@@ -3,8 +3,10 @@ package main
 import (
 	"RemoteKnown/internal/detector"
 	"RemoteKnown/internal/notifier"
+	"RemoteKnown/internal/secret"
 	"RemoteKnown/internal/server"
 	"RemoteKnown/internal/storage"
+	"fmt"
 	"io"
 	"log"
 	_ "net/http/pprof"
@@ -35,6 +37,11 @@ func main() {
 		appDataDir = "."
 	}
 
+	// 命令行子命令：RemoteKnown audit verify —— 校验审计日志哈希链后退出
+	if len(os.Args) >= 3 && os.Args[1] == "audit" && os.Args[2] == "verify" {
+		os.Exit(runAuditVerify(filepath.Join(appDataDir, "RemoteKnown.db")))
+	}
+
 	// 设置日志文件
 	logFile := filepath.Join(appDataDir, "RemoteKnown.log")
 	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -57,7 +64,15 @@ func main() {
 	}
 	defer storage.Close()
 
-	notifier := notifier.NewNotifier(storage)
+	// 通知密钥等敏感配置的加密密钥，独立于数据库文件保存
+	box, err := secret.LoadOrCreateKey(filepath.Join(appDataDir, "secret.key"))
+	if err != nil {
+		log.Fatalf("初始化配置加密密钥失败: %v", err)
+	}
+
+	notifier := notifier.NewNotifier(storage, box)
+	notifier.Start()
+	go notifier.NotifyAppStart()
 	detector := detector.NewDetector(storage, notifier)
 
 	srv := server.NewServer(detector, storage, notifier)
@@ -76,5 +91,28 @@ func main() {
 
 	log.Println("正在关闭 RemoteKnown 守护进程...")
 	srv.Stop()
+	notifier.Stop()
 	log.Println("RemoteKnown 已退出")
 }
+
+// runAuditVerify 校验审计日志哈希链并打印结果，返回进程退出码（0 完好 / 1 断链 / 2 出错）。
+func runAuditVerify(dbPath string) int {
+	st, err := storage.NewStorage(dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return 2
+	}
+	defer st.Close()
+
+	result, err := st.VerifyAuditChain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验审计日志失败: %v\n", err)
+		return 2
+	}
+	if !result.OK {
+		fmt.Printf("审计日志已损坏：第 %d 条记录断链（%s），此前 %d 条完好\n", result.BrokenSeq, result.Reason, result.Count)
+		return 1
+	}
+	fmt.Printf("审计日志完好：共 %d 条记录，链头哈希 %s\n", result.Count, result.HeadHash)
+	return 0
+}
@@ -1,6 +1,7 @@
 package detector
 
 import (
+	"RemoteKnown/internal/settings"
 	"RemoteKnown/internal/storage"
 	"fmt"
 	"log"
@@ -17,6 +18,10 @@ type Signal struct {
 	Name       string    `json:"name"`
 	Confidence float64   `json:"confidence"`
 	Source     string    `json:"source"`
+	Tool       string    `json:"tool,omitempty"`   // 远程工具名（如 ToDesk、Windows RDP）
+	Peer       string    `json:"peer,omitempty"`   // 对端地址（可获取时，如 RDP 客户端 IP）
+	Method     string    `json:"method,omitempty"` // 判定方式（如 窗口类名、TCP连接数）
+	PID        int       `json:"pid,omitempty"`    // 远程工具进程 PID（进程类信号）
 	DetectedAt time.Time `json:"detected_at"`
 }
 
@@ -30,9 +35,12 @@ type DetectionResult struct {
 
 type Detector struct {
 	storage     *storage.Storage
+	settings    *settings.Store
 	notifier    Notifier
 	windows     *WindowsDetector
 	signals     []Signal
+	openSignals []Signal // 当前会话开始时的信号，会话结束通知沿用
+	reminder    sessionReminder
 	lastState   bool
 	lastChange  time.Time
 	stateMutex  sync.RWMutex
@@ -42,22 +50,34 @@ type Detector struct {
 
 // Notifier 接口，避免循环依赖
 type Notifier interface {
-	NotifyRemoteStart(signals []NotifierSignal)
-	NotifyRemoteEnd(signals []NotifierSignal)
+	NotifyRemoteStart(event SessionEvent)
+	NotifyRemoteEnd(event SessionEvent)
+	NotifyRemoteReminder(event SessionEvent)
+	NotifyRemoteEscalation(event SessionEvent)
+	NotifyStateChange(state RemoteState)
 }
 
-// NotifierSignal 接口，用于通知
-type NotifierSignal interface {
-	GetName() string
+// RemoteState 是检测器在状态切换（空闲 ↔ 远程中）时的当前状态，供 MQTT 等状态发布使用
+type RemoteState struct {
+	Active     bool
+	Since      time.Time // 进入当前状态的时间（远程中时即会话开始时间）
+	Signals    []Signal
+	Confidence float64
 }
 
-// notifierSignal 实现 NotifierSignal 接口
-type notifierSignal struct {
-	name string
+// sessionReminder 记录当前会话已发送的提醒次数与是否已升级
+type sessionReminder struct {
+	sessionID string
+	sent      int
+	escalated bool
 }
 
-func (n notifierSignal) GetName() string {
-	return n.name
+// SessionEvent 描述一次远程会话的开始或结束，供通知器渲染消息
+type SessionEvent struct {
+	SessionID string
+	StartTime time.Time
+	EndTime   time.Time // 仅会话结束时有值
+	Signals   []Signal
 }
 
 // 简化：不再使用复杂的置信度系统
@@ -68,6 +88,7 @@ const (
 func NewDetector(storage *storage.Storage, notifier Notifier) *Detector {
 	d := &Detector{
 		storage:    storage,
+		settings:   settings.New(storage),
 		notifier:   notifier,
 		windows:    NewWindowsDetector(),
 		lastState:  false,
@@ -192,6 +213,16 @@ func (d *Detector) detect() {
 		} else {
 			d.handleRemoteEnd()
 		}
+		if d.notifier != nil {
+			d.notifier.NotifyStateChange(RemoteState{
+				Active:     isRemote,
+				Since:      d.lastChange,
+				Signals:    allSignals,
+				Confidence: averageConfidence(allSignals),
+			})
+		}
+	} else if isRemote {
+		d.checkReminders(time.Now())
 	}
 
 	d.stateMutex.Unlock()
@@ -201,16 +232,20 @@ func (d *Detector) detect() {
 	}
 }
 
-func (d *Detector) handleRemoteStart(signals []Signal) {
-	// 简化：计算平均置信度（实际上都是1.0）
-	var avgConf float64 = 0.0
-	if len(signals) > 0 {
-		total := 0.0
-		for _, s := range signals {
-			total += s.Confidence
-		}
-		avgConf = total / float64(len(signals))
+// averageConfidence 计算信号的平均置信度（简化后实际上都是1.0）
+func averageConfidence(signals []Signal) float64 {
+	if len(signals) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, s := range signals {
+		total += s.Confidence
 	}
+	return total / float64(len(signals))
+}
+
+func (d *Detector) handleRemoteStart(signals []Signal) {
+	avgConf := averageConfidence(signals)
 
 	session := &storage.RemoteSession{
 		StartTime:  d.lastChange,
@@ -236,45 +271,101 @@ func (d *Detector) handleRemoteStart(signals []Signal) {
 
 	log.Printf("远程会话开始: %s, 置信度: %.2f", session.ID, avgConf)
 
+	if _, err := d.storage.AppendAuditEvent(storage.AuditSessionStart, map[string]interface{}{
+		"session_id": session.ID,
+		"start_time": session.StartTime.Format(time.RFC3339),
+		"signals":    session.Signals,
+	}); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+
+	d.openSignals = signals
+	d.reminder = sessionReminder{sessionID: session.ID}
+
 	// 发送通知
 	if d.notifier != nil {
-		// 将 detector.Signal 转换为 NotifierSignal
-		notifierSignals := make([]NotifierSignal, len(signals))
-		for i, s := range signals {
-			notifierSignals[i] = notifierSignal{name: s.Name}
-		}
-		d.notifier.NotifyRemoteStart(notifierSignals)
+		d.notifier.NotifyRemoteStart(SessionEvent{
+			SessionID: session.ID,
+			StartTime: session.StartTime,
+			Signals:   signals,
+		})
 	}
 }
 
 func (d *Detector) handleRemoteEnd() {
 	openSession, _ := d.storage.GetOpenSession()
-	var signals []Signal
+	event := SessionEvent{EndTime: time.Now()}
 	if openSession != nil {
-		endTime := time.Now()
+		endTime := event.EndTime
+		event.SessionID = openSession.ID
+		event.StartTime = openSession.StartTime
 		duration := endTime.Sub(openSession.StartTime)
 		d.storage.UpdateSessionEnd(openSession.ID, endTime, duration)
 		log.Printf("远程会话结束: %s, 持续时间: %v", openSession.ID, duration)
 
-		// 从会话记录中解析信号信息
-		if openSession.Signals != "" {
-			signalNames := d.parseSignals(openSession.Signals)
-			for _, name := range signalNames {
-				signals = append(signals, Signal{
-					Name: name,
-				})
+		if _, err := d.storage.AppendAuditEvent(storage.AuditSessionEnd, map[string]interface{}{
+			"session_id": openSession.ID,
+			"end_time":   endTime.Format(time.RFC3339),
+			"duration":   int64(duration.Seconds()),
+		}); err != nil {
+			log.Printf("写入审计日志失败: %v", err)
+		}
+
+		if len(d.openSignals) > 0 {
+			event.Signals = d.openSignals
+		} else if openSession.Signals != "" {
+			// 进程重启后内存中没有开始时的信号，从会话记录中解析信号名称
+			for _, name := range d.parseSignals(openSession.Signals) {
+				event.Signals = append(event.Signals, Signal{Name: name})
 			}
 		}
 	}
+	d.openSignals = nil
+	d.reminder = sessionReminder{}
 
 	// 发送通知
 	if d.notifier != nil {
-		// 将信号名称转换为 NotifierSignal
-		notifierSignals := make([]NotifierSignal, len(signals))
-		for i, s := range signals {
-			notifierSignals[i] = notifierSignal{name: s.Name}
+		d.notifier.NotifyRemoteEnd(event)
+	}
+}
+
+// checkReminders 在会话持续期间按配置的间隔发送提醒，超过升级阈值时发送一次升级通知（调用方持有 stateMutex）。
+// 以数据库中未结束的会话为准，会话结束后不再提醒。
+func (d *Detector) checkReminders(now time.Time) {
+	interval := d.settings.SessionReminderInterval()
+	escalateAfter := d.settings.SessionEscalationAfter()
+	if d.notifier == nil || (interval == 0 && escalateAfter == 0) {
+		return
+	}
+	openSession, err := d.storage.GetOpenSession()
+	if err != nil || openSession == nil {
+		return
+	}
+
+	elapsed := now.Sub(openSession.StartTime)
+	if openSession.ID != d.reminder.sessionID {
+		// 沿用进程内未记录的会话（如守护进程重启后）：跳过已错过的提醒，避免一次补发多条
+		d.reminder = sessionReminder{sessionID: openSession.ID}
+		if interval > 0 {
+			d.reminder.sent = int(elapsed / interval)
 		}
-		d.notifier.NotifyRemoteEnd(notifierSignals)
+		d.reminder.escalated = escalateAfter > 0 && elapsed >= escalateAfter
+	}
+
+	event := SessionEvent{
+		SessionID: openSession.ID,
+		StartTime: openSession.StartTime,
+		Signals:   d.signals,
+	}
+	if interval > 0 && elapsed >= time.Duration(d.reminder.sent+1)*interval {
+		d.reminder.sent = int(elapsed / interval)
+		log.Printf("远程会话持续提醒: %s, 已持续 %v", openSession.ID, elapsed.Round(time.Second))
+		d.notifier.NotifyRemoteReminder(event)
+	}
+	if escalateAfter > 0 && !d.reminder.escalated && elapsed >= escalateAfter {
+		d.reminder.escalated = true
+		log.Printf("远程会话升级通知: %s, 已持续 %v", openSession.ID, elapsed.Round(time.Second))
+		d.notifier.NotifyRemoteEscalation(event)
 	}
 }
 
@@ -3,15 +3,13 @@ package detector
 import (
 	"encoding/json"
 	"strings"
-)
 
-// 用户叠加层的 Config KV：与版本化规则集独立存储，避免被官方规则更新覆盖。
-const (
-	ConfigKeyCustomTools   = "custom_tools"   // []RemoteTool 的 JSON：用户录制的自定义工具
-	ConfigKeyDisabledTools = "disabled_tools" // []string 的 JSON：用户取消关注的工具进程名（小写）
-	ConfigKeyToolOverrides = "tool_overrides" // map[进程名(小写)]RemoteTool 的 JSON：用户对内置规则的修改（覆盖层）
+	"RemoteKnown/internal/settings"
 )
 
+// 用户叠加层存放在配置项 custom_tools / disabled_tools / tool_overrides 中（见 settings 包），
+// 与版本化规则集独立存储，避免被官方规则更新覆盖。
+
 // MonitoredTool 是合并后供前端展示的监控项：官方/自定义规则 + 来源 + 是否启用 + 图标。
 type MonitoredTool struct {
 	RemoteTool
@@ -32,12 +30,8 @@ func (d *Detector) officialRules() []RemoteTool {
 
 // GetToolOverrides 读取用户对内置规则的修改（覆盖层），键为进程名小写。
 func (d *Detector) GetToolOverrides() (map[string]RemoteTool, error) {
-	raw, err := d.storage.GetConfig(ConfigKeyToolOverrides)
-	if err != nil || raw == "" {
-		return nil, err
-	}
 	var ov map[string]RemoteTool
-	if err := json.Unmarshal([]byte(raw), &ov); err != nil {
+	if err := d.settings.GetJSON(settings.KeyToolOverrides, &ov); err != nil {
 		return nil, err
 	}
 	return ov, nil
@@ -47,11 +41,7 @@ func (d *Detector) setToolOverrides(ov map[string]RemoteTool) error {
 	if ov == nil {
 		ov = map[string]RemoteTool{}
 	}
-	b, err := json.Marshal(ov)
-	if err != nil {
-		return err
-	}
-	return d.storage.SetConfig(ConfigKeyToolOverrides, string(b))
+	return d.settings.SetJSON(settings.KeyToolOverrides, ov)
 }
 
 // applyOverrides 用覆盖层替换官方规则中同进程名的条目（不改变顺序）。
@@ -79,44 +69,28 @@ func rulesEqual(a, b RemoteTool) bool {
 
 // GetCustomTools 读取用户录制的自定义工具规则。
 func (d *Detector) GetCustomTools() ([]RemoteTool, error) {
-	raw, err := d.storage.GetConfig(ConfigKeyCustomTools)
-	if err != nil || raw == "" {
-		return nil, err
-	}
 	var tools []RemoteTool
-	if err := json.Unmarshal([]byte(raw), &tools); err != nil {
+	if err := d.settings.GetJSON(settings.KeyCustomTools, &tools); err != nil {
 		return nil, err
 	}
 	return tools, nil
 }
 
 func (d *Detector) setCustomTools(tools []RemoteTool) error {
-	b, err := json.Marshal(tools)
-	if err != nil {
-		return err
-	}
-	return d.storage.SetConfig(ConfigKeyCustomTools, string(b))
+	return d.settings.SetJSON(settings.KeyCustomTools, tools)
 }
 
 // GetDisabledTools 读取用户取消关注的工具进程名（小写）。
 func (d *Detector) GetDisabledTools() ([]string, error) {
-	raw, err := d.storage.GetConfig(ConfigKeyDisabledTools)
-	if err != nil || raw == "" {
-		return nil, err
-	}
 	var names []string
-	if err := json.Unmarshal([]byte(raw), &names); err != nil {
+	if err := d.settings.GetJSON(settings.KeyDisabledTools, &names); err != nil {
 		return nil, err
 	}
 	return names, nil
 }
 
 func (d *Detector) setDisabledTools(names []string) error {
-	b, err := json.Marshal(names)
-	if err != nil {
-		return err
-	}
-	return d.storage.SetConfig(ConfigKeyDisabledTools, string(b))
+	return d.settings.SetJSON(settings.KeyDisabledTools, names)
 }
 
 // mergeUserOverlay 把官方规则与用户叠加层合并：
@@ -331,6 +331,9 @@ func (d *WindowsDetector) DetectRemoteTools() ([]Signal, error) {
 				Name:       signalName,
 				Confidence: 1.0, // 简化：检测到就是1.0
 				Source:     fmt.Sprintf("进程:%s PID:%d", tool.ProcessName, remoteProcess.Pid),
+				Tool:       tool.ToolName,
+				Method:     detectionMethod,
+				PID:        int(remoteProcess.Pid),
 				DetectedAt: time.Now(),
 			})
 		}
@@ -590,6 +593,9 @@ func (d *WindowsDetector) DetectSessions() ([]Signal, error) {
 			Name:       fmt.Sprintf("Windows RDP (来自: %s)", displayName),
 			Confidence: 0.95,
 			Source:     fmt.Sprintf("会话ID:%d Station:%s", info.SessionId, stationName),
+			Tool:       "Windows RDP",
+			Peer:       clientIP,
+			Method:     "RDP 会话",
 			DetectedAt: time.Now(),
 		})
 	}
@@ -0,0 +1,286 @@
+// Package netconf 是出站连接（通知 Webhook、SMTP、规则下载）共用的网络选项：代理、CA 证书、
+// 客户端证书、证书校验开关与超时。仅使用标准库，HTTP / HTTPS / SOCKS5 代理均自行实现隧道握手。
+package netconf
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认超时
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultTimeout        = 15 * time.Second
+)
+
+// SensitiveFields 是需加密落库、API 返回时打码的字段
+var SensitiveFields = []string{"proxy_password", "client_key"}
+
+// Options 是出站连接的网络选项，零值表示直连、系统证书、默认超时。
+type Options struct {
+	ProxyURL           string `json:"proxy_url"`            // 代理地址：http:// / https:// / socks5://；留空时 HTTP 请求沿用系统代理环境变量，其余直连
+	ProxyUsername      string `json:"proxy_username"`       // 代理认证用户名（可选）
+	ProxyPassword      string `json:"proxy_password"`       // 代理认证密码（可选）
+	CACert             string `json:"ca_cert"`              // 额外信任的 CA 证书（PEM），与系统证书一起使用
+	ClientCert         string `json:"client_cert"`          // 双向 TLS 的客户端证书（PEM）
+	ClientKey          string `json:"client_key"`           // 客户端证书私钥（PEM）
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 跳过服务端证书校验（仅用于自签名证书的内网主机）
+	ConnectTimeout     int    `json:"connect_timeout"`      // 建立连接（含代理握手）的超时秒数，0 表示默认 10 秒
+	Timeout            int    `json:"timeout"`              // 单次请求的整体超时秒数，0 表示默认值
+}
+
+// Merge 以 override 中已设置的字段覆盖 base（渠道选项覆盖全局选项）；跳过证书校验任一方开启即开启。
+func Merge(base, override Options) Options {
+	out := base
+	str := func(dst *string, v string) {
+		if strings.TrimSpace(v) != "" {
+			*dst = v
+		}
+	}
+	str(&out.ProxyURL, override.ProxyURL)
+	str(&out.ProxyUsername, override.ProxyUsername)
+	str(&out.ProxyPassword, override.ProxyPassword)
+	str(&out.CACert, override.CACert)
+	str(&out.ClientCert, override.ClientCert)
+	str(&out.ClientKey, override.ClientKey)
+	out.InsecureSkipVerify = base.InsecureSkipVerify || override.InsecureSkipVerify
+	if override.ConnectTimeout > 0 {
+		out.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.Timeout > 0 {
+		out.Timeout = override.Timeout
+	}
+	return out
+}
+
+// Validate 校验代理地址、证书与超时
+func (o Options) Validate() error {
+	if _, err := o.Proxy(); err != nil {
+		return err
+	}
+	if _, err := o.TLSConfig(""); err != nil {
+		return err
+	}
+	if o.ConnectTimeout < 0 || o.Timeout < 0 {
+		return fmt.Errorf("超时不能为负数")
+	}
+	return nil
+}
+
+// ConnectTimeoutDuration 返回连接超时
+func (o Options) ConnectTimeoutDuration() time.Duration {
+	if o.ConnectTimeout > 0 {
+		return time.Duration(o.ConnectTimeout) * time.Second
+	}
+	return DefaultConnectTimeout
+}
+
+// TimeoutOr 返回整体超时，未配置时取 def
+func (o Options) TimeoutOr(def time.Duration) time.Duration {
+	if o.Timeout > 0 {
+		return time.Duration(o.Timeout) * time.Second
+	}
+	return def
+}
+
+// Proxy 解析代理地址（含认证信息），未配置时返回 nil
+func (o Options) Proxy() (*url.URL, error) {
+	raw := strings.TrimSpace(o.ProxyURL)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("代理地址无效: %s", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %q，应为 http、https 或 socks5", u.Scheme)
+	}
+	if o.ProxyUsername != "" {
+		u.User = url.UserPassword(o.ProxyUsername, o.ProxyPassword)
+	}
+	return u, nil
+}
+
+// TLSConfig 按选项构建 TLS 配置：系统证书加上自定义 CA、可选的客户端证书与跳过校验
+func (o Options) TLSConfig(serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: serverName, InsecureSkipVerify: o.InsecureSkipVerify}
+	if strings.TrimSpace(o.CACert) != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(o.CACert)) {
+			return nil, fmt.Errorf("CA 证书无效（应为 PEM 格式）")
+		}
+		cfg.RootCAs = pool
+	}
+	hasCert, hasKey := strings.TrimSpace(o.ClientCert) != "", strings.TrimSpace(o.ClientKey) != ""
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("客户端证书与私钥需同时填写")
+	}
+	if hasCert {
+		cert, err := tls.X509KeyPair([]byte(o.ClientCert), []byte(o.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("客户端证书或私钥无效: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// maxHTTPClients 是缓存的 HTTP 客户端上限。选项变更（更换代理、证书）后旧客户端不再使用，
+// 超过上限时淘汰最久未用的客户端并关闭其空闲连接。
+const maxHTTPClients = 16
+
+// cachedClient 是缓存的 HTTP 客户端及其最近一次使用的序号
+type cachedClient struct {
+	client  *http.Client
+	lastUse uint64
+}
+
+var (
+	clientsMu  sync.Mutex
+	clients    = make(map[[sha256.Size]byte]*cachedClient) // 键为选项 JSON 的哈希，内存中不以代理密码、私钥明文作键
+	clientsSeq uint64
+)
+
+// HTTPClient 返回按选项配置的 HTTP 客户端。相同选项复用同一客户端（及其连接池），
+// 整体超时未配置时为 DefaultTimeout。
+func (o Options) HTTPClient() (*http.Client, error) {
+	raw, _ := json.Marshal(o)
+	key := sha256.Sum256(raw)
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clientsSeq++
+	if c, ok := clients[key]; ok {
+		c.lastUse = clientsSeq
+		return c.client, nil
+	}
+
+	proxyURL, err := o.Proxy()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := o.TLSConfig("")
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: o.ConnectTimeoutDuration(), KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSClientConfig = tlsConfig
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	c := &http.Client{Transport: transport, Timeout: o.TimeoutOr(DefaultTimeout)}
+	if len(clients) >= maxHTTPClients {
+		evictLeastUsedClient()
+	}
+	clients[key] = &cachedClient{client: c, lastUse: clientsSeq}
+	return c, nil
+}
+
+// evictLeastUsedClient 淘汰最久未用的客户端并关闭其空闲连接（进行中的请求不受影响）。调用方持有 clientsMu。
+func evictLeastUsedClient() {
+	var oldest [sha256.Size]byte
+	var oldestUse uint64
+	found := false
+	for key, c := range clients {
+		if !found || c.lastUse < oldestUse {
+			oldest, oldestUse, found = key, c.lastUse, true
+		}
+	}
+	if found {
+		clients[oldest].client.CloseIdleConnections()
+		delete(clients, oldest)
+	}
+}
+
+// Dial 建立 TCP 连接：配置了代理时经 HTTP CONNECT 或 SOCKS5 隧道，否则直连。
+// 连接超时覆盖代理握手；调用方自行设置后续读写的截止时间。
+func (o Options) Dial(addr string) (net.Conn, error) {
+	proxyURL, err := o.Proxy()
+	if err != nil {
+		return nil, err
+	}
+	timeout := o.ConnectTimeoutDuration()
+	if proxyURL == nil {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), defaultProxyPort(proxyURL.Scheme))
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接代理 %s 失败: %w", proxyAddr, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if proxyURL.Scheme == "https" {
+		tlsConfig, err := o.TLSConfig(proxyURL.Hostname())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("与代理 %s 的 TLS 握手失败: %w", proxyAddr, err)
+		}
+		conn = tlsConn
+	}
+
+	if strings.HasPrefix(proxyURL.Scheme, "socks5") {
+		err = socks5Connect(conn, addr, proxyURL.User)
+	} else {
+		conn, err = httpConnect(conn, addr, proxyURL.User)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// DialTLS 建立 TCP 连接（可经代理）后完成 TLS 握手，serverName 用于证书校验
+func (o Options) DialTLS(addr, serverName string) (net.Conn, error) {
+	tlsConfig, err := o.TLSConfig(serverName)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := o.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(o.ConnectTimeoutDuration()))
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+func defaultProxyPort(scheme string) string {
+	switch scheme {
+	case "https":
+		return "443"
+	case "socks5", "socks5h":
+		return "1080"
+	}
+	return "80"
+}
@@ -0,0 +1,324 @@
+package netconf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// startEchoServer 启动一个先发送问候、再原样回显的 TCP 服务器
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("220 hello\r\n"))
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// relay 在两个连接之间双向转发
+func relay(a, b net.Conn) {
+	go io.Copy(a, b)
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+}
+
+// fakeProxy 记录代理收到的目标地址与认证信息
+type fakeProxy struct {
+	mu      sync.Mutex
+	targets []string
+	auths   []string
+}
+
+func (p *fakeProxy) record(target, auth string) {
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.auths = append(p.auths, auth)
+	p.mu.Unlock()
+}
+
+// startConnectProxy 启动 HTTP CONNECT 代理
+func startConnectProxy(t *testing.T) (*fakeProxy, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	p := &fakeProxy{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					conn.Close()
+					return
+				}
+				p.record(req.Host, req.Header.Get("Proxy-Authorization"))
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					conn.Close()
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				relay(conn, upstream)
+			}()
+		}
+	}()
+	return p, ln.Addr().String()
+}
+
+// startSOCKS5Proxy 启动要求用户名密码认证的 SOCKS5 代理
+func startSOCKS5Proxy(t *testing.T, user, pass string) (*fakeProxy, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	p := &fakeProxy{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				buf := make([]byte, 257)
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					conn.Close()
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 2})
+				// 用户名密码子协商
+				io.ReadFull(conn, buf[:2])
+				u := make([]byte, buf[1])
+				io.ReadFull(conn, u)
+				io.ReadFull(conn, buf[:1])
+				pw := make([]byte, buf[0])
+				io.ReadFull(conn, pw)
+				if string(u) != user || string(pw) != pass {
+					conn.Write([]byte{1, 1})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{1, 0})
+				// CONNECT 请求（域名地址）
+				io.ReadFull(conn, buf[:5])
+				host := make([]byte, buf[4])
+				io.ReadFull(conn, host)
+				io.ReadFull(conn, buf[:2])
+				target := net.JoinHostPort(string(host), strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+				p.record(target, string(u))
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				relay(conn, upstream)
+			}()
+		}
+	}()
+	return p, ln.Addr().String()
+}
+
+// readGreeting 读取回显服务器的问候，并验证隧道可双向传输
+func readGreeting(t *testing.T, conn net.Conn) {
+	t.Helper()
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || line != "220 hello\r\n" {
+		t.Fatalf("未经隧道收到问候: %q %v", line, err)
+	}
+	conn.Write([]byte("ping\n"))
+	if line, _ := r.ReadString('\n'); line != "ping\n" {
+		t.Errorf("隧道回显不正确: %q", line)
+	}
+}
+
+func TestDialViaHTTPConnectProxy(t *testing.T) {
+	target := startEchoServer(t)
+	proxy, proxyAddr := startConnectProxy(t)
+	o := Options{ProxyURL: "http://" + proxyAddr, ProxyUsername: "alice", ProxyPassword: "s3cret"}
+
+	conn, err := o.Dial(target)
+	if err != nil {
+		t.Fatalf("经 CONNECT 代理连接失败: %v", err)
+	}
+	defer conn.Close()
+	readGreeting(t, conn)
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.targets) != 1 || proxy.targets[0] != target {
+		t.Errorf("代理收到的目标地址不正确: %v", proxy.targets)
+	}
+	if proxy.auths[0] != "Basic YWxpY2U6czNjcmV0" {
+		t.Errorf("代理认证头不正确: %q", proxy.auths[0])
+	}
+}
+
+func TestDialViaSOCKS5Proxy(t *testing.T) {
+	target := startEchoServer(t)
+	proxy, proxyAddr := startSOCKS5Proxy(t, "bob", "pw")
+
+	o := Options{ProxyURL: "socks5://" + proxyAddr, ProxyUsername: "bob", ProxyPassword: "pw"}
+	conn, err := o.Dial(target)
+	if err != nil {
+		t.Fatalf("经 SOCKS5 代理连接失败: %v", err)
+	}
+	defer conn.Close()
+	readGreeting(t, conn)
+	proxy.mu.Lock()
+	if len(proxy.targets) != 1 || proxy.targets[0] != target {
+		t.Errorf("SOCKS5 代理收到的目标地址不正确: %v", proxy.targets)
+	}
+	proxy.mu.Unlock()
+
+	o.ProxyPassword = "wrong"
+	if _, err := o.Dial(target); err == nil || !strings.Contains(err.Error(), "用户名或密码错误") {
+		t.Errorf("SOCKS5 认证失败时应报错，实际 %v", err)
+	}
+}
+
+func TestHTTPClientCustomCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	plain, err := Options{}.HTTPClient()
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	if _, err := plain.Get(srv.URL); err == nil {
+		t.Errorf("未配置 CA 时自签名证书应校验失败")
+	}
+
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	withCA, err := Options{CACert: caPEM}.HTTPClient()
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	resp, err := withCA.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("配置 CA 后应校验通过: %v", err)
+	}
+	resp.Body.Close()
+
+	insecure, _ := Options{InsecureSkipVerify: true}.HTTPClient()
+	resp, err = insecure.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("跳过校验时应请求成功: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// TestHTTPClientCacheBounded 验证相同选项复用客户端，缓存数量有上限且淘汰最久未用的客户端
+func TestHTTPClientCacheBounded(t *testing.T) {
+	first, _ := Options{Timeout: 1}.HTTPClient()
+	if again, _ := (Options{Timeout: 1}).HTTPClient(); again != first {
+		t.Errorf("相同选项应复用同一客户端")
+	}
+	for i := 2; i <= maxHTTPClients+1; i++ {
+		Options{Timeout: i}.HTTPClient()
+	}
+
+	clientsMu.Lock()
+	size := len(clients)
+	clientsMu.Unlock()
+	if size > maxHTTPClients {
+		t.Errorf("缓存的客户端数 %d 超过上限 %d", size, maxHTTPClients)
+	}
+	if again, _ := (Options{Timeout: 1}).HTTPClient(); again == first {
+		t.Errorf("最久未用的客户端应被淘汰")
+	}
+}
+
+func TestHTTPClientViaProxy(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	proxy, proxyAddr := startConnectProxy(t)
+
+	c, err := Options{ProxyURL: "http://" + proxyAddr, InsecureSkipVerify: true}.HTTPClient()
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("经代理请求失败: %v", err)
+	}
+	resp.Body.Close()
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.targets) != 1 || proxy.targets[0] != strings.TrimPrefix(srv.URL, "https://") {
+		t.Errorf("HTTPS 请求应经代理隧道: %v", proxy.targets)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Options{ProxyURL: "http://global:3128", CACert: "ca", ConnectTimeout: 5, Timeout: 30}
+	got := Merge(base, Options{ProxyURL: "socks5://local:1080", Timeout: 60, InsecureSkipVerify: true})
+	if got.ProxyURL != "socks5://local:1080" || got.CACert != "ca" || got.ConnectTimeout != 5 || got.Timeout != 60 || !got.InsecureSkipVerify {
+		t.Errorf("合并结果不正确: %+v", got)
+	}
+	if Merge(base, Options{}) != base {
+		t.Errorf("空的覆盖选项不应改变全局选项")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		o    Options
+		want string
+	}{
+		{"代理协议", Options{ProxyURL: "ftp://proxy:21"}, "不支持的代理协议"},
+		{"代理地址", Options{ProxyURL: "proxy:3128"}, "代理"},
+		{"CA 证书", Options{CACert: "not a pem"}, "CA 证书无效"},
+		{"证书私钥不成对", Options{ClientCert: "cert"}, "同时填写"},
+		{"负超时", Options{Timeout: -1}, "负数"},
+	}
+	for _, c := range cases {
+		err := c.o.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 应报含 %q 的错误，实际 %v", c.name, c.want, err)
+		}
+	}
+	if err := (Options{ProxyURL: "socks5h://127.0.0.1:1080", ConnectTimeout: 3}).Validate(); err != nil {
+		t.Errorf("有效选项不应报错: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package netconf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// httpConnect 通过 HTTP CONNECT 建立到 addr 的隧道。
+// 代理的响应可能与目标服务器的首批数据（如 SMTP 问候）一起到达，缓冲中剩余的数据由返回的连接先行读出。
+func httpConnect(conn net.Conn, addr string, user *url.Userinfo) (net.Conn, error) {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if user != nil {
+		password, _ := user.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + cred + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return conn, fmt.Errorf("发送 CONNECT 请求失败: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return conn, fmt.Errorf("读取代理响应失败: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("代理拒绝建立隧道: %s", resp.Status)
+	}
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读出握手时多读入缓冲区的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(p)
+	}
+	return c.Conn.Read(p)
+}
+
+// socks5Errors 是 SOCKS5 CONNECT 应答码的含义
+var socks5Errors = map[byte]string{
+	1: "代理服务器内部错误",
+	2: "规则不允许连接",
+	3: "网络不可达",
+	4: "主机不可达",
+	5: "连接被拒绝",
+	6: "TTL 过期",
+	7: "不支持的命令",
+	8: "不支持的地址类型",
+}
+
+// socks5Connect 按 RFC 1928 / 1929 完成 SOCKS5 握手（无认证或用户名密码认证），
+// 目标地址以域名形式交给代理解析
+func socks5Connect(conn net.Conn, addr string, user *url.Userinfo) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("端口无效: %s", portStr)
+	}
+	if len(host) > 255 {
+		return fmt.Errorf("主机名过长: %s", host)
+	}
+
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("SOCKS5 握手失败: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("SOCKS5 握手失败: %w", err)
+	}
+	if reply[0] != 5 {
+		return fmt.Errorf("代理不是 SOCKS5 服务器")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return fmt.Errorf("SOCKS5 代理要求用户名密码认证")
+		}
+		password, _ := user.Password()
+		req := []byte{1, byte(len(user.Username()))}
+		req = append(req, user.Username()...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return fmt.Errorf("SOCKS5 认证失败: %w", err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("SOCKS5 认证失败: %w", err)
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("SOCKS5 代理用户名或密码错误")
+		}
+	default:
+		return fmt.Errorf("SOCKS5 代理不接受可用的认证方式")
+	}
+
+	req := []byte{5, 1, 0, 3, byte(len(host))}
+	req = append(req, host...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("SOCKS5 CONNECT 失败: %w", err)
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("SOCKS5 CONNECT 失败: %w", err)
+	}
+	if head[1] != 0 {
+		if msg, ok := socks5Errors[head[1]]; ok {
+			return fmt.Errorf("SOCKS5 代理无法连接 %s: %s", addr, msg)
+		}
+		return fmt.Errorf("SOCKS5 代理无法连接 %s，应答码 %d", addr, head[1])
+	}
+	// 跳过应答中的绑定地址与端口
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = 4
+	case 4:
+		skip = 16
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return fmt.Errorf("SOCKS5 CONNECT 失败: %w", err)
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("SOCKS5 应答的地址类型无效")
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return fmt.Errorf("SOCKS5 CONNECT 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,588 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// 渠道订阅的会话范围
+const (
+	SessionsAll          = "all"
+	SessionsAuthorized   = "authorized"   // 已被用户确认（知悉）的会话
+	SessionsUnauthorized = "unauthorized" // 尚未确认的会话
+)
+
+// ChannelType 描述一种渠道类型
+type ChannelType struct {
+	Type   string  `json:"type"`
+	Label  string  `json:"label"`
+	Schema *Schema `json:"schema,omitempty"` // 渠道设置的 JSON Schema，界面据此渲染表单
+}
+
+// channelTypes 是支持的渠道类型（按界面展示顺序）。显示名称与设置结构在 RegisterChannel 时填入，
+// 未列出的类型按注册顺序追加在末尾。
+var channelTypes = []ChannelType{
+	{Type: "feishu"},
+	{Type: "dingtalk"},
+	{Type: "wecom"},
+	{Type: "slack"},
+	{Type: "teams"},
+	{Type: "discord"},
+	{Type: "telegram"},
+	{Type: "webhook"},
+	{Type: "email"},
+	{Type: "syslog"},
+	{Type: "mqtt"},
+	{Type: "serverchan"},
+	{Type: "pushplus"},
+	{Type: "bark"},
+	{Type: "ntfy"},
+	{Type: "gotify"},
+}
+
+// ChannelTypes 返回支持的渠道类型列表
+func ChannelTypes() []ChannelType {
+	out := make([]ChannelType, len(channelTypes))
+	copy(out, channelTypes)
+	return out
+}
+
+// typeLabel 返回渠道类型的显示名称；未知类型返回空串
+func typeLabel(typ string) string {
+	for _, t := range channelTypes {
+		if t.Type == typ {
+			return t.Label
+		}
+	}
+	return ""
+}
+
+// Channel 是一个通知渠道实例。同一类型可以配置多个实例（如两个飞书群）。
+// 以类型名为 ID 的渠道由旧版 /api/notification 表单维护（兼容视图）。
+type Channel struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Enabled  bool                   `json:"enabled"`
+	Settings map[string]interface{} `json:"settings"` // 结构同旧版 notification_configs 中对应类型的子项
+	Filter   ChannelFilter          `json:"filter"`
+	// Templates 是渠道专用的消息模板（事件类型 → 模板），未配置的事件使用全局模板
+	Templates map[string]MessageTemplate `json:"templates,omitempty"`
+	Throttle  ChannelThrottle            `json:"throttle"`
+	// Network 是渠道专用的网络选项，已设置的字段覆盖全局选项（network_options）
+	Network *netconf.Options `json:"network,omitempty"`
+}
+
+// ChannelFilter 是渠道的订阅条件，各字段为空表示不限制。
+type ChannelFilter struct {
+	Events   []string `json:"events"`   // 事件类型：remote_start / remote_end / remote_reminder / remote_escalation / app_start / app_exit / rules_updated / digest（app_start 需明确勾选，见 Channel.subscribes）
+	Tools    []string `json:"tools"`    // 远程工具名（不区分大小写），仅作用于会话事件
+	Sessions string   `json:"sessions"` // all / authorized / unauthorized，仅作用于会话事件
+}
+
+// Match 判断事件是否满足订阅条件
+func (f ChannelFilter) Match(ev *Event) bool {
+	if len(f.Events) > 0 && !containsFold(f.Events, ev.Type) {
+		return false
+	}
+	if !isSessionEvent(ev.Type) {
+		return true
+	}
+	if len(f.Tools) > 0 {
+		matched := false
+		for _, tool := range ev.Tools {
+			if containsFold(f.Tools, tool) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	switch f.Sessions {
+	case SessionsAuthorized:
+		return ev.Authorized
+	case SessionsUnauthorized:
+		return !ev.Authorized
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribes 判断渠道是否订阅事件。未限定事件类型时，守护进程启动事件只发给 syslog / MQTT（接收方据此记录服务上线），
+// 其他渠道需在订阅条件中明确勾选，避免每次开机向所有聊天群推送"服务已启动"。
+func (c Channel) subscribes(ev *Event) bool {
+	if ev.Type == EventAppStart && len(c.Filter.Events) == 0 {
+		return c.Type == "syslog" || c.Type == "mqtt"
+	}
+	return c.Filter.Match(ev)
+}
+
+// machineSinks 是面向程序的渠道类型：日志平台、消息总线、自定义 Webhook 与值班平台需要收到每一条事件
+// （值班平台还要靠会话结束事件关闭告警），由接收方自行归并，因此不参与去重与限流
+var machineSinks = map[string]bool{"syslog": true, "mqtt": true, "webhook": true, "pagerduty": true, "opsgenie": true}
+
+// isLegacy 报告渠道是否由旧版表单维护
+func (c Channel) isLegacy() bool {
+	return c.ID == c.Type
+}
+
+// LoadChannels 读取渠道列表（敏感字段保持密文）。
+func (n *Notifier) LoadChannels() ([]Channel, error) {
+	return loadChannels(n.settings)
+}
+
+func loadChannels(store *settings.Store) ([]Channel, error) {
+	var channels []Channel
+	if err := store.GetJSON(settings.KeyNotificationChannels, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// SaveChannels 校验并保存渠道列表：补全 ID / 名称，加密敏感字段；
+// 敏感字段为掩码时沿用同 ID 渠道已保存的值。
+func (n *Notifier) SaveChannels(channels []Channel) error {
+	return n.saveChannels(n.settings, channels)
+}
+
+// SaveChannelsIn 同 SaveChannels，但在调用方的事务 tx 中读写（导入配置包时与其他配置一起提交或回滚）
+func (n *Notifier) SaveChannelsIn(tx *storage.Storage, channels []Channel) error {
+	return n.saveChannels(settings.New(tx), channels)
+}
+
+func (n *Notifier) saveChannels(store *settings.Store, channels []Channel) error {
+	previous, err := loadChannels(store)
+	if err != nil {
+		return err
+	}
+	prevByID := make(map[string]Channel, len(previous))
+	for _, c := range previous {
+		prevByID[c.ID] = c
+	}
+
+	seen := make(map[string]bool, len(channels))
+	for i := range channels {
+		c := &channels[i]
+		if typeLabel(c.Type) == "" {
+			return fmt.Errorf("不支持的通知类型: %s", c.Type)
+		}
+		if c.ID == "" {
+			c.ID = uuid.New().String()
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("渠道 ID 重复: %s", c.ID)
+		}
+		seen[c.ID] = true
+		if strings.TrimSpace(c.Name) == "" {
+			c.Name = typeLabel(c.Type)
+		}
+		switch c.Filter.Sessions {
+		case "":
+			c.Filter.Sessions = SessionsAll
+		case SessionsAll, SessionsAuthorized, SessionsUnauthorized:
+		default:
+			return fmt.Errorf("渠道「%s」的会话范围无效: %s", c.Name, c.Filter.Sessions)
+		}
+		if err := c.Throttle.validate(); err != nil {
+			return fmt.Errorf("渠道「%s」: %w", c.Name, err)
+		}
+		if err := ValidateTemplates(c.Templates); err != nil {
+			return fmt.Errorf("渠道「%s」: %w", c.Name, err)
+		}
+		if c.Settings == nil {
+			c.Settings = make(map[string]interface{})
+		}
+		var prevSettings map[string]interface{}
+		var prevNetwork *netconf.Options
+		if prev, ok := prevByID[c.ID]; ok && prev.Type == c.Type {
+			prevSettings = prev.Settings
+			prevNetwork = prev.Network
+		}
+		if err := n.sealSettings(c.Type, c.Settings, prevSettings); err != nil {
+			return err
+		}
+		if c.Network != nil {
+			if err := n.validateNetwork(*c.Network, prevNetwork); err != nil {
+				return fmt.Errorf("渠道「%s」的网络选项: %w", c.Name, err)
+			}
+			if err := n.sealNetwork(c.Network, prevNetwork); err != nil {
+				return err
+			}
+		}
+	}
+
+	return store.SetJSON(settings.KeyNotificationChannels, channels)
+}
+
+// MaskChannels 把渠道中非空的敏感字段替换为掩码（原地修改），供 API 返回。
+func MaskChannels(channels []Channel) {
+	for _, c := range channels {
+		maskSettings(c.Type, c.Settings)
+		if c.Network != nil {
+			maskNetwork(c.Network)
+		}
+	}
+}
+
+// ExportChannels 返回用于导出的渠道列表：includeSecrets 为 true 时敏感字段解密为明文，否则替换为掩码。
+func (n *Notifier) ExportChannels(includeSecrets bool) ([]Channel, error) {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range channels {
+		if includeSecrets {
+			n.openSettings(c.Type, c.Settings)
+			if c.Network != nil {
+				n.openNetwork(c.Network)
+			}
+		} else {
+			maskSettings(c.Type, c.Settings)
+			if c.Network != nil {
+				maskNetwork(c.Network)
+			}
+		}
+	}
+	return channels, nil
+}
+
+// FillChannelSecrets 把渠道中回传的掩码替换为同 ID 渠道已保存的真实值（用于测试未保存的渠道）。
+func (n *Notifier) FillChannelSecrets(c *Channel) error {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		return err
+	}
+	for _, stored := range channels {
+		if stored.ID != c.ID || stored.Type != c.Type {
+			continue
+		}
+		for _, field := range sensitiveFields[c.Type] {
+			if v, _ := c.Settings[field].(string); v == secret.Mask {
+				c.Settings[field] = n.open(stringField(stored.Settings, field))
+			}
+		}
+		if c.Network != nil && stored.Network != nil {
+			storedFields := networkSecretFields(stored.Network)
+			for field, p := range networkSecretFields(c.Network) {
+				if *p == secret.Mask {
+					*p = n.open(*storedFields[field])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// channelConfig 把渠道转换为发送用的配置：复制设置并解密敏感字段（不修改 c），合并网络选项
+func (n *Notifier) channelConfig(c Channel) NotificationConfig {
+	config := NotificationConfig{Enabled: c.Enabled, Type: c.Type, Network: n.NetworkOptions()}
+	config.Settings = make(map[string]interface{}, len(c.Settings))
+	for k, v := range c.Settings {
+		config.Settings[k] = v
+	}
+	n.openSettings(c.Type, config.Settings)
+	if c.Network != nil {
+		network := *c.Network
+		n.openNetwork(&network)
+		config.Network = netconf.Merge(config.Network, network)
+	}
+	return config
+}
+
+// ValidateChannel 按渠道实现的规则校验设置是否完整；停用的渠道允许保存未填完的草稿，未知类型不校验。
+// 敏感字段为掩码时按已保存的值校验（不修改 c）。
+func (n *Notifier) ValidateChannel(c Channel) error {
+	d := channelDriver(c.Type)
+	if d == nil || !c.Enabled {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(c.Settings))
+	for k, v := range c.Settings {
+		copied[k] = v
+	}
+	c.Settings = copied
+	if c.Network != nil {
+		network := *c.Network
+		c.Network = &network
+	}
+	if err := n.FillChannelSecrets(&c); err != nil {
+		return err
+	}
+	if err := d.Validate(n.channelConfig(c)); err != nil {
+		name := c.Name
+		if name == "" {
+			name = typeLabel(c.Type)
+		}
+		return fmt.Errorf("渠道「%s」: %w", name, err)
+	}
+	return nil
+}
+
+// TestChannel 向单个渠道发送测试通知（不检查启用状态与订阅条件）
+func (n *Notifier) TestChannel(c Channel) error {
+	return n.sendTest(n.channelConfig(c))
+}
+
+// sendTest 按渠道实现的 Test 发送测试通知（如值班平台测试后随即关闭事件）
+func (n *Notifier) sendTest(config NotificationConfig) error {
+	d := channelDriver(config.Type)
+	if d == nil {
+		return fmt.Errorf("不支持的通知类型: %s", config.Type)
+	}
+	return d.Test(n, config)
+}
+
+// dispatch 把事件写入所有订阅它的启用渠道的发件箱并立即并发投递一次，逐个记录结果；
+// 失败的消息由发件箱投递协程按退避时间重试
+func (n *Notifier) dispatch(ev *Event) {
+	n.dispatchTo(ev, nil)
+}
+
+// dispatchTo 同 dispatch，force 中的渠道（仍需启用）不检查订阅条件
+func (n *Notifier) dispatchTo(ev *Event, force map[string]bool) {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		log.Printf("[通知器] 读取通知渠道失败: %v", err)
+		return
+	}
+	global, err := n.LoadTemplates()
+	if err != nil {
+		log.Printf("[通知器] 读取通知模板失败，使用默认模板: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	matched := 0
+	now := time.Now()
+	for _, c := range channels {
+		if !c.Enabled || !(force[c.ID] || c.subscribes(ev)) {
+			continue
+		}
+		matched++
+		if !n.throttle.allow(c, ev, now) {
+			log.Printf("[通知器] 渠道「%s」(%s) 的 %s 通知在去重窗口内或已达频率上限，计入汇总", c.Name, c.Type, ev.Type)
+			continue
+		}
+		wg.Add(1)
+		go func(c Channel, ev *Event) {
+			defer wg.Done()
+			msg, err := n.enqueueOutbox(c, ev)
+			if err != nil {
+				// 写入发件箱失败时仍尝试直接发送一次，不因此丢失告警
+				log.Printf("[通知器] 写入发件箱失败，直接发送: %v", err)
+				if err := n.deliverRecorded(c, ev, "", 1); err != nil {
+					log.Printf("[通知器] 渠道「%s」(%s) 发送 %s 通知失败: %v", c.Name, c.Type, ev.Type, err)
+				}
+				return
+			}
+			n.attemptOutbox(*msg, c, now)
+		}(c, n.applyTemplate(ev, c, global))
+	}
+	wg.Wait()
+
+	if matched == 0 {
+		log.Printf("[通知器] 没有订阅 %s 事件的启用渠道", ev.Type)
+	}
+}
+
+// LegacyView 以旧版 /api/notification 的结构返回以类型名为 ID 的渠道（敏感字段打码）：
+// enabled / type 取第一个启用的渠道，各类型的子项为对应渠道的设置。
+func (n *Notifier) LegacyView() (map[string]interface{}, error) {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		return nil, err
+	}
+	view := map[string]interface{}{"enabled": false, "type": "feishu"}
+	first, selected := "", false
+	for _, c := range channels {
+		if !c.isLegacy() {
+			continue
+		}
+		maskSettings(c.Type, c.Settings)
+		view[c.Type] = c.Settings
+		if first == "" {
+			first = c.Type
+		}
+		if !selected && c.Enabled {
+			view["enabled"] = true
+			view["type"] = c.Type
+			selected = true
+		}
+	}
+	if !selected && first != "" {
+		view["type"] = first
+	}
+	return view, nil
+}
+
+// LegacyTypeSettings 从旧版 /api/notification 表单中取出某类型的设置：以类型名为键的子项，
+// 加上旧表单放在顶层、且属于该类型 Schema 的字段（如群机器人的 webhook_url / secret）。
+func LegacyTypeSettings(typ string, form map[string]interface{}) (map[string]interface{}, error) {
+	d := channelDriver(typ)
+	if d == nil {
+		return nil, fmt.Errorf("不支持的通知类型: %s", typ)
+	}
+	sub, _ := form[typ].(map[string]interface{})
+	out := make(map[string]interface{}, len(sub))
+	for k, v := range sub {
+		out[k] = v
+	}
+	for name := range d.Schema().Properties {
+		if _, ok := out[name]; ok {
+			continue
+		}
+		if v, ok := form[name]; ok {
+			out[name] = v
+		}
+	}
+	return out, nil
+}
+
+// SaveLegacyView 按旧版表单保存：更新类型名渠道的设置与启用状态，并停用其他类型名渠道（保持旧表单的单选语义）。
+// 通过 /api/notification/channels 新建的渠道不受影响。
+func (n *Notifier) SaveLegacyView(enabled bool, typ string, typeSettings map[string]interface{}) error {
+	if typeLabel(typ) == "" {
+		return fmt.Errorf("不支持的通知类型: %s", typ)
+	}
+	channels, err := n.LoadChannels()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range channels {
+		c := &channels[i]
+		if !c.isLegacy() {
+			continue
+		}
+		if c.Type == typ {
+			found = true
+			c.Enabled = enabled
+			if typeSettings != nil {
+				c.Settings = typeSettings
+			}
+		} else if enabled {
+			c.Enabled = false
+		}
+	}
+	if !found {
+		channels = append(channels, Channel{
+			ID:       typ,
+			Name:     typeLabel(typ),
+			Type:     typ,
+			Enabled:  enabled,
+			Settings: typeSettings,
+		})
+	}
+	return n.SaveChannels(channels)
+}
+
+// migrateLegacyConfigs 把旧版 notification_configs / notification_config 迁移为渠道列表（启动时执行一次）。
+// 当前选中的类型迁移为启用状态，其余填写过的类型迁移为停用的渠道，迁移后清空旧配置。
+func (n *Notifier) migrateLegacyConfigs() error {
+	raw, err := n.settings.Get(settings.KeyNotificationChannels)
+	if err != nil || raw != "" {
+		return err
+	}
+
+	allConfigs, err := n.LoadConfigs()
+	if err != nil {
+		return err
+	}
+	if allConfigs == nil {
+		// 最早的单类型格式
+		var oldConfig map[string]interface{}
+		if err := n.settings.GetJSON(settings.KeyNotificationLegacy, &oldConfig); err != nil {
+			return err
+		}
+		if oldConfig == nil {
+			return nil
+		}
+		typ, _ := oldConfig["type"].(string)
+		if typ == "" {
+			typ = "feishu"
+		}
+		allConfigs = map[string]interface{}{
+			"enabled": oldConfig["enabled"],
+			"type":    typ,
+			typ: map[string]interface{}{
+				"webhook_url": oldConfig["webhook_url"],
+				"secret":      oldConfig["secret"],
+			},
+		}
+	}
+
+	current, _ := allConfigs["type"].(string)
+	if current == "" {
+		current = "feishu"
+	}
+	enabled, _ := allConfigs["enabled"].(bool)
+
+	var channels []Channel
+	for _, t := range channelTypes {
+		sub, ok := allConfigs[t.Type].(map[string]interface{})
+		if !ok || (t.Type != current && !hasValue(sub)) {
+			continue
+		}
+		channels = append(channels, Channel{
+			ID:       t.Type,
+			Name:     t.Label,
+			Type:     t.Type,
+			Enabled:  enabled && t.Type == current,
+			Settings: sub,
+		})
+	}
+	if err := n.SaveChannels(channels); err != nil {
+		return err
+	}
+	if err := n.settings.Set(settings.KeyNotificationConfigs, ""); err != nil {
+		return err
+	}
+	if err := n.settings.Set(settings.KeyNotificationLegacy, ""); err != nil {
+		return err
+	}
+	log.Printf("[通知器] 已将旧版通知配置迁移为 %d 个通知渠道", len(channels))
+	return nil
+}
+
+// hasValue 报告配置子项中是否填写过任何值
+func hasValue(sub map[string]interface{}) bool {
+	for _, v := range sub {
+		switch x := v.(type) {
+		case string:
+			if x != "" {
+				return true
+			}
+		case float64:
+			if x != 0 {
+				return true
+			}
+		case bool:
+			if x {
+				return true
+			}
+		case nil:
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package notifier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestChannelFilterMatch(t *testing.T) {
+	ev := sampleEvent() // remote_end，工具 ToDesk / Windows RDP，未确认
+	cases := []struct {
+		name   string
+		filter ChannelFilter
+		want   bool
+	}{
+		{"空条件", ChannelFilter{}, true},
+		{"事件匹配", ChannelFilter{Events: []string{EventRemoteStart, EventRemoteEnd}}, true},
+		{"事件不匹配", ChannelFilter{Events: []string{EventRemoteStart}}, false},
+		{"工具不区分大小写", ChannelFilter{Tools: []string{"todesk"}}, true},
+		{"工具不匹配", ChannelFilter{Tools: []string{"AnyDesk"}}, false},
+		{"仅未确认会话", ChannelFilter{Sessions: SessionsUnauthorized}, true},
+		{"仅已确认会话", ChannelFilter{Sessions: SessionsAuthorized}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.Match(ev); got != c.want {
+			t.Errorf("%s: Match = %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+
+	// 工具与会话条件不作用于非会话事件
+	exit := &Event{Type: EventAppExit}
+	if !(ChannelFilter{Tools: []string{"AnyDesk"}, Sessions: SessionsAuthorized}).Match(exit) {
+		t.Error("app_exit 事件不应受工具 / 会话条件限制")
+	}
+}
+
+// TestAppStartIsOptIn 验证守护进程启动事件默认只发给 syslog / MQTT，其他渠道需明确订阅
+func TestAppStartIsOptIn(t *testing.T) {
+	start := &Event{Type: EventAppStart}
+	cases := []struct {
+		channel Channel
+		want    bool
+	}{
+		{Channel{Type: "feishu"}, false},
+		{Channel{Type: "email", Filter: ChannelFilter{Events: []string{EventAppStart, EventAppExit}}}, true},
+		{Channel{Type: "syslog"}, true},
+		{Channel{Type: "mqtt"}, true},
+		{Channel{Type: "syslog", Filter: ChannelFilter{Events: []string{EventRemoteStart}}}, false},
+	}
+	for _, c := range cases {
+		if got := c.channel.subscribes(start); got != c.want {
+			t.Errorf("%s %v: subscribes = %v, 期望 %v", c.channel.Type, c.channel.Filter.Events, got, c.want)
+		}
+	}
+	if !(Channel{Type: "feishu"}).subscribes(&Event{Type: EventAppExit}) {
+		t.Error("其他事件仍按订阅条件匹配")
+	}
+}
+
+// TestDispatchRoutesByFilter 验证事件只发送到启用且订阅它的渠道。
+func TestDispatchRoutesByFilter(t *testing.T) {
+	n, _ := newTestNotifier(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	err := n.SaveChannels([]Channel{
+		{Name: "全部事件", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL + "/all"}},
+		{Name: "仅开始", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL + "/start"},
+			Filter: ChannelFilter{Events: []string{EventRemoteStart}}},
+		{Name: "已停用", Type: "discord", Settings: map[string]interface{}{"webhook_url": srv.URL + "/disabled"}},
+	})
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	n.dispatch(sampleEvent())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/all"] != 1 || hits["/start"] != 0 || hits["/disabled"] != 0 {
+		t.Errorf("投递结果不正确: %v", hits)
+	}
+}
+
+// TestLegacyViewRoundTrip 验证旧版表单的读写与单选语义，且不影响新建的渠道。
+func TestLegacyViewRoundTrip(t *testing.T) {
+	n, _ := newTestNotifier(t)
+
+	if err := n.SaveChannels([]Channel{{Name: "值班群", Type: "feishu", Enabled: true, Settings: map[string]interface{}{"webhook_url": "x"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SaveLegacyView(true, "dingtalk", map[string]interface{}{"webhook_url": "d", "secret": "s1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SaveLegacyView(true, "feishu", map[string]interface{}{"webhook_url": "f"}); err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := n.LegacyView()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view["type"] != "feishu" || view["enabled"] != true {
+		t.Errorf("旧版视图应选中飞书，实际 type=%v enabled=%v", view["type"], view["enabled"])
+	}
+	if got := view["dingtalk"].(map[string]interface{})["secret"]; got != "******" {
+		t.Errorf("旧版视图中的密钥应打码，实际 %v", got)
+	}
+
+	channels, _ := n.LoadChannels()
+	if len(channels) != 3 {
+		t.Fatalf("应有 3 个渠道，实际 %d", len(channels))
+	}
+	for _, c := range channels {
+		switch {
+		case !c.isLegacy() && !c.Enabled:
+			t.Error("旧版表单不应停用新建的渠道")
+		case c.ID == "dingtalk" && c.Enabled:
+			t.Error("选中飞书后钉钉应被停用")
+		case c.ID == "dingtalk" && n.channelConfig(c).Settings["secret"] != "s1":
+			t.Error("钉钉密钥不应丢失")
+		}
+	}
+}
+
+// TestLegacyTypeSettings 验证旧版表单按类型的 Schema 取设置：子项优先，顶层只取该类型声明的字段
+func TestLegacyTypeSettings(t *testing.T) {
+	form := map[string]interface{}{
+		"enabled": true, "type": "wecom", "webhook_url": "https://qyapi.weixin.qq.com/x", "secret": "",
+		"wecom": map[string]interface{}{"mentioned_mobiles": "@all"},
+		"email": map[string]interface{}{"smtp_host": "smtp.example.com"},
+	}
+	got, err := LegacyTypeSettings("wecom", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["webhook_url"] != "https://qyapi.weixin.qq.com/x" || got["mentioned_mobiles"] != "@all" {
+		t.Errorf("企业微信应合并顶层地址与 @ 提醒: %v", got)
+	}
+	if _, ok := got["secret"]; ok {
+		t.Errorf("企业微信没有签名密钥，不应取顶层 secret: %v", got)
+	}
+	if got, _ := LegacyTypeSettings("email", form); got["smtp_host"] != "smtp.example.com" || got["webhook_url"] != nil {
+		t.Errorf("邮件只应取 email 子项: %v", got)
+	}
+	if _, err := LegacyTypeSettings("unknown", form); err == nil {
+		t.Errorf("未知类型应返回错误")
+	}
+}
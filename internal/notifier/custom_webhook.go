@@ -0,0 +1,384 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func init() {
+	RegisterChannel(webhookDriver{})
+}
+
+// WebhookConfig 自定义 Webhook 渠道配置：请求体由 text/template 按事件渲染，对接 SOAR、工单等内部系统。
+type WebhookConfig struct {
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`        // POST（默认）/ PUT / PATCH；签名按请求体计算，不支持不带请求体的 GET
+	Headers      map[string]string `json:"headers"`       // 自定义请求头（未指定 Content-Type 时为 application/json）
+	BodyTemplate string            `json:"body_template"` // Go text/template，数据为 Event；留空发送事件 JSON
+	Secret       string            `json:"secret"`        // 签名密钥（可选），用于 X-RemoteKnown-Signature
+
+	// 成功条件：状态码范围（如 "200-299"、"200,201"，默认 2xx），
+	// 以及可选的响应 JSON 字段相等判断（如 $.code == 0）
+	SuccessStatus    string `json:"success_status"`
+	SuccessJSONPath  string `json:"success_json_path"`
+	SuccessJSONValue string `json:"success_json_value"`
+}
+
+// 签名相关请求头：Signature = "sha256=" + hex(HMAC-SHA256(secret, Timestamp + body))
+const (
+	HeaderSignature = "X-RemoteKnown-Signature"
+	HeaderTimestamp = "X-RemoteKnown-Timestamp"
+	HeaderEvent     = "X-RemoteKnown-Event"
+)
+
+// templateFuncs 是请求体模板可用的辅助函数
+var templateFuncs = template.FuncMap{
+	// json 把值编码为 JSON 字面量，字符串放进 JSON 模板时应使用它以正确转义
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": func(items []string, sep string) string {
+		return strings.Join(items, sep)
+	},
+	"formatTime": func(t time.Time, layout string) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(layout)
+	},
+	// datetime 按 "2006-01-02 15:04:05" 格式化时间，零值返回空串
+	"datetime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+// webhookMethods 是自定义 Webhook 支持的请求方法：都带请求体，签名与接收方收到的内容一致
+var webhookMethods = []interface{}{"POST", "PUT", "PATCH"}
+
+// webhookDriver 是自定义 Webhook 渠道
+type webhookDriver struct{ sendsTest }
+
+func (webhookDriver) Type() string { return "webhook" }
+
+func (webhookDriver) Schema() *Schema {
+	webhookURL := field("请求地址", "")
+	webhookURL.Format = "uri"
+	bodyTemplate := field("请求体模板", "Go text/template，数据为事件（如 {{json .Title}}）；留空发送事件 JSON")
+	bodyTemplate.Format = "textarea"
+	return &Schema{
+		Type:  "object",
+		Title: "自定义 Webhook",
+		Properties: map[string]*Schema{
+			"url":    webhookURL,
+			"method": {Type: "string", Title: "请求方法", Enum: webhookMethods, Default: "POST"},
+			"headers": {Type: "object", Title: "请求头", Description: "未指定 Content-Type 时为 application/json",
+				AdditionalProperties: &Schema{Type: "string"}},
+			"body_template":      bodyTemplate,
+			"secret":             secretField("签名密钥", "填写后按 HMAC-SHA256 签名，放在 "+HeaderSignature+" 请求头中"),
+			"success_status":     field("成功状态码", "如 200-299 或 200,201，留空为 2xx"),
+			"success_json_path":  field("成功判断字段", "响应 JSON 中需比较的字段，如 $.code；留空不检查"),
+			"success_json_value": field("成功判断值", "字段等于该值时视为成功，如 0"),
+		},
+		Required: []string{"url"},
+		Order: []string{"url", "method", "headers", "body_template", "secret",
+			"success_status", "success_json_path", "success_json_value"},
+	}
+}
+
+func (webhookDriver) Validate(config NotificationConfig) error {
+	wh := webhookSettings(config)
+	if err := validateWebhookURL(wh.URL); err != nil {
+		return err
+	}
+	if _, err := wh.method(); err != nil {
+		return err
+	}
+	if _, err := wh.successCondition(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(wh.BodyTemplate) != "" {
+		if _, err := template.New("webhook").Funcs(templateFuncs).Parse(wh.BodyTemplate); err != nil {
+			return fmt.Errorf("请求体模板解析失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (webhookDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendCustomWebhook(config, ev)
+}
+
+func (webhookDriver) Endpoint(config NotificationConfig) string { return webhookSettings(config).URL }
+
+// webhookSettings 返回渠道的自定义 Webhook 设置
+func webhookSettings(config NotificationConfig) WebhookConfig {
+	var wh WebhookConfig
+	config.decode(&wh)
+	return wh
+}
+
+// renderWebhookBody 按模板渲染请求体；模板为空时返回事件 JSON
+func renderWebhookBody(tmpl string, ev *Event) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(ev)
+	}
+	t, err := template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("请求体模板解析失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("请求体模板渲染失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// signWebhook 计算请求签名
+func signWebhook(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// sendCustomWebhook 发送自定义 Webhook 通知
+func (n *Notifier) sendCustomWebhook(config NotificationConfig, ev *Event) error {
+	wh := webhookSettings(config)
+	if wh.URL == "" {
+		return fmt.Errorf("Webhook URL 不能为空")
+	}
+	cond, err := wh.successCondition()
+	if err != nil {
+		return err
+	}
+	body, err := renderWebhookBody(wh.BodyTemplate, ev)
+	if err != nil {
+		return err
+	}
+
+	method, err := wh.method()
+	if err != nil {
+		return err
+	}
+	client, err := httpClientFor(config.Network)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %s", redactURL(err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(HeaderEvent, ev.Type)
+	if wh.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderSignature, signWebhook(wh.Secret, ts, body))
+	}
+
+	log.Printf("[通知器] 发送自定义 Webhook 请求: %s %s", method, redactURL(wh.URL))
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息包含完整 URL，需脱敏后再返回
+		return fmt.Errorf("发送 HTTP 请求失败: %s", redactURL(err.Error()))
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	return cond.check(resp.StatusCode, respBody)
+}
+
+// method 返回请求方法（留空为 POST），不支持的方法返回错误
+func (wh WebhookConfig) method() (string, error) {
+	method := strings.ToUpper(strings.TrimSpace(wh.Method))
+	if method == "" {
+		return http.MethodPost, nil
+	}
+	for _, m := range webhookMethods {
+		if m == method {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("请求方法 %q 无效，应为 POST / PUT / PATCH", wh.Method)
+}
+
+// successCondition 把配置转换为成功条件
+func (wh WebhookConfig) successCondition() (successCondition, error) {
+	statuses, err := parseStatusRanges(wh.SuccessStatus)
+	if err != nil {
+		return successCondition{}, err
+	}
+	return successCondition{
+		Statuses:  statuses,
+		JSONPath:  strings.TrimSpace(wh.SuccessJSONPath),
+		JSONValue: wh.SuccessJSONValue,
+	}, nil
+}
+
+// statusRange 是闭区间 [Min, Max] 的 HTTP 状态码范围
+type statusRange struct {
+	Min, Max int
+}
+
+// successCondition 描述如何判断一次 HTTP 投递是否成功
+type successCondition struct {
+	Statuses    []statusRange // 允许的状态码，空表示 2xx
+	JSONPath    string        // 需比较的响应 JSON 字段，如 $.code；空表示不检查
+	JSONValue   string        // JSONPath 的期望值（按字符串比较）
+	MessagePath string        // 失败时取错误描述的字段
+	Lenient     bool          // 响应不是 JSON 或缺少该字段时视为成功
+}
+
+// check 按状态码与 JSON 字段判断响应是否成功
+func (c successCondition) check(status int, body []byte) error {
+	statuses := c.Statuses
+	if len(statuses) == 0 {
+		statuses = []statusRange{{200, 299}}
+	}
+	ok := false
+	for _, r := range statuses {
+		if status >= r.Min && status <= r.Max {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("HTTP 请求失败，状态码: %d，响应: %s", status, summarizeBody(body))
+	}
+	if c.JSONPath == "" {
+		return nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		if c.Lenient {
+			log.Printf("[通知器] 解析响应失败: %v", err)
+			return nil
+		}
+		return fmt.Errorf("响应不是合法的 JSON: %s", summarizeBody(body))
+	}
+	got, found, err := lookupJSONPath(doc, c.JSONPath)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if c.Lenient {
+			return nil
+		}
+		return fmt.Errorf("响应中缺少字段 %s: %s", c.JSONPath, summarizeBody(body))
+	}
+	if jsonScalarString(got) == c.JSONValue {
+		return nil
+	}
+
+	msg := summarizeBody(body)
+	if c.MessagePath != "" {
+		if m, ok, _ := lookupJSONPath(doc, c.MessagePath); ok {
+			msg = jsonScalarString(m)
+		}
+	}
+	field := c.JSONPath[strings.LastIndexAny(c.JSONPath, ".]")+1:]
+	return fmt.Errorf("通知发送失败(%s=%s): %s", field, jsonScalarString(got), msg)
+}
+
+// parseStatusRanges 解析 "200-299"、"200,201,204" 形式的状态码列表
+func parseStatusRanges(s string) ([]statusRange, error) {
+	var out []statusRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		min, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		max, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || min < 100 || max > 599 || min > max {
+			return nil, fmt.Errorf("成功状态码格式无效: %q", part)
+		}
+		out = append(out, statusRange{min, max})
+	}
+	return out, nil
+}
+
+// lookupJSONPath 在已解析的 JSON 中查找简单路径（$.a.b[0].c）对应的值
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	cur := doc
+	for p != "" {
+		switch {
+		case strings.HasPrefix(p, "."):
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key := p[:end]
+			p = p[end:]
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if cur, ok = obj[key]; !ok {
+				return nil, false, nil
+			}
+		case strings.HasPrefix(p, "["):
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, false, fmt.Errorf("JSONPath 格式无效: %s", path)
+			}
+			idx, err := strconv.Atoi(p[1:end])
+			if err != nil {
+				return nil, false, fmt.Errorf("JSONPath 下标无效: %s", path)
+			}
+			p = p[end+1:]
+			arr, ok := cur.([]interface{})
+			if !ok || idx < 0 || idx >= len(arr) {
+				return nil, false, nil
+			}
+			cur = arr[idx]
+		default:
+			return nil, false, fmt.Errorf("JSONPath 格式无效: %s", path)
+		}
+	}
+	return cur, true, nil
+}
+
+// jsonScalarString 把 JSON 值转为比较用的字符串（数字不带多余小数，对象/数组为紧凑 JSON）
+func jsonScalarString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
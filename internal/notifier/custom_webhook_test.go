@@ -0,0 +1,179 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/detector"
+)
+
+func sampleEvent() *Event {
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	ev := &Event{Type: EventRemoteEnd, Title: "✅ 远程控制已断开", DeviceName: `财务"PC"`, Hostname: "FIN-01", Time: start}
+	ev.setSession(detector.SessionEvent{
+		SessionID: "s1",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Minute),
+		Signals: []detector.Signal{
+			{Name: "ToDesk (进程存在)", Tool: "ToDesk"},
+			{Name: "Windows RDP (来自: PC 10.0.0.8)", Tool: "Windows RDP", Peer: "10.0.0.8"},
+			{Name: "ToDesk (窗口)", Tool: "ToDesk"},
+		},
+	})
+	return ev
+}
+
+func TestEventSetSession(t *testing.T) {
+	ev := sampleEvent()
+	if ev.Tool != "ToDesk" || len(ev.Tools) != 2 {
+		t.Errorf("工具应去重且保留顺序，实际 %q %v", ev.Tool, ev.Tools)
+	}
+	if len(ev.Peers) != 1 || ev.Peers[0] != "10.0.0.8" {
+		t.Errorf("对端地址错误: %v", ev.Peers)
+	}
+	if ev.Duration != "01:30:00" || ev.DurationSeconds != 5400 {
+		t.Errorf("时长错误: %s %d", ev.Duration, ev.DurationSeconds)
+	}
+}
+
+func TestRenderWebhookBody(t *testing.T) {
+	tmpl := `{"event":{{json .Type}},"host":{{json .DeviceName}},"tools":{{json .Tools}},"peers":"{{join .Peers ","}}","start":"{{formatTime .StartTime "15:04"}}","secs":{{.DurationSeconds}}}`
+	body, err := renderWebhookBody(tmpl, sampleEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("渲染结果不是合法 JSON: %v\n%s", err, body)
+	}
+	if got["host"] != `财务"PC"` || got["event"] != "remote_end" || got["peers"] != "10.0.0.8" || got["start"] != "09:00" || got["secs"] != float64(5400) {
+		t.Errorf("渲染结果错误: %s", body)
+	}
+
+	if _, err := renderWebhookBody(`{{.NoSuchField}}`, sampleEvent()); err == nil {
+		t.Error("引用不存在的字段应报错")
+	}
+
+	body, err = renderWebhookBody("", sampleEvent())
+	if err != nil || !strings.Contains(string(body), `"session_id":"s1"`) {
+		t.Errorf("空模板应发送事件 JSON: %s %v", body, err)
+	}
+}
+
+func TestSendCustomWebhookSignsRequest(t *testing.T) {
+	const secret = "s3cr3t"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("请求方法应为 PUT，实际 %s", r.Method)
+		}
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			t.Errorf("自定义请求头未携带")
+		}
+		if r.Header.Get(HeaderEvent) != EventRemoteEnd {
+			t.Errorf("事件类型请求头错误: %s", r.Header.Get(HeaderEvent))
+		}
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(r.Header.Get(HeaderTimestamp)))
+		mac.Write(body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(HeaderSignature) != want {
+			t.Errorf("签名不匹配: %s != %s", r.Header.Get(HeaderSignature), want)
+		}
+		w.Write([]byte(`{"result":{"status":"queued"}}`))
+	}))
+	defer srv.Close()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "webhook", Settings: settingsOf(WebhookConfig{
+		URL:              srv.URL,
+		Method:           "put",
+		Headers:          map[string]string{"Authorization": "Bearer abc"},
+		BodyTemplate:     `{"tool":{{json .Tool}}}`,
+		Secret:           secret,
+		SuccessJSONPath:  "$.result.status",
+		SuccessJSONValue: "queued",
+	})}
+	if err := n.deliver(cfg, sampleEvent()); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	cfg.Settings["success_json_value"] = "done"
+	if err := n.deliver(cfg, sampleEvent()); err == nil || !strings.Contains(err.Error(), "status=queued") {
+		t.Errorf("JSON 字段不等于期望值时应报错，实际 %v", err)
+	}
+}
+
+// TestCustomWebhookRejectsGET 验证不带请求体的 GET 不能使用：签名按请求体计算，接收方无法校验
+func TestCustomWebhookRejectsGET(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, "ok")
+	cfg := NotificationConfig{Type: "webhook", Settings: settingsOf(WebhookConfig{URL: fake.URL, Method: "get", Secret: "s"})}
+	if err := (webhookDriver{}).Validate(cfg); err == nil {
+		t.Error("GET 应校验失败")
+	}
+	if err := (&Notifier{}).deliver(cfg, sampleEvent()); err == nil || len(fake.Requests()) != 0 {
+		t.Errorf("GET 不应发送请求，实际 %v", err)
+	}
+	cfg.Settings["method"] = "PATCH"
+	if err := (webhookDriver{}).Validate(cfg); err != nil {
+		t.Errorf("PATCH 应校验通过: %v", err)
+	}
+}
+
+// TestCustomWebhookRedactsURL 验证请求失败时错误信息中的地址已脱敏，不泄露路径中的令牌
+func TestCustomWebhookRedactsURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close() // 关闭后连接失败，错误信息中带有请求地址
+
+	cfg := NotificationConfig{Type: "webhook", Settings: settingsOf(WebhookConfig{URL: addr + "/bot123:tok3n/hook"})}
+	err := (&Notifier{}).deliver(cfg, sampleEvent())
+	if err == nil || strings.Contains(err.Error(), "tok3n") {
+		t.Errorf("错误信息应脱敏，实际 %v", err)
+	}
+}
+
+func TestSuccessCondition(t *testing.T) {
+	cond := successCondition{Statuses: []statusRange{{200, 200}, {202, 202}}}
+	if err := cond.check(202, nil); err != nil {
+		t.Errorf("202 应视为成功: %v", err)
+	}
+	if err := cond.check(201, []byte("created")); err == nil {
+		t.Error("201 不在允许范围内应报错")
+	}
+
+	// 飞书 / 钉钉兼容：非 JSON 或缺少字段时视为成功
+	if err := feishuSuccess.check(200, []byte("not json")); err != nil {
+		t.Errorf("宽松模式下非 JSON 响应应视为成功: %v", err)
+	}
+	if err := feishuSuccess.check(200, []byte(`{"code":19021,"msg":"sign match fail"}`)); err == nil || !strings.Contains(err.Error(), "sign match fail") {
+		t.Errorf("飞书错误码应报错并带上 msg，实际 %v", err)
+	}
+
+	strict := successCondition{JSONPath: "$.items[1].ok", JSONValue: "true"}
+	if err := strict.check(200, []byte(`{"items":[{"ok":false},{"ok":true}]}`)); err != nil {
+		t.Errorf("数组下标路径应能匹配: %v", err)
+	}
+	if err := strict.check(200, []byte(`{"items":[]}`)); err == nil {
+		t.Error("严格模式下缺少字段应报错")
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	got, err := parseStatusRanges("200-299, 304")
+	if err != nil || len(got) != 2 || got[0] != (statusRange{200, 299}) || got[1] != (statusRange{304, 304}) {
+		t.Errorf("解析结果错误: %v %v", got, err)
+	}
+	for _, bad := range []string{"abc", "300-200", "42"} {
+		if _, err := parseStatusRanges(bad); err == nil {
+			t.Errorf("%q 应解析失败", bad)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+)
+
+// 诊断步骤的结果
+const (
+	DiagOK   = "ok"
+	DiagWarn = "warn" // 通过，但存在隐患（如证书即将过期、明文传输）
+	DiagFail = "fail"
+	DiagSkip = "skip" // 不适用，或前序步骤失败未执行
+)
+
+// certExpiryWarning 是证书剩余有效期低于多少时给出提醒
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// DiagnosticStep 是连通性诊断的一个步骤
+type DiagnosticStep struct {
+	Name     string `json:"name"`           // 步骤标识：config / url / dns / tcp / tls / http / ehlo / starttls / auth_mechs / auth / envelope
+	Title    string `json:"title"`          // 步骤名称
+	Status   string `json:"status"`         // ok / warn / fail / skip
+	Detail   string `json:"detail"`         // 结果说明或错误信息
+	Hint     string `json:"hint,omitempty"` // 处理建议
+	Duration int64  `json:"duration_ms"`    // 耗时（毫秒）
+}
+
+// DiagnosticReport 是一次渠道连通性诊断的结果
+type DiagnosticReport struct {
+	Type     string           `json:"type"`
+	Target   string           `json:"target"`          // 诊断目标（只含协议与主机，不含路径中的密钥）
+	Proxy    string           `json:"proxy,omitempty"` // 经由的代理
+	Success  bool             `json:"success"`
+	Steps    []DiagnosticStep `json:"steps"`
+	Duration int64            `json:"duration_ms"`
+}
+
+// diagnosis 依次执行诊断步骤：某一步失败后，后续步骤记为跳过
+type diagnosis struct {
+	report *DiagnosticReport
+	failed bool
+}
+
+// run 执行一个步骤并记录耗时。fn 可设置 Detail / Hint，或把 Status 设为 warn / skip；返回错误即判定失败。
+func (d *diagnosis) run(name, title string, fn func(s *DiagnosticStep) error) bool {
+	s := DiagnosticStep{Name: name, Title: title}
+	if d.failed {
+		s.Status = DiagSkip
+		s.Detail = "前序步骤失败，未执行"
+		d.report.Steps = append(d.report.Steps, s)
+		return false
+	}
+	start := time.Now()
+	err := fn(&s)
+	s.Duration = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		s.Status = DiagFail
+		if s.Detail == "" {
+			s.Detail = err.Error()
+		} else {
+			s.Detail += "：" + err.Error()
+		}
+		d.failed = true
+	case s.Status == "":
+		s.Status = DiagOK
+	}
+	d.report.Steps = append(d.report.Steps, s)
+	return err == nil
+}
+
+// skip 记录一个不适用的步骤（不影响后续步骤）
+func (d *diagnosis) skip(name, title, detail string) {
+	if d.failed {
+		detail = "前序步骤失败，未执行"
+	}
+	d.report.Steps = append(d.report.Steps, DiagnosticStep{Name: name, Title: title, Status: DiagSkip, Detail: detail})
+}
+
+// DiagnoseChannel 分步检查渠道的连通性并返回报告。
+// HTTP 类渠道依次检查地址、DNS、TCP、TLS，最后发送一条测试通知查看服务端响应；
+// 邮件渠道检查到 EHLO、STARTTLS、认证与收发件人为止，不实际发送邮件。
+func (n *Notifier) DiagnoseChannel(c Channel) (*DiagnosticReport, error) {
+	config := n.channelConfig(c)
+	d := &diagnosis{report: &DiagnosticReport{Type: c.Type}}
+	if proxyURL, err := config.Network.Proxy(); err == nil && proxyURL != nil {
+		d.report.Proxy = proxyURL.Scheme + "://" + proxyURL.Host
+	}
+
+	start := time.Now()
+	if c.Type == "email" {
+		n.diagnoseSMTP(d, config)
+	} else if e, ok := channelDriver(c.Type).(ChannelEndpoint); ok {
+		n.diagnoseHTTP(d, config, e.Endpoint(config))
+	} else {
+		return nil, fmt.Errorf("%s 渠道暂不支持分步诊断，请使用发送测试通知", typeLabel(c.Type))
+	}
+	d.report.Duration = time.Since(start).Milliseconds()
+	d.report.Success = !d.failed
+	return d.report, nil
+}
+
+// ChannelEndpoint 是经 HTTP 发送的渠道实现的可选接口，分步诊断据此检查服务端的连通性
+type ChannelEndpoint interface {
+	// Endpoint 返回请求的地址，未填写时返回空串
+	Endpoint(config NotificationConfig) string
+}
+
+// diagnoseHTTP 诊断 HTTP 类渠道：地址 → DNS → TCP → TLS → 发送测试通知
+func (n *Notifier) diagnoseHTTP(d *diagnosis, config NotificationConfig, endpoint string) {
+	var u *url.URL
+	d.run("url", "解析地址", func(s *DiagnosticStep) error {
+		if strings.TrimSpace(endpoint) == "" {
+			s.Hint = "请填写渠道的 Webhook 地址或服务器地址"
+			return fmt.Errorf("地址为空")
+		}
+		parsed, err := url.Parse(strings.TrimSpace(endpoint))
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			s.Hint = "地址应以 http:// 或 https:// 开头，形如 https://example.com/hook"
+			return fmt.Errorf("地址无效: %s", redactURL(endpoint))
+		}
+		u = parsed
+		d.report.Target = u.Scheme + "://" + u.Host
+		s.Detail = d.report.Target
+		if u.Scheme == "http" {
+			s.Status = DiagWarn
+			s.Hint = "使用明文 HTTP，消息内容与地址中的密钥可能被窃听，建议改用 HTTPS"
+		}
+		return nil
+	})
+	if u == nil {
+		n.diagnoseConnection(d, config.Network, "", false)
+		d.run("http", "HTTP 响应", nil)
+		return
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if conn := n.diagnoseConnection(d, config.Network, net.JoinHostPort(u.Hostname(), port), u.Scheme == "https"); conn != nil {
+		conn.Close()
+	}
+
+	d.run("http", "HTTP 响应", func(s *DiagnosticStep) error {
+		if err := n.sendTest(config); err != nil {
+			s.Hint = httpHint(err)
+			return err
+		}
+		s.Detail = "已发送测试通知，服务端返回成功"
+		return nil
+	})
+}
+
+// diagnoseConnection 依次检查 DNS、TCP 连接（经代理时为代理隧道）与可选的 TLS 握手，成功时返回已建立的连接
+func (n *Notifier) diagnoseConnection(d *diagnosis, opts netconf.Options, addr string, useTLS bool) net.Conn {
+	host, _, _ := net.SplitHostPort(addr)
+	proxyURL, proxyErr := opts.Proxy()
+
+	d.run("dns", "DNS 解析", func(s *DiagnosticStep) error {
+		if proxyErr != nil {
+			s.Hint = "请在网络选项中修正代理地址"
+			return proxyErr
+		}
+		lookup := host
+		if proxyURL != nil {
+			// 经代理连接时目标地址由代理解析，本机只需解析代理地址
+			lookup = proxyURL.Hostname()
+			s.Detail = "经代理连接，目标地址由代理解析；"
+		}
+		if net.ParseIP(lookup) != nil {
+			s.Detail += lookup + " 为 IP 地址，无需解析"
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), opts.ConnectTimeoutDuration())
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, lookup)
+		if err != nil {
+			s.Hint = "请检查域名拼写与本机 DNS 设置；内网域名需确认本机能够解析，或改填 IP 地址"
+			return fmt.Errorf("解析 %s 失败: %w", lookup, err)
+		}
+		ips := make([]string, 0, len(addrs))
+		for _, a := range addrs {
+			ips = append(ips, a.IP.String())
+		}
+		s.Detail += fmt.Sprintf("%s → %s", lookup, strings.Join(ips, ", "))
+		return nil
+	})
+
+	var conn net.Conn
+	d.run("tcp", "TCP 连接", func(s *DiagnosticStep) error {
+		c, err := opts.Dial(addr)
+		if err != nil {
+			s.Hint = dialHint(err, proxyURL != nil)
+			return err
+		}
+		conn = c
+		if proxyURL != nil {
+			s.Detail = fmt.Sprintf("已经代理 %s 建立到 %s 的隧道", proxyURL.Host, addr)
+		} else {
+			s.Detail = "已连接 " + c.RemoteAddr().String()
+		}
+		return nil
+	})
+
+	if !useTLS {
+		d.skip("tls", "TLS 握手", "未使用 TLS")
+		return conn
+	}
+	var tlsConn *tls.Conn
+	d.run("tls", "TLS 握手", func(s *DiagnosticStep) error {
+		cfg, err := opts.TLSConfig(host)
+		if err != nil {
+			conn.Close()
+			s.Hint = "请在网络选项中修正证书设置"
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(opts.ConnectTimeoutDuration()))
+		tc := tls.Client(conn, cfg)
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			s.Hint = tlsHint(err)
+			// 不校验证书再握手一次，报告服务器实际出示的证书，便于判断问题所在
+			if peer := inspectCertificate(opts, addr, host); peer != "" {
+				s.Detail = "服务器证书：" + peer
+			}
+			return fmt.Errorf("TLS 握手失败: %w", err)
+		}
+		tc.SetDeadline(time.Time{})
+		tlsConn = tc
+		describeTLS(s, tc.ConnectionState(), cfg.InsecureSkipVerify)
+		return nil
+	})
+	if tlsConn == nil {
+		return nil
+	}
+	return tlsConn
+}
+
+// inspectCertificate 跳过校验完成 TLS 握手，返回服务器证书的描述；失败时返回空串
+func inspectCertificate(opts netconf.Options, addr, host string) string {
+	opts.InsecureSkipVerify = true
+	conn, err := opts.DialTLS(addr, host)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return describeCertificate(state.PeerCertificates[0])
+}
+
+// describeTLS 把握手结果写入步骤：协议版本与证书信息；证书即将过期或跳过了校验时给出提醒
+func describeTLS(s *DiagnosticStep, state tls.ConnectionState, insecure bool) {
+	s.Detail = tls.VersionName(state.Version)
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	cert := state.PeerCertificates[0]
+	s.Detail += "，" + describeCertificate(cert)
+	if len(state.VerifiedChains) > 0 {
+		s.Detail += fmt.Sprintf("，证书链 %d 级已校验", len(state.VerifiedChains[0]))
+	}
+	switch left := time.Until(cert.NotAfter); {
+	case insecure:
+		s.Status = DiagWarn
+		s.Hint = "已跳过证书校验，连接可能被中间人劫持；建议改为在网络选项中添加 CA 证书"
+	case left < certExpiryWarning:
+		s.Status = DiagWarn
+		s.Hint = fmt.Sprintf("服务器证书将在 %d 天内过期，请提醒服务方及时续期", int(left.Hours()/24)+1)
+	}
+}
+
+// describeCertificate 返回证书的主体、签发者、域名与有效期
+func describeCertificate(cert *x509.Certificate) string {
+	desc := fmt.Sprintf("主体 %s，签发者 %s", cert.Subject.CommonName, cert.Issuer.CommonName)
+	if len(cert.DNSNames) > 0 {
+		desc += "，域名 " + strings.Join(cert.DNSNames, " ")
+	}
+	return desc + fmt.Sprintf("，有效期 %s 至 %s", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+}
+
+// tlsHint 按证书校验错误给出处理建议
+func tlsHint(err error) string {
+	var unknownCA x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownCA):
+		return "证书由不受信任的 CA 签发（自签名或企业内部 CA）：请在网络选项中添加该 CA 证书；仅内网测试时可开启“跳过证书校验”"
+	case errors.As(err, &hostname):
+		return "证书与访问的域名不匹配：请使用证书中列出的域名访问，或让服务方更换证书"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "证书已过期或尚未生效：请确认本机系统时间正确，并让服务方续期证书"
+	case strings.Contains(err.Error(), "certificate required") || strings.Contains(err.Error(), "bad certificate"):
+		return "服务器要求客户端证书：请在网络选项中配置客户端证书与私钥"
+	}
+	return "请确认端口提供的是 TLS 服务，以及代理或防火墙未拦截 HTTPS 流量"
+}
+
+// dialHint 按连接错误给出处理建议
+func dialHint(err error, viaProxy bool) string {
+	var netErr net.Error
+	msg := strings.ToLower(err.Error())
+	switch {
+	case viaProxy && (strings.Contains(err.Error(), "代理") || strings.Contains(err.Error(), "SOCKS5")):
+		return "请检查网络选项中的代理地址、端口与认证信息，并确认代理允许连接目标端口"
+	case strings.Contains(msg, "refused"):
+		return "目标端口拒绝连接：请确认地址与端口正确、服务已启动"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "连接超时：请检查防火墙或出站策略是否放行；无法直连外网时可在网络选项中配置代理"
+	}
+	return "请检查网络连通性与防火墙设置"
+}
+
+// statusCodePattern 从渠道返回的错误中提取 HTTP 状态码
+var statusCodePattern = regexp.MustCompile(`状态码[:：]?\s*(\d{3})`)
+
+// httpHint 按发送测试通知的错误给出处理建议
+func httpHint(err error) string {
+	if m := statusCodePattern.FindStringSubmatch(err.Error()); m != nil {
+		switch code := m[1]; {
+		case code == "401" || code == "403":
+			return "服务端拒绝访问：请检查密钥、令牌或签名设置，以及机器人的 IP 白名单"
+		case code == "404":
+			return "地址不存在：请确认 Webhook 地址完整，且机器人或应用未被删除"
+		case code == "429":
+			return "请求过于频繁，被服务端限流，请稍后再试"
+		case code[0] == '5':
+			return "服务端内部错误，请稍后重试或联系服务方"
+		}
+	}
+	return "连接已建立但服务端未确认成功：请根据错误信息检查渠道配置（密钥、关键词、chat id 等）"
+}
+
+// smtpExtensions 是诊断时查询的 EHLO 扩展
+var smtpExtensions = []string{"STARTTLS", "AUTH", "SIZE", "8BITMIME", "SMTPUTF8", "PIPELINING", "ENHANCEDSTATUSCODES", "DSN", "CHUNKING"}
+
+// ehloCapabilities 返回服务器通告的扩展，如 "STARTTLS, AUTH LOGIN PLAIN, SIZE 35882577"
+func ehloCapabilities(client *smtp.Client) string {
+	var caps []string
+	for _, ext := range smtpExtensions {
+		if ok, param := client.Extension(ext); ok {
+			caps = append(caps, strings.TrimSpace(ext+" "+param))
+		}
+	}
+	if len(caps) == 0 {
+		return "（未通告任何扩展）"
+	}
+	return strings.Join(caps, ", ")
+}
+
+// diagnoseSMTP 诊断邮件渠道：配置 → DNS → TCP → TLS(SSL) → EHLO → STARTTLS → AUTH 机制 → 认证 → 收发件人
+func (n *Notifier) diagnoseSMTP(d *diagnosis, config NotificationConfig) {
+	e := emailSettings(config)
+	d.run("config", "检查配置", func(s *DiagnosticStep) error {
+		switch {
+		case e.SMTPHost == "":
+			return fmt.Errorf("SMTP 服务器地址不能为空")
+		case e.From == "":
+			return fmt.Errorf("发件人地址不能为空")
+		case len(parseRecipients(e.To)) == 0:
+			return fmt.Errorf("收件人地址不能为空")
+		}
+		d.report.Target = e.addr()
+		encryption := strings.ToLower(e.Encryption)
+		if encryption == "" {
+			encryption = "none"
+		}
+		s.Detail = fmt.Sprintf("%s，加密方式 %s", d.report.Target, encryption)
+		return nil
+	})
+
+	var conn net.Conn
+	if d.failed {
+		n.diagnoseConnection(d, config.Network, "", false)
+	} else {
+		conn = n.diagnoseConnection(d, config.Network, e.addr(), strings.EqualFold(e.Encryption, "ssl"))
+	}
+
+	var client *smtp.Client
+	d.run("ehlo", "EHLO", func(s *DiagnosticStep) error {
+		conn.SetDeadline(time.Now().Add(config.Network.TimeoutOr(smtpDefaultTimeout)))
+		c, err := smtp.NewClient(conn, e.SMTPHost)
+		if err != nil {
+			conn.Close()
+			s.Hint = "端口上可能不是 SMTP 服务，或需要改用 SSL(465)"
+			return fmt.Errorf("读取服务器问候失败: %w", err)
+		}
+		if err := c.Hello("localhost"); err != nil {
+			c.Close()
+			return fmt.Errorf("EHLO 失败: %w", err)
+		}
+		client = c
+		s.Detail = "服务器支持：" + ehloCapabilities(c)
+		return nil
+	})
+	if client != nil {
+		defer client.Close()
+	}
+
+	switch {
+	case strings.EqualFold(e.Encryption, "starttls"):
+		d.run("starttls", "STARTTLS", func(s *DiagnosticStep) error {
+			if ok, _ := client.Extension("STARTTLS"); !ok {
+				s.Hint = hintSMTPNoSTARTTLS
+				return fmt.Errorf("服务器不支持 STARTTLS")
+			}
+			cfg, err := config.Network.TLSConfig(e.SMTPHost)
+			if err != nil {
+				s.Hint = "请在网络选项中修正证书设置"
+				return err
+			}
+			if err := client.StartTLS(cfg); err != nil {
+				s.Hint = tlsHint(err)
+				return fmt.Errorf("STARTTLS 升级失败: %w", err)
+			}
+			state, _ := client.TLSConnectionState()
+			describeTLS(s, state, cfg.InsecureSkipVerify)
+			return nil
+		})
+	case strings.EqualFold(e.Encryption, "ssl"):
+		d.skip("starttls", "STARTTLS", "已使用 SSL 隐式加密")
+	default:
+		d.run("starttls", "STARTTLS", func(s *DiagnosticStep) error {
+			s.Status = DiagSkip
+			s.Detail = "未加密"
+			if ok, _ := client.Extension("STARTTLS"); ok {
+				s.Status = DiagWarn
+				s.Hint = "服务器支持 STARTTLS，建议将加密方式改为 STARTTLS，避免密码与邮件明文传输"
+			}
+			return nil
+		})
+	}
+
+	oauth2 := strings.EqualFold(e.AuthMode, EmailAuthOAuth2)
+	var mechs string
+	d.run("auth_mechs", "AUTH 机制", func(s *DiagnosticStep) error {
+		var ok bool
+		ok, mechs = client.Extension("AUTH")
+		_, isTLS := client.TLSConnectionState()
+		switch {
+		case ok:
+			s.Detail = "服务器通告：" + mechs
+		case isTLS && (oauth2 || e.Username != ""):
+			s.Hint = hintSMTPEncryption
+			return fmt.Errorf("服务器未通告 AUTH 认证机制")
+		default:
+			s.Status = DiagWarn
+			s.Detail = "服务器未通告 AUTH 认证机制"
+			if !isTLS {
+				s.Hint = "多数服务器只在加密连接上通告认证机制；" + hintSMTPEncryption
+			}
+		}
+		return nil
+	})
+
+	authenticated := false
+	if !oauth2 && e.Username == "" {
+		d.skip("auth", "认证", "未填写用户名，匿名发送")
+	} else {
+		d.run("auth", "认证", func(s *DiagnosticStep) error {
+			if oauth2 {
+				s.Detail = "选用 XOAUTH2 机制"
+				if err := n.authXOAUTH2(client, e, config.Network); err != nil {
+					return err
+				}
+				s.Detail += "，认证成功"
+				authenticated = true
+				return nil
+			}
+			_, isTLS := client.TLSConnectionState()
+			auth := chooseSMTPAuth(mechs, e, isTLS)
+			if auth == nil {
+				if isTLS {
+					s.Hint = hintSMTPEncryption
+					return fmt.Errorf("没有可用的认证机制（通告: %q）", mechs)
+				}
+				s.Status = DiagWarn
+				s.Detail = "没有可用的认证机制，发送时将跳过认证尝试匿名投递"
+				return nil
+			}
+			proto, _, _ := auth.Start(&smtp.ServerInfo{Name: e.SMTPHost, TLS: isTLS})
+			s.Detail = "选用 " + proto + " 机制"
+			if err := client.Auth(auth); err != nil {
+				s.Hint = hintSMTPPassword
+				return err
+			}
+			s.Detail += "，认证成功"
+			authenticated = true
+			return nil
+		})
+	}
+
+	d.run("envelope", "发件人与收件人", func(s *DiagnosticStep) error {
+		if err := client.Mail(e.From); err != nil {
+			if !authenticated {
+				s.Hint = hintSMTPNeedAuth
+			}
+			return fmt.Errorf("服务器拒绝发件人 %s: %w", e.From, err)
+		}
+		recipients := append(append(parseRecipients(e.To), parseRecipients(e.Cc)...), parseRecipients(e.Bcc)...)
+		for _, rcpt := range recipients {
+			if err := client.Rcpt(rcpt); err != nil {
+				s.Hint = "请确认收件人地址正确；部分服务器不允许向外域中继，需先通过认证"
+				return fmt.Errorf("服务器拒绝收件人 %s: %w", rcpt, err)
+			}
+		}
+		client.Reset()
+		client.Quit()
+		s.Detail = fmt.Sprintf("服务器接受发件人与 %d 个收件人（未发送邮件）", len(recipients))
+		return nil
+	})
+}
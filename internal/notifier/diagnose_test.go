@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"RemoteKnown/internal/netconf"
+)
+
+// stepStatuses 把报告的步骤结果拼成 "name=status" 列表，便于整体比对
+func stepStatuses(r *DiagnosticReport) string {
+	var parts []string
+	for _, s := range r.Steps {
+		parts = append(parts, s.Name+"="+s.Status)
+	}
+	return strings.Join(parts, " ")
+}
+
+func findStep(r *DiagnosticReport, name string) DiagnosticStep {
+	for _, s := range r.Steps {
+		if s.Name == name {
+			return s
+		}
+	}
+	return DiagnosticStep{}
+}
+
+// TestDiagnoseWebhookTLS 验证 HTTPS Webhook 的分步诊断：未信任自签名证书时 TLS 失败并给出建议，配置 CA 后全部通过
+func TestDiagnoseWebhookTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	n, _ := newTestNotifier(t)
+	c := Channel{Type: "webhook", Settings: map[string]interface{}{"url": srv.URL + "/hook"}}
+
+	report, err := n.DiagnoseChannel(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stepStatuses(report); got != "url=ok dns=ok tcp=ok tls=fail http=skip" || report.Success {
+		t.Fatalf("未信任证书时的诊断结果不正确: %s", got)
+	}
+	tlsStep := findStep(report, "tls")
+	if !strings.Contains(tlsStep.Hint, "CA 证书") || !strings.Contains(tlsStep.Detail, "服务器证书") {
+		t.Errorf("TLS 失败应报告服务器证书并建议添加 CA: %+v", tlsStep)
+	}
+
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	c.Network = &netconf.Options{CACert: caPEM}
+	report, err = n.DiagnoseChannel(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stepStatuses(report); got != "url=ok dns=ok tcp=ok tls=ok http=ok" || !report.Success {
+		t.Fatalf("配置 CA 后应全部通过: %s %+v", got, report.Steps)
+	}
+	if report.Target != srv.URL || strings.Contains(report.Target, "/hook") {
+		t.Errorf("诊断目标应只含协议与主机: %s", report.Target)
+	}
+}
+
+func TestDiagnoseWebhookHTTPStatus(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusForbidden, "forbidden")
+	n, _ := newTestNotifier(t)
+	report, err := n.DiagnoseChannel(Channel{Type: "webhook", Settings: map[string]interface{}{"url": fake.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stepStatuses(report); got != "url=warn dns=ok tcp=ok tls=skip http=fail" {
+		t.Fatalf("诊断结果不正确: %s", got)
+	}
+	if h := findStep(report, "http").Hint; !strings.Contains(h, "拒绝访问") {
+		t.Errorf("403 应提示检查密钥或白名单，实际 %q", h)
+	}
+}
+
+func TestDiagnoseConnectionRefused(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	n, _ := newTestNotifier(t)
+	report, err := n.DiagnoseChannel(Channel{Type: "slack", Settings: map[string]interface{}{"webhook_url": "https://" + addr + "/x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stepStatuses(report); got != "url=ok dns=ok tcp=fail tls=skip http=skip" {
+		t.Fatalf("诊断结果不正确: %s", got)
+	}
+	if h := findStep(report, "tcp").Hint; !strings.Contains(h, "拒绝连接") {
+		t.Errorf("连接被拒绝时应提示检查端口，实际 %q", h)
+	}
+}
+
+// TestDiagnoseSMTPAnonymous 验证内网匿名 SMTP：未通告 AUTH 时给出提醒、跳过认证，只检查收发件人不发送邮件
+func TestDiagnoseSMTPAnonymous(t *testing.T) {
+	host, port, result := startFakeSMTP(t)
+	n, _ := newTestNotifier(t)
+	report, err := n.DiagnoseChannel(Channel{Type: "email", Settings: map[string]interface{}{
+		"smtp_host": host, "smtp_port": port, "encryption": "none", "from": "a@example.com", "to": "b@example.com, c@example.com",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "config=ok dns=ok tcp=ok tls=skip ehlo=ok starttls=skip auth_mechs=warn auth=skip envelope=ok"
+	if got := stepStatuses(report); got != want || !report.Success {
+		t.Fatalf("诊断结果不正确:\n实际 %s\n期望 %s", got, want)
+	}
+	cap := <-result
+	if len(cap.rcpts) != 2 || cap.data != "" {
+		t.Errorf("应检查 2 个收件人且不发送邮件: %+v", cap)
+	}
+}
+
+func TestDiagnoseSMTPXOAUTH2(t *testing.T) {
+	_, port := startXOAUTH2SMTP(t, "at-1")
+	_, tokenURL := startFakeTokenEndpoint(t, 3600, "at-1")
+	n, _ := newTestNotifier(t)
+	report, err := n.DiagnoseChannel(Channel{Type: "email", Settings: map[string]interface{}{
+		"smtp_host": "127.0.0.1", "smtp_port": port, "encryption": "none", "auth_mode": "oauth2",
+		"from": "alert@contoso.com", "to": "it@contoso.com",
+		"oauth2_client_id": "client-1", "oauth2_token_url": tokenURL, "oauth2_refresh_token": "rt-1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Success || report.Target != "127.0.0.1:"+strconv.Itoa(port) {
+		t.Fatalf("诊断应通过: %s %+v", stepStatuses(report), report.Steps)
+	}
+	if d := findStep(report, "ehlo").Detail; !strings.Contains(d, "AUTH LOGIN XOAUTH2") {
+		t.Errorf("EHLO 应列出服务器通告的扩展，实际 %q", d)
+	}
+	if d := findStep(report, "auth").Detail; !strings.Contains(d, "XOAUTH2") || !strings.Contains(d, "认证成功") {
+		t.Errorf("认证步骤应报告选用的机制，实际 %q", d)
+	}
+}
+
+func TestDiagnoseUnsupportedType(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	if _, err := n.DiagnoseChannel(Channel{Type: "syslog", Settings: map[string]interface{}{}}); err == nil {
+		t.Errorf("Syslog 渠道应返回不支持诊断的错误")
+	}
+}
@@ -0,0 +1,405 @@
+package notifier
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+// Digest 是一段时间内远程会话的统计报告
+type Digest struct {
+	Period         string          `json:"period"` // daily / weekly
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	Sessions       int             `json:"sessions"`
+	TotalSeconds   int64           `json:"total_seconds"`
+	Total          string          `json:"total"`
+	Tools          []DigestTool    `json:"tools"`
+	Longest        *DigestSession  `json:"longest,omitempty"`
+	OutsideHours   []DigestSession `json:"outside_hours"`  // 非工作时间开始的会话
+	Unacknowledged []DigestSession `json:"unacknowledged"` // 尚未确认的会话
+}
+
+// DigestTool 是单个远程工具的统计
+type DigestTool struct {
+	Tool     string `json:"tool"`
+	Sessions int    `json:"sessions"`
+	Seconds  int64  `json:"seconds"`
+	Duration string `json:"duration"`
+}
+
+// DigestSession 是报告中列出的单次会话
+type DigestSession struct {
+	ID        string    `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	Open      bool      `json:"open"` // 统计截止时仍未结束
+	Seconds   int64     `json:"seconds"`
+	Duration  string    `json:"duration"`
+	Tools     []string  `json:"tools"`
+}
+
+// digestListLimit 是报告正文中每个列表最多展示的会话数
+const digestListLimit = 10
+
+// BuildDigest 根据 [from, to) 内开始的会话计算统计报告；bizStart / bizEnd 为工作时间（距零点的时长，周一至周五）
+func BuildDigest(period string, sessions []storage.RemoteSession, from, to time.Time, bizStart, bizEnd time.Duration) *Digest {
+	d := &Digest{Period: period, From: from, To: to, OutsideHours: []DigestSession{}, Unacknowledged: []DigestSession{}}
+	byTool := make(map[string]*DigestTool)
+
+	for _, s := range sessions {
+		end := to
+		if s.EndTime != nil && s.EndTime.Before(to) {
+			end = *s.EndTime
+		}
+		seconds := int64(end.Sub(s.StartTime).Seconds())
+		if seconds < 0 {
+			seconds = 0
+		}
+		ds := DigestSession{
+			ID:        s.ID,
+			StartTime: s.StartTime,
+			Open:      s.EndTime == nil,
+			Seconds:   seconds,
+			Duration:  formatDuration(time.Duration(seconds) * time.Second),
+			Tools:     toolsFromSignals(s.Signals),
+		}
+
+		d.Sessions++
+		d.TotalSeconds += seconds
+		for _, tool := range ds.Tools {
+			t := byTool[tool]
+			if t == nil {
+				t = &DigestTool{Tool: tool}
+				byTool[tool] = t
+			}
+			t.Sessions++
+			t.Seconds += seconds
+		}
+		if d.Longest == nil || seconds > d.Longest.Seconds {
+			longest := ds
+			d.Longest = &longest
+		}
+		if !inBusinessHours(s.StartTime, bizStart, bizEnd) {
+			d.OutsideHours = append(d.OutsideHours, ds)
+		}
+		if s.AcknowledgedAt == nil {
+			d.Unacknowledged = append(d.Unacknowledged, ds)
+		}
+	}
+
+	d.Total = formatDuration(time.Duration(d.TotalSeconds) * time.Second)
+	for _, t := range byTool {
+		t.Duration = formatDuration(time.Duration(t.Seconds) * time.Second)
+		d.Tools = append(d.Tools, *t)
+	}
+	sort.Slice(d.Tools, func(i, j int) bool {
+		if d.Tools[i].Seconds != d.Tools[j].Seconds {
+			return d.Tools[i].Seconds > d.Tools[j].Seconds
+		}
+		return d.Tools[i].Tool < d.Tools[j].Tool
+	})
+	if d.Tools == nil {
+		d.Tools = []DigestTool{}
+	}
+	return d
+}
+
+// toolsFromSignals 从会话记录的信号名称（如 "ToDesk (进程存在), Windows RDP (来自: ...)"）中提取去重后的工具名
+func toolsFromSignals(signals string) []string {
+	var tools []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(signals, ",") {
+		tool, _, _ := strings.Cut(strings.TrimSpace(name), " (")
+		tool = strings.TrimSpace(tool)
+		if tool != "" && !seen[tool] {
+			seen[tool] = true
+			tools = append(tools, tool)
+		}
+	}
+	if len(tools) == 0 {
+		tools = []string{"未知工具"}
+	}
+	return tools
+}
+
+// inBusinessHours 报告时间是否落在工作日的工作时间内
+func inBusinessHours(t time.Time, start, end time.Duration) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	return offset >= start && offset < end
+}
+
+// digestTitle 返回报告标题，如 "📊 远程控制日报（10-17）"
+func digestTitle(d *Digest) string {
+	last := d.To.Add(-time.Second)
+	if d.Period == "weekly" {
+		return fmt.Sprintf("📊 远程控制周报（%s ~ %s）", d.From.Format("01-02"), last.Format("01-02"))
+	}
+	return fmt.Sprintf("📊 远程控制日报（%s）", last.Format("01-02"))
+}
+
+// digestSections 返回报告各部分的标题与条目，供不同格式共用
+func digestSections(d *Digest) [][2]interface{} {
+	sessionLine := func(s DigestSession) string {
+		line := fmt.Sprintf("%s %s %s", s.StartTime.Format("01-02 15:04"), strings.Join(s.Tools, "、"), s.Duration)
+		if s.Open {
+			line += "（进行中）"
+		}
+		return line
+	}
+	list := func(sessions []DigestSession) []string {
+		var out []string
+		for i, s := range sessions {
+			if i == digestListLimit {
+				out = append(out, fmt.Sprintf("…… 另有 %d 次", len(sessions)-digestListLimit))
+				break
+			}
+			out = append(out, sessionLine(s))
+		}
+		return out
+	}
+
+	var tools []string
+	for _, t := range d.Tools {
+		tools = append(tools, fmt.Sprintf("%s：%d 次，%s", t.Tool, t.Sessions, t.Duration))
+	}
+	var longest []string
+	if d.Longest != nil {
+		longest = []string{sessionLine(*d.Longest)}
+	}
+	return [][2]interface{}{
+		{"按工具统计", tools},
+		{"最长会话", longest},
+		{fmt.Sprintf("非工作时间会话（%d 次）", len(d.OutsideHours)), list(d.OutsideHours)},
+		{fmt.Sprintf("未确认会话（%d 次）", len(d.Unacknowledged)), list(d.Unacknowledged)},
+	}
+}
+
+// digestSummaryLine 返回报告的概要行
+func digestSummaryLine(d *Digest) string {
+	return fmt.Sprintf("统计时间：%s ~ %s\n远程会话：%d 次，累计时长 %s",
+		d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"), d.Sessions, d.Total)
+}
+
+// digestText 以纯文本格式化报告（Slack、Teams、Discord、Telegram 等）
+func digestText(device string, d *Digest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "主机：%s\n\n%s\n", device, digestSummaryLine(d))
+	for _, sec := range digestSections(d) {
+		items := sec[1].([]string)
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s：\n", sec[0])
+		for _, item := range items {
+			b.WriteString("  " + item + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// digestMarkdown 以 Markdown 格式化报告（钉钉、企业微信、飞书卡片）
+func digestMarkdown(device string, d *Digest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**主机**：%s\n\n", device)
+	fmt.Fprintf(&b, "**统计时间**：%s ~ %s\n\n", d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "**远程会话**：%d 次，累计时长 %s\n", d.Sessions, d.Total)
+	for _, sec := range digestSections(d) {
+		items := sec[1].([]string)
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n**%s**\n\n", sec[0])
+		for _, item := range items {
+			b.WriteString("- " + item + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// digestHTML 以 HTML 表格格式化报告（邮件）
+func digestHTML(device string, d *Digest) string {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString(`<html><body style="font-family:sans-serif;font-size:14px">`)
+	fmt.Fprintf(&b, "<h2>%s</h2>", esc(digestTitle(d)))
+	b.WriteString(`<table border="1" cellspacing="0" cellpadding="6" style="border-collapse:collapse">`)
+	for _, row := range [][2]string{
+		{"主机", device},
+		{"统计时间", d.From.Format("2006-01-02 15:04") + " ~ " + d.To.Format("2006-01-02 15:04")},
+		{"远程会话", fmt.Sprintf("%d 次", d.Sessions)},
+		{"累计时长", d.Total},
+		{"非工作时间会话", fmt.Sprintf("%d 次", len(d.OutsideHours))},
+		{"未确认会话", fmt.Sprintf("%d 次", len(d.Unacknowledged))},
+	} {
+		fmt.Fprintf(&b, "<tr><th align=\"left\">%s</th><td>%s</td></tr>", esc(row[0]), esc(row[1]))
+	}
+	b.WriteString("</table>")
+
+	if len(d.Tools) > 0 {
+		b.WriteString(`<h3>按工具统计</h3><table border="1" cellspacing="0" cellpadding="6" style="border-collapse:collapse">`)
+		b.WriteString("<tr><th>工具</th><th>会话数</th><th>累计时长</th></tr>")
+		for _, t := range d.Tools {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td align=\"right\">%d</td><td>%s</td></tr>", esc(t.Tool), t.Sessions, esc(t.Duration))
+		}
+		b.WriteString("</table>")
+	}
+
+	sessionTable := func(title string, sessions []DigestSession) {
+		if len(sessions) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, `<h3>%s</h3><table border="1" cellspacing="0" cellpadding="6" style="border-collapse:collapse">`, esc(title))
+		b.WriteString("<tr><th>开始时间</th><th>工具</th><th>时长</th></tr>")
+		for i, s := range sessions {
+			if i == digestListLimit {
+				fmt.Fprintf(&b, "<tr><td colspan=\"3\">…… 另有 %d 次</td></tr>", len(sessions)-digestListLimit)
+				break
+			}
+			duration := s.Duration
+			if s.Open {
+				duration += "（进行中）"
+			}
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>",
+				s.StartTime.Format("2006-01-02 15:04"), esc(strings.Join(s.Tools, "、")), esc(duration))
+		}
+		b.WriteString("</table>")
+	}
+	if d.Longest != nil {
+		sessionTable("最长会话", []DigestSession{*d.Longest})
+	}
+	sessionTable("非工作时间会话", d.OutsideHours)
+	sessionTable("未确认会话", d.Unacknowledged)
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// deliverDigest 按渠道类型格式化并发送统计报告；返回 false 表示该类型使用通用的纯文本正文
+func (n *Notifier) deliverDigest(config NotificationConfig, ev *Event) (bool, error) {
+	d := ev.Digest
+	switch config.Type {
+	case "feishu":
+		return true, n.sendFeishuCard(config, ev, feishuElements(ev))
+	case "dingtalk":
+		return true, n.sendDingtalkNotification(config, ev.Title, dingtalkContent(ev))
+	case "wecom":
+		return true, n.sendWeComNotification(config, ev, digestMarkdown(ev.DeviceName, d))
+	case "email":
+		return true, n.sendEmail(config, eventEmail(config, ev))
+	}
+	return false, nil
+}
+
+// digestPeriod 返回 now 之前最近一次计划发送时间及其覆盖的统计区间；计划关闭时 ok 为 false
+func digestPeriod(sched settings.DigestSchedule, now time.Time) (due, from time.Time, ok bool) {
+	due = time.Date(now.Year(), now.Month(), now.Day(), sched.Hour, sched.Minute, 0, 0, now.Location())
+	switch sched.Frequency {
+	case "daily":
+		if due.After(now) {
+			due = due.AddDate(0, 0, -1)
+		}
+		return due, due.AddDate(0, 0, -1), true
+	case "weekly":
+		back := (int(now.Weekday()) - int(sched.Weekday) + 7) % 7
+		due = due.AddDate(0, 0, -back)
+		if due.After(now) {
+			due = due.AddDate(0, 0, -7)
+		}
+		return due, due.AddDate(0, 0, -7), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// DigestFrequency 返回按当前设置发送的报告周期：weekly 或 daily（未开启定期发送时按日报预览与手动发送）
+func (n *Notifier) DigestFrequency() string {
+	if n.settings.DigestSchedule().Frequency == "weekly" {
+		return "weekly"
+	}
+	return "daily"
+}
+
+// BuildCurrentDigest 按当前设置计算截至 now 的最近一期统计报告（供预览与手动发送）
+func (n *Notifier) BuildCurrentDigest(period string, now time.Time) (*Digest, error) {
+	from := now.AddDate(0, 0, -1)
+	if period == "weekly" {
+		from = now.AddDate(0, 0, -7)
+	}
+	return n.buildDigest(period, from, now)
+}
+
+func (n *Notifier) buildDigest(period string, from, to time.Time) (*Digest, error) {
+	sessions, err := n.storage.GetSessionsBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	bizStart, bizEnd := n.settings.BusinessHours()
+	return BuildDigest(period, sessions, from, to, bizStart, bizEnd), nil
+}
+
+// SendDigest 通过订阅 digest 事件的渠道发送统计报告
+func (n *Notifier) SendDigest(d *Digest) {
+	ev := n.newEvent(EventDigest, digestTitle(d), "")
+	ev.Digest = d
+	ev.Content = digestText(ev.DeviceName, d)
+	n.dispatch(ev)
+}
+
+// digestPollInterval 是检查统计报告计划时间的间隔
+const digestPollInterval = time.Minute
+
+func (n *Notifier) digestLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(digestPollInterval)
+	defer ticker.Stop()
+
+	n.checkDigest(time.Now())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		n.checkDigest(time.Now())
+	}
+}
+
+// checkDigest 到达计划时间后发送一次统计报告（守护进程停机错过的一期在启动后补发）
+func (n *Notifier) checkDigest(now time.Time) {
+	sched := n.settings.DigestSchedule()
+	due, from, ok := digestPeriod(sched, now)
+	if !ok {
+		return
+	}
+
+	last, _ := n.settings.Get(settings.KeyDigestLastSent)
+	lastSent, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		// 首次启用：从下一期开始发送，不补发启用前的报告
+		n.settings.Set(settings.KeyDigestLastSent, due.Format(time.RFC3339))
+		return
+	}
+	if !due.After(lastSent) {
+		return
+	}
+
+	// 先记录再发送，发送失败由发件箱重试，避免重复生成同一期报告
+	if err := n.settings.Set(settings.KeyDigestLastSent, due.Format(time.RFC3339)); err != nil {
+		log.Printf("[通知器] 记录统计报告发送时间失败: %v", err)
+		return
+	}
+	d, err := n.buildDigest(sched.Frequency, from, due)
+	if err != nil {
+		log.Printf("[通知器] 生成统计报告失败: %v", err)
+		return
+	}
+	log.Printf("[通知器] 发送%s：%d 次会话，累计 %s", digestTitle(d), d.Sessions, d.Total)
+	n.SendDigest(d)
+}
@@ -0,0 +1,205 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+// digestSessions 返回 2026-10-14（周三）的一组示例会话
+func digestSessions() []storage.RemoteSession {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 14, h, m, 0, 0, time.Local) }
+	end := func(t time.Time) *time.Time { return &t }
+	ack := at(12, 0)
+	return []storage.RemoteSession{
+		{ID: "a", StartTime: at(10, 0), EndTime: end(at(10, 30)), Signals: "ToDesk (进程存在)", AcknowledgedAt: &ack},
+		{ID: "b", StartTime: at(20, 0), EndTime: end(at(22, 0)), Signals: "ToDesk (进程存在), Windows RDP (来自: 192.0.2.1)"},
+		{ID: "c", StartTime: at(23, 0), Signals: ""},
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	from := time.Date(2026, 10, 14, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 1)
+	d := BuildDigest("daily", digestSessions(), from, to, 9*time.Hour, 18*time.Hour)
+
+	if d.Sessions != 3 {
+		t.Errorf("会话数应为 3，实际 %d", d.Sessions)
+	}
+	// 30 分钟 + 2 小时 + 进行中的会话计到统计截止（1 小时）
+	if d.TotalSeconds != 3*3600+30*60 || d.Total != "03:30:00" {
+		t.Errorf("累计时长不正确: %d %s", d.TotalSeconds, d.Total)
+	}
+	if d.Longest == nil || d.Longest.ID != "b" {
+		t.Errorf("最长会话应为 b，实际 %+v", d.Longest)
+	}
+	if len(d.OutsideHours) != 2 || d.OutsideHours[0].ID != "b" || d.OutsideHours[1].ID != "c" {
+		t.Errorf("非工作时间会话应为 b、c，实际 %+v", d.OutsideHours)
+	}
+	if len(d.Unacknowledged) != 2 {
+		t.Errorf("未确认会话应为 2 次，实际 %d", len(d.Unacknowledged))
+	}
+	if !d.Unacknowledged[1].Open {
+		t.Error("未结束的会话应标记为进行中")
+	}
+
+	want := []DigestTool{
+		{Tool: "ToDesk", Sessions: 2, Seconds: 9000, Duration: "02:30:00"},
+		{Tool: "Windows RDP", Sessions: 1, Seconds: 7200, Duration: "02:00:00"},
+		{Tool: "未知工具", Sessions: 1, Seconds: 3600, Duration: "01:00:00"},
+	}
+	if len(d.Tools) != len(want) {
+		t.Fatalf("工具统计不正确: %+v", d.Tools)
+	}
+	for i := range want {
+		if d.Tools[i] != want[i] {
+			t.Errorf("工具统计[%d] 应为 %+v，实际 %+v", i, want[i], d.Tools[i])
+		}
+	}
+
+	// 周末全天都算非工作时间
+	saturday := []storage.RemoteSession{{ID: "s", StartTime: time.Date(2026, 10, 17, 10, 0, 0, 0, time.Local)}}
+	if d := BuildDigest("daily", saturday, from, to, 9*time.Hour, 18*time.Hour); len(d.OutsideHours) != 1 {
+		t.Error("周末的会话应计入非工作时间")
+	}
+}
+
+func TestDigestPeriod(t *testing.T) {
+	// 2026-10-14 是周三
+	now := time.Date(2026, 10, 14, 8, 0, 0, 0, time.Local)
+
+	due, from, ok := digestPeriod(settings.DigestSchedule{Frequency: "daily", Hour: 9}, now)
+	if !ok || !due.Equal(time.Date(2026, 10, 13, 9, 0, 0, 0, time.Local)) || !from.Equal(due.AddDate(0, 0, -1)) {
+		t.Errorf("未到当天发送时间时应取前一天: %v %v", due, from)
+	}
+	due, _, _ = digestPeriod(settings.DigestSchedule{Frequency: "daily", Hour: 7, Minute: 30}, now)
+	if !due.Equal(time.Date(2026, 10, 14, 7, 30, 0, 0, time.Local)) {
+		t.Errorf("已过当天发送时间时应取当天: %v", due)
+	}
+
+	due, from, _ = digestPeriod(settings.DigestSchedule{Frequency: "weekly", Hour: 9, Weekday: time.Monday}, now)
+	if !due.Equal(time.Date(2026, 10, 12, 9, 0, 0, 0, time.Local)) || !from.Equal(due.AddDate(0, 0, -7)) {
+		t.Errorf("周报应取本周一: %v %v", due, from)
+	}
+	due, _, _ = digestPeriod(settings.DigestSchedule{Frequency: "weekly", Hour: 9, Weekday: time.Wednesday}, now)
+	if !due.Equal(time.Date(2026, 10, 7, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("未到本周发送时间时应取上周: %v", due)
+	}
+
+	if _, _, ok := digestPeriod(settings.DigestSchedule{Frequency: "off"}, now); ok {
+		t.Error("关闭时不应有发送计划")
+	}
+}
+
+// TestDeliverDigestFormats 验证报告按渠道类型格式化：钉钉为 Markdown、飞书为卡片，其余渠道使用纯文本正文。
+func TestDeliverDigestFormats(t *testing.T) {
+	from := time.Date(2026, 10, 14, 0, 0, 0, 0, time.Local)
+	d := BuildDigest("daily", digestSessions(), from, from.AddDate(0, 0, 1), 9*time.Hour, 18*time.Hour)
+	n := &Notifier{}
+	ev := &Event{Type: EventDigest, Title: digestTitle(d), DeviceName: "财务PC", Digest: d}
+	ev.Content = digestText(ev.DeviceName, d)
+
+	if ev.Title != "📊 远程控制日报（10-14）" {
+		t.Errorf("标题不正确: %s", ev.Title)
+	}
+
+	fake := startFakeHTTP(t, http.StatusOK, `{"errcode":0}`)
+	if err := n.deliver(NotificationConfig{Type: "dingtalk", Settings: map[string]interface{}{"webhook_url": fake.URL}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	text := fake.Last().Body["markdown"].(map[string]interface{})["text"].(string)
+	if !strings.Contains(text, "**按工具统计**") || !strings.Contains(text, "- ToDesk：2 次，02:30:00") {
+		t.Errorf("钉钉报告应为 Markdown 列表: %s", text)
+	}
+
+	fake = startFakeHTTP(t, http.StatusOK, `{"code":0}`)
+	if err := n.deliver(NotificationConfig{Type: "feishu", Settings: map[string]interface{}{"webhook_url": fake.URL}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	card := fake.Last().Body["card"].(map[string]interface{})
+	elements := card["elements"].([]interface{})
+	content := elements[0].(map[string]interface{})["text"].(map[string]interface{})["content"].(string)
+	if !strings.Contains(content, "**未确认会话（2 次）**") {
+		t.Errorf("飞书报告应为卡片 Markdown: %s", content)
+	}
+
+	fake = startFakeHTTP(t, http.StatusOK, "ok")
+	if err := n.deliver(NotificationConfig{Type: "discord", Settings: map[string]interface{}{"webhook_url": fake.URL}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	embed := fake.Last().Body["embeds"].([]interface{})[0].(map[string]interface{})
+	if !strings.Contains(embed["description"].(string), "最长会话：\n  10-14 20:00 ToDesk、Windows RDP 02:00:00") {
+		t.Errorf("其余渠道应使用纯文本正文: %v", embed["description"])
+	}
+
+	page := digestHTML("<财务>", d)
+	if !strings.Contains(page, "<table") || !strings.Contains(page, "&lt;财务&gt;") {
+		t.Errorf("邮件报告应为转义后的 HTML 表格: %s", page)
+	}
+}
+
+// TestCheckDigestSchedule 验证首次启用时不补发历史报告，到达计划时间后每期只发送一次。
+func TestCheckDigestSchedule(t *testing.T) {
+	n, st := newTestNotifier(t)
+
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	err := n.SaveChannels([]Channel{
+		{ID: "report", Name: "日报群", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL},
+			Filter: ChannelFilter{Events: []string{EventDigest}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		settings.KeyDigestFrequency: "daily",
+		settings.KeyDigestTime:      "09:00",
+	} {
+		if err := n.settings.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range digestSessions() {
+		s := s
+		if err := st.SaveSession(&s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n.checkDigest(time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local))
+	if len(bodies) != 0 {
+		t.Fatal("首次启用时不应补发报告")
+	}
+	n.checkDigest(time.Date(2026, 10, 15, 8, 59, 0, 0, time.Local))
+	if len(bodies) != 0 {
+		t.Fatal("未到计划时间不应发送")
+	}
+	n.checkDigest(time.Date(2026, 10, 15, 9, 0, 0, 0, time.Local))
+	n.checkDigest(time.Date(2026, 10, 15, 9, 1, 0, 0, time.Local))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("每期报告应只发送一次，实际 %d", len(bodies))
+	}
+	// 统计区间为 10-14 09:00 ~ 10-15 09:00，进行中的会话计到统计截止
+	if !strings.Contains(bodies[0], "远程会话：3 次，累计时长 12:30:00") {
+		t.Errorf("报告内容不正确: %s", bodies[0])
+	}
+}
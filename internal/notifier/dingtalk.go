@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+func init() {
+	RegisterChannel(dingtalkDriver{})
+}
+
+// dingtalkDriver 是钉钉群机器人渠道
+type dingtalkDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (dingtalkDriver) Type() string { return "dingtalk" }
+
+func (dingtalkDriver) Schema() *Schema {
+	return webhookSchema("钉钉", "钉钉群机器人的 Webhook 地址（含 access_token）", "机器人安全设置中选择“加签”时填写（SEC 开头）")
+}
+
+func (dingtalkDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (dingtalkDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendDingtalkNotification(config, ev.Title, ev.Content)
+}
+
+func (dingtalkDriver) Preview(_ *Notifier, config NotificationConfig, ev *Event) (*Preview, error) {
+	webhookURL, message := dingtalkRequest(config, ev.Title, dingtalkContent(ev))
+	return jsonPreview(config.Type, maskURL(webhookURL, false), message)
+}
+
+// sendDingtalkNotification 发送钉钉通知
+func (n *Notifier) sendDingtalkNotification(config NotificationConfig, title, content string) error {
+	webhookURL, message := dingtalkRequest(config, title, content)
+	return n.postWebhook(config.Network, webhookURL, nil, message, errcodeSuccess)
+}
+
+// dingtalkContent 返回事件的 markdown 正文：统计报告按 markdown 排版，其余事件使用渲染后的正文
+func dingtalkContent(ev *Event) string {
+	if ev.Digest != nil {
+		return digestMarkdown(ev.DeviceName, ev.Digest)
+	}
+	return ev.Content
+}
+
+// dingtalkRequest 返回钉钉 markdown 消息及请求地址（配置了签名密钥时在地址上附加时间戳与签名）
+func dingtalkRequest(config NotificationConfig, title, content string) (string, map[string]interface{}) {
+	message := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"title": title,
+			"text":  fmt.Sprintf("### %s\n\n%s", title, content),
+		},
+	}
+
+	bot := botSettings(config)
+	webhookURL := bot.WebhookURL
+
+	// 如果配置了签名密钥，将签名和时间戳附加到URL
+	if bot.Secret != "" {
+		timestamp := time.Now().UnixMilli()
+		sign := generateDingtalkSign(bot.Secret, timestamp)
+		webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s", bot.WebhookURL, timestamp, url.QueryEscape(sign))
+	}
+	return webhookURL, message
+}
+
+// generateDingtalkSign 生成钉钉签名
+func generateDingtalkSign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(stringToSign))
+	signData := h.Sum(nil)
+	return base64.StdEncoding.EncodeToString(signData)
+}
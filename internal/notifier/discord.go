@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterChannel(discordDriver{})
+}
+
+// discordDriver 是 Discord 渠道
+type discordDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (discordDriver) Type() string { return "discord" }
+
+func (discordDriver) Schema() *Schema {
+	return webhookSchema("Discord", "频道设置 → 整合 → Webhook 中复制的地址", "")
+}
+
+func (discordDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (discordDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendDiscordNotification(config, ev)
+}
+
+// embed 的长度上限：标题 256 字符，描述 4096 字符（按字节截断，不会超出）
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+)
+
+// sendDiscordNotification 通过 Webhook 发送 Discord 通知（embed）。
+// Discord 成功时返回 204 No Content；失败时返回 JSON {"message": ..., "code": ...}。
+func (n *Notifier) sendDiscordNotification(config NotificationConfig, ev *Event) error {
+	message := map[string]interface{}{
+		"embeds": []map[string]interface{}{
+			{
+				"title":       truncateUTF8(ev.Title, discordTitleLimit),
+				"description": truncateUTF8(ev.Content, discordDescriptionLimit),
+				"color":       getDiscordColor(ev.Type),
+				"timestamp":   time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	status, body, err := n.postJSON(config.Network, botSettings(config).WebhookURL, nil, message)
+	if err != nil {
+		return err
+	}
+	// 带 ?wait=true 时 Discord 返回 200 和消息对象
+	if status == http.StatusNoContent || status == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Message    string  `json:"message"`
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &result) == nil && result.Message != "" {
+		if status == http.StatusTooManyRequests {
+			return fmt.Errorf("Discord 通知被限流，请 %.1f 秒后重试: %s", result.RetryAfter, result.Message)
+		}
+		return discordError(status, fmt.Errorf("Discord 通知发送失败，状态码: %d: %s", status, result.Message))
+	}
+	return discordError(status, fmt.Errorf("Discord 通知发送失败，状态码: %d，响应: %s", status, summarizeBody(body)))
+}
+
+// discordError 把 400（Invalid Form Body）与 413（消息过大）标记为不可重试：消息本身无效，重试不会成功
+func discordError(status int, err error) error {
+	if status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// getDiscordColor 返回各事件类型 Discord embed 的颜色（与邮件标题栏一致）
+func getDiscordColor(eventType string) int {
+	c, _ := strconv.ParseInt(strings.TrimPrefix(emailHeaderColor(eventType), "#"), 16, 32)
+	return int(c)
+}
@@ -0,0 +1,336 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterChannel(emailDriver{})
+}
+
+// emailDriver 是 SMTP 邮件渠道
+type emailDriver struct{ sendsTest }
+
+func (emailDriver) Type() string { return "email" }
+
+func (emailDriver) Schema() *Schema {
+	port := &Schema{Type: "integer", Title: "端口", Description: "留空或 0 时按加密方式取默认端口（25 / 587 / 465）", Minimum: floatPtr(0), Maximum: floatPtr(65535)}
+	encryption := &Schema{Type: "string", Title: "加密方式", Enum: []interface{}{"none", "starttls", "ssl"}, Default: "none",
+		Description: "none 为明文（内网），starttls 为 587 端口升级加密，ssl 为 465 端口隐式加密"}
+	authMode := &Schema{Type: "string", Title: "认证方式", Enum: []interface{}{EmailAuthPassword, EmailAuthOAuth2}, Default: EmailAuthPassword}
+	password := field("密码 / 授权码", "QQ/163/Gmail 等公网邮箱需填写“授权码”而非登录密码")
+	password.WriteOnly = true
+	from := field("发件人", "")
+	from.Format = "email"
+	clientSecret := field("OAuth2 客户端密钥", "")
+	clientSecret.WriteOnly = true
+	refreshToken := field("OAuth2 刷新令牌", "")
+	refreshToken.WriteOnly = true
+	tokenURL := field("OAuth2 令牌地址", "Gmail 等填写令牌端点，如 https://oauth2.googleapis.com/token；Microsoft 365 填租户 ID 即可")
+	tokenURL.Format = "uri"
+	return &Schema{
+		Type:  "object",
+		Title: "邮件",
+		Properties: map[string]*Schema{
+			"smtp_host":            field("SMTP 服务器", ""),
+			"smtp_port":            port,
+			"encryption":           encryption,
+			"auth_mode":            authMode,
+			"username":             field("用户名", "留空表示匿名发送（内网中继常见）"),
+			"password":             password,
+			"from":                 from,
+			"to":                   field("收件人", "多个地址用逗号、分号或空格分隔"),
+			"cc":                   field("抄送", "格式同收件人"),
+			"bcc":                  field("密送", "格式同收件人，不出现在邮件头中"),
+			"oauth2_client_id":     field("OAuth2 客户端 ID", ""),
+			"oauth2_client_secret": clientSecret,
+			"oauth2_tenant":        field("OAuth2 租户 ID", "Microsoft 365 的目录（租户）ID 或域名"),
+			"oauth2_token_url":     tokenURL,
+			"oauth2_refresh_token": refreshToken,
+			"oauth2_scope":         field("OAuth2 权限", "留空时 Microsoft 365 取 SMTP.Send"),
+		},
+		Required: []string{"smtp_host", "from", "to"},
+		Order: []string{"smtp_host", "smtp_port", "encryption", "auth_mode", "username", "password", "from", "to", "cc", "bcc",
+			"oauth2_client_id", "oauth2_client_secret", "oauth2_tenant", "oauth2_token_url", "oauth2_refresh_token", "oauth2_scope"},
+	}
+}
+
+func (emailDriver) Validate(config NotificationConfig) error {
+	e := emailSettings(config)
+	switch {
+	case e.SMTPHost == "":
+		return fmt.Errorf("SMTP 服务器地址不能为空")
+	case e.From == "":
+		return fmt.Errorf("发件人地址不能为空")
+	case len(parseRecipients(e.To)) == 0:
+		return fmt.Errorf("收件人地址不能为空")
+	case e.SMTPPort < 0 || e.SMTPPort > 65535:
+		return fmt.Errorf("SMTP 端口无效: %d", e.SMTPPort)
+	}
+	switch strings.ToLower(e.Encryption) {
+	case "", "none", "starttls", "ssl":
+	default:
+		return fmt.Errorf("不支持的加密方式: %s", e.Encryption)
+	}
+	switch e.AuthMode {
+	case "", EmailAuthPassword:
+	case EmailAuthOAuth2:
+		if e.oauth2TokenURL() == "" {
+			return fmt.Errorf("OAuth2 需填写租户 ID 或令牌地址")
+		}
+		if e.OAuth2ClientID == "" || e.OAuth2RefreshToken == "" {
+			return fmt.Errorf("OAuth2 需填写客户端 ID 与刷新令牌")
+		}
+	default:
+		return fmt.Errorf("不支持的认证方式: %s", e.AuthMode)
+	}
+	return nil
+}
+
+func (emailDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendEventEmail(config, ev)
+}
+
+func (emailDriver) Preview(_ *Notifier, config NotificationConfig, ev *Event) (*Preview, error) {
+	e := emailSettings(config)
+	m, recipients, err := composeEmail(e, eventEmail(config, ev))
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{
+		Type:        config.Type,
+		URL:         "smtp://" + e.addr(),
+		ContentType: "message/rfc822",
+		From:        m.From,
+		Recipients:  recipients,
+		Body:        string(buildEmailMessage(m)),
+	}, nil
+}
+
+// emailSettings 返回渠道的邮件设置
+func emailSettings(config NotificationConfig) EmailConfig {
+	var e EmailConfig
+	config.decode(&e)
+	return e
+}
+
+// emailMessage 是一封待发送的邮件。Text 与 HTML 同时存在时构建 multipart/alternative，
+// 邮件客户端优先显示 HTML 部分，纯文本部分供不支持 HTML 的客户端使用。
+type emailMessage struct {
+	From      string
+	To        []string
+	Cc        []string // 抄送（出现在邮件头中）；密送只出现在 SMTP 信封中，不写入邮件头
+	Subject   string
+	Text      string
+	HTML      string
+	Date      time.Time
+	MessageID string // 形如 <id@domain>
+	InReplyTo string // 回复的邮件 Message-ID，同时写入 References，使客户端归为同一会话
+}
+
+// buildEmailMessage 构建符合 RFC 5322 的邮件（主题/正文按 UTF-8 编码，避免中文乱码）
+func buildEmailMessage(m emailMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + m.From + "\r\n")
+	buf.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	if len(m.Cc) > 0 {
+		buf.WriteString("Cc: " + strings.Join(m.Cc, ", ") + "\r\n")
+	}
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", m.Subject) + "\r\n")
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	buf.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	if m.MessageID != "" {
+		buf.WriteString("Message-ID: " + m.MessageID + "\r\n")
+	}
+	if m.InReplyTo != "" {
+		buf.WriteString("In-Reply-To: " + m.InReplyTo + "\r\n")
+		buf.WriteString("References: " + m.InReplyTo + "\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.Text == "" || m.HTML == "" {
+		contentType, body := "text/plain", m.Text
+		if m.HTML != "" {
+			contentType, body = "text/html", m.HTML
+		}
+		buf.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		buf.WriteString("\r\n")
+		writeBase64Lines(&buf, body)
+		return buf.Bytes()
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	buf.WriteString("Content-Type: multipart/alternative; boundary=\"" + mw.Boundary() + "\"\r\n")
+	buf.WriteString("\r\n")
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		var part bytes.Buffer
+		writeBase64Lines(&part, p.body)
+		w.Write(part.Bytes())
+	}
+	mw.Close()
+	buf.Write(parts.Bytes())
+	return buf.Bytes()
+}
+
+// writeBase64Lines 以 base64 编码正文，按 RFC 2045 每 76 字符换行
+func writeBase64Lines(buf *bytes.Buffer, body string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		buf.WriteString(encoded[i:end] + "\r\n")
+	}
+}
+
+// messageIDDomain 取发件人地址的域名作为 Message-ID 的右半部分
+func messageIDDomain(from string) string {
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		if domain := strings.Trim(from[i+1:], "<> "); domain != "" {
+			return domain
+		}
+	}
+	return "remoteknown.local"
+}
+
+// newMessageID 生成唯一的 Message-ID
+func newMessageID(from string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s.remoteknown@%s>", time.Now().UnixNano(), hex.EncodeToString(b), messageIDDomain(from))
+}
+
+// sessionMessageID 是会话开始邮件的 Message-ID。由会话 ID 确定，
+// 提醒、升级与结束邮件据此设置 In-Reply-To，无需记录已发送邮件的 ID。
+func sessionMessageID(sessionID, from string) string {
+	return fmt.Sprintf("<session.%s.remoteknown@%s>", sessionID, messageIDDomain(from))
+}
+
+// sendEventEmail 以纯文本 + HTML 邮件发送事件；同一会话的后续邮件回复开始邮件，归为一个会话线索
+func (n *Notifier) sendEventEmail(config NotificationConfig, ev *Event) error {
+	return n.sendEmail(config, eventEmail(config, ev))
+}
+
+// eventEmail 构建事件对应的邮件：统计报告使用报告排版，会话事件设置 Message-ID / In-Reply-To 归入会话线索
+func eventEmail(config NotificationConfig, ev *Event) emailMessage {
+	if ev.Digest != nil {
+		return emailMessage{
+			Subject: ev.Title,
+			Text:    digestText(ev.DeviceName, ev.Digest),
+			HTML:    digestHTML(ev.DeviceName, ev.Digest),
+		}
+	}
+	m := emailMessage{
+		Subject: ev.Title,
+		Text:    ev.Content,
+		HTML:    eventEmailHTML(ev),
+	}
+	if isSessionEvent(ev.Type) && ev.SessionID != "" {
+		thread := sessionMessageID(ev.SessionID, emailSettings(config).From)
+		if ev.Type == EventRemoteStart {
+			m.MessageID = thread
+		} else {
+			m.InReplyTo = thread
+		}
+	}
+	return m
+}
+
+// emailHeaderColor 返回各事件类型邮件标题栏的颜色
+func emailHeaderColor(eventType string) string {
+	switch eventType {
+	case EventRemoteStart:
+		return "#E74C3C"
+	case EventRemoteEscalation:
+		return "#A93226"
+	case EventRemoteReminder:
+		return "#E67E22"
+	case EventRemoteEnd:
+		return "#27AE60"
+	case EventAppStart, EventAppExit:
+		return "#7F8C8D"
+	default:
+		return "#3498DB"
+	}
+}
+
+// eventEmailHTML 渲染事件邮件的 HTML 正文：彩色标题栏、会话信息、信号明细表与通知正文
+func eventEmailHTML(ev *Event) string {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString(`<html><body style="font-family:sans-serif;font-size:14px;color:#333">`)
+	fmt.Fprintf(&b, `<div style="background:%s;color:#fff;padding:12px 16px;font-size:18px;font-weight:bold">%s</div>`,
+		emailHeaderColor(ev.Type), esc(ev.Title))
+
+	var rows [][2]string
+	if ev.DeviceName != "" {
+		rows = append(rows, [2]string{"主机", ev.DeviceName})
+	}
+	if ev.Hostname != "" && ev.Hostname != ev.DeviceName {
+		rows = append(rows, [2]string{"计算机名", ev.Hostname})
+	}
+	if !ev.StartTime.IsZero() {
+		rows = append(rows, [2]string{"开始时间", ev.StartTime.Format("2006-01-02 15:04:05")})
+	}
+	if !ev.EndTime.IsZero() {
+		rows = append(rows, [2]string{"结束时间", ev.EndTime.Format("2006-01-02 15:04:05")})
+	}
+	if ev.Duration != "" {
+		rows = append(rows, [2]string{"持续时长", ev.Duration})
+	}
+	if ev.SessionID != "" {
+		rows = append(rows, [2]string{"会话 ID", ev.SessionID})
+	}
+	if len(rows) > 0 {
+		b.WriteString(`<table cellspacing="0" cellpadding="6" style="border-collapse:collapse;margin:12px 0">`)
+		for _, r := range rows {
+			fmt.Fprintf(&b, `<tr><td style="color:#888">%s</td><td>%s</td></tr>`, esc(r[0]), esc(r[1]))
+		}
+		b.WriteString("</table>")
+	}
+
+	if len(ev.SignalDetails) > 0 {
+		b.WriteString(`<table border="1" cellspacing="0" cellpadding="6" style="border-collapse:collapse;margin:12px 0;border-color:#ddd">`)
+		b.WriteString(`<tr style="background:#f5f5f5"><th>工具</th><th>判定方式</th><th>PID</th><th>对端 IP</th></tr>`)
+		for _, s := range ev.SignalDetails {
+			tool := s.Tool
+			if tool == "" {
+				tool = s.Name
+			}
+			pid := ""
+			if s.PID > 0 {
+				pid = fmt.Sprintf("%d", s.PID)
+			}
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+				esc(tool), esc(s.Method), esc(pid), esc(s.Peer))
+		}
+		b.WriteString("</table>")
+	}
+
+	fmt.Fprintf(&b, `<div style="white-space:pre-wrap;margin:12px 0">%s</div>`, esc(ev.Content))
+	b.WriteString("</body></html>")
+	return b.String()
+}
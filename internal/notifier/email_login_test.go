@@ -118,7 +118,7 @@ func TestSendEmailLoginOnlyServer(t *testing.T) {
 	cfg := NotificationConfig{
 		Type:    "email",
 		Enabled: true,
-		Email: EmailConfig{
+		Settings: settingsOf(EmailConfig{
 			SMTPHost:   "127.0.0.1",
 			SMTPPort:   portNum,
 			Encryption: "none",
@@ -126,7 +126,7 @@ func TestSendEmailLoginOnlyServer(t *testing.T) {
 			Password:   "secret",
 			From:       "admin@Email.com",
 			To:         "admin@Email.com",
-		},
+		}),
 	}
 
 	if err := n.sendEmailNotification(cfg, "测试标题", "测试内容"); err != nil {
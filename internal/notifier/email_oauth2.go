@@ -0,0 +1,248 @@
+package notifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+// 邮件认证方式
+const (
+	EmailAuthPassword = "password" // 用户名 + 密码，按服务器通告的机制选择 PLAIN / LOGIN / CRAM-MD5（默认）
+	EmailAuthOAuth2   = "oauth2"   // OAuth 2.0 刷新令牌换取访问令牌，以 XOAUTH2 认证（Microsoft 365 / Gmail 禁用基本认证时）
+)
+
+// defaultMicrosoftSMTPScope 是 Microsoft 365 SMTP 发信所需的权限
+const defaultMicrosoftSMTPScope = "https://outlook.office.com/SMTP.Send offline_access"
+
+// oauth2RefreshMargin 是访问令牌到期前提前刷新的时间
+const oauth2RefreshMargin = time.Minute
+
+// oauth2Token 是缓存的访问令牌
+type oauth2Token struct {
+	accessToken  string
+	refreshToken string // 授权服务器轮换刷新令牌时保存新值（同时加密写回渠道配置），后续刷新使用
+	expiry       time.Time
+}
+
+// oauth2TokenURL 返回令牌端点：优先使用配置的地址，否则按租户拼接 Microsoft 身份平台地址
+func (e EmailConfig) oauth2TokenURL() string {
+	if u := strings.TrimSpace(e.OAuth2TokenURL); u != "" {
+		return u
+	}
+	if tenant := strings.TrimSpace(e.OAuth2Tenant); tenant != "" {
+		return "https://login.microsoftonline.com/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+	}
+	return ""
+}
+
+// oauth2Scope 返回刷新令牌时申请的权限；使用 Microsoft 租户且未配置时取 SMTP.Send
+func (e EmailConfig) oauth2Scope() string {
+	if s := strings.TrimSpace(e.OAuth2Scope); s != "" {
+		return s
+	}
+	if strings.TrimSpace(e.OAuth2TokenURL) == "" && strings.TrimSpace(e.OAuth2Tenant) != "" {
+		return defaultMicrosoftSMTPScope
+	}
+	return ""
+}
+
+// oauth2CacheKey 按令牌端点、客户端与刷新令牌区分缓存（刷新令牌只参与哈希，不以明文留在内存键中）
+func (e EmailConfig) oauth2CacheKey() string {
+	h := sha256.Sum256([]byte(e.oauth2TokenURL() + "\x00" + e.OAuth2ClientID + "\x00" + e.OAuth2RefreshToken))
+	return hex.EncodeToString(h[:])
+}
+
+// oauth2AccessToken 返回有效的访问令牌：缓存未过期（留出提前刷新的余量）时直接使用，否则用刷新令牌换取新的
+func (n *Notifier) oauth2AccessToken(e EmailConfig, opts netconf.Options) (string, error) {
+	tokenURL := e.oauth2TokenURL()
+	if tokenURL == "" {
+		return "", fmt.Errorf("OAuth2 需填写租户 ID 或令牌地址")
+	}
+	if e.OAuth2ClientID == "" || e.OAuth2RefreshToken == "" {
+		return "", fmt.Errorf("OAuth2 需填写客户端 ID 与刷新令牌")
+	}
+
+	key := e.oauth2CacheKey()
+	n.oauthMu.Lock()
+	defer n.oauthMu.Unlock()
+	cached := n.oauthTokens[key]
+	if cached != nil && time.Until(cached.expiry) > oauth2RefreshMargin {
+		return cached.accessToken, nil
+	}
+
+	refreshToken := e.OAuth2RefreshToken
+	if cached != nil && cached.refreshToken != "" {
+		refreshToken = cached.refreshToken
+	}
+	client, err := httpClientFor(opts)
+	if err != nil {
+		return "", err
+	}
+	tok, err := fetchOAuth2Token(client, tokenURL, e.OAuth2ClientID, e.OAuth2ClientSecret, refreshToken, e.oauth2Scope())
+	if err != nil {
+		return "", err
+	}
+	if tok.refreshToken == "" {
+		tok.refreshToken = refreshToken
+	}
+	if n.oauthTokens == nil {
+		n.oauthTokens = make(map[string]*oauth2Token)
+	}
+	n.oauthTokens[key] = tok
+	if tok.refreshToken != e.OAuth2RefreshToken {
+		if err := n.persistRefreshToken(e, tok.refreshToken); err != nil {
+			log.Printf("[通知器] 保存轮换后的 OAuth2 刷新令牌失败: %v", err)
+		} else {
+			// 渠道配置已改用新令牌，按新配置的缓存键沿用这次取得的访问令牌
+			rotated := e
+			rotated.OAuth2RefreshToken = tok.refreshToken
+			n.oauthTokens[rotated.oauth2CacheKey()] = tok
+		}
+	}
+	return tok.accessToken, nil
+}
+
+// persistRefreshToken 把授权服务器轮换后的刷新令牌加密写回仍使用旧令牌的邮件渠道，
+// 守护进程重启后以新令牌刷新（部分授权服务器轮换后旧令牌随即失效）。
+// 读改写在同一事务中完成，不覆盖同时保存的其他渠道修改。
+func (n *Notifier) persistRefreshToken(e EmailConfig, rotated string) error {
+	if n.storage == nil {
+		return nil
+	}
+	sealed, err := n.seal(rotated)
+	if err != nil {
+		return err
+	}
+	return n.storage.Transaction(func(tx *storage.Storage) error {
+		store := settings.New(tx)
+		channels, err := loadChannels(store)
+		if err != nil {
+			return err
+		}
+		updated := 0
+		for _, c := range channels {
+			if c.Type != "email" || stringField(c.Settings, "oauth2_client_id") != e.OAuth2ClientID ||
+				n.open(stringField(c.Settings, "oauth2_refresh_token")) != e.OAuth2RefreshToken {
+				continue
+			}
+			c.Settings["oauth2_refresh_token"] = sealed
+			updated++
+		}
+		if updated == 0 {
+			return nil
+		}
+		log.Printf("[通知器] OAuth2 刷新令牌已轮换，已更新 %d 个邮件渠道", updated)
+		return store.SetJSON(settings.KeyNotificationChannels, channels)
+	})
+}
+
+// invalidateOAuth2Token 丢弃缓存的访问令牌（服务器拒绝认证时调用，下次发送重新获取）
+func (n *Notifier) invalidateOAuth2Token(e EmailConfig) {
+	n.oauthMu.Lock()
+	defer n.oauthMu.Unlock()
+	if tok := n.oauthTokens[e.oauth2CacheKey()]; tok != nil {
+		tok.expiry = time.Time{}
+	}
+}
+
+// fetchOAuth2Token 以 refresh_token 授权向令牌端点换取访问令牌
+func fetchOAuth2Token(client *http.Client, tokenURL, clientID, clientSecret, refreshToken, scope string) (*oauth2Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	log.Printf("[通知器] 刷新 OAuth2 访问令牌: %s", tokenURL)
+	resp, err := client.PostForm(tokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("请求 OAuth2 令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("读取 OAuth2 令牌响应失败: %w", err)
+	}
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("OAuth2 令牌响应无法解析（状态码 %d）: %s", resp.StatusCode, summarizeBody(body))
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("状态码 %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("获取 OAuth2 访问令牌失败: %s %s", result.Error, truncateUTF8(result.ErrorDescription, 200))
+	}
+	expiresIn := time.Duration(result.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+	return &oauth2Token{
+		accessToken:  result.AccessToken,
+		refreshToken: result.RefreshToken,
+		expiry:       time.Now().Add(expiresIn),
+	}, nil
+}
+
+// authXOAUTH2 以 XOAUTH2 机制认证；服务器拒绝时丢弃缓存的令牌
+func (n *Notifier) authXOAUTH2(client *smtp.Client, e EmailConfig, opts netconf.Options) error {
+	if ok, mechs := client.Extension("AUTH"); !ok || !strings.Contains(strings.ToUpper(mechs), "XOAUTH2") {
+		return fmt.Errorf("服务器未通告 XOAUTH2 认证机制（通告: %q）。请确认加密方式为 SSL(465) 或 STARTTLS(587)", mechs)
+	}
+	token, err := n.oauth2AccessToken(e, opts)
+	if err != nil {
+		return err
+	}
+	user := e.Username
+	if user == "" {
+		user = e.From
+	}
+	if err := client.Auth(&xoauth2Auth{username: user, token: token}); err != nil {
+		n.invalidateOAuth2Token(e)
+		return fmt.Errorf("XOAUTH2 认证失败（请确认应用已授予 SMTP 发信权限且邮箱启用了 SMTP AUTH）: %w", err)
+	}
+	return nil
+}
+
+// xoauth2Auth 实现 XOAUTH2 SASL 机制：初始响应为 "user=<邮箱>^Aauth=Bearer <令牌>^A^A"。
+// 认证失败时服务器先以 334 返回 JSON 错误详情，客户端回复空行后服务器再返回 535。
+type xoauth2Auth struct {
+	username, token string
+}
+
+func (a *xoauth2Auth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(_ []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
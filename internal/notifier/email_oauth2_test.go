@@ -0,0 +1,262 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// xoauth2SMTP 是只通告 AUTH XOAUTH2 的假 SMTP 服务器，接受指定令牌，记录收到的认证串与邮件数。
+type xoauth2SMTP struct {
+	mu       sync.Mutex
+	token    string   // 接受的访问令牌
+	auths    []string // 解码后的 XOAUTH2 初始响应
+	messages int
+}
+
+func startXOAUTH2SMTP(t *testing.T, token string) (*xoauth2SMTP, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &xoauth2SMTP{token: token}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	_, p, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(p)
+	return s, port
+}
+
+func (s *xoauth2SMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	w("220 fake-smtp ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"):
+			w("250-fake-smtp")
+			w("250 AUTH LOGIN XOAUTH2")
+		case strings.HasPrefix(upper, "AUTH XOAUTH2 "):
+			raw, _ := base64.StdEncoding.DecodeString(cmd[len("AUTH XOAUTH2 "):])
+			s.mu.Lock()
+			s.auths = append(s.auths, string(raw))
+			ok := strings.Contains(string(raw), "auth=Bearer "+s.token+"\x01")
+			s.mu.Unlock()
+			if ok {
+				w("235 2.7.0 Authentication successful")
+				continue
+			}
+			// 与 Gmail / Office 365 一致：先以 334 返回错误详情，客户端回复空行后返回 535
+			w("334 " + base64.StdEncoding.EncodeToString([]byte(`{"status":"401","schemes":"bearer"}`)))
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			w("535 5.7.3 Authentication unsuccessful")
+		case upper == "DATA":
+			w("354 End data with <CR><LF>.<CR><LF>")
+			for {
+				dl, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dl == ".\r\n" {
+					break
+				}
+			}
+			s.mu.Lock()
+			s.messages++
+			s.mu.Unlock()
+			w("250 OK queued")
+		case upper == "QUIT":
+			w("221 Bye")
+			return
+		default:
+			w("250 OK")
+		}
+	}
+}
+
+// fakeTokenEndpoint 是本地 OAuth2 令牌端点：依次返回 tokens 中的访问令牌，记录请求表单
+type fakeTokenEndpoint struct {
+	mu        sync.Mutex
+	tokens    []string
+	expiresIn int
+	forms     []map[string]string
+}
+
+func startFakeTokenEndpoint(t *testing.T, expiresIn int, tokens ...string) (*fakeTokenEndpoint, string) {
+	t.Helper()
+	f := &fakeTokenEndpoint{tokens: tokens, expiresIn: expiresIn}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		f.forms = append(f.forms, form)
+		if form["refresh_token"] == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "AADSTS70000: token revoked"})
+			return
+		}
+		tok := f.tokens[0]
+		if len(f.tokens) > 1 {
+			f.tokens = f.tokens[1:]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  tok,
+			"expires_in":    f.expiresIn,
+			"token_type":    "Bearer",
+			"refresh_token": "rotated-" + tok,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func oauth2EmailConfig(port int, tokenURL string) NotificationConfig {
+	return NotificationConfig{Type: "email", Settings: settingsOf(EmailConfig{
+		SMTPHost: "127.0.0.1", SMTPPort: port, Encryption: "none",
+		AuthMode: EmailAuthOAuth2, From: "alert@contoso.com", To: "it@contoso.com",
+		OAuth2ClientID: "client-1", OAuth2ClientSecret: "cs", OAuth2TokenURL: tokenURL, OAuth2RefreshToken: "rt-1",
+	})}
+}
+
+// TestSendEmailXOAUTH2CachesToken 验证以 XOAUTH2 认证，且访问令牌在有效期内复用
+func TestSendEmailXOAUTH2CachesToken(t *testing.T) {
+	smtpSrv, port := startXOAUTH2SMTP(t, "at-1")
+	endpoint, tokenURL := startFakeTokenEndpoint(t, 3600, "at-1")
+	n := &Notifier{}
+	cfg := oauth2EmailConfig(port, tokenURL)
+
+	for i := 0; i < 2; i++ {
+		if err := n.sendEmailNotification(cfg, "测试标题", "测试内容"); err != nil {
+			t.Fatalf("第 %d 次发送失败: %v", i+1, err)
+		}
+	}
+
+	smtpSrv.mu.Lock()
+	defer smtpSrv.mu.Unlock()
+	if smtpSrv.messages != 2 {
+		t.Errorf("服务器应收到 2 封邮件，实际 %d", smtpSrv.messages)
+	}
+	if len(smtpSrv.auths) == 0 || smtpSrv.auths[0] != "user=alert@contoso.com\x01auth=Bearer at-1\x01\x01" {
+		t.Errorf("XOAUTH2 初始响应不正确: %q", smtpSrv.auths)
+	}
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	if len(endpoint.forms) != 1 {
+		t.Fatalf("有效期内应只请求一次令牌，实际 %d 次", len(endpoint.forms))
+	}
+	if f := endpoint.forms[0]; f["grant_type"] != "refresh_token" || f["refresh_token"] != "rt-1" || f["client_id"] != "client-1" || f["client_secret"] != "cs" {
+		t.Errorf("令牌请求参数不正确: %v", f)
+	}
+}
+
+// TestSendEmailXOAUTH2RefreshesBeforeExpiry 验证临近过期的令牌会刷新，并使用轮换后的刷新令牌
+func TestSendEmailXOAUTH2RefreshesBeforeExpiry(t *testing.T) {
+	_, port := startXOAUTH2SMTP(t, "at-2")
+	endpoint, tokenURL := startFakeTokenEndpoint(t, 30, "at-1", "at-2") // 30 秒小于提前刷新的余量
+	n := &Notifier{}
+	cfg := oauth2EmailConfig(port, tokenURL)
+
+	// 第一个令牌被服务器拒绝：报错并丢弃缓存
+	err := n.sendEmailNotification(cfg, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "XOAUTH2 认证失败") {
+		t.Fatalf("令牌无效时应报认证失败，实际 %v", err)
+	}
+	if err := n.sendEmailNotification(cfg, "t", "c"); err != nil {
+		t.Fatalf("刷新令牌后应发送成功: %v", err)
+	}
+
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	if len(endpoint.forms) != 2 || endpoint.forms[1]["refresh_token"] != "rotated-at-1" {
+		t.Errorf("第二次应使用轮换后的刷新令牌刷新: %v", endpoint.forms)
+	}
+}
+
+// TestOAuth2RotatedRefreshTokenPersisted 验证轮换后的刷新令牌加密写回渠道配置，重启后使用新令牌，且不因此重复刷新
+func TestOAuth2RotatedRefreshTokenPersisted(t *testing.T) {
+	_, port := startXOAUTH2SMTP(t, "at-1")
+	endpoint, tokenURL := startFakeTokenEndpoint(t, 3600, "at-1")
+	n, st := newTestNotifier(t)
+	err := n.SaveChannels([]Channel{{ID: "mail", Type: "email", Enabled: true, Settings: map[string]interface{}{
+		"smtp_host": "127.0.0.1", "smtp_port": port, "encryption": "none", "auth_mode": EmailAuthOAuth2,
+		"from": "alert@contoso.com", "to": "it@contoso.com",
+		"oauth2_client_id": "client-1", "oauth2_token_url": tokenURL, "oauth2_refresh_token": "rt-1",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := n.sendEmailNotification(n.channelConfig(mustChannel(t, n, "mail")), "t", "c"); err != nil {
+			t.Fatalf("第 %d 次发送失败: %v", i+1, err)
+		}
+	}
+	endpoint.mu.Lock()
+	refreshes := len(endpoint.forms)
+	endpoint.mu.Unlock()
+	if refreshes != 1 {
+		t.Errorf("令牌有效期内应只刷新一次，实际 %d 次", refreshes)
+	}
+
+	if raw, _ := st.GetConfig("notification_channels"); strings.Contains(raw, "rotated-at-1") {
+		t.Fatalf("轮换后的刷新令牌应加密保存: %s", raw)
+	}
+	restarted := NewNotifier(st, n.box)
+	if got := emailSettings(restarted.channelConfig(mustChannel(t, restarted, "mail"))).OAuth2RefreshToken; got != "rotated-at-1" {
+		t.Errorf("重启后应使用轮换后的刷新令牌，实际 %q", got)
+	}
+}
+
+func TestSendEmailXOAUTH2TokenError(t *testing.T) {
+	_, port := startXOAUTH2SMTP(t, "at-1")
+	_, tokenURL := startFakeTokenEndpoint(t, 3600, "at-1")
+	cfg := oauth2EmailConfig(port, tokenURL)
+	cfg.Settings["oauth2_refresh_token"] = "revoked"
+	err := (&Notifier{}).sendEmailNotification(cfg, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") || !strings.Contains(err.Error(), "AADSTS70000") {
+		t.Errorf("令牌端点报错时应返回错误详情，实际 %v", err)
+	}
+}
+
+func TestOAuth2TokenURLAndScope(t *testing.T) {
+	ms := EmailConfig{OAuth2Tenant: "contoso.onmicrosoft.com"}
+	if got := ms.oauth2TokenURL(); got != "https://login.microsoftonline.com/contoso.onmicrosoft.com/oauth2/v2.0/token" {
+		t.Errorf("Microsoft 令牌地址不正确: %s", got)
+	}
+	if ms.oauth2Scope() != defaultMicrosoftSMTPScope {
+		t.Errorf("Microsoft 租户默认应申请 SMTP.Send 权限")
+	}
+	google := EmailConfig{OAuth2TokenURL: "https://oauth2.googleapis.com/token"}
+	if google.oauth2TokenURL() != "https://oauth2.googleapis.com/token" || google.oauth2Scope() != "" {
+		t.Errorf("自定义令牌地址时不应附加默认权限")
+	}
+}
@@ -2,7 +2,13 @@ package notifier
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net"
+	"net/mail"
 	"strconv"
 	"strings"
 	"testing"
@@ -106,13 +112,13 @@ func TestSendEmailPlaintextNoAuth(t *testing.T) {
 	n := &Notifier{}
 	cfg := NotificationConfig{
 		Type: "email",
-		Email: EmailConfig{
+		Settings: settingsOf(EmailConfig{
 			SMTPHost:   host,
 			SMTPPort:   port,
 			Encryption: "none",
 			From:       "alert@example.com",
 			To:         "a@example.com, b@example.com",
-		},
+		}),
 	}
 
 	if err := n.sendEmailNotification(cfg, "远程控制告警", "检测到远程会话"); err != nil {
@@ -133,3 +139,106 @@ func TestSendEmailPlaintextNoAuth(t *testing.T) {
 		t.Errorf("邮件正文未使用 base64 编码:\n%s", got.data)
 	}
 }
+
+// TestBuildEmailMessageMultipart 验证纯文本 + HTML 邮件为 multipart/alternative，且包含抄送与会话线索头
+func TestBuildEmailMessageMultipart(t *testing.T) {
+	raw := buildEmailMessage(emailMessage{
+		From:      "alert@example.com",
+		To:        []string{"a@example.com"},
+		Cc:        []string{"c@example.com"},
+		Subject:   "远程控制结束",
+		Text:      "纯文本正文",
+		HTML:      "<b>HTML 正文</b>",
+		MessageID: "<end@example.com>",
+		InReplyTo: "<start@example.com>",
+	})
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("邮件格式无效: %v", err)
+	}
+	if msg.Header.Get("Cc") != "c@example.com" || msg.Header.Get("Message-ID") != "<end@example.com>" {
+		t.Errorf("邮件头不正确: %v", msg.Header)
+	}
+	if msg.Header.Get("In-Reply-To") != "<start@example.com>" || msg.Header.Get("References") != "<start@example.com>" {
+		t.Errorf("应设置 In-Reply-To 与 References: %v", msg.Header)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type 应为 multipart/alternative，实际 %q", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		types = append(types, p.Header.Get("Content-Type"))
+		bodies = append(bodies, string(b))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Fatalf("应依次包含纯文本与 HTML 两部分，实际 %v", types)
+	}
+	if bodies[0] != "纯文本正文" || bodies[1] != "<b>HTML 正文</b>" {
+		t.Errorf("正文解码结果不正确: %q", bodies)
+	}
+}
+
+// TestSendEventEmailThreadsSession 验证会话结束邮件回复开始邮件，密送只出现在信封中
+func TestSendEventEmailThreadsSession(t *testing.T) {
+	host, port, result := startFakeSMTP(t)
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "email", Settings: settingsOf(EmailConfig{
+		SMTPHost: host, SMTPPort: port, Encryption: "none",
+		From: "alert@corp.example", To: "a@corp.example", Cc: "b@corp.example", Bcc: "audit@corp.example",
+	})}
+	ev := &Event{
+		Type: EventRemoteEnd, Title: "远程控制结束", Content: "会话已结束",
+		SessionID: "s-42", DeviceName: "财务PC", Duration: "00:05:00",
+		SignalDetails: []SignalDetail{{Name: "ToDesk (TCP连接数:3)", Tool: "ToDesk", Method: "TCP连接数:3", PID: 4321, Peer: "10.0.0.8"}},
+	}
+	if err := n.deliver(cfg, ev); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	got := <-result
+	if len(got.rcpts) != 3 {
+		t.Errorf("信封收件人应包含收件人、抄送与密送，实际 %v", got.rcpts)
+	}
+	if strings.Contains(got.data, "audit@corp.example") {
+		t.Errorf("密送地址不应出现在邮件头中")
+	}
+	if want := "In-Reply-To: <session.s-42.remoteknown@corp.example>"; !strings.Contains(got.data, want) {
+		t.Errorf("结束邮件应回复开始邮件（%s）:\n%s", want, got.data)
+	}
+	if !strings.Contains(got.data, "multipart/alternative") {
+		t.Errorf("事件邮件应包含 HTML 部分")
+	}
+}
+
+func TestEventEmailHTML(t *testing.T) {
+	ev := &Event{
+		Type: EventRemoteStart, Title: "远程控制开始 <告警>", Content: "a & b",
+		SignalDetails: []SignalDetail{
+			{Name: "ToDesk (进程存在)", Tool: "ToDesk", Method: "进程存在", PID: 100},
+			{Name: "Windows RDP (来自: 10.0.0.8)", Tool: "Windows RDP", Method: "RDP 会话", Peer: "10.0.0.8"},
+		},
+	}
+	out := eventEmailHTML(ev)
+	for _, want := range []string{
+		emailHeaderColor(EventRemoteStart),
+		"远程控制开始 &lt;告警&gt;",
+		"<td>ToDesk</td><td>进程存在</td><td>100</td><td></td>",
+		"<td>Windows RDP</td><td>RDP 会话</td><td></td><td>10.0.0.8</td>",
+		"a &amp; b",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML 正文缺少 %q:\n%s", want, out)
+		}
+	}
+	if sessionMessageID("s1", "Alert <a@x.com>") != "<session.s1.remoteknown@x.com>" {
+		t.Errorf("会话 Message-ID 应使用发件人域名")
+	}
+}
@@ -0,0 +1,128 @@
+package notifier
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/detector"
+)
+
+// 通知事件类型
+const (
+	EventRemoteStart      = "remote_start"      // 远程控制开始
+	EventRemoteEnd        = "remote_end"        // 远程控制结束
+	EventRemoteReminder   = "remote_reminder"   // 远程控制持续中的定期提醒
+	EventRemoteEscalation = "remote_escalation" // 远程控制持续过久的升级通知
+	EventAppStart         = "app_start"         // 守护进程启动
+	EventAppExit          = "app_exit"          // 守护进程退出
+	EventRulesUpdated     = "rules_updated"     // 检测规则更新
+	EventDigest           = "digest"            // 定期统计报告（日报 / 周报）
+	EventSummary          = "summary"           // 去重窗口内重复事件的汇总
+	EventTest             = "test"              // 测试通知
+)
+
+// Event 是一条通知对应的类型化事件。固定格式的渠道（飞书、钉钉等）只使用 Title / Content，
+// 自定义 Webhook 等渠道可在模板中引用其余字段。
+type Event struct {
+	Type            string         `json:"type"`
+	Title           string         `json:"title"`
+	Content         string         `json:"content"`
+	SessionID       string         `json:"session_id,omitempty"`
+	Authorized      bool           `json:"authorized"` // 会话已被用户确认（知悉）
+	DeviceName      string         `json:"device_name"`
+	Hostname        string         `json:"hostname"`
+	Tool            string         `json:"tool"`  // 主要远程工具（多个时为第一个）
+	Tools           []string       `json:"tools"` // 涉及的全部远程工具（去重）
+	Signals         []string       `json:"signals"`
+	SignalDetails   []SignalDetail `json:"signal_details,omitempty"` // 信号明细（工具、判定方式、PID、对端地址）
+	Peers           []string       `json:"peers"`                    // 对端地址（可获取时）
+	StartTime       time.Time      `json:"start_time,omitempty"`
+	EndTime         time.Time      `json:"end_time,omitempty"`
+	Duration        string         `json:"duration,omitempty"` // 会话时长，如 01:02:03
+	DurationSeconds int64          `json:"duration_seconds,omitempty"`
+	Time            time.Time      `json:"time"`                    // 事件发生时间
+	RulesVersion    string         `json:"rules_version,omitempty"` // 切换到的规则版本（仅 rules_updated）
+	RulesSource     string         `json:"rules_source,omitempty"`  // 规则来源：github / manual / rollback / bundle（仅 rules_updated）
+	Digest          *Digest        `json:"digest,omitempty"`        // 统计报告（仅 digest）
+
+	progress *deliveryProgress // 发件箱投递时已送达的部分（不序列化），直接发送时为 nil
+}
+
+// SignalDetail 是一条检测信号的明细
+type SignalDetail struct {
+	Name   string `json:"name"`
+	Tool   string `json:"tool,omitempty"`
+	Method string `json:"method,omitempty"`
+	PID    int    `json:"pid,omitempty"`
+	Peer   string `json:"peer,omitempty"`
+}
+
+// isSessionEvent 报告事件是否与某次远程会话相关
+func isSessionEvent(eventType string) bool {
+	switch eventType {
+	case EventRemoteStart, EventRemoteEnd, EventRemoteReminder, EventRemoteEscalation:
+		return true
+	}
+	return false
+}
+
+// newEvent 创建带设备信息的事件
+func (n *Notifier) newEvent(eventType, title, content string) *Event {
+	hostname, _ := os.Hostname()
+	return &Event{
+		Type:       eventType,
+		Title:      title,
+		Content:    content,
+		DeviceName: n.getDeviceName(),
+		Hostname:   hostname,
+		Time:       time.Now(),
+	}
+}
+
+// setSession 把检测器的会话信息填入事件
+func (ev *Event) setSession(se detector.SessionEvent) {
+	ev.SessionID = se.SessionID
+	ev.StartTime = se.StartTime
+	ev.EndTime = se.EndTime
+	// 会话进行中（提醒 / 升级）时按事件时间计算已持续的时长
+	end := se.EndTime
+	if end.IsZero() && (ev.Type == EventRemoteReminder || ev.Type == EventRemoteEscalation) {
+		end = ev.Time
+	}
+	if !se.StartTime.IsZero() && !end.IsZero() {
+		d := end.Sub(se.StartTime)
+		ev.DurationSeconds = int64(d.Seconds())
+		ev.Duration = formatDuration(d)
+	}
+
+	seenTool := make(map[string]bool)
+	seenPeer := make(map[string]bool)
+	for _, sig := range se.Signals {
+		ev.Signals = append(ev.Signals, sig.Name)
+		ev.SignalDetails = append(ev.SignalDetails, SignalDetail{
+			Name: sig.Name, Tool: sig.Tool, Method: sig.Method, PID: sig.PID, Peer: sig.Peer,
+		})
+		if sig.Tool != "" && !seenTool[sig.Tool] {
+			seenTool[sig.Tool] = true
+			ev.Tools = append(ev.Tools, sig.Tool)
+		}
+		if sig.Peer != "" && !seenPeer[sig.Peer] {
+			seenPeer[sig.Peer] = true
+			ev.Peers = append(ev.Peers, sig.Peer)
+		}
+	}
+	if len(ev.Tools) > 0 {
+		ev.Tool = ev.Tools[0]
+	}
+}
+
+// signalList 返回用于正文的信号列表（每行一个）
+func (ev *Event) signalList() string {
+	return strings.Join(ev.Signals, "\n")
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
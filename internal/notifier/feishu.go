@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"RemoteKnown/internal/secret"
+)
+
+func init() {
+	RegisterChannel(feishuDriver{})
+}
+
+// feishuDriver 是飞书群机器人渠道
+type feishuDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (feishuDriver) Type() string { return "feishu" }
+
+func (feishuDriver) Schema() *Schema {
+	return webhookSchema("飞书", "飞书群机器人的 Webhook 地址", "机器人安全设置中开启“签名校验”时填写")
+}
+
+func (feishuDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (feishuDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendFeishuCard(config, ev, feishuElements(ev))
+}
+
+func (feishuDriver) Preview(_ *Notifier, config NotificationConfig, ev *Event) (*Preview, error) {
+	message := feishuMessage(config, ev, feishuElements(ev))
+	if _, ok := message["sign"]; ok {
+		message["sign"] = secret.Mask
+	}
+	return jsonPreview(config.Type, maskURL(botSettings(config).WebhookURL, true), message)
+}
+
+// feishuTextElements 返回纯文本的卡片正文
+func feishuTextElements(content string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"tag": "div",
+			"text": map[string]interface{}{
+				"tag":     "plain_text",
+				"content": content,
+			},
+		},
+	}
+}
+
+// feishuElements 返回事件的卡片正文：统计报告用 lark_md 排版，其余事件为纯文本
+func feishuElements(ev *Event) []map[string]interface{} {
+	if ev.Digest == nil {
+		return feishuTextElements(ev.Content)
+	}
+	return []map[string]interface{}{
+		{
+			"tag": "div",
+			"text": map[string]interface{}{
+				"tag":     "lark_md",
+				"content": digestMarkdown(ev.DeviceName, ev.Digest),
+			},
+		},
+	}
+}
+
+// sendFeishuCard 以消息卡片发送飞书通知，elements 为卡片正文元素
+func (n *Notifier) sendFeishuCard(config NotificationConfig, ev *Event, elements []map[string]interface{}) error {
+	message := feishuMessage(config, ev, elements)
+	if debugMode {
+		if raw, err := json.Marshal(message); err == nil {
+			log.Printf("[通知器] 飞书消息原始内容: %s", string(raw))
+		}
+	}
+	return n.postWebhook(config.Network, botSettings(config).WebhookURL, nil, message, feishuSuccess)
+}
+
+// feishuMessage 构建飞书消息卡片（配置了签名密钥时附带时间戳与签名），标题栏颜色按事件类型
+func feishuMessage(config NotificationConfig, ev *Event, elements []map[string]interface{}) map[string]interface{} {
+	message := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title": map[string]interface{}{
+					"tag":     "plain_text",
+					"content": ev.Title,
+				},
+				"template": getFeishuColor(ev.Type),
+			},
+			"elements": elements,
+		},
+	}
+
+	// 如果配置了签名密钥，添加签名
+	if signKey := botSettings(config).Secret; signKey != "" {
+		timestamp := time.Now().Unix()
+		sign := generateFeishuSign(signKey, timestamp)
+		message["timestamp"] = fmt.Sprintf("%d", timestamp)
+		message["sign"] = sign
+	}
+	return message
+}
+
+// generateFeishuSign 生成飞书签名
+func generateFeishuSign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// getFeishuColor 返回各事件类型飞书卡片标题栏的颜色（标题可由模板自定义，不据此判断）
+func getFeishuColor(eventType string) string {
+	switch eventType {
+	case EventRemoteStart, EventRemoteEscalation:
+		return "red"
+	case EventRemoteReminder:
+		return "orange"
+	case EventRemoteEnd:
+		return "green"
+	case EventAppStart, EventAppExit:
+		return "grey"
+	default:
+		return "blue"
+	}
+}
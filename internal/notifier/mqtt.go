@@ -0,0 +1,497 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/version"
+)
+
+func init() {
+	RegisterChannel(mqttDriver{})
+}
+
+// MQTTConfig 是 MQTT 渠道配置。渠道启用期间守护进程与代理保持长连接：
+//
+//	<topic_prefix>/<设备>/state         保留消息，active / idle
+//	<topic_prefix>/<设备>/attributes    保留消息，当前状态的 JSON 属性（工具、开始时间、置信度）
+//	<topic_prefix>/<设备>/availability  保留消息，online / offline（遗嘱消息）
+//	<topic_prefix>/<设备>/event         会话开始 / 结束等事件（按渠道订阅条件），非保留
+//
+// 连接按渠道的网络选项经代理建立，TLS 的 CA 证书、客户端证书与证书校验同样取自网络选项。
+type MQTTConfig struct {
+	Broker          string `json:"broker"`           // 代理地址，如 tcp://192.168.1.2:1883 或 ssl://broker:8883（mqtts:// / tls:// 同 ssl://）
+	Username        string `json:"username"`         // 用户名（可选）
+	Password        string `json:"password"`         // 密码（可选）
+	ClientID        string `json:"client_id"`        // 客户端 ID，默认 remoteknown-<主机名>
+	TopicPrefix     string `json:"topic_prefix"`     // 主题前缀，默认 remoteknown
+	QoS             int    `json:"qos"`              // 0 / 1，默认 0
+	Discovery       bool   `json:"discovery"`        // 发布 Home Assistant MQTT 自动发现配置
+	DiscoveryPrefix string `json:"discovery_prefix"` // 自动发现前缀，默认 homeassistant
+}
+
+// mqttDriver 是 MQTT 渠道
+type mqttDriver struct{ sendsTest }
+
+func (mqttDriver) Type() string { return "mqtt" }
+
+func (mqttDriver) Schema() *Schema {
+	broker := field("代理地址", "如 tcp://192.168.1.2:1883 或 ssl://broker:8883；TLS 的 CA 证书与客户端证书在渠道的网络选项中设置")
+	return &Schema{
+		Type:        "object",
+		Title:       "MQTT",
+		Description: "与代理保持长连接，发布远程控制状态（保留消息）与事件，可接入 Home Assistant",
+		Properties: map[string]*Schema{
+			"broker":           broker,
+			"username":         field("用户名", ""),
+			"password":         secretField("密码", ""),
+			"client_id":        field("客户端 ID", "留空为 remoteknown-<主机名>"),
+			"topic_prefix":     field("主题前缀", "留空为 remoteknown"),
+			"qos":              {Type: "integer", Title: "QoS", Enum: []interface{}{0, 1}, Default: 0},
+			"discovery":        {Type: "boolean", Title: "Home Assistant 自动发现"},
+			"discovery_prefix": field("自动发现前缀", "留空为 homeassistant"),
+		},
+		Required: []string{"broker"},
+		Order: []string{"broker", "username", "password", "client_id", "topic_prefix", "qos",
+			"discovery", "discovery_prefix"},
+	}
+}
+
+func (mqttDriver) Validate(config NotificationConfig) error {
+	cfg := mqttSettings(config)
+	if _, _, err := mqttAddress(cfg.Broker); err != nil {
+		return err
+	}
+	if cfg.QoS != 0 && cfg.QoS != 1 {
+		return fmt.Errorf("MQTT QoS 只支持 0 或 1")
+	}
+	return nil
+}
+
+func (mqttDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendMQTT(config, ev)
+}
+
+// mqttSettings 返回渠道的 MQTT 设置
+func mqttSettings(config NotificationConfig) MQTTConfig {
+	var cfg MQTTConfig
+	config.decode(&cfg)
+	return cfg
+}
+
+// mqttSyncInterval 是检查 MQTT 连接（断线重连、配置变更）的间隔
+const mqttSyncInterval = 30 * time.Second
+
+// mqttTopics 是一个设备在 MQTT 中使用的主题
+type mqttTopics struct {
+	node         string // Home Assistant 节点 ID（仅字母数字与下划线）
+	state        string
+	attributes   string
+	availability string
+	event        string
+	discovery    string
+}
+
+// mqttSession 是一个 MQTT 渠道与代理之间的长连接
+type mqttSession struct {
+	key     string
+	cfg     MQTTConfig
+	network netconf.Options
+	device  string
+	topics  mqttTopics
+
+	mu     sync.Mutex
+	client *mqttClient
+	closed bool // 已关闭（渠道停用、配置变更或守护进程退出），不再重连
+}
+
+// mqttState 是最近一次的检测状态（连接建立后作为保留消息发布）
+type mqttState struct {
+	Active     bool      `json:"-"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	StartTime  time.Time `json:"start_time,omitempty"`
+	Tools      []string  `json:"tools"`
+	Signals    []string  `json:"signals"`
+	Peers      []string  `json:"peers"`
+	Confidence float64   `json:"confidence"`
+	Device     string    `json:"device"`
+	Hostname   string    `json:"hostname"`
+}
+
+// NotifyStateChange 记录检测器的最新状态，并唤醒 MQTT 协程发布到所有启用的 MQTT 渠道。
+// 检测器在持有状态锁时调用，这里不做任何网络操作，避免代理无响应时阻塞检测。
+func (n *Notifier) NotifyStateChange(state detector.RemoteState) {
+	ev := n.newEvent("", "", "")
+	ev.setSession(detector.SessionEvent{StartTime: state.Since, Signals: state.Signals})
+	st := &mqttState{
+		Active:     state.Active,
+		State:      "idle",
+		Since:      state.Since,
+		Tools:      ev.Tools,
+		Signals:    ev.Signals,
+		Peers:      ev.Peers,
+		Confidence: state.Confidence,
+		Device:     ev.DeviceName,
+		Hostname:   ev.Hostname,
+	}
+	if state.Active {
+		st.State = "active"
+		st.StartTime = state.Since
+	}
+
+	n.mqttMu.Lock()
+	n.mqttState = st
+	n.mqttMu.Unlock()
+
+	select {
+	case n.mqttWake <- struct{}{}:
+	default:
+	}
+}
+
+// publishMQTTState 把最近一次的检测状态发布到所有启用的 MQTT 渠道（连续的状态变化只发布最新的一次）
+func (n *Notifier) publishMQTTState() {
+	sessions := n.syncMQTT()
+	st := n.currentMQTTState()
+	for _, s := range sessions {
+		if err := s.publishState(st); err != nil {
+			log.Printf("[MQTT] 发布状态到 %s 失败: %v", s.cfg.Broker, err)
+		}
+	}
+}
+
+// currentMQTTState 返回最近一次的检测状态；检测器尚未报告时视为空闲
+func (n *Notifier) currentMQTTState() *mqttState {
+	n.mqttMu.Lock()
+	defer n.mqttMu.Unlock()
+	if n.mqttState == nil {
+		hostname, _ := os.Hostname()
+		n.mqttState = &mqttState{State: "idle", Since: time.Now(), Tools: []string{}, Signals: []string{},
+			Peers: []string{}, Device: n.getDeviceName(), Hostname: hostname}
+	}
+	return n.mqttState
+}
+
+// sendMQTT 把事件以 JSON 发布到渠道的事件主题
+func (n *Notifier) sendMQTT(config NotificationConfig, ev *Event) error {
+	s, err := n.mqttSessionFor(config)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.publish(n, s.topics.event, payload, false)
+}
+
+func (n *Notifier) mqttLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(mqttSyncInterval)
+	defer ticker.Stop()
+
+	n.syncMQTT()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-n.mqttWake:
+			n.publishMQTTState()
+		case <-ticker.C:
+			n.syncMQTT()
+		}
+	}
+}
+
+// syncMQTT 为每个启用的 MQTT 渠道保持连接（断线时重连），关闭已删除、停用或配置已变更的连接，
+// 返回当前的连接
+func (n *Notifier) syncMQTT() []*mqttSession {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		log.Printf("[MQTT] 读取通知渠道失败: %v", err)
+		return nil
+	}
+
+	var sessions []*mqttSession
+	keep := make(map[string]bool)
+	for _, c := range channels {
+		if !c.Enabled || c.Type != "mqtt" {
+			continue
+		}
+		s, err := n.mqttSessionFor(n.channelConfig(c))
+		if err == errMQTTClosed {
+			return nil
+		}
+		if err != nil {
+			log.Printf("[MQTT] 渠道「%s」配置无效: %v", c.Name, err)
+			continue
+		}
+		keep[s.key] = true
+		if err := s.ensure(n); err != nil {
+			log.Printf("[MQTT] 渠道「%s」连接失败: %v", c.Name, err)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	n.mqttMu.Lock()
+	var stale []*mqttSession
+	for key, s := range n.mqttSessions {
+		if !keep[key] {
+			stale = append(stale, s)
+			delete(n.mqttSessions, key)
+		}
+	}
+	n.mqttMu.Unlock()
+	for _, s := range stale {
+		s.close()
+	}
+	return sessions
+}
+
+// closeMQTT 发布离线状态并断开所有 MQTT 连接（守护进程退出时）。
+// 之后不再建立连接：MQTT 协程或发件箱此时正在进行的同步、发布不会在关闭后重新连上代理。
+func (n *Notifier) closeMQTT() {
+	n.mqttMu.Lock()
+	sessions := n.mqttSessions
+	n.mqttSessions = nil
+	n.mqttClosed = true
+	n.mqttMu.Unlock()
+	for _, s := range sessions {
+		s.close()
+	}
+}
+
+// mqttSessionFor 返回渠道配置对应的连接（按设置、网络选项与设备名复用，不会主动建立连接）；
+// closeMQTT 之后返回 errMQTTClosed
+func (n *Notifier) mqttSessionFor(config NotificationConfig) (*mqttSession, error) {
+	cfg := mqttSettings(config)
+	if _, _, err := mqttAddress(cfg.Broker); err != nil {
+		return nil, err
+	}
+	device := n.getDeviceName()
+	raw, _ := json.Marshal(cfg)
+	network, _ := json.Marshal(config.Network)
+	key := string(raw) + "|" + string(network) + "|" + device
+
+	n.mqttMu.Lock()
+	defer n.mqttMu.Unlock()
+	if n.mqttClosed {
+		return nil, errMQTTClosed
+	}
+	if s, ok := n.mqttSessions[key]; ok {
+		return s, nil
+	}
+	if n.mqttSessions == nil {
+		n.mqttSessions = make(map[string]*mqttSession)
+	}
+	s := &mqttSession{key: key, cfg: cfg, network: config.Network, device: device, topics: newMQTTTopics(cfg, device)}
+	n.mqttSessions[key] = s
+	return s, nil
+}
+
+// newMQTTTopics 按主题前缀与设备名生成主题；设备名中的 MQTT 通配符、分隔符与空白替换为下划线
+func newMQTTTopics(cfg MQTTConfig, device string) mqttTopics {
+	prefix := strings.Trim(cfg.TopicPrefix, "/")
+	if prefix == "" {
+		prefix = "remoteknown"
+	}
+	topicDevice := strings.Map(func(r rune) rune {
+		if r == '/' || r == '+' || r == '#' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, device)
+	base := prefix + "/" + topicDevice
+
+	nodeID := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return '_'
+		}, s)
+	}
+	node := nodeID(topicDevice)
+	if strings.Trim(node, "_") == "" {
+		// 设备名全为非 ASCII 字符时，用主机名生成节点 ID
+		hostname, _ := os.Hostname()
+		node = nodeID(hostname)
+	}
+
+	discoveryPrefix := strings.Trim(cfg.DiscoveryPrefix, "/")
+	if discoveryPrefix == "" {
+		discoveryPrefix = "homeassistant"
+	}
+	return mqttTopics{
+		node:         node,
+		state:        base + "/state",
+		attributes:   base + "/attributes",
+		availability: base + "/availability",
+		event:        base + "/event",
+		discovery:    discoveryPrefix + "/binary_sensor/remoteknown_" + node + "/remote_control/config",
+	}
+}
+
+// mqttAddress 解析代理地址，返回 host:port 与是否使用 TLS；省略端口时明文为 1883、TLS 为 8883
+func mqttAddress(broker string) (addr string, useTLS bool, err error) {
+	broker = strings.TrimSpace(broker)
+	if broker == "" {
+		return "", false, fmt.Errorf("MQTT 代理地址不能为空")
+	}
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil || u.Hostname() == "" {
+		return "", false, fmt.Errorf("MQTT 代理地址无效: %s", broker)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if port == "" {
+			port = "1883"
+		}
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		if port == "" {
+			port = "8883"
+		}
+	default:
+		return "", false, fmt.Errorf("不支持的 MQTT 代理协议: %s", u.Scheme)
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// ensure 在未连接或连接已断开时（重新）连接代理，并发布在线状态、自动发现配置与当前状态
+func (s *mqttSession) ensure(n *Notifier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ensureLocked(n)
+}
+
+func (s *mqttSession) ensureLocked(n *Notifier) error {
+	if s.closed {
+		return errMQTTClosed
+	}
+	if s.client != nil && !s.client.Closed() {
+		return nil
+	}
+	addr, useTLS, err := mqttAddress(s.cfg.Broker)
+	if err != nil {
+		return err
+	}
+	opts := mqttConnectOptions{
+		Address:  addr,
+		TLS:      useTLS,
+		Network:  s.network,
+		ClientID: s.cfg.ClientID,
+		Username: s.cfg.Username,
+		Password: s.cfg.Password,
+		Will:     &mqttWill{Topic: s.topics.availability, Payload: []byte("offline"), Retain: true},
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "remoteknown-" + s.topics.node
+	}
+
+	client, err := dialMQTT(opts)
+	if err != nil {
+		return err
+	}
+	s.client = client
+	log.Printf("[MQTT] 已连接 %s", s.cfg.Broker)
+
+	if err := s.publishLocked(s.topics.availability, []byte("online"), true); err != nil {
+		return err
+	}
+	if s.cfg.Discovery {
+		payload, _ := json.Marshal(s.discoveryConfig())
+		if err := s.publishLocked(s.topics.discovery, payload, true); err != nil {
+			return err
+		}
+	}
+	return s.publishStateLocked(n.currentMQTTState())
+}
+
+// discoveryConfig 返回 Home Assistant 二元传感器的自动发现配置
+func (s *mqttSession) discoveryConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"name":                  "远程控制",
+		"unique_id":             "remoteknown_" + s.topics.node + "_remote_control",
+		"state_topic":           s.topics.state,
+		"payload_on":            "active",
+		"payload_off":           "idle",
+		"json_attributes_topic": s.topics.attributes,
+		"availability_topic":    s.topics.availability,
+		"payload_available":     "online",
+		"payload_not_available": "offline",
+		"device_class":          "problem",
+		"icon":                  "mdi:remote-desktop",
+		"device": map[string]interface{}{
+			"identifiers":  []string{"remoteknown_" + s.topics.node},
+			"name":         s.device,
+			"manufacturer": "RemoteKnown",
+			"model":        "RemoteKnown",
+			"sw_version":   version.Version,
+		},
+	}
+}
+
+// publish 发布一条消息，必要时先（重新）连接
+func (s *mqttSession) publish(n *Notifier, topic string, payload []byte, retain bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureLocked(n); err != nil {
+		return err
+	}
+	return s.publishLocked(topic, payload, retain)
+}
+
+func (s *mqttSession) publishLocked(topic string, payload []byte, retain bool) error {
+	if err := s.client.Publish(topic, payload, byte(s.cfg.QoS), retain); err != nil {
+		s.client.close(err)
+		return err
+	}
+	return nil
+}
+
+// publishState 发布状态与属性（保留消息）
+func (s *mqttSession) publishState(st *mqttState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil || s.client.Closed() {
+		return errMQTTClosed
+	}
+	return s.publishStateLocked(st)
+}
+
+func (s *mqttSession) publishStateLocked(st *mqttState) error {
+	if err := s.publishLocked(s.topics.state, []byte(st.State), true); err != nil {
+		return err
+	}
+	attributes, _ := json.Marshal(st)
+	return s.publishLocked(s.topics.attributes, attributes, true)
+}
+
+// close 发布离线状态后正常断开，之后不再重连
+func (s *mqttSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.client == nil || s.client.Closed() {
+		return
+	}
+	s.client.Publish(s.topics.availability, []byte("offline"), byte(s.cfg.QoS), true)
+	s.client.Disconnect()
+	log.Printf("[MQTT] 已断开 %s", s.cfg.Broker)
+}
@@ -0,0 +1,352 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+)
+
+// 以下是仅支持发布的最小 MQTT 3.1.1 客户端：CONNECT（含遗嘱消息与用户名密码）、
+// QoS 0 / 1 的 PUBLISH、PINGREQ 保活与 DISCONNECT，不支持订阅。
+//
+// 未使用 paho 等第三方客户端：渠道只需发布，而第三方客户端自带的自动重连、离线队列与会话存储
+// 与 syncMQTT 按渠道配置管理连接的方式重复，连接仍需经 netconf 的代理隧道与证书建立；
+// 出站网络代码与 netconf 一样只依赖标准库，不为此引入新的依赖。
+
+// MQTT 控制报文类型
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPingreq    = 12
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+)
+
+// mqttTimeout 是 CONNACK、写入与等待 PUBACK 的默认超时时间（网络选项未设置整体超时时）
+const mqttTimeout = 10 * time.Second
+
+// mqttKeepAlive 是 CONNECT 中声明的保活间隔
+const mqttKeepAlive = 60 * time.Second
+
+var errMQTTClosed = errors.New("MQTT 连接已断开")
+
+// mqttConnackErrors 是 CONNACK 返回码的含义
+var mqttConnackErrors = map[byte]string{
+	1: "不支持的协议版本",
+	2: "客户端 ID 被拒绝",
+	3: "服务不可用",
+	4: "用户名或密码错误",
+	5: "未授权",
+}
+
+// mqttWill 是连接异常断开时由代理发布的遗嘱消息
+type mqttWill struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// mqttConnectOptions 是建立连接的参数
+type mqttConnectOptions struct {
+	Address  string          // host:port
+	TLS      bool            // 使用 TLS
+	Network  netconf.Options // 代理、CA 证书、客户端证书与超时
+	ClientID string
+	Username string
+	Password string
+	Will     *mqttWill
+}
+
+// mqttClient 是一条已建立的 MQTT 连接
+type mqttClient struct {
+	conn    net.Conn
+	timeout time.Duration // 写入与等待 PUBACK 的超时
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan struct{} // 等待 PUBACK 的报文标识符
+	closed  chan struct{}
+	err     error
+}
+
+// dialMQTT 按网络选项连接代理并完成 CONNECT / CONNACK 握手，随后启动读取与保活协程
+func dialMQTT(opts mqttConnectOptions) (*mqttClient, error) {
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		host, _, _ := net.SplitHostPort(opts.Address)
+		conn, err = opts.Network.DialTLS(opts.Address, host)
+	} else {
+		conn, err = opts.Network.Dial(opts.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接 MQTT 代理 %s 失败: %w", opts.Address, err)
+	}
+
+	timeout := opts.Network.TimeoutOr(mqttTimeout)
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(encodeMQTTConnect(opts)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送 CONNECT 失败: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	header, body, err := readMQTTPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取 CONNACK 失败: %w", err)
+	}
+	if header>>4 != mqttConnack || len(body) < 2 {
+		conn.Close()
+		return nil, fmt.Errorf("MQTT 代理返回了意外的报文类型 %d", header>>4)
+	}
+	if code := body[1]; code != 0 {
+		conn.Close()
+		if msg, ok := mqttConnackErrors[code]; ok {
+			return nil, fmt.Errorf("MQTT 代理拒绝连接: %s", msg)
+		}
+		return nil, fmt.Errorf("MQTT 代理拒绝连接，返回码 %d", code)
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &mqttClient{
+		conn:    conn,
+		timeout: timeout,
+		pending: make(map[uint16]chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop(r)
+	go c.pingLoop()
+	return c, nil
+}
+
+// Publish 发布一条消息；QoS 1 时等待代理的 PUBACK
+func (c *mqttClient) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	var id uint16
+	var acked chan struct{}
+	if qos > 0 {
+		qos = 1
+		c.mu.Lock()
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		acked = make(chan struct{})
+		c.pending[id] = acked
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.pending, id)
+			c.mu.Unlock()
+		}()
+	}
+
+	if err := c.write(encodeMQTTPublish(topic, payload, qos, retain, id)); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	select {
+	case <-acked:
+		return nil
+	case <-c.closed:
+		return c.closeErr()
+	case <-time.After(c.timeout):
+		return fmt.Errorf("等待 MQTT 代理确认超时")
+	}
+}
+
+// Disconnect 发送 DISCONNECT 后关闭连接（正常断开时代理不会发布遗嘱消息）
+func (c *mqttClient) Disconnect() {
+	c.write([]byte{mqttDisconnect << 4, 0})
+	c.close(errMQTTClosed)
+}
+
+// Closed 报告连接是否已断开
+func (c *mqttClient) Closed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *mqttClient) write(packet []byte) error {
+	if c.Closed() {
+		return c.closeErr()
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		c.close(err)
+		return fmt.Errorf("写入 MQTT 报文失败: %w", err)
+	}
+	return nil
+}
+
+func (c *mqttClient) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.closed)
+	c.conn.Close()
+}
+
+func (c *mqttClient) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil || c.err == errMQTTClosed {
+		return errMQTTClosed
+	}
+	return fmt.Errorf("%w: %v", errMQTTClosed, c.err)
+}
+
+func (c *mqttClient) readLoop(r *bufio.Reader) {
+	for {
+		// 每个保活周期内至少会收到一次 PINGRESP，超过 1.5 倍保活间隔无数据视为断开
+		c.conn.SetReadDeadline(time.Now().Add(mqttKeepAlive * 3 / 2))
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		if header>>4 == mqttPuback && len(body) >= 2 {
+			id := binary.BigEndian.Uint16(body)
+			c.mu.Lock()
+			if ch, ok := c.pending[id]; ok {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *mqttClient) pingLoop() {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if err := c.write([]byte{mqttPingreq << 4, 0}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// encodeMQTTConnect 编码 CONNECT 报文（协议 MQTT 3.1.1，clean session）
+func encodeMQTTConnect(opts mqttConnectOptions) []byte {
+	var payload []byte
+	payload = appendMQTTString(payload, opts.ClientID)
+
+	flags := byte(0x02) // clean session
+	if opts.Will != nil {
+		flags |= 0x04 | 0x08 // will flag，will QoS 1
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+		payload = appendMQTTString(payload, opts.Will.Topic)
+		payload = appendMQTTBytes(payload, opts.Will.Payload)
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendMQTTString(payload, opts.Username)
+		if opts.Password != "" {
+			flags |= 0x40
+			payload = appendMQTTString(payload, opts.Password)
+		}
+	}
+
+	var vh []byte
+	vh = appendMQTTString(vh, "MQTT")
+	vh = append(vh, 4, flags) // 协议级别 4 = 3.1.1
+	vh = binary.BigEndian.AppendUint16(vh, uint16(mqttKeepAlive/time.Second))
+	return encodeMQTTPacket(mqttConnect<<4, append(vh, payload...))
+}
+
+// encodeMQTTPublish 编码 PUBLISH 报文
+func encodeMQTTPublish(topic string, payload []byte, qos byte, retain bool, id uint16) []byte {
+	header := byte(mqttPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendMQTTString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return encodeMQTTPacket(header, append(body, payload...))
+}
+
+// encodeMQTTPacket 拼接固定报头（含剩余长度的变长编码）与报文体
+func encodeMQTTPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	return appendMQTTBytes(b, []byte(s))
+}
+
+func appendMQTTBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// readMQTTPacket 读取一个报文，返回固定报头首字节（高 4 位为报文类型）与报文体（可变报头 + 有效载荷）
+func readMQTTPacket(r *bufio.Reader) (header byte, body []byte, err error) {
+	header, err = r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, fmt.Errorf("MQTT 报文剩余长度无效")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
@@ -0,0 +1,381 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/detector"
+)
+
+// fakeBroker 是测试用的进程内 MQTT 代理：接受 CONNECT 并校验用户名密码，记录保留消息与普通消息，
+// 客户端未发送 DISCONNECT 就断开时发布其遗嘱消息。
+type fakeBroker struct {
+	t        *testing.T
+	ln       net.Listener
+	username string
+	password string
+
+	mu       sync.Mutex
+	retained map[string]string
+	messages []string // "topic payload"
+	clients  []string
+	conns    []net.Conn
+}
+
+func startFakeBroker(t *testing.T, username, password string) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, username: username, password: password, retained: make(map[string]string)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) addr() string { return "tcp://" + b.ln.Addr().String() }
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	header, body, err := readMQTTPacket(r)
+	if err != nil || header>>4 != mqttConnect {
+		return
+	}
+
+	// 解析 CONNECT：协议名、级别、标志、保活，之后为客户端 ID、遗嘱、用户名、密码
+	readString := func() string {
+		n := int(binary.BigEndian.Uint16(body))
+		s := string(body[2 : 2+n])
+		body = body[2+n:]
+		return s
+	}
+	readString()
+	flags := body[1]
+	body = body[4:]
+	clientID := readString()
+	var willTopic, willPayload string
+	if flags&0x04 != 0 {
+		willTopic, willPayload = readString(), readString()
+	}
+	var user, pass string
+	if flags&0x80 != 0 {
+		user = readString()
+	}
+	if flags&0x40 != 0 {
+		pass = readString()
+	}
+	if user != b.username || pass != b.password {
+		conn.Write([]byte{mqttConnack << 4, 2, 0, 4})
+		return
+	}
+	conn.Write([]byte{mqttConnack << 4, 2, 0, 0})
+	b.mu.Lock()
+	b.clients = append(b.clients, clientID)
+	b.mu.Unlock()
+
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			if willTopic != "" {
+				b.record(willTopic, willPayload, true)
+			}
+			return
+		}
+		switch header >> 4 {
+		case mqttPublish:
+			n := int(binary.BigEndian.Uint16(body))
+			topic := string(body[2 : 2+n])
+			rest := body[2+n:]
+			if qos := header >> 1 & 0x03; qos > 0 {
+				conn.Write([]byte{mqttPuback << 4, 2, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			b.record(topic, string(rest), header&0x01 != 0)
+		case mqttPingreq:
+			conn.Write([]byte{mqttPingresp << 4, 0})
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) record(topic, payload string, retain bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if retain {
+		b.retained[topic] = payload
+	}
+	b.messages = append(b.messages, topic+" "+payload)
+}
+
+func (b *fakeBroker) retainedValue(topic string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retained[topic]
+}
+
+// dropConnections 模拟网络中断：直接关闭代理侧的所有连接
+func (b *fakeBroker) dropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = nil
+}
+
+// waitFor 等待条件成立（代理在独立协程中处理报文）
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMQTTStateDiscoveryAndEvents(t *testing.T) {
+	broker := startFakeBroker(t, "ha", "secret")
+	n, _ := newTestNotifier(t)
+	if err := n.settings.SetDeviceName("财务 PC/1"); err != nil {
+		t.Fatal(err)
+	}
+	err := n.SaveChannels([]Channel{{
+		ID: "ha", Name: "Home Assistant", Type: "mqtt", Enabled: true,
+		Settings: map[string]interface{}{"broker": broker.addr(), "username": "ha", "password": "secret", "qos": 1, "discovery": true},
+		Filter:   ChannelFilter{Events: []string{EventRemoteStart, EventRemoteEnd}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.closeMQTT()
+	stop := make(chan struct{})
+	defer close(stop)
+	go n.mqttLoop(stop)
+
+	n.NotifyStateChange(detector.RemoteState{
+		Active:     true,
+		Since:      time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+		Signals:    []detector.Signal{{Name: "ToDesk (进程存在)", Tool: "ToDesk"}},
+		Confidence: 1,
+	})
+
+	base := "remoteknown/财务_PC_1"
+	waitFor(t, "状态主题为 active", func() bool { return broker.retainedValue(base+"/state") == "active" })
+	if got := broker.retainedValue(base + "/availability"); got != "online" {
+		t.Errorf("连接后应发布在线状态，实际 %q", got)
+	}
+	var attrs map[string]interface{}
+	json.Unmarshal([]byte(broker.retainedValue(base+"/attributes")), &attrs)
+	if attrs["start_time"] != "2026-10-18T09:00:00Z" || attrs["confidence"] != 1.0 || len(attrs["tools"].([]interface{})) != 1 {
+		t.Errorf("属性主题内容不正确: %v", attrs)
+	}
+
+	var discovery map[string]interface{}
+	json.Unmarshal([]byte(broker.retainedValue("homeassistant/binary_sensor/remoteknown____pc_1/remote_control/config")), &discovery)
+	if discovery["state_topic"] != base+"/state" || discovery["availability_topic"] != base+"/availability" || discovery["payload_on"] != "active" {
+		t.Errorf("自动发现配置不正确: %v", discovery)
+	}
+
+	n.NotifyRemoteEnd(detector.SessionEvent{SessionID: "s1", StartTime: time.Now().Add(-time.Minute), EndTime: time.Now()})
+	n.NotifyAppExit() // 未订阅，不应发布到事件主题
+	n.NotifyStateChange(detector.RemoteState{Since: time.Now()})
+	waitFor(t, "状态主题为 idle", func() bool { return broker.retainedValue(base+"/state") == "idle" })
+
+	broker.mu.Lock()
+	var events []string
+	for _, m := range broker.messages {
+		if strings.HasPrefix(m, base+"/event ") {
+			events = append(events, m)
+		}
+	}
+	clients := len(broker.clients)
+	broker.mu.Unlock()
+	if len(events) != 1 || !strings.Contains(events[0], `"type":"remote_end"`) {
+		t.Errorf("事件主题应只收到一条会话结束事件: %v", events)
+	}
+	if clients != 1 {
+		t.Errorf("多次发布应复用同一连接，实际建立 %d 次连接", clients)
+	}
+
+	// 网络中断后代理发布遗嘱消息，下次同步时重连并恢复在线状态
+	broker.dropConnections()
+	waitFor(t, "遗嘱消息", func() bool { return broker.retainedValue(base+"/availability") == "offline" })
+	waitFor(t, "客户端察觉断线", func() bool {
+		s, _ := n.mqttSessionFor(n.channelConfig(mustChannel(t, n, "ha")))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.client.Closed()
+	})
+	n.syncMQTT()
+	if got := broker.retainedValue(base + "/availability"); got != "online" {
+		t.Errorf("重连后应恢复在线状态，实际 %q", got)
+	}
+
+	// 正常退出时发布离线状态
+	n.closeMQTT()
+	waitFor(t, "离线状态", func() bool { return broker.retainedValue(base+"/availability") == "offline" })
+}
+
+// TestCloseMQTTStopsReconnect 验证 closeMQTT 与仍在运行的 MQTT 协程、发件箱并发时，关闭后不会重新连上代理：
+// 关闭前已取到连接的发布与之后的同步都返回 errMQTTClosed，代理上保留离线状态
+func TestCloseMQTTStopsReconnect(t *testing.T) {
+	broker := startFakeBroker(t, "", "")
+	n, _ := newTestNotifier(t)
+	n.SaveChannels([]Channel{{ID: "ha", Name: "Home Assistant", Type: "mqtt", Enabled: true,
+		Settings: map[string]interface{}{"broker": broker.addr()}}})
+	config := n.channelConfig(mustChannel(t, n, "ha"))
+	base := "remoteknown/" + n.getDeviceName()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		n.mqttLoop(stop)
+		close(done)
+	}()
+	waitFor(t, "在线状态", func() bool { return broker.retainedValue(base+"/availability") == "online" })
+	held, err := n.mqttSessionFor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				n.NotifyStateChange(detector.RemoteState{Active: (i+j)%2 == 0, Since: time.Now()})
+				n.sendMQTT(config, &Event{Type: EventTest})
+			}
+		}(i)
+	}
+	n.closeMQTT()
+	close(stop)
+	wg.Wait()
+	<-done
+
+	if err := held.publish(n, held.topics.event, []byte("{}"), false); err != errMQTTClosed {
+		t.Errorf("关闭前取到的连接不应重连，实际 %v", err)
+	}
+	if err := n.sendMQTT(config, &Event{Type: EventTest}); err != errMQTTClosed {
+		t.Errorf("关闭后发布应返回 errMQTTClosed，实际 %v", err)
+	}
+	n.syncMQTT()
+	waitFor(t, "离线状态", func() bool { return broker.retainedValue(base+"/availability") == "offline" })
+	broker.mu.Lock()
+	clients := len(broker.clients)
+	broker.mu.Unlock()
+	if clients != 1 {
+		t.Errorf("关闭后不应重新连接，实际建立 %d 次连接", clients)
+	}
+	n.mqttMu.Lock()
+	remaining := len(n.mqttSessions)
+	n.mqttMu.Unlock()
+	if remaining != 0 {
+		t.Errorf("关闭后不应保留连接，实际 %d 个", remaining)
+	}
+}
+
+// TestNotifyStateChangeDoesNotBlock 验证代理无响应时记录状态也立即返回（检测器持锁调用）
+func TestNotifyStateChangeDoesNotBlock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close() // 接受连接但从不响应 CONNACK
+		}
+	}()
+
+	n, _ := newTestNotifier(t)
+	n.SaveChannels([]Channel{{ID: "ha", Name: "Home Assistant", Type: "mqtt", Enabled: true,
+		Settings: map[string]interface{}{"broker": "tcp://" + ln.Addr().String()}}})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		n.NotifyStateChange(detector.RemoteState{Active: i%2 == 0, Since: time.Now()})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("记录状态不应等待代理，耗时 %v", elapsed)
+	}
+	if st := n.currentMQTTState(); st.State != "active" {
+		t.Errorf("应保留最新状态，实际 %q", st.State)
+	}
+}
+
+func TestMQTTRejectsBadCredentials(t *testing.T) {
+	broker := startFakeBroker(t, "ha", "secret")
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "mqtt", Settings: settingsOf(MQTTConfig{Broker: broker.addr(), Username: "ha", Password: "wrong"})}
+	err := n.deliver(cfg, &Event{Type: EventTest})
+	if err == nil || !strings.Contains(err.Error(), "用户名或密码错误") {
+		t.Errorf("密码错误时应返回代理的拒绝原因，实际 %v", err)
+	}
+}
+
+func TestMQTTAddress(t *testing.T) {
+	cases := []struct {
+		in   string
+		addr string
+		tls  bool
+	}{
+		{"192.168.1.2", "192.168.1.2:1883", false},
+		{"tcp://broker:1884", "broker:1884", false},
+		{"ssl://broker", "broker:8883", true},
+		{"mqtts://broker:9883", "broker:9883", true},
+	}
+	for _, c := range cases {
+		addr, useTLS, err := mqttAddress(c.in)
+		if err != nil || addr != c.addr || useTLS != c.tls {
+			t.Errorf("mqttAddress(%q) = %q, %v, %v", c.in, addr, useTLS, err)
+		}
+	}
+	for _, bad := range []string{"", "ws://broker", "tcp://"} {
+		if _, _, err := mqttAddress(bad); err == nil {
+			t.Errorf("mqttAddress(%q) 应报错", bad)
+		}
+	}
+}
+
+func mustChannel(t *testing.T, n *Notifier, id string) Channel {
+	t.Helper()
+	channels, err := n.LoadChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range channels {
+		if c.ID == id {
+			return c
+		}
+	}
+	t.Fatalf("渠道 %s 不存在", id)
+	return Channel{}
+}
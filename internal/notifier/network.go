@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/settings"
+)
+
+// networkSecretFields 返回 Options 中敏感字段（按 json 标签对应 netconf.SensitiveFields）的指针
+func networkSecretFields(o *netconf.Options) map[string]*string {
+	out := make(map[string]*string, len(netconf.SensitiveFields))
+	v := reflect.ValueOf(o).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		for _, f := range netconf.SensitiveFields {
+			if tag == f {
+				out[f] = v.Field(i).Addr().Interface().(*string)
+			}
+		}
+	}
+	return out
+}
+
+// sealNetwork 加密网络选项中的敏感字段（原地修改）；字段为掩码时沿用 previous 中已保存的密文
+func (n *Notifier) sealNetwork(o *netconf.Options, previous *netconf.Options) error {
+	var prev map[string]*string
+	if previous != nil {
+		prev = networkSecretFields(previous)
+	}
+	for field, p := range networkSecretFields(o) {
+		if *p == secret.Mask {
+			*p = ""
+			if prev != nil {
+				*p = *prev[field]
+			}
+			continue
+		}
+		sealed, err := n.seal(*p)
+		if err != nil {
+			return fmt.Errorf("加密 network.%s 失败: %w", field, err)
+		}
+		*p = sealed
+	}
+	return nil
+}
+
+// openNetwork 解密网络选项中的敏感字段（原地修改）
+func (n *Notifier) openNetwork(o *netconf.Options) {
+	for _, p := range networkSecretFields(o) {
+		if *p != "" {
+			*p = n.open(*p)
+		}
+	}
+}
+
+// maskNetwork 把网络选项中非空的敏感字段替换为掩码（原地修改）
+func maskNetwork(o *netconf.Options) {
+	for _, p := range networkSecretFields(o) {
+		if *p != "" {
+			*p = secret.Mask
+		}
+	}
+}
+
+// NetworkOptions 返回全局网络选项（敏感字段已解密）；未配置时为零值（直连、系统证书、默认超时）
+func (n *Notifier) NetworkOptions() netconf.Options {
+	var o netconf.Options
+	if n.settings == nil {
+		return o
+	}
+	n.settings.GetJSON(settings.KeyNetworkOptions, &o)
+	n.openNetwork(&o)
+	return o
+}
+
+// MaskedNetworkOptions 返回供 API 展示的全局网络选项（敏感字段为掩码）
+func (n *Notifier) MaskedNetworkOptions() (netconf.Options, error) {
+	var o netconf.Options
+	if err := n.settings.GetJSON(settings.KeyNetworkOptions, &o); err != nil {
+		return o, err
+	}
+	maskNetwork(&o)
+	return o, nil
+}
+
+// SaveNetworkOptions 校验并保存全局网络选项；敏感字段为掩码时沿用已保存的值
+func (n *Notifier) SaveNetworkOptions(o netconf.Options) error {
+	var previous netconf.Options
+	if err := n.settings.GetJSON(settings.KeyNetworkOptions, &previous); err != nil {
+		return err
+	}
+	if err := n.validateNetwork(o, &previous); err != nil {
+		return err
+	}
+	if err := n.sealNetwork(&o, &previous); err != nil {
+		return err
+	}
+	return n.settings.SetJSON(settings.KeyNetworkOptions, o)
+}
+
+// validateNetwork 用已保存的值替换掩码后校验网络选项（previous 为密文）
+func (n *Notifier) validateNetwork(o netconf.Options, previous *netconf.Options) error {
+	plain := o
+	var stored netconf.Options
+	if previous != nil {
+		stored = *previous
+		n.openNetwork(&stored)
+	}
+	storedFields := networkSecretFields(&stored)
+	for field, p := range networkSecretFields(&plain) {
+		if *p == secret.Mask {
+			*p = *storedFields[field]
+		}
+	}
+	return plain.Validate()
+}
+
+// httpClientFor 返回按网络选项配置的 HTTP 客户端
+func httpClientFor(opts netconf.Options) (*http.Client, error) {
+	c, err := opts.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("网络选项无效: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/secret"
+)
+
+// TestChannelNetworkSealedAndMerged 验证渠道网络选项的敏感字段加密落库、打码返回，
+// 回传掩码时保留原值，且发送时与全局选项合并。
+func TestChannelNetworkSealedAndMerged(t *testing.T) {
+	n, st := newTestNotifier(t)
+	if err := n.SaveNetworkOptions(netconf.Options{ProxyURL: "http://global:3128", ProxyPassword: "gpw", Timeout: 30}); err != nil {
+		t.Fatalf("保存全局网络选项失败: %v", err)
+	}
+
+	err := n.SaveChannels([]Channel{{
+		ID: "hook", Type: "webhook", Enabled: true,
+		Settings: map[string]interface{}{"url": "https://example.com/hook"},
+		Network:  &netconf.Options{ProxyURL: "socks5://local:1080", ProxyUsername: "u", ProxyPassword: "cpw"},
+	}})
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	raw, _ := st.GetConfig("notification_channels")
+	if strings.Contains(raw, "cpw") {
+		t.Fatalf("代理密码以明文落库: %s", raw)
+	}
+
+	channels, _ := n.LoadChannels()
+	MaskChannels(channels)
+	if got := channels[0].Network.ProxyPassword; got != secret.Mask {
+		t.Errorf("代理密码应被打码，实际 %q", got)
+	}
+	if err := n.SaveChannels(channels); err != nil {
+		t.Fatal(err)
+	}
+
+	channels, _ = n.LoadChannels()
+	cfg := n.channelConfig(channels[0])
+	if cfg.Network.ProxyURL != "socks5://local:1080" || cfg.Network.ProxyPassword != "cpw" || cfg.Network.Timeout != 30 {
+		t.Errorf("渠道选项应覆盖全局选项并保留原密码: %+v", cfg.Network)
+	}
+
+	masked, _ := n.MaskedNetworkOptions()
+	if masked.ProxyPassword != secret.Mask {
+		t.Errorf("全局代理密码应被打码，实际 %q", masked.ProxyPassword)
+	}
+}
+
+func TestSaveNetworkOptionsRejectsInvalid(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	if err := n.SaveNetworkOptions(netconf.Options{ProxyURL: "ftp://proxy:21"}); err == nil {
+		t.Errorf("不支持的代理协议应被拒绝")
+	}
+	err := n.SaveChannels([]Channel{{Type: "webhook", Network: &netconf.Options{ClientKey: "key"}}})
+	if err == nil || !strings.Contains(err.Error(), "网络选项") {
+		t.Errorf("渠道网络选项无效时应报错，实际 %v", err)
+	}
+}
@@ -2,35 +2,44 @@ package notifier
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"crypto/tls"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"mime"
 	"net"
 	"net/http"
 	"net/smtp"
-	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/settings"
 	"RemoteKnown/internal/storage"
 )
 
 var debugMode = os.Getenv("REMOTEKNOWN_DEBUG") != ""
 
-// NotificationConfig 通知配置
+// NotificationConfig 是发送一个渠道所需的配置：渠道类型、该类型的设置与网络选项。
+// 各渠道实现用 decode 把 Settings 反序列化为自己的设置结构。
 type NotificationConfig struct {
-	Enabled    bool        `json:"enabled"`
-	Type       string      `json:"type"`        // "feishu" / "dingtalk" / "email"
-	WebhookURL string      `json:"webhook_url"` // Webhook URL（飞书/钉钉）
-	Secret     string      `json:"secret"`      // 签名密钥（可选，飞书/钉钉）
-	Email      EmailConfig `json:"email"`       // 邮件配置（Type == "email" 时使用）
+	Enabled  bool                   `json:"enabled"`
+	Type     string                 `json:"type"`     // 渠道类型，即注册的 ChannelDriver.Type()
+	Settings map[string]interface{} `json:"settings"` // 渠道设置（敏感字段已解密），结构由渠道的 Schema 描述
+	Network  netconf.Options        `json:"network"`  // 网络选项（代理、证书、超时），为全局选项与渠道选项合并后的结果
+}
+
+// decode 把渠道设置按 json 标签反序列化到 target（渠道实现的设置结构）
+func (c NotificationConfig) decode(target interface{}) {
+	if b, err := json.Marshal(c.Settings); err == nil {
+		json.Unmarshal(b, target)
+	}
 }
 
 // EmailConfig SMTP 邮件通知配置
@@ -38,112 +47,167 @@ type EmailConfig struct {
 	SMTPHost   string `json:"smtp_host"`  // SMTP 服务器地址
 	SMTPPort   int    `json:"smtp_port"`  // SMTP 端口；0 表示按加密方式取默认端口
 	Encryption string `json:"encryption"` // 加密方式："none"(明文/内网) / "starttls" / "ssl"
-	Username   string `json:"username"`   // 认证用户名；留空表示匿名发送（内网常见）
+	AuthMode   string `json:"auth_mode"`  // 认证方式："password"(默认) / "oauth2"
+	Username   string `json:"username"`   // 认证用户名；留空表示匿名发送（内网常见）；oauth2 时为邮箱地址，留空取发件人
 	Password   string `json:"password"`   // 认证密码 / 授权码
 	From       string `json:"from"`       // 发件人地址
 	To         string `json:"to"`         // 收件人地址，多个用逗号/分号/空格分隔
+	Cc         string `json:"cc"`         // 抄送地址（可选），格式同收件人
+	Bcc        string `json:"bcc"`        // 密送地址（可选），不出现在邮件头中
+
+	// OAuth2（AuthMode == "oauth2"）：Microsoft 365 填租户 ID，Gmail 等填令牌地址（如 https://oauth2.googleapis.com/token）
+	OAuth2ClientID     string `json:"oauth2_client_id"`
+	OAuth2ClientSecret string `json:"oauth2_client_secret"`
+	OAuth2Tenant       string `json:"oauth2_tenant"`
+	OAuth2TokenURL     string `json:"oauth2_token_url"`
+	OAuth2RefreshToken string `json:"oauth2_refresh_token"`
+	OAuth2Scope        string `json:"oauth2_scope"` // 留空时 Microsoft 365 取 SMTP.Send 权限
 }
 
 // Notifier 通知器
 type Notifier struct {
-	storage *storage.Storage
+	storage  *storage.Storage
+	settings *settings.Store
+	box      *secret.Box // 敏感配置的加解密（密钥文件位于数据库之外）
+
+	stop       chan struct{} // 关闭以停止后台协程（发件箱投递、统计报告）
+	outboxWake chan struct{} // 手动重试或等待中的消息可以投递时唤醒投递协程
+	throttle   *throttle     // 各渠道的去重窗口与令牌桶
+
+	mqttMu       sync.Mutex
+	mqttSessions map[string]*mqttSession // MQTT 渠道的长连接（按配置与设备名）
+	mqttClosed   bool                    // closeMQTT 后不再建立连接
+	mqttState    *mqttState              // 最近一次的检测状态
+	mqttWake     chan struct{}           // 检测状态变化时唤醒 MQTT 协程发布
+
+	oauthMu     sync.Mutex
+	oauthTokens map[string]*oauth2Token // 邮件 XOAUTH2 的访问令牌缓存
 }
 
 // NewNotifier 创建新的通知器
-func NewNotifier(storage *storage.Storage) *Notifier {
-	return &Notifier{
-		storage: storage,
-	}
-}
+func NewNotifier(storage *storage.Storage, box *secret.Box) *Notifier {
+	n := &Notifier{
+		storage:  storage,
+		settings: settings.New(storage),
+		box:      box,
 
-// NotifyRemoteStart 通知远程控制开始
-func (n *Notifier) NotifyRemoteStart(signals []detector.NotifierSignal) {
-	config, err := n.getConfig()
-	if err != nil || !config.Enabled {
-		log.Printf("[通知器] 通知未启用或配置读取失败")
-		return
+		outboxWake: make(chan struct{}, 1),
+		mqttWake:   make(chan struct{}, 1),
 	}
-
-	signalNames := make([]string, len(signals))
-	for i, sig := range signals {
-		signalNames[i] = sig.GetName()
+	n.throttle = newThrottle(n.sendSummary)
+	if err := n.sealStoredSecrets(); err != nil {
+		log.Printf("[通知器] 加密已有通知密钥失败: %v", err)
 	}
+	return n
+}
 
-	title := "⚠️ 远程控制检测告警"
-	content := fmt.Sprintf("主机：%s\n\n检测到远程控制连接已建立\n\n检测信号：\n%s\n\n时间：%s",
-		n.getDeviceName(),
-		strings.Join(signalNames, "\n"),
-		time.Now().Format("2006-01-02 15:04:05"))
+// Start 启动后台协程：发件箱投递（守护进程重启后继续投递未完成的消息）与定期统计报告
+func (n *Notifier) Start() {
+	n.stop = make(chan struct{})
+	n.mqttMu.Lock()
+	n.mqttClosed = false
+	n.mqttMu.Unlock()
+	go n.outboxLoop(n.stop)
+	go n.digestLoop(n.stop)
+	go n.mqttLoop(n.stop)
+	log.Printf("[通知器] 发件箱投递、统计报告与 MQTT 协程已启动")
+}
 
-	if err := n.sendNotification(config, title, content); err != nil {
-		log.Printf("[通知器] 发送远程开始通知失败: %v", err)
-	} else {
-		log.Printf("[通知器] 远程开始通知已发送")
+// Stop 停止后台协程（未完成的消息留在发件箱中，下次启动后继续）与去重窗口的计时器，并以离线状态断开 MQTT 连接
+func (n *Notifier) Stop() {
+	if n.stop != nil {
+		close(n.stop)
+		n.stop = nil
 	}
+	n.throttle.stop()
+	n.closeMQTT()
+}
+
+// NotifyRemoteStart 通知远程控制开始
+func (n *Notifier) NotifyRemoteStart(se detector.SessionEvent) {
+	ev := n.newEvent(EventRemoteStart, "", "")
+	ev.setSession(se)
+	n.setAuthorized(ev)
+	n.dispatch(ev)
 }
 
 // NotifyRemoteEnd 通知远程控制结束
-func (n *Notifier) NotifyRemoteEnd(signals []detector.NotifierSignal) {
-	config, err := n.getConfig()
-	if err != nil || !config.Enabled {
-		log.Printf("[通知器] 通知未启用或配置读取失败")
-		return
-	}
+func (n *Notifier) NotifyRemoteEnd(se detector.SessionEvent) {
+	ev := n.newEvent(EventRemoteEnd, "", "")
+	ev.setSession(se)
+	n.setAuthorized(ev)
+	n.dispatch(ev)
+}
 
-	signalNames := make([]string, len(signals))
-	for i, sig := range signals {
-		signalNames[i] = sig.GetName()
-	}
+// NotifyRemoteReminder 提醒远程控制会话仍在进行
+func (n *Notifier) NotifyRemoteReminder(se detector.SessionEvent) {
+	ev := n.newEvent(EventRemoteReminder, "", "")
+	ev.setSession(se)
+	n.setAuthorized(ev)
+	n.dispatch(ev)
+}
 
-	title := "✅ 远程控制已断开"
-	content := fmt.Sprintf("主机：%s\n\n远程控制会话已结束\n\n上次检测信号：\n%s\n\n时间：%s",
-		n.getDeviceName(),
-		strings.Join(signalNames, "\n"),
-		time.Now().Format("2006-01-02 15:04:05"))
+// NotifyRemoteEscalation 会话持续超过升级阈值：除订阅该事件的渠道外，还发送到配置的升级渠道
+func (n *Notifier) NotifyRemoteEscalation(se detector.SessionEvent) {
+	ev := n.newEvent(EventRemoteEscalation, "", "")
+	ev.setSession(se)
+	n.setAuthorized(ev)
 
-	if err := n.sendNotification(config, title, content); err != nil {
-		log.Printf("[通知器] 发送远程结束通知失败: %v", err)
-	} else {
-		log.Printf("[通知器] 远程结束通知已发送")
+	force := make(map[string]bool)
+	for _, id := range n.settings.SessionEscalationChannels() {
+		force[id] = true
 	}
+	n.dispatchTo(ev, force)
+}
+
+// NotifyAppStart 通知守护进程已启动
+func (n *Notifier) NotifyAppStart() {
+	n.dispatch(n.newEvent(EventAppStart, "", ""))
 }
 
 // NotifyAppExit 通知应用退出
 func (n *Notifier) NotifyAppExit() {
-	config, err := n.getConfig()
-	if err != nil || !config.Enabled {
-		log.Printf("[通知器] 通知未启用或配置读取失败")
-		return
-	}
+	n.dispatch(n.newEvent(EventAppExit, "", ""))
+}
 
-	title := "🔴 RemoteKnown 服务已退出"
-	content := fmt.Sprintf("主机：%s\n\nRemoteKnown 守护进程已停止运行\n\n时间：%s",
-		n.getDeviceName(),
-		time.Now().Format("2006-01-02 15:04:05"))
+// NotifyRulesUpdated 通知检测规则已更新（应用 / 导入 / 回滚）
+func (n *Notifier) NotifyRulesUpdated(version, source string) {
+	ev := n.newEvent(EventRulesUpdated, "", "")
+	ev.RulesVersion = version
+	ev.RulesSource = source
+	n.dispatch(ev)
+}
 
-	if err := n.sendNotification(config, title, content); err != nil {
-		log.Printf("[通知器] 发送应用退出通知失败: %v", err)
-	} else {
-		log.Printf("[通知器] 应用退出通知已发送")
+// setAuthorized 按会话记录判断会话是否已被用户确认（知悉）
+func (n *Notifier) setAuthorized(ev *Event) {
+	if n.storage == nil || ev.SessionID == "" {
+		return
+	}
+	if session, err := n.storage.GetSession(ev.SessionID); err == nil && session != nil {
+		ev.Authorized = session.AcknowledgedAt != nil
 	}
 }
 
 // SendTestNotification 发送测试通知
 func (n *Notifier) SendTestNotification(config NotificationConfig) error {
-	title := "🧪 测试通知"
-	content := fmt.Sprintf("主机：%s\n\n这是一条测试通知\n\n通知类型：%s\n发送时间：%s",
-		n.getDeviceName(),
+	ev := n.newEvent(EventTest, "🧪 测试通知", "")
+	ev.Content = fmt.Sprintf("主机：%s\n\n这是一条测试通知\n\n通知类型：%s\n发送时间：%s",
+		ev.DeviceName,
 		config.Type,
-		time.Now().Format("2006-01-02 15:04:05"))
+		ev.Time.Format("2006-01-02 15:04:05"))
+
+	// 填充示例会话信息，便于调试自定义模板
+	ev.setSession(sampleSession(ev.Time))
 
-	return n.sendNotification(config, title, content)
+	return n.deliver(config, ev)
 }
 
 // getDeviceName 获取设备标识名，优先用用户配置，未配置则返回主机名
 func (n *Notifier) getDeviceName() string {
-	name, err := n.storage.GetConfig("device_name")
-	if err == nil && name != "" {
-		return name
+	if n.settings != nil {
+		if name := n.settings.DeviceName(); name != "" {
+			return name
+		}
 	}
 	if hostname, err := os.Hostname(); err == nil {
 		return hostname
@@ -151,197 +215,57 @@ func (n *Notifier) getDeviceName() string {
 	return "未知主机"
 }
 
-// getConfig 从存储中获取通知配置
-func (n *Notifier) getConfig() (NotificationConfig, error) {
-	var config NotificationConfig
-
-	// 尝试读取新格式的配置
-	allConfigJSON, err := n.storage.GetConfig("notification_configs")
-	if err != nil {
-		return config, err
-	}
-
-	// 如果新格式不存在，尝试读取旧格式
-	if allConfigJSON == "" {
-		oldConfigJSON, err := n.storage.GetConfig("notification_config")
-		if err != nil || oldConfigJSON == "" {
-			return config, fmt.Errorf("未找到通知配置")
-		}
-
-		// 解析旧格式配置
-		var oldConfig map[string]interface{}
-		if err := json.Unmarshal([]byte(oldConfigJSON), &oldConfig); err != nil {
-			return config, err
-		}
-
-		// 从旧格式读取配置
-		if enabled, ok := oldConfig["enabled"].(bool); ok {
-			config.Enabled = enabled
-		}
-		if t, ok := oldConfig["type"].(string); ok {
-			config.Type = t
-		}
-		if webhookURL, ok := oldConfig["webhook_url"].(string); ok {
-			config.WebhookURL = webhookURL
-		}
-		if secret, ok := oldConfig["secret"].(string); ok {
-			config.Secret = secret
-		}
-
-		return config, nil
-	}
-
-	// 解析新格式的完整配置
-	var allConfigs map[string]interface{}
-	if err := json.Unmarshal([]byte(allConfigJSON), &allConfigs); err != nil {
-		return config, err
-	}
-
-	// 获取基本配置
-	if enabled, ok := allConfigs["enabled"].(bool); ok {
-		config.Enabled = enabled
-	}
-
-	currentType := "feishu"
-	if t, ok := allConfigs["type"].(string); ok {
-		currentType = t
-		config.Type = currentType
-	}
-
-	// 获取对应类型的具体配置
-	if currentType == "email" {
-		// 邮件配置：借助 json 标签直接反序列化到 EmailConfig
-		if raw, ok := allConfigs["email"]; ok {
-			if b, err := json.Marshal(raw); err == nil {
-				json.Unmarshal(b, &config.Email)
-			}
-		}
-	} else if typeConfig, ok := allConfigs[currentType].(map[string]interface{}); ok {
-		if webhookURL, ok := typeConfig["webhook_url"].(string); ok {
-			config.WebhookURL = webhookURL
-		}
-		if secret, ok := typeConfig["secret"].(string); ok {
-			config.Secret = secret
-		}
-	}
-
-	return config, nil
-}
-
-// sendNotification 发送通知
+// sendNotification 以给定标题和正文发送通知
 func (n *Notifier) sendNotification(config NotificationConfig, title, content string) error {
-	switch config.Type {
-	case "feishu":
-		return n.sendFeishuNotification(config, title, content)
-	case "dingtalk":
-		return n.sendDingtalkNotification(config, title, content)
-	case "email":
-		return n.sendEmailNotification(config, title, content)
-	default:
-		return fmt.Errorf("不支持的通知类型: %s", config.Type)
-	}
+	return n.deliver(config, n.newEvent(EventTest, title, content))
 }
 
-// sendFeishuNotification 发送飞书通知
-func (n *Notifier) sendFeishuNotification(config NotificationConfig, title, content string) error {
-	// 构建飞书消息体
-	message := map[string]interface{}{
-		"msg_type": "interactive",
-		"card": map[string]interface{}{
-			"header": map[string]interface{}{
-				"title": map[string]interface{}{
-					"tag":     "plain_text",
-					"content": title,
-				},
-				"template": getFeishuColor(title),
-			},
-			"elements": []map[string]interface{}{
-				{
-					"tag": "div",
-					"text": map[string]interface{}{
-						"tag":     "plain_text",
-						"content": content,
-					},
-				},
-			},
-		},
-	}
-
-	// 如果配置了签名密钥，添加签名
-	if config.Secret != "" {
-		timestamp := time.Now().Unix()
-		sign := generateFeishuSign(config.Secret, timestamp)
-		message["timestamp"] = fmt.Sprintf("%d", timestamp)
-		message["sign"] = sign
-	}
-
-	if debugMode {
-		if raw, err := json.Marshal(message); err == nil {
-			log.Printf("[通知器] 飞书消息原始内容: %s", string(raw))
+// deliver 按渠道类型发送事件
+func (n *Notifier) deliver(config NotificationConfig, ev *Event) error {
+	if ev.Digest != nil {
+		if handled, err := n.deliverDigest(config, ev); handled {
+			return err
 		}
 	}
-	return n.postWebhook(config.WebhookURL, message)
+	d := channelDriver(config.Type)
+	if d == nil {
+		return fmt.Errorf("不支持的通知类型: %s", config.Type)
+	}
+	return d.Send(n, config, ev)
 }
 
-// sendDingtalkNotification 发送钉钉通知
-func (n *Notifier) sendDingtalkNotification(config NotificationConfig, title, content string) error {
-	message := map[string]interface{}{
-		"msgtype": "markdown",
-		"markdown": map[string]interface{}{
-			"title": title,
-			"text":  fmt.Sprintf("### %s\n\n%s", title, content),
-		},
+// truncateUTF8 把 s 截断到不超过 limit 字节，且不截断多字节字符
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
 	}
-
-	webhookURL := config.WebhookURL
-
-	// 如果配置了签名密钥，将签名和时间戳附加到URL
-	if config.Secret != "" {
-		timestamp := time.Now().UnixMilli()
-		sign := generateDingtalkSign(config.Secret, timestamp)
-		webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s", config.WebhookURL, timestamp, url.QueryEscape(sign))
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
 	}
-
-	return n.postWebhook(webhookURL, message)
+	return s[:limit]
 }
 
-// sendEmailNotification 通过 SMTP 发送邮件通知
+// sendEmailNotification 通过 SMTP 发送纯文本邮件通知
 func (n *Notifier) sendEmailNotification(config NotificationConfig, title, content string) error {
-	e := config.Email
-	if e.SMTPHost == "" {
-		return fmt.Errorf("SMTP 服务器地址不能为空")
-	}
-	if e.From == "" {
-		return fmt.Errorf("发件人地址不能为空")
-	}
-
-	recipients := parseRecipients(e.To)
-	if len(recipients) == 0 {
-		return fmt.Errorf("收件人地址不能为空")
-	}
+	return n.sendEmail(config, emailMessage{Subject: title, Text: content})
+}
 
-	// 端口为 0 时按加密方式取默认端口
-	port := e.SMTPPort
-	if port == 0 {
-		switch strings.ToLower(e.Encryption) {
-		case "ssl":
-			port = 465
-		case "starttls":
-			port = 587
-		default:
-			port = 25
-		}
+// sendEmail 发送邮件：发件人、收件人与抄送取自配置，未指定 Message-ID 时自动生成
+func (n *Notifier) sendEmail(config NotificationConfig, m emailMessage) error {
+	e := emailSettings(config)
+	m, recipients, err := composeEmail(e, m)
+	if err != nil {
+		return err
 	}
-	addr := net.JoinHostPort(e.SMTPHost, fmt.Sprintf("%d", port))
-
-	msg := buildEmailMessage(e.From, recipients, title, content)
+	addr := e.addr()
+	msg := buildEmailMessage(m)
 
 	if debugMode {
 		log.Printf("[通知器] 发送邮件: addr=%s 加密=%s 收件人=%v 认证=%v",
 			addr, e.Encryption, recipients, e.Username != "")
 	}
 
-	client, err := dialSMTP(addr, e)
+	client, err := dialSMTP(addr, e, config.Network)
 	if err != nil {
 		return err
 	}
@@ -350,9 +274,13 @@ func (n *Notifier) sendEmailNotification(config NotificationConfig, title, conte
 	// STARTTLS：明文连接后升级到 TLS
 	if strings.EqualFold(e.Encryption, "starttls") {
 		if ok, _ := client.Extension("STARTTLS"); !ok {
-			return fmt.Errorf("服务器不支持 STARTTLS，请改用“不加密”或“SSL”")
+			return fmt.Errorf("服务器不支持 STARTTLS，%s", hintSMTPNoSTARTTLS)
+		}
+		tlsConfig, err := config.Network.TLSConfig(e.SMTPHost)
+		if err != nil {
+			return fmt.Errorf("网络选项无效: %w", err)
 		}
-		if err := client.StartTLS(&tls.Config{ServerName: e.SMTPHost}); err != nil {
+		if err := client.StartTLS(tlsConfig); err != nil {
 			return fmt.Errorf("STARTTLS 升级失败: %w", err)
 		}
 	}
@@ -362,19 +290,24 @@ func (n *Notifier) sendEmailNotification(config NotificationConfig, title, conte
 	// 强行 AUTH PLAIN 会得到 "504 Authentication mechanism not supported"）。
 	// 内网明文服务器若未通告可用机制，则跳过认证直接尝试匿名投递。
 	authenticated := false
-	if e.Username != "" {
+	if strings.EqualFold(e.AuthMode, EmailAuthOAuth2) {
+		if err := n.authXOAUTH2(client, e, config.Network); err != nil {
+			return err
+		}
+		authenticated = true
+	} else if e.Username != "" {
 		authOK, mechs := client.Extension("AUTH")
 		_, isTLS := client.TLSConnectionState()
 		auth := chooseSMTPAuth(mechs, e, isTLS)
 		switch {
 		case authOK && auth != nil:
 			if err := client.Auth(auth); err != nil {
-				return fmt.Errorf("SMTP 认证失败（请检查用户名/密码；QQ/163/Gmail 等公网邮箱密码必须用“授权码”而非登录密码）: %w", err)
+				return fmt.Errorf("SMTP 认证失败（%s）: %w", hintSMTPPassword, err)
 			}
 			authenticated = true
 		case isTLS:
 			// 加密连接却拿不到可用 AUTH 机制，通常是服务器/配置问题
-			return fmt.Errorf("服务器未提供可用的 AUTH 认证机制（通告: %q）。公网邮箱请确认加密方式为 SSL(465) 或 STARTTLS(587)", mechs)
+			return fmt.Errorf("服务器未提供可用的 AUTH 认证机制（通告: %q）。%s", mechs, hintSMTPEncryption)
 		default:
 			// 内网明文：服务器未通告可用 AUTH 机制，跳过认证直接尝试匿名投递（内网中继常见）
 			log.Printf("[通知器] 服务器未通告可用 AUTH 机制(%q)，跳过认证尝试匿名发送", mechs)
@@ -384,7 +317,7 @@ func (n *Notifier) sendEmailNotification(config NotificationConfig, title, conte
 	if err := client.Mail(e.From); err != nil {
 		// 多数公网邮箱要求先登录才能发信（如 503 need AUTH first）
 		if !authenticated {
-			return fmt.Errorf("设置发件人失败，服务器要求先通过认证。请确认用户名/密码正确；公网邮箱需将加密方式选为 SSL(465) 或 STARTTLS(587) 并使用“授权码”: %w", err)
+			return fmt.Errorf("设置发件人失败，服务器要求先通过认证。%s: %w", hintSMTPNeedAuth, err)
 		}
 		return fmt.Errorf("设置发件人失败: %w", err)
 	}
@@ -409,25 +342,74 @@ func (n *Notifier) sendEmailNotification(config NotificationConfig, title, conte
 	return client.Quit()
 }
 
-// dialSMTP 根据加密方式建立 SMTP 连接：ssl 走隐式 TLS，其余先明文连接（starttls 稍后升级）
-func dialSMTP(addr string, e EmailConfig) (*smtp.Client, error) {
+// composeEmail 按配置填写邮件的发件人、收件人与抄送（未指定 Message-ID 时自动生成），
+// 返回填好的邮件与 SMTP 信封收件人（收件人、抄送与密送）
+func composeEmail(e EmailConfig, m emailMessage) (emailMessage, []string, error) {
+	if e.SMTPHost == "" {
+		return m, nil, fmt.Errorf("SMTP 服务器地址不能为空")
+	}
+	if e.From == "" {
+		return m, nil, fmt.Errorf("发件人地址不能为空")
+	}
+
+	to := parseRecipients(e.To)
+	if len(to) == 0 {
+		return m, nil, fmt.Errorf("收件人地址不能为空")
+	}
+	cc := parseRecipients(e.Cc)
+	recipients := append(append(append([]string{}, to...), cc...), parseRecipients(e.Bcc)...)
+
+	m.From, m.To, m.Cc = e.From, to, cc
+	if m.MessageID == "" {
+		m.MessageID = newMessageID(e.From)
+	}
+	return m, recipients, nil
+}
+
+// SMTP 常见问题的处理建议（发送失败与连通性诊断共用）
+const (
+	hintSMTPPassword   = "请检查用户名/密码；QQ/163/Gmail 等公网邮箱密码必须用“授权码”而非登录密码"
+	hintSMTPEncryption = "公网邮箱请确认加密方式为 SSL(465) 或 STARTTLS(587)"
+	hintSMTPNeedAuth   = "请确认用户名/密码正确；公网邮箱需将加密方式选为 SSL(465) 或 STARTTLS(587) 并使用“授权码”"
+	hintSMTPNoSTARTTLS = "请改用“不加密”或“SSL”"
+)
+
+// addr 返回 SMTP 服务器地址；端口为 0 时按加密方式取默认端口
+func (e EmailConfig) addr() string {
+	port := e.SMTPPort
+	if port == 0 {
+		switch strings.ToLower(e.Encryption) {
+		case "ssl":
+			port = 465
+		case "starttls":
+			port = 587
+		default:
+			port = 25
+		}
+	}
+	return net.JoinHostPort(e.SMTPHost, strconv.Itoa(port))
+}
+
+// smtpDefaultTimeout 是一次 SMTP 投递（连接、认证与发送）的默认整体超时
+const smtpDefaultTimeout = 60 * time.Second
+
+// dialSMTP 根据加密方式建立 SMTP 连接（按网络选项经代理、使用自定义证书）：ssl 走隐式 TLS，其余先明文连接（starttls 稍后升级）。
+// 整个会话受网络选项的整体超时约束，避免服务器无响应时一直阻塞。
+func dialSMTP(addr string, e EmailConfig, opts netconf.Options) (*smtp.Client, error) {
+	var conn net.Conn
+	var err error
 	if strings.EqualFold(e.Encryption, "ssl") {
-		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{ServerName: e.SMTPHost})
+		conn, err = opts.DialTLS(addr, e.SMTPHost)
 		if err != nil {
 			return nil, fmt.Errorf("SSL 连接 SMTP 服务器失败: %w", err)
 		}
-		client, err := smtp.NewClient(conn, e.SMTPHost)
+	} else {
+		conn, err = opts.Dial(addr)
 		if err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("创建 SMTP 客户端失败: %w", err)
+			return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
 		}
-		return client, nil
-	}
-
-	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
-	if err != nil {
-		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
 	}
+	conn.SetDeadline(time.Now().Add(opts.TimeoutOr(smtpDefaultTimeout)))
 	client, err := smtp.NewClient(conn, e.SMTPHost)
 	if err != nil {
 		conn.Close()
@@ -436,30 +418,6 @@ func dialSMTP(addr string, e EmailConfig) (*smtp.Client, error) {
 	return client, nil
 }
 
-// buildEmailMessage 构建符合 RFC 822 的纯文本邮件（主题/正文按 UTF-8 编码，避免中文乱码）
-func buildEmailMessage(from string, to []string, subject, body string) []byte {
-	var buf bytes.Buffer
-	buf.WriteString("From: " + from + "\r\n")
-	buf.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
-	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
-	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
-	buf.WriteString("MIME-Version: 1.0\r\n")
-	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
-	buf.WriteString("\r\n")
-
-	// 正文用 base64 编码，按 RFC 2045 每 76 字符换行
-	encoded := base64.StdEncoding.EncodeToString([]byte(body))
-	for i := 0; i < len(encoded); i += 76 {
-		end := i + 76
-		if end > len(encoded) {
-			end = len(encoded)
-		}
-		buf.WriteString(encoded[i:end] + "\r\n")
-	}
-	return buf.Bytes()
-}
-
 // parseRecipients 解析收件人列表，支持逗号/分号/空白分隔
 func parseRecipients(s string) []string {
 	fields := strings.FieldsFunc(s, func(r rune) bool {
@@ -540,71 +498,80 @@ func (a *loginAuth) Next(_ []byte, more bool) ([]byte, error) {
 	}
 }
 
-// postWebhook 发送 webhook 请求
-func (n *Notifier) postWebhook(url string, data interface{}) error {
+// urlSecretPatterns 匹配 URL 路径中的密钥段（Telegram 机器人 token、Server酱 SendKey），用于日志脱敏
+var urlSecretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`/bot[^/]+/`), "/bot***/"},
+	{regexp.MustCompile(`/[^/?\s]+\.send\b`), "/***.send"},
+}
+
+// redactURL 隐藏 URL 路径中的密钥，避免写入日志
+func redactURL(u string) string {
+	for _, p := range urlSecretPatterns {
+		u = p.re.ReplaceAllString(u, p.repl)
+	}
+	return u
+}
+
+// postJSON 以 JSON 发送 POST 请求（按网络选项使用代理、证书与超时），header 为额外的请求头（可为 nil），
+// 返回状态码与响应正文，由各渠道自行判断是否成功。
+func (n *Notifier) postJSON(opts netconf.Options, url string, header map[string]string, data interface{}) (int, []byte, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("序列化消息失败: %w", err)
+		return 0, nil, fmt.Errorf("序列化消息失败: %w", err)
 	}
-
-	log.Printf("[通知器] 发送 Webhook 请求到: %s", url)
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	client, err := httpClientFor(opts)
 	if err != nil {
-		return fmt.Errorf("发送 HTTP 请求失败: %w", err)
+		return 0, nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP 请求失败，状态码: %d", resp.StatusCode)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, nil, fmt.Errorf("创建请求失败: %s", redactURL(err.Error()))
 	}
-
-	// 读取响应
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("[通知器] 解析响应失败: %v", err)
-		return nil // 忽略解析错误，因为通知可能已经发送成功
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
 	}
 
-	log.Printf("[通知器] Webhook 响应: %+v", result)
+	log.Printf("[通知器] 发送 Webhook 请求到: %s", redactURL(url))
 
-	// 检查飞书响应
-	if code, ok := result["code"].(float64); ok && code != 0 {
-		return fmt.Errorf("通知发送失败: %v", result["msg"])
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息包含完整 URL，需脱敏后再返回
+		return 0, nil, fmt.Errorf("发送 HTTP 请求失败: %s", redactURL(err.Error()))
 	}
+	defer resp.Body.Close()
 
-	// 检查钉钉响应
-	if errcode, ok := result["errcode"].(float64); ok && errcode != 0 {
-		return fmt.Errorf("通知发送失败: %v", result["errmsg"])
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("读取响应失败: %w", err)
 	}
-
-	return nil
+	return resp.StatusCode, body, nil
 }
 
-// generateFeishuSign 生成飞书签名
-func generateFeishuSign(secret string, timestamp int64) string {
-	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
-	h := hmac.New(sha256.New, []byte(stringToSign))
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+// summarizeBody 截取响应正文用于错误信息
+func summarizeBody(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if s == "" {
+		return "(空)"
+	}
+	return truncateUTF8(s, 200)
 }
 
-// generateDingtalkSign 生成钉钉签名
-func generateDingtalkSign(secret string, timestamp int64) string {
-	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(stringToSign))
-	signData := h.Sum(nil)
-	return base64.StdEncoding.EncodeToString(signData)
-}
+// 飞书 / 钉钉 / 企业微信以 HTTP 200 + JSON 错误码表示结果；响应无法解析时按成功处理（消息可能已送达）
+var (
+	feishuSuccess  = successCondition{Statuses: []statusRange{{200, 200}}, JSONPath: "$.code", JSONValue: "0", MessagePath: "$.msg", Lenient: true}
+	errcodeSuccess = successCondition{Statuses: []statusRange{{200, 200}}, JSONPath: "$.errcode", JSONValue: "0", MessagePath: "$.errmsg", Lenient: true}
+)
 
-// getFeishuColor 根据标题获取飞书卡片颜色
-func getFeishuColor(title string) string {
-	if strings.Contains(title, "⚠️") || strings.Contains(title, "告警") {
-		return "red"
-	} else if strings.Contains(title, "✅") {
-		return "green"
-	} else if strings.Contains(title, "🔴") {
-		return "red"
+// postWebhook 发送 webhook 请求，并按 cond 判断是否成功
+func (n *Notifier) postWebhook(opts netconf.Options, url string, header map[string]string, data interface{}, cond successCondition) error {
+	status, body, err := n.postJSON(opts, url, header, data)
+	if err != nil {
+		return err
 	}
-	return "blue"
+	log.Printf("[通知器] Webhook 响应: %d %s", status, summarizeBody(body))
+	return cond.check(status, body)
 }
@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterChannel(opsgenieDriver{})
+}
+
+// OpsgenieConfig Opsgenie Alerts API 配置。会话事件以会话 ID 作为告警别名（alias）：
+// 会话开始 / 提醒 / 升级创建同一别名的告警（Opsgenie 按别名去重），会话结束时按别名关闭。
+// 不属于会话的事件创建后无法关闭，不发送到 Opsgenie。
+type OpsgenieConfig struct {
+	APIKey             string `json:"api_key"`             // API 集成的 API Key
+	APIBase            string `json:"api_base"`            // 留空使用 https://api.opsgenie.com；欧洲区为 https://api.eu.opsgenie.com，也可填本地替身服务地址
+	PriorityStart      string `json:"priority_start"`      // 会话开始的优先级：P1 ~ P5
+	PriorityReminder   string `json:"priority_reminder"`   // 会话持续提醒的优先级
+	PriorityEscalation string `json:"priority_escalation"` // 会话升级的优先级
+	PriorityOther      string `json:"priority_other"`      // 测试通知的优先级
+	Responders         string `json:"responders"`          // 接收告警的团队名称（可选），多个用逗号分隔
+	Tags               string `json:"tags"`                // 告警标签（可选），多个用逗号分隔
+}
+
+const defaultOpsgenieAPI = "https://api.opsgenie.com"
+
+var opsgeniePriorities = []interface{}{"P1", "P2", "P3", "P4", "P5"}
+
+// opsgenieDriver 是 Opsgenie 渠道
+type opsgenieDriver struct{}
+
+func (opsgenieDriver) Type() string { return "opsgenie" }
+
+func (opsgenieDriver) Schema() *Schema {
+	apiKey := field("API Key", "Teams / Settings → Integrations 中添加 API 集成后得到的 API Key")
+	apiKey.WriteOnly = true
+	apiBase := field("API 地址", "留空使用 "+defaultOpsgenieAPI+"，欧洲区填 https://api.eu.opsgenie.com")
+	apiBase.Format = "uri"
+	priority := func(title, def string) *Schema {
+		return &Schema{Type: "string", Title: title, Enum: opsgeniePriorities, Default: def}
+	}
+	return &Schema{
+		Type:        "object",
+		Title:       "Opsgenie",
+		Description: "远程会话开始时创建告警（alias 为会话 ID），会话结束时自动关闭",
+		Properties: map[string]*Schema{
+			"api_key":             apiKey,
+			"api_base":            apiBase,
+			"priority_start":      priority("会话开始的优先级", "P2"),
+			"priority_reminder":   priority("持续提醒的优先级", "P3"),
+			"priority_escalation": priority("会话升级的优先级", "P1"),
+			"priority_other":      priority("测试通知的优先级", "P5"),
+			"responders":          field("响应团队", "接收告警的团队名称，多个用逗号分隔"),
+			"tags":                field("标签", "多个用逗号分隔"),
+		},
+		Required: []string{"api_key"},
+		Order: []string{"api_key", "priority_start", "priority_reminder", "priority_escalation", "priority_other",
+			"responders", "tags", "api_base"},
+	}
+}
+
+func (opsgenieDriver) Validate(config NotificationConfig) error {
+	og := opsgenieSettings(config)
+	if strings.TrimSpace(og.APIKey) == "" {
+		return fmt.Errorf("API Key 不能为空")
+	}
+	if err := validateAPIBase(og.APIBase); err != nil {
+		return err
+	}
+	for _, p := range []string{og.PriorityStart, og.PriorityReminder, og.PriorityEscalation, og.PriorityOther} {
+		if p != "" && !containsFold([]string{"P1", "P2", "P3", "P4", "P5"}, p) {
+			return fmt.Errorf("优先级 %q 无效，应为 P1 ~ P5", p)
+		}
+	}
+	return nil
+}
+
+func (opsgenieDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	endpoint, body, ok := opsgenieRequest(config, ev)
+	if !ok {
+		log.Printf("[通知器] Opsgenie: %s 事件不属于任何会话，创建后无法关闭告警，已跳过", ev.Type)
+		return nil
+	}
+	return n.sendOpsgenie(config, endpoint, body)
+}
+
+// Test 创建测试告警后随即关闭，不在值班表中留下未处理的告警
+func (d opsgenieDriver) Test(n *Notifier, config NotificationConfig) error {
+	if err := d.Validate(config); err != nil {
+		return err
+	}
+	if err := n.SendTestNotification(config); err != nil {
+		return err
+	}
+	return n.sendOpsgenie(config, opsgenieCloseURL(opsgenieSettings(config), testIncidentKey(n.getDeviceName())), map[string]interface{}{
+		"source": "RemoteKnown",
+		"note":   "测试通知，自动关闭",
+	})
+}
+
+func (opsgenieDriver) Preview(_ *Notifier, config NotificationConfig, ev *Event) (*Preview, error) {
+	endpoint, body, ok := opsgenieRequest(config, ev)
+	if !ok {
+		return nil, fmt.Errorf("%s 事件不属于任何会话，不会发送到 Opsgenie", ev.Type)
+	}
+	return jsonPreview(config.Type, endpoint, body)
+}
+
+// SequenceKey 使同一会话的触发与关闭按顺序投递：触发失败重试期间关闭请求等待，不会留下无法关闭的事件
+func (opsgenieDriver) SequenceKey(ev *Event) string { return incidentKey(ev) }
+
+func (opsgenieDriver) Endpoint(config NotificationConfig) string {
+	return serverBase(opsgenieSettings(config).APIBase, defaultOpsgenieAPI)
+}
+
+// opsgenieSettings 返回渠道的 Opsgenie 设置
+func opsgenieSettings(config NotificationConfig) OpsgenieConfig {
+	var og OpsgenieConfig
+	config.decode(&og)
+	return og
+}
+
+// opsgenieRequest 返回 Alerts API 请求的地址与请求体：会话结束为按别名关闭，其余为创建告警。
+// 事件没有别名（不属于任何会话）时创建的告警无法关闭，返回 false。
+func opsgenieRequest(config NotificationConfig, ev *Event) (string, map[string]interface{}, bool) {
+	og := opsgenieSettings(config)
+	alias := incidentKey(ev)
+	if alias == "" {
+		return "", nil, false
+	}
+	if ev.Type == EventRemoteEnd {
+		return opsgenieCloseURL(og, alias), map[string]interface{}{
+			"source": "RemoteKnown",
+			"note":   truncateUTF8(ev.Title+"\n\n"+ev.Content, 25000),
+		}, true
+	}
+
+	priority := og.PriorityOther
+	def := "P5"
+	switch ev.Type {
+	case EventRemoteStart:
+		priority, def = og.PriorityStart, "P2"
+	case EventRemoteReminder:
+		priority, def = og.PriorityReminder, "P3"
+	case EventRemoteEscalation:
+		priority, def = og.PriorityEscalation, "P1"
+	}
+	if priority == "" {
+		priority = def
+	}
+
+	details := make(map[string]string)
+	for k, v := range incidentDetails(ev) {
+		if k != "content" {
+			details[k] = fmt.Sprint(v)
+		}
+	}
+	body := map[string]interface{}{
+		"message":     truncateUTF8(ev.Title, 130),
+		"description": truncateUTF8(ev.Content, 15000),
+		"priority":    strings.ToUpper(priority),
+		"source":      "RemoteKnown",
+		"entity":      ev.DeviceName,
+		"alias":       alias,
+		"details":     details,
+	}
+	if tags := parseRecipients(og.Tags); len(tags) > 0 {
+		body["tags"] = tags
+	}
+	var responders []map[string]string
+	for _, team := range strings.Split(og.Responders, ",") {
+		if team = strings.TrimSpace(team); team != "" {
+			responders = append(responders, map[string]string{"type": "team", "name": team})
+		}
+	}
+	if len(responders) > 0 {
+		body["responders"] = responders
+	}
+	return serverBase(og.APIBase, defaultOpsgenieAPI) + "/v2/alerts", body, true
+}
+
+// opsgenieCloseURL 返回按别名关闭告警的地址
+func opsgenieCloseURL(og OpsgenieConfig, alias string) string {
+	return serverBase(og.APIBase, defaultOpsgenieAPI) + "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+}
+
+// sendOpsgenie 发送 Alerts API 请求（GenieKey 认证），接受后返回 202
+func (n *Notifier) sendOpsgenie(config NotificationConfig, endpoint string, body map[string]interface{}) error {
+	apiKey := opsgenieSettings(config).APIKey
+	return n.sendPush(pushRequest{
+		Service: "Opsgenie",
+		URL:     endpoint,
+		Secret:  apiKey,
+		Header:  map[string]string{"Authorization": "GenieKey " + apiKey},
+		Body:    body,
+		Network: config.Network,
+	})
+}
@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOpsgenieCreateAndClose 验证会话开始以会话 ID 为别名创建告警，会话结束时按别名关闭
+func TestOpsgenieCreateAndClose(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{"result":"Request will be processed","requestId":"r1"}`)
+	n, _ := newTestNotifier(t)
+	err := n.SaveChannels([]Channel{{ID: "og", Name: "值班", Type: "opsgenie", Enabled: true, Settings: map[string]interface{}{
+		"api_key": "genie-key", "api_base": fake.URL, "responders": "IT 运维, 安全", "tags": "remote,desktop",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se := liveSession("s-7")
+	n.NotifyRemoteStart(se)
+	se.EndTime = time.Now()
+	n.NotifyRemoteEnd(se)
+
+	got := fake.Requests()
+	if len(got) != 2 {
+		t.Fatalf("应发送创建与关闭两个请求，实际 %d", len(got))
+	}
+	create, closing := got[0], got[1]
+	if create.Path != "/v2/alerts" || create.Header.Get("Authorization") != "GenieKey genie-key" {
+		t.Errorf("创建告警请求不正确: %s %v", create.Path, create.Header)
+	}
+	if create.Body["alias"] != "s-7" || create.Body["priority"] != "P2" || create.Body["entity"] == "" {
+		t.Errorf("告警内容不正确: %v", create.Body)
+	}
+	if responders, _ := create.Body["responders"].([]interface{}); len(responders) != 2 {
+		t.Errorf("应有两个响应团队: %v", create.Body["responders"])
+	}
+	if details, _ := create.Body["details"].(map[string]interface{}); details["tools"] != "ToDesk" {
+		t.Errorf("告警明细应包含远程工具: %v", create.Body["details"])
+	}
+	if closing.Path != "/v2/alerts/s-7/close?identifierType=alias" || closing.Body["source"] != "RemoteKnown" {
+		t.Errorf("会话结束应按别名关闭告警: %s %v", closing.Path, closing.Body)
+	}
+}
+
+func TestOpsgenieEndWithoutSession(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{}`)
+	n, _ := newTestNotifier(t)
+	config := NotificationConfig{Type: "opsgenie", Settings: settingsOf(OpsgenieConfig{APIKey: "k", APIBase: fake.URL})}
+	if err := (opsgenieDriver{}).Send(n, config, n.newEvent(EventRemoteEnd, "远程控制结束", "")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Requests()) != 0 {
+		t.Errorf("没有会话 ID 时不应发送关闭请求")
+	}
+	if _, err := (opsgenieDriver{}).Preview(n, config, n.newEvent(EventRemoteEnd, "", "")); err == nil {
+		t.Errorf("没有会话 ID 时预览应说明不会关闭告警")
+	}
+}
+
+func TestOpsgenieRejected(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusUnprocessableEntity, `{"message":"Key format is not valid!"}`)
+	n, _ := newTestNotifier(t)
+	config := NotificationConfig{Type: "opsgenie", Settings: settingsOf(OpsgenieConfig{APIKey: "secret-genie", APIBase: fake.URL})}
+	ev := n.newEvent(EventRemoteStart, "检测到远程控制", "")
+	ev.SessionID = "s-1"
+	err := (opsgenieDriver{}).Send(n, config, ev)
+	if err == nil || !strings.Contains(err.Error(), "422") || strings.Contains(err.Error(), "secret-genie") {
+		t.Errorf("应返回脱敏后的错误，实际 %v", err)
+	}
+}
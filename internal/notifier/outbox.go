@@ -0,0 +1,341 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"RemoteKnown/internal/storage"
+)
+
+// 发件箱重试参数：第 n 次失败后等待 outboxBaseDelay * 2^(n-1)（上限 outboxMaxDelay），并加 ±20% 抖动
+const (
+	outboxBaseDelay    = 30 * time.Second
+	outboxMaxDelay     = time.Hour
+	outboxPollInterval = 10 * time.Second
+	outboxBatchSize    = 50
+	outboxClaimLease   = 5 * time.Minute      // 领取后在此时间内不会被再次取到；投递中途退出的消息在此之后重试
+	outboxSentKeep     = 30 * 24 * time.Hour  // 已投递消息保留时间
+	deliveryKeep       = 365 * 24 * time.Hour // 发送记录保留时间（审计留证，长于发件箱）
+)
+
+// retryAfterError 表示服务端限流并给出了可重试的时间（如 429 的 retry_after），发件箱不会早于该时间重试
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// retryAfter 返回错误中服务端要求的等待时间，没有时返回 0
+func retryAfter(err error) time.Duration {
+	var ra *retryAfterError
+	if errors.As(err, &ra) {
+		return ra.after
+	}
+	return 0
+}
+
+// permanentError 表示请求本身被服务端判为无效（如消息格式或长度不符合平台限制的 400），
+// 原样重试不会成功，发件箱直接标记为失败而不是重试到最长重试时间
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isPermanent 返回错误是否不可重试
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// deliveryProgress 记录一条通知中已送达的部分。一次 Send 需发出多个请求的渠道（Telegram 的多个会话、
+// 企业微信按手机号 @ 的追加消息）以 done 跳过已送达的部分、以 markDone 记录新送达的部分；
+// 部分失败时发件箱保存进度，重试只补发未送达的部分，已收到通知的接收方不会重复收到。
+// 方法对 nil 安全：不经发件箱直接发送（如测试通知）时不记录进度。
+type deliveryProgress struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+// done 报告 part 是否已送达
+func (p *deliveryProgress) done(part string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.sent {
+		if s == part {
+			return true
+		}
+	}
+	return false
+}
+
+// markDone 记录 part 已送达
+func (p *deliveryProgress) markDone(part string) {
+	if p == nil || p.done(part) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, part)
+}
+
+// parts 返回已送达的部分
+func (p *deliveryProgress) parts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.sent...)
+}
+
+// outboxBackoff 返回第 attempts 次失败后的重试等待时间
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseDelay
+	for i := 1; i < attempts && d < outboxMaxDelay; i++ {
+		d *= 2
+	}
+	if d > outboxMaxDelay {
+		d = outboxMaxDelay
+	}
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(float64(d) * jitter)
+}
+
+func (n *Notifier) outboxLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	n.processOutbox(time.Now())
+	n.pruneOutbox()
+	lastPrune := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-n.outboxWake:
+		}
+		n.processOutbox(time.Now())
+		if time.Since(lastPrune) > time.Hour {
+			n.pruneOutbox()
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// processOutbox 投递所有到期的待发消息
+func (n *Notifier) processOutbox(now time.Time) {
+	msgs, err := n.storage.DueOutbox(now, outboxBatchSize)
+	if err != nil {
+		log.Printf("[通知器] 读取发件箱失败: %v", err)
+		return
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	channels, err := n.LoadChannels()
+	if err != nil {
+		log.Printf("[通知器] 读取通知渠道失败: %v", err)
+		return
+	}
+	byID := make(map[string]Channel, len(channels))
+	for _, c := range channels {
+		byID[c.ID] = c
+	}
+
+	var wg sync.WaitGroup
+	for _, msg := range msgs {
+		c, ok := byID[msg.ChannelID]
+		switch {
+		case !ok:
+			n.storage.MarkOutboxFailed(msg.ID, msg.Attempts, "渠道已删除")
+			continue
+		case !c.Enabled:
+			n.storage.MarkOutboxFailed(msg.ID, msg.Attempts, "渠道已停用")
+			continue
+		}
+		wg.Add(1)
+		go func(msg storage.OutboxMessage, c Channel) {
+			defer wg.Done()
+			n.attemptOutbox(msg, c, now)
+		}(msg, c)
+	}
+	wg.Wait()
+}
+
+// ChannelSequencer 是需要按顺序投递的渠道实现的可选接口（如值班平台）：SequenceKey 相同的消息按创建顺序逐条投递，
+// 前一条仍待发（失败后等待重试）时后面的消息等待，避免关闭请求先于触发请求送达、留下无法关闭的事件。
+// 返回空串表示该事件不限顺序。
+type ChannelSequencer interface {
+	SequenceKey(ev *Event) string
+}
+
+// attemptOutbox 投递一条发件箱消息并记录结果；失败时按退避时间安排重试，超过最长重试时间则标记为失败。
+// 同一顺序键下还有更早的待发消息时不投递，留待其投递后再试。
+func (n *Notifier) attemptOutbox(msg storage.OutboxMessage, c Channel, now time.Time) {
+	held, err := n.storage.OutboxHeld(&msg)
+	if err != nil {
+		log.Printf("[通知器] 检查发件箱顺序失败: %v", err)
+		return
+	}
+	if held {
+		log.Printf("[通知器] 渠道「%s」(%s) 的 %s 通知等待同一事件的前一条消息投递后再发送", c.Name, c.Type, msg.EventType)
+		return
+	}
+
+	// 同一消息只投递一次：首次投递与投递协程（或另一个进程）可能同时取到它，以条件更新领取，只有一方成功
+	claimed, err := n.storage.ClaimOutbox(msg.ID, msg.Attempts, time.Now().Add(outboxClaimLease))
+	if err != nil {
+		log.Printf("[通知器] 领取发件箱消息失败: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	var ev Event
+	if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+		n.storage.MarkOutboxFailed(msg.ID, msg.Attempts, "消息内容损坏: "+err.Error())
+		return
+	}
+
+	delivered := msg.DeliveredParts()
+	ev.progress = &deliveryProgress{sent: delivered}
+	attempts := msg.Attempts + 1
+	err = n.deliverRecorded(c, &ev, msg.ID, attempts)
+	if err == nil {
+		log.Printf("[通知器] 渠道「%s」(%s) 已发送 %s 通知", c.Name, c.Type, ev.Type)
+		if err := n.storage.MarkOutboxSent(msg.ID, attempts); err != nil {
+			log.Printf("[通知器] 更新发件箱失败: %v", err)
+		}
+		if msg.OrderKey != "" {
+			n.wakeOutbox() // 同一顺序键下等待的消息可以投递了
+		}
+		return
+	}
+
+	if parts := ev.progress.parts(); len(parts) > len(delivered) {
+		if err := n.storage.SetOutboxDelivered(msg.ID, parts); err != nil {
+			log.Printf("[通知器] 更新发件箱失败: %v", err)
+		}
+	}
+	if isPermanent(err) {
+		log.Printf("[通知器] 渠道「%s」(%s) 的 %s 通知被服务端拒绝，重试不会成功，不再重试: %v", c.Name, c.Type, ev.Type, err)
+		n.storage.MarkOutboxFailed(msg.ID, attempts, err.Error())
+		return
+	}
+	next := now.Add(outboxBackoff(attempts))
+	if wait := retryAfter(err); now.Add(wait).After(next) {
+		next = now.Add(wait)
+	}
+	if next.Sub(msg.RetryWindowStart()) > n.settings.OutboxMaxAge() {
+		log.Printf("[通知器] 渠道「%s」(%s) 发送 %s 通知失败，已超过最长重试时间，不再重试: %v", c.Name, c.Type, ev.Type, err)
+		n.storage.MarkOutboxFailed(msg.ID, attempts, err.Error())
+		return
+	}
+	log.Printf("[通知器] 渠道「%s」(%s) 发送 %s 通知失败（第 %d 次），将于 %s 重试: %v",
+		c.Name, c.Type, ev.Type, attempts, next.Format("15:04:05"), err)
+	if err := n.storage.MarkOutboxRetry(msg.ID, attempts, err.Error(), next); err != nil {
+		log.Printf("[通知器] 更新发件箱失败: %v", err)
+	}
+}
+
+// deliverRecorded 向渠道发送事件，并把这次尝试（结果、耗时、错误）写入发送记录；
+// 成功的发送同时追加到审计链，作为告警已发出的凭证
+func (n *Notifier) deliverRecorded(c Channel, ev *Event, outboxID string, attempt int) error {
+	start := time.Now()
+	err := n.deliver(n.channelConfig(c), ev)
+	record := &storage.NotificationDelivery{
+		OutboxID:    outboxID,
+		ChannelID:   c.ID,
+		ChannelName: c.Name,
+		ChannelType: c.Type,
+		EventType:   ev.Type,
+		SessionID:   ev.SessionID,
+		Title:       ev.Title,
+		Attempt:     attempt,
+		Success:     err == nil,
+		LatencyMs:   time.Since(start).Milliseconds(),
+		CreatedAt:   start,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if rerr := n.storage.RecordDelivery(record); rerr != nil {
+		log.Printf("[通知器] 写入发送记录失败: %v", rerr)
+	}
+	if err == nil {
+		if _, aerr := n.storage.AppendAuditEvent(storage.AuditNotification, map[string]interface{}{
+			"delivery_id":  record.ID,
+			"channel_id":   c.ID,
+			"channel_type": c.Type,
+			"event_type":   ev.Type,
+			"session_id":   ev.SessionID,
+			"title":        ev.Title,
+		}); aerr != nil {
+			log.Printf("[通知器] 写入审计日志失败: %v", aerr)
+		}
+	}
+	return err
+}
+
+// enqueueOutbox 把渠道要发送的事件写入发件箱
+func (n *Notifier) enqueueOutbox(c Channel, ev *Event) (*storage.OutboxMessage, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	msg := &storage.OutboxMessage{
+		ChannelID:   c.ID,
+		ChannelName: c.Name,
+		ChannelType: c.Type,
+		EventType:   ev.Type,
+		Title:       ev.Title,
+		Payload:     string(payload),
+	}
+	if s, ok := channelDriver(c.Type).(ChannelSequencer); ok {
+		msg.OrderKey = s.SequenceKey(ev)
+	}
+	if err := n.storage.EnqueueOutbox(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// RetryOutboxMessage 手动重试一条消息：重新排入发件箱并唤醒投递协程
+func (n *Notifier) RetryOutboxMessage(id string) error {
+	if _, err := n.storage.RetryOutbox(id); err != nil {
+		return err
+	}
+	n.wakeOutbox()
+	return nil
+}
+
+// wakeOutbox 唤醒投递协程立即处理到期的消息
+func (n *Notifier) wakeOutbox() {
+	select {
+	case n.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Notifier) pruneOutbox() {
+	if count, err := n.storage.PruneOutbox(time.Now().Add(-outboxSentKeep)); err != nil {
+		log.Printf("[通知器] 清理发件箱失败: %v", err)
+	} else if count > 0 {
+		log.Printf("[通知器] 已清理 %d 条已投递的历史通知", count)
+	}
+	if count, err := n.storage.PruneDeliveries(time.Now().Add(-deliveryKeep)); err != nil {
+		log.Printf("[通知器] 清理发送记录失败: %v", err)
+	} else if count > 0 {
+		log.Printf("[通知器] 已清理 %d 条过期的发送记录", count)
+	}
+}
@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, time.Hour},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			d := outboxBackoff(c.attempts)
+			if d < c.base*8/10 || d > c.base*12/10 {
+				t.Errorf("第 %d 次失败的等待时间 %v 超出 %v±20%%", c.attempts, d, c.base)
+			}
+		}
+	}
+}
+
+// TestOutboxRetriesUntilDelivered 验证投递失败的通知留在发件箱中，到期后由投递协程重试成功。
+func TestOutboxRetriesUntilDelivered(t *testing.T) {
+	n, st := newTestNotifier(t)
+
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	err := n.SaveChannels([]Channel{{Name: "值班群", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.dispatch(sampleEvent())
+	msgs, _, _ := st.ListOutbox(storage.OutboxPending, 1, 10)
+	if len(msgs) != 1 || msgs[0].Attempts != 1 || msgs[0].LastError == "" {
+		t.Fatalf("首次投递失败后消息应待重试并记录错误: %+v", msgs)
+	}
+	if !msgs[0].NextAttemptAt.After(time.Now()) {
+		t.Error("重试时间应在将来")
+	}
+
+	// 未到重试时间不投递
+	n.processOutbox(time.Now())
+	if hits.Load() != 1 {
+		t.Errorf("未到重试时间不应投递，实际请求 %d 次", hits.Load())
+	}
+
+	healthy.Store(true)
+	n.processOutbox(time.Now().Add(2 * time.Minute))
+	sent, _, _ := st.ListOutbox(storage.OutboxSent, 1, 10)
+	if len(sent) != 1 || sent[0].Attempts != 2 || sent[0].SentAt == nil {
+		t.Fatalf("重试后应投递成功: %+v", sent)
+	}
+
+	// 每次尝试各有一条发送记录，并关联到会话
+	records, total, _ := st.ListDeliveries(storage.DeliveryFilter{SessionID: "s1"}, 1, 10)
+	if total != 2 || !records[0].Success || records[0].Attempt != 2 || records[0].OutboxID != sent[0].ID {
+		t.Fatalf("发送记录不正确: %+v", records)
+	}
+	if failed := records[1]; failed.Success || failed.Error == "" || failed.ChannelName != "值班群" || failed.Title == "" {
+		t.Errorf("失败的尝试应记录错误与渠道: %+v", failed)
+	}
+	// 只有成功的发送写入审计链
+	if res, err := st.VerifyAuditChain(); err != nil || !res.OK || res.Count != 1 {
+		t.Errorf("成功发送应追加一条审计记录: %+v %v", res, err)
+	}
+}
+
+// TestOutboxGivesUpAfterMaxAge 验证超过最长重试时间的消息被标记为失败，且可手动重试。
+func TestOutboxGivesUpAfterMaxAge(t *testing.T) {
+	n, st := newTestNotifier(t)
+	if err := n.settings.Set(settings.KeyOutboxMaxAgeHours, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	n.SaveChannels([]Channel{{Name: "值班群", Type: "discord", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL}}})
+
+	n.dispatch(sampleEvent())
+	n.processOutbox(time.Now().Add(2 * time.Hour))
+
+	failed, _, _ := st.ListOutbox(storage.OutboxFailed, 1, 10)
+	if len(failed) != 1 || failed[0].Attempts != 2 {
+		t.Fatalf("超过最长重试时间后应标记为失败: %+v", failed)
+	}
+
+	if err := n.RetryOutboxMessage(failed[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _, _ := st.ListOutbox(storage.OutboxPending, 1, 10); len(pending) != 1 {
+		t.Error("手动重试后消息应回到待发状态")
+	}
+}
+
+// TestOutboxHonorsRetryAfter 验证服务端限流时发件箱不早于 retry_after 重试
+func TestOutboxHonorsRetryAfter(t *testing.T) {
+	n, st := newTestNotifier(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 600","parameters":{"retry_after":600}}`))
+	}))
+	defer srv.Close()
+	n.SaveChannels([]Channel{{Name: "值班群", Type: "telegram", Enabled: true, Settings: map[string]interface{}{
+		"bot_token": "t", "chat_ids": "1", "api_base": srv.URL,
+	}}})
+
+	n.dispatch(sampleEvent())
+	msgs, _, _ := st.ListOutbox(storage.OutboxPending, 1, 10)
+	if len(msgs) != 1 || msgs[0].NextAttemptAt.Before(time.Now().Add(590*time.Second)) {
+		t.Fatalf("限流后应按 retry_after 推迟重试: %+v", msgs)
+	}
+}
+
+// TestOutboxDeliversOnce 验证首次投递与投递协程同时取到同一条消息时只投递一次
+func TestOutboxDeliversOnce(t *testing.T) {
+	n, st := newTestNotifier(t)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	c := Channel{ID: "c1", Name: "值班群", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL}}
+	n.SaveChannels([]Channel{c})
+
+	msg, err := n.enqueueOutbox(c, sampleEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *msg
+	n.attemptOutbox(*msg, c, time.Now())
+	n.attemptOutbox(stale, c, time.Now())
+	n.processOutbox(time.Now())
+
+	if hits.Load() != 1 {
+		t.Errorf("同一条消息应只投递一次，实际 %d 次", hits.Load())
+	}
+	if sent, _, _ := st.ListOutbox(storage.OutboxSent, 1, 10); len(sent) != 1 || sent[0].Attempts != 1 {
+		t.Errorf("消息应已投递且只尝试一次: %+v", sent)
+	}
+}
+
+// TestOutboxFailsPermanentErrors 验证服务端判为无效的消息（如 Slack 返回 400 invalid_payload）不再重试，直接标记为失败
+func TestOutboxFailsPermanentErrors(t *testing.T) {
+	n, st := newTestNotifier(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid_blocks"))
+	}))
+	defer srv.Close()
+	n.SaveChannels([]Channel{{Name: "值班群", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL}}})
+
+	n.dispatch(sampleEvent())
+	failed, _, _ := st.ListOutbox(storage.OutboxFailed, 1, 10)
+	if len(failed) != 1 || failed[0].Attempts != 1 || failed[0].LastError == "" {
+		t.Fatalf("400 后应直接标记为失败: %+v", failed)
+	}
+}
@@ -0,0 +1,247 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/secret"
+)
+
+func init() {
+	RegisterChannel(pagerDutyDriver{})
+}
+
+// PagerDutyConfig PagerDuty Events API v2 配置。会话事件以会话 ID 作为 dedup_key：
+// 会话开始 / 提醒 / 升级触发（trigger）同一个事件，会话结束时 resolve，值班事件随会话结束自动关闭。
+// 启动、退出、规则更新、统计报告等不属于会话的事件触发后无法关闭，不发送到值班平台。
+type PagerDutyConfig struct {
+	RoutingKey         string `json:"routing_key"`         // 服务中 Events API v2 集成的 Integration Key
+	APIBase            string `json:"api_base"`            // 留空使用 https://events.pagerduty.com，可填本地替身服务地址用于测试
+	SeverityStart      string `json:"severity_start"`      // 会话开始的严重级别：critical / error / warning / info
+	SeverityReminder   string `json:"severity_reminder"`   // 会话持续提醒的严重级别
+	SeverityEscalation string `json:"severity_escalation"` // 会话升级的严重级别
+	SeverityOther      string `json:"severity_other"`      // 测试通知的严重级别
+}
+
+const defaultPagerDutyAPI = "https://events.pagerduty.com"
+
+// pagerDutySeverities 是 Events API v2 支持的严重级别
+var pagerDutySeverities = []interface{}{"critical", "error", "warning", "info"}
+
+// pagerDutyDriver 是 PagerDuty 渠道
+type pagerDutyDriver struct{}
+
+func (pagerDutyDriver) Type() string { return "pagerduty" }
+
+func (pagerDutyDriver) Schema() *Schema {
+	routingKey := field("Integration Key", "服务 → Integrations 中添加 Events API v2 集成后得到的 Routing Key")
+	routingKey.WriteOnly = true
+	apiBase := field("API 地址", "留空使用 "+defaultPagerDutyAPI)
+	apiBase.Format = "uri"
+	severity := func(title, def string) *Schema {
+		return &Schema{Type: "string", Title: title, Enum: pagerDutySeverities, Default: def}
+	}
+	return &Schema{
+		Type:        "object",
+		Title:       "PagerDuty",
+		Description: "远程会话开始时触发值班事件（dedup_key 为会话 ID），会话结束时自动 resolve",
+		Properties: map[string]*Schema{
+			"routing_key":         routingKey,
+			"api_base":            apiBase,
+			"severity_start":      severity("会话开始的严重级别", "error"),
+			"severity_reminder":   severity("持续提醒的严重级别", "warning"),
+			"severity_escalation": severity("会话升级的严重级别", "critical"),
+			"severity_other":      severity("测试通知的严重级别", "info"),
+		},
+		Required: []string{"routing_key"},
+		Order:    []string{"routing_key", "severity_start", "severity_reminder", "severity_escalation", "severity_other", "api_base"},
+	}
+}
+
+func (pagerDutyDriver) Validate(config NotificationConfig) error {
+	pd := pagerDutySettings(config)
+	if strings.TrimSpace(pd.RoutingKey) == "" {
+		return fmt.Errorf("Integration Key 不能为空")
+	}
+	if err := validateAPIBase(pd.APIBase); err != nil {
+		return err
+	}
+	for _, s := range []string{pd.SeverityStart, pd.SeverityReminder, pd.SeverityEscalation, pd.SeverityOther} {
+		if s != "" && !containsFold([]string{"critical", "error", "warning", "info"}, s) {
+			return fmt.Errorf("严重级别 %q 无效，应为 critical / error / warning / info", s)
+		}
+	}
+	return nil
+}
+
+func (pagerDutyDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	body, ok := pagerDutyEvent(config, ev)
+	if !ok {
+		log.Printf("[通知器] PagerDuty: %s 事件不属于任何会话，触发后无法 resolve，已跳过", ev.Type)
+		return nil
+	}
+	return n.sendPagerDuty(config, body)
+}
+
+// Test 发送测试事件后随即 resolve，不在值班表中留下未处理的事件
+func (d pagerDutyDriver) Test(n *Notifier, config NotificationConfig) error {
+	if err := d.Validate(config); err != nil {
+		return err
+	}
+	if err := n.SendTestNotification(config); err != nil {
+		return err
+	}
+	return n.sendPagerDuty(config, map[string]interface{}{
+		"routing_key":  pagerDutySettings(config).RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    testIncidentKey(n.getDeviceName()),
+	})
+}
+
+func (pagerDutyDriver) Preview(_ *Notifier, config NotificationConfig, ev *Event) (*Preview, error) {
+	body, ok := pagerDutyEvent(config, ev)
+	if !ok {
+		return nil, fmt.Errorf("%s 事件不属于任何会话，不会发送到 PagerDuty", ev.Type)
+	}
+	body["routing_key"] = secret.Mask
+	return jsonPreview(config.Type, pagerDutyURL(pagerDutySettings(config)), body)
+}
+
+// SequenceKey 使同一会话的触发与关闭按顺序投递：触发失败重试期间关闭请求等待，不会留下无法关闭的事件
+func (pagerDutyDriver) SequenceKey(ev *Event) string { return incidentKey(ev) }
+
+func (pagerDutyDriver) Endpoint(config NotificationConfig) string {
+	return pagerDutyURL(pagerDutySettings(config))
+}
+
+// pagerDutySettings 返回渠道的 PagerDuty 设置
+func pagerDutySettings(config NotificationConfig) PagerDutyConfig {
+	var pd PagerDutyConfig
+	config.decode(&pd)
+	return pd
+}
+
+// pagerDutyEvent 构建 Events API v2 请求体：会话结束为 resolve，其余为 trigger。
+// 事件没有去重键（不属于任何会话）时触发的事件无法关闭，返回 false。
+func pagerDutyEvent(config NotificationConfig, ev *Event) (map[string]interface{}, bool) {
+	pd := pagerDutySettings(config)
+	key := incidentKey(ev)
+	if key == "" {
+		return nil, false
+	}
+	if ev.Type == EventRemoteEnd {
+		return map[string]interface{}{
+			"routing_key":  pd.RoutingKey,
+			"event_action": "resolve",
+			"dedup_key":    key,
+		}, true
+	}
+
+	severity := pd.SeverityOther
+	def := "info"
+	switch ev.Type {
+	case EventRemoteStart:
+		severity, def = pd.SeverityStart, "error"
+	case EventRemoteReminder:
+		severity, def = pd.SeverityReminder, "warning"
+	case EventRemoteEscalation:
+		severity, def = pd.SeverityEscalation, "critical"
+	}
+	if severity == "" {
+		severity = def
+	}
+
+	payload := map[string]interface{}{
+		"summary":        truncateUTF8(ev.Title, 1024),
+		"source":         ev.DeviceName,
+		"severity":       strings.ToLower(severity),
+		"timestamp":      ev.Time.Format(time.RFC3339),
+		"class":          ev.Type,
+		"custom_details": incidentDetails(ev),
+	}
+	if ev.Tool != "" {
+		payload["component"] = ev.Tool
+	}
+	return map[string]interface{}{
+		"routing_key":  pd.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"client":       "RemoteKnown",
+		"payload":      payload,
+	}, true
+}
+
+// sendPagerDuty 发送 Events API v2 请求，接受后返回 202
+func (n *Notifier) sendPagerDuty(config NotificationConfig, body map[string]interface{}) error {
+	pd := pagerDutySettings(config)
+	return n.sendPush(pushRequest{
+		Service: "PagerDuty",
+		URL:     pagerDutyURL(pd),
+		Secret:  pd.RoutingKey,
+		Body:    body,
+		Network: config.Network,
+		Success: successCondition{JSONPath: "$.status", JSONValue: "success", MessagePath: "$.message"},
+	})
+}
+
+func pagerDutyURL(pd PagerDutyConfig) string {
+	return serverBase(pd.APIBase, defaultPagerDutyAPI) + "/v2/enqueue"
+}
+
+// incidentKey 返回值班事件的去重键：会话事件为会话 ID，测试通知为按设备区分的固定键，其余事件为空（不发送）
+func incidentKey(ev *Event) string {
+	switch {
+	case ev.Type == EventTest:
+		return testIncidentKey(ev.DeviceName)
+	case isSessionEvent(ev.Type):
+		return ev.SessionID
+	}
+	return ""
+}
+
+// testIncidentKey 是测试通知的去重键，测试后据此关闭
+func testIncidentKey(device string) string {
+	return "remoteknown-test-" + device
+}
+
+// incidentDetails 返回附加在值班事件上的会话明细
+func incidentDetails(ev *Event) map[string]interface{} {
+	details := map[string]interface{}{
+		"event":    ev.Type,
+		"device":   ev.DeviceName,
+		"hostname": ev.Hostname,
+		"content":  ev.Content,
+	}
+	if ev.SessionID != "" {
+		details["session_id"] = ev.SessionID
+		details["authorized"] = ev.Authorized
+	}
+	if len(ev.Tools) > 0 {
+		details["tools"] = strings.Join(ev.Tools, ", ")
+	}
+	if len(ev.Peers) > 0 {
+		details["peers"] = strings.Join(ev.Peers, ", ")
+	}
+	if !ev.StartTime.IsZero() {
+		details["start_time"] = ev.StartTime.Format(time.RFC3339)
+	}
+	if ev.Duration != "" {
+		details["duration"] = ev.Duration
+	}
+	return details
+}
+
+// validateAPIBase 检查可选的 API 地址：留空使用默认地址，填写时须为 http(s) 地址
+func validateAPIBase(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("API 地址无效，应以 http:// 或 https:// 开头")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/storage"
+)
+
+// liveSession 返回一个进行中的示例会话
+func liveSession(id string) detector.SessionEvent {
+	return detector.SessionEvent{
+		SessionID: id,
+		StartTime: time.Now().Add(-3 * time.Minute),
+		Signals:   []detector.Signal{{Name: "ToDesk (进程存在)", Tool: "ToDesk"}},
+	}
+}
+
+// TestPagerDutyTriggerAndResolve 验证会话开始以会话 ID 触发事件（按设置映射严重级别），会话结束时 resolve 同一 dedup_key
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{"status":"success","message":"Event processed"}`)
+	n, st := newTestNotifier(t)
+	err := n.SaveChannels([]Channel{{ID: "pd", Name: "值班", Type: "pagerduty", Enabled: true, Settings: map[string]interface{}{
+		"routing_key": "R0UT1NGKEY", "api_base": fake.URL, "severity_start": "critical",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := st.GetConfig("notification_channels"); strings.Contains(raw, "R0UT1NGKEY") {
+		t.Fatalf("Routing Key 应加密保存: %s", raw)
+	}
+
+	se := liveSession("s-42")
+	n.NotifyRemoteStart(se)
+	se.EndTime = time.Now()
+	n.NotifyRemoteEnd(se)
+
+	got := fake.Requests()
+	if len(got) != 2 {
+		t.Fatalf("应发送 trigger 与 resolve 两个请求，实际 %d", len(got))
+	}
+	trigger, resolve := got[0].Body, got[1].Body
+	if got[0].Path != "/v2/enqueue" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != "s-42" || trigger["routing_key"] != "R0UT1NGKEY" {
+		t.Errorf("trigger 请求不正确: %s %v", got[0].Path, trigger)
+	}
+	payload, _ := trigger["payload"].(map[string]interface{})
+	if payload["severity"] != "critical" || payload["component"] != "ToDesk" || payload["summary"] == "" {
+		t.Errorf("payload 不正确: %v", payload)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "s-42" {
+		t.Errorf("会话结束应 resolve 同一 dedup_key: %v", resolve)
+	}
+}
+
+// TestPagerDutyResolveWaitsForTrigger 验证 trigger 首次失败进入重试后，会话结束的 resolve 等待 trigger 送达后再发送，
+// 不会先 resolve 再 trigger 留下无法关闭的事件
+func TestPagerDutyResolveWaitsForTrigger(t *testing.T) {
+	var mu sync.Mutex
+	var actions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		actions = append(actions, fmt.Sprint(body["event_action"]))
+		first := len(actions) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer srv.Close()
+
+	n, st := newTestNotifier(t)
+	n.SaveChannels([]Channel{{ID: "pd", Name: "值班", Type: "pagerduty", Enabled: true, Settings: map[string]interface{}{
+		"routing_key": "k", "api_base": srv.URL,
+	}}})
+
+	se := liveSession("s-9")
+	n.NotifyRemoteStart(se)
+	se.EndTime = time.Now()
+	n.NotifyRemoteEnd(se)
+	mu.Lock()
+	before := append([]string(nil), actions...)
+	mu.Unlock()
+	if len(before) != 1 {
+		t.Fatalf("trigger 待重试期间 resolve 不应发送，实际请求 %v", before)
+	}
+
+	later := time.Now().Add(2 * time.Minute)
+	n.processOutbox(later)
+	n.processOutbox(later) // 与 trigger 同批取到的 resolve 在 trigger 送达后的下一轮发送
+
+	mu.Lock()
+	got := strings.Join(actions, ", ")
+	mu.Unlock()
+	if got != "trigger, trigger, resolve" {
+		t.Errorf("resolve 应在 trigger 重试成功后发送，实际 %s", got)
+	}
+	if sent, _, _ := st.ListOutbox(storage.OutboxSent, 1, 10); len(sent) != 2 {
+		t.Errorf("trigger 与 resolve 都应投递成功: %+v", sent)
+	}
+}
+
+func TestPagerDutyRejected(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusBadRequest, `{"status":"invalid event","message":"Event object is invalid"}`)
+	n, _ := newTestNotifier(t)
+	config := NotificationConfig{Type: "pagerduty", Settings: settingsOf(PagerDutyConfig{RoutingKey: "bad-key", APIBase: fake.URL})}
+	ev := n.newEvent(EventRemoteStart, "检测到远程控制", "")
+	ev.SessionID = "s-1"
+	err := pagerDutyDriver{}.Send(n, config, ev)
+	if err == nil || !strings.Contains(err.Error(), "400") || strings.Contains(err.Error(), "bad-key") {
+		t.Errorf("应返回脱敏后的错误，实际 %v", err)
+	}
+}
+
+// TestPagerDutyBackToBackSessions 验证紧接着的两次会话各自触发并 resolve，会话结束不被去重窗口吞掉
+func TestPagerDutyBackToBackSessions(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{"status":"success"}`)
+	n, _ := newTestNotifier(t)
+	n.SaveChannels([]Channel{{ID: "pd", Name: "值班", Type: "pagerduty", Enabled: true, Settings: map[string]interface{}{
+		"routing_key": "k", "api_base": fake.URL,
+	}}})
+
+	for _, id := range []string{"s-1", "s-2"} {
+		se := liveSession(id)
+		n.NotifyRemoteStart(se)
+		se.EndTime = time.Now()
+		n.NotifyRemoteEnd(se)
+	}
+
+	var got []string
+	for _, r := range fake.Requests() {
+		got = append(got, r.Body["event_action"].(string)+" "+r.Body["dedup_key"].(string))
+	}
+	if strings.Join(got, ", ") != "trigger s-1, resolve s-1, trigger s-2, resolve s-2" {
+		t.Errorf("两次会话都应触发并 resolve，实际 %v", got)
+	}
+}
+
+// TestIncidentChannelsSkipNonSessionEvents 验证启动、退出、规则更新、统计报告与汇总不会创建无法关闭的值班事件
+func TestIncidentChannelsSkipNonSessionEvents(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{"status":"success"}`)
+	n, _ := newTestNotifier(t)
+	configs := []NotificationConfig{
+		{Type: "pagerduty", Settings: settingsOf(PagerDutyConfig{RoutingKey: "k", APIBase: fake.URL})},
+		{Type: "opsgenie", Settings: settingsOf(OpsgenieConfig{APIKey: "k", APIBase: fake.URL})},
+	}
+	for _, typ := range []string{EventAppStart, EventAppExit, EventRulesUpdated, EventDigest, EventSummary} {
+		for _, config := range configs {
+			if err := channelDriver(config.Type).Send(n, config, n.newEvent(typ, "标题", "内容")); err != nil {
+				t.Errorf("%s %s: %v", config.Type, typ, err)
+			}
+		}
+	}
+	if got := len(fake.Requests()); got != 0 {
+		t.Errorf("不属于会话的事件不应发送到值班平台，实际 %d 个请求", got)
+	}
+}
+
+// TestPagerDutyTestResolves 验证测试通知触发后随即 resolve
+func TestPagerDutyTestResolves(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusAccepted, `{"status":"success"}`)
+	n, _ := newTestNotifier(t)
+	c := Channel{Type: "pagerduty", Settings: map[string]interface{}{"routing_key": "k", "api_base": fake.URL}}
+	if err := n.TestChannel(c); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.Requests()
+	if len(got) != 2 || got[0].Body["event_action"] != "trigger" || got[1].Body["event_action"] != "resolve" ||
+		got[0].Body["dedup_key"] != got[1].Body["dedup_key"] {
+		t.Errorf("测试通知应先 trigger 再 resolve 同一事件: %+v", got)
+	}
+}
+
+func TestPagerDutyValidate(t *testing.T) {
+	d := pagerDutyDriver{}
+	cases := []struct {
+		pd PagerDutyConfig
+		ok bool
+	}{
+		{PagerDutyConfig{RoutingKey: "k"}, true},
+		{PagerDutyConfig{}, false},
+		{PagerDutyConfig{RoutingKey: "k", SeverityStart: "fatal"}, false},
+		{PagerDutyConfig{RoutingKey: "k", APIBase: "localhost:8080"}, false},
+	}
+	for _, c := range cases {
+		if err := d.Validate(NotificationConfig{Settings: settingsOf(c.pd)}); (err == nil) != c.ok {
+			t.Errorf("%+v: 期望通过=%v，实际 %v", c.pd, c.ok, err)
+		}
+	}
+}
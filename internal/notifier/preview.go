@@ -0,0 +1,151 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/storage"
+)
+
+// Preview 是一条通知在渠道上实际发送的内容（未发送），其中的密钥、令牌与签名已打码
+type Preview struct {
+	Type        string   `json:"type"`                 // 渠道类型
+	Event       *Event   `json:"event"`                // 渲染模板后的事件
+	Method      string   `json:"method,omitempty"`     // HTTP 方法（Webhook 类渠道）
+	URL         string   `json:"url"`                  // 请求地址；邮件为 smtp://主机:端口
+	ContentType string   `json:"content_type"`         // application/json / message/rfc822
+	From        string   `json:"from,omitempty"`       // 邮件信封发件人
+	Recipients  []string `json:"recipients,omitempty"` // 邮件信封收件人（含抄送与密送）
+	Body        string   `json:"body"`                 // 请求正文（JSON 已缩进排版）或完整的 MIME 邮件
+}
+
+// ChannelPreviewer 由支持预览的 ChannelDriver 实现：按 Send 的同样方式构建请求但不发送
+type ChannelPreviewer interface {
+	Preview(n *Notifier, config NotificationConfig, ev *Event) (*Preview, error)
+}
+
+// PreviewEvents 是可预览的事件类型
+var PreviewEvents = []string{
+	EventRemoteStart, EventRemoteEnd, EventRemoteReminder, EventRemoteEscalation,
+	EventAppStart, EventAppExit, EventRulesUpdated, EventDigest,
+}
+
+// previewEventAliases 是预览接口接受的事件类型简写
+var previewEventAliases = map[string]string{
+	"reminder":   EventRemoteReminder,
+	"escalation": EventRemoteEscalation,
+}
+
+// PreviewChannel 渲染事件在渠道上将要发送的内容（套用渠道与全局模板，不检查启用状态与订阅条件），不发送任何消息。
+// live 不为 nil 时使用其中的会话信息（当前检测状态，会话结束事件的结束时间取当前时间），否则使用示例数据；统计报告在 live 时按最近一天的会话计算。
+func (n *Notifier) PreviewChannel(c Channel, eventType string, live *detector.SessionEvent) (*Preview, error) {
+	eventType = strings.ToLower(strings.TrimSpace(eventType))
+	if alias, ok := previewEventAliases[eventType]; ok {
+		eventType = alias
+	}
+	if !containsFold(PreviewEvents, eventType) {
+		return nil, fmt.Errorf("不支持预览的事件类型: %s", eventType)
+	}
+	previewer, ok := channelDriver(c.Type).(ChannelPreviewer)
+	if !ok {
+		return nil, fmt.Errorf("%s 渠道暂不支持预览", typeLabel(c.Type))
+	}
+
+	ev := n.newEvent(eventType, "", "")
+	if eventType == EventDigest {
+		period := n.DigestFrequency()
+		d := sampleDigest(period, ev.Time)
+		if live != nil {
+			var err error
+			if d, err = n.BuildCurrentDigest(period, ev.Time); err != nil {
+				return nil, fmt.Errorf("统计会话失败: %w", err)
+			}
+		}
+		ev.Title, ev.Content, ev.Digest = digestTitle(d), digestText(ev.DeviceName, d), d
+	} else {
+		if live != nil {
+			se := *live
+			if eventType == EventRemoteEnd && se.EndTime.IsZero() {
+				se.EndTime = ev.Time
+			}
+			ev.setSession(se)
+			n.setAuthorized(ev)
+		} else {
+			ev.setSample()
+		}
+		global, err := n.LoadTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("读取通知模板失败: %w", err)
+		}
+		ev = n.applyTemplate(ev, c, global)
+	}
+
+	p, err := previewer.Preview(n, n.channelConfig(c), ev)
+	if err != nil {
+		return nil, err
+	}
+	p.Event = ev
+	return p, nil
+}
+
+// jsonPreview 返回 JSON 请求的预览
+func jsonPreview(typ, url string, message interface{}) (*Preview, error) {
+	body, err := json.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return &Preview{Type: typ, Method: "POST", URL: url, ContentType: "application/json", Body: string(body)}, nil
+}
+
+// maskURL 打码 URL 中的凭据：用户信息与所有查询参数的值；maskPath 为 true 时同时打码路径的最后一段（飞书等把令牌放在路径中）
+func maskURL(raw string, maskPath bool) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return secret.Mask
+	}
+	var b strings.Builder
+	b.WriteString(u.Scheme + "://")
+	if u.User != nil {
+		b.WriteString(secret.Mask + "@")
+	}
+	b.WriteString(u.Host)
+	path := u.EscapedPath()
+	if i := strings.LastIndex(path, "/"); maskPath && i >= 0 && i < len(path)-1 {
+		path = path[:i+1] + secret.Mask
+	}
+	b.WriteString(path)
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, p := range params {
+			key, _, _ := strings.Cut(p, "=")
+			params[i] = key + "=" + secret.Mask
+		}
+		b.WriteString("?" + strings.Join(params, "&"))
+	}
+	return b.String()
+}
+
+// sampleDigest 返回预览用的示例日报：一次工作时间内已确认的会话与一次非工作时间的未确认会话
+func sampleDigest(period string, now time.Time) *Digest {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	sessionDay := day.AddDate(0, 0, -1)
+	for sessionDay.Weekday() == time.Saturday || sessionDay.Weekday() == time.Sunday {
+		sessionDay = sessionDay.AddDate(0, 0, -1)
+	}
+	start1, end1 := sessionDay.Add(10*time.Hour), sessionDay.Add(10*time.Hour+25*time.Minute)
+	start2, end2 := sessionDay.Add(22*time.Hour), sessionDay.Add(23*time.Hour+5*time.Minute)
+	sessions := []storage.RemoteSession{
+		{ID: "sample-1", StartTime: start1, EndTime: &end1, Signals: "示例工具 (进程存在)", AcknowledgedAt: &end1},
+		{ID: "sample-2", StartTime: start2, EndTime: &end2, Signals: "示例工具 (来自: 192.0.2.1)"},
+	}
+	from, to := sessionDay, sessionDay.AddDate(0, 0, 1)
+	if period == "weekly" {
+		from, to = day.AddDate(0, 0, -7), day
+	}
+	return BuildDigest(period, sessions, from, to, 9*time.Hour, 18*time.Hour)
+}
@@ -0,0 +1,154 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/settings"
+)
+
+// TestPreviewFeishu 验证飞书预览返回完整的卡片 JSON，地址中的令牌与签名打码，且不发出请求
+func TestPreviewFeishu(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	n, _ := newTestNotifier(t)
+	c := Channel{Type: "feishu", Settings: map[string]interface{}{
+		"webhook_url": srv.URL + "/open-apis/bot/v2/hook/hook-token-123", "secret": "sign-secret",
+	}}
+	p, err := n.PreviewChannel(c, "remote_start", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Fatalf("预览不应发出请求")
+	}
+	if p.URL != srv.URL+"/open-apis/bot/v2/hook/******" || p.Method != "POST" || p.ContentType != "application/json" {
+		t.Errorf("请求信息不正确: %+v", p)
+	}
+	if strings.Contains(p.Body, "hook-token-123") || strings.Contains(p.Body, "sign-secret") {
+		t.Fatalf("预览中不应出现密钥: %s", p.Body)
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal([]byte(p.Body), &msg); err != nil {
+		t.Fatalf("正文应为 JSON: %v", err)
+	}
+	if msg["msg_type"] != "interactive" || msg["sign"] != "******" || msg["timestamp"] == nil {
+		t.Errorf("卡片消息不正确: %v", msg)
+	}
+	if p.Event.Title == "" || !strings.Contains(p.Body, p.Event.Title) || !strings.Contains(p.Body, "示例工具") {
+		t.Errorf("卡片应包含按模板渲染的示例会话: %s", p.Body)
+	}
+	header, _ := msg["card"].(map[string]interface{})["header"].(map[string]interface{})
+	if header["template"] != "red" {
+		t.Errorf("会话开始的卡片标题栏应为红色，实际 %v", header["template"])
+	}
+}
+
+func TestPreviewDingtalkDigest(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	c := Channel{Type: "dingtalk", Settings: map[string]interface{}{
+		"webhook_url": "https://oapi.dingtalk.com/robot/send?access_token=tk-1", "secret": "SEC-1",
+	}}
+	p, err := n.PreviewChannel(c, "digest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != "https://oapi.dingtalk.com/robot/send?access_token=******&timestamp=******&sign=******" {
+		t.Errorf("地址中的令牌与签名应打码: %s", p.URL)
+	}
+	var msg struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		} `json:"markdown"`
+	}
+	json.Unmarshal([]byte(p.Body), &msg)
+	if msg.MsgType != "markdown" || !strings.HasPrefix(msg.Markdown.Text, "### 📊 远程控制日报") {
+		t.Errorf("日报应以 markdown 发送: %+v", msg)
+	}
+	if p.Event.Digest == nil || p.Event.Digest.Sessions != 2 || len(p.Event.Digest.OutsideHours) != 1 {
+		t.Errorf("示例日报应包含两次会话，其中一次在非工作时间: %+v", p.Event.Digest)
+	}
+}
+
+// TestPreviewDigestFrequency 验证按周发送报告时，示例与当前数据的预览都是周报
+func TestPreviewDigestFrequency(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	if err := n.settings.Set(settings.KeyDigestFrequency, "weekly"); err != nil {
+		t.Fatal(err)
+	}
+	c := Channel{Type: "dingtalk", Settings: map[string]interface{}{"webhook_url": "https://oapi.dingtalk.com/robot/send?access_token=tk-1"}}
+	for _, live := range []*detector.SessionEvent{nil, {SessionID: "s-live", StartTime: time.Now()}} {
+		p, err := n.PreviewChannel(c, "digest", live)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Event.Digest == nil || p.Event.Digest.Period != "weekly" || !strings.Contains(p.Event.Title, "周报") {
+			t.Errorf("live=%v: 按周发送时应预览周报: %+v", live != nil, p.Event.Digest)
+		}
+		if d := p.Event.Digest; d != nil && d.To.Sub(d.From) != 7*24*time.Hour {
+			t.Errorf("live=%v: 周报应覆盖 7 天: %v ~ %v", live != nil, d.From, d.To)
+		}
+	}
+}
+
+// TestPreviewEmailLive 验证邮件预览返回完整的 MIME 与信封收件人，使用当前会话时归入会话线索
+func TestPreviewEmailLive(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	c := Channel{Type: "email", Settings: map[string]interface{}{
+		"smtp_host": "smtp.example.com", "encryption": "starttls", "password": "p@ss",
+		"from": "alert@example.com", "to": "it@example.com", "bcc": "audit@example.com",
+	}}
+	live := &detector.SessionEvent{
+		SessionID: "s-live",
+		StartTime: time.Now().Add(-10 * time.Minute),
+		Signals:   []detector.Signal{{Name: "ToDesk (进程存在)", Tool: "ToDesk"}},
+	}
+	p, err := n.PreviewChannel(c, "remote_end", live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != "smtp://smtp.example.com:587" || p.ContentType != "message/rfc822" || p.From != "alert@example.com" {
+		t.Errorf("邮件预览信息不正确: %+v", p)
+	}
+	if strings.Join(p.Recipients, ",") != "it@example.com,audit@example.com" {
+		t.Errorf("信封收件人应包含密送: %v", p.Recipients)
+	}
+	for _, want := range []string{"In-Reply-To: <session.s-live.remoteknown@example.com>", "multipart/alternative", "To: it@example.com"} {
+		if !strings.Contains(p.Body, want) {
+			t.Errorf("MIME 中缺少 %q:\n%s", want, p.Body)
+		}
+	}
+	if strings.Contains(p.Body, "audit@example.com") || strings.Contains(p.Body, "p@ss") {
+		t.Errorf("密送地址与密码不应出现在邮件中:\n%s", p.Body)
+	}
+	if p.Event.Tool != "ToDesk" || p.Event.EndTime.IsZero() {
+		t.Errorf("应使用当前会话并以当前时间作为结束时间: %+v", p.Event)
+	}
+}
+
+func TestPreviewUnsupported(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	feishu := Channel{Type: "feishu", Settings: map[string]interface{}{"webhook_url": "https://example.com/hook/x"}}
+	if _, err := n.PreviewChannel(feishu, "reminder", nil); err != nil {
+		t.Errorf("reminder 应作为 remote_reminder 的简写: %v", err)
+	}
+	if _, err := n.PreviewChannel(feishu, "unknown", nil); err == nil {
+		t.Errorf("未知事件类型应返回错误")
+	}
+	if _, err := n.PreviewChannel(Channel{Type: "slack", Settings: map[string]interface{}{}}, "remote_start", nil); err == nil {
+		t.Errorf("未实现预览的渠道应返回错误")
+	}
+}
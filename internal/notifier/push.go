@@ -0,0 +1,438 @@
+package notifier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"RemoteKnown/internal/netconf"
+)
+
+func init() {
+	RegisterChannel(serverChanDriver{})
+	RegisterChannel(pushPlusDriver{})
+	RegisterChannel(barkDriver{})
+	RegisterChannel(ntfyDriver{})
+	RegisterChannel(gotifyDriver{})
+}
+
+// 国内常用的推送服务：Server酱、PushPlus、Bark、ntfy 与 Gotify。
+// 它们的请求头与成功判断各不相同，统一经 sendPush 描述后交给 postWebhook 发送。
+
+// ServerChanConfig Server酱 推送配置
+type ServerChanConfig struct {
+	SendKey string `json:"send_key"` // SendKey；Server酱³ 的 sctp 开头 key 自动使用对应的推送地址
+}
+
+// PushPlusConfig PushPlus 推送配置
+type PushPlusConfig struct {
+	Token string `json:"token"` // 用户 token
+	Topic string `json:"topic"` // 群组编码（可选），填写后一对多推送
+}
+
+// BarkConfig Bark 推送配置
+type BarkConfig struct {
+	Server    string `json:"server"`     // 服务器地址；留空使用官方 https://api.day.app，可填自建服务器
+	DeviceKey string `json:"device_key"` // 设备 key
+	Group     string `json:"group"`      // 消息分组（可选）
+	Sound     string `json:"sound"`      // 提示音（可选）
+}
+
+// NtfyConfig ntfy 推送配置
+type NtfyConfig struct {
+	Server   string `json:"server"`   // 服务器地址；留空使用公共服务 https://ntfy.sh，可填自建服务器
+	Topic    string `json:"topic"`    // 主题
+	Priority int    `json:"priority"` // 优先级 1-5，0 表示默认（3）
+	Tags     string `json:"tags"`     // 标签（可为 emoji 短码），多个用逗号分隔
+	Token    string `json:"token"`    // 访问令牌（可选，受保护的主题使用）
+}
+
+// GotifyConfig Gotify 推送配置
+type GotifyConfig struct {
+	Server   string `json:"server"`    // Gotify 服务器地址
+	AppToken string `json:"app_token"` // 应用 token
+	Priority int    `json:"priority"`  // 优先级，0 表示服务端默认
+}
+
+const (
+	defaultBarkServer = "https://api.day.app"
+	defaultNtfyServer = "https://ntfy.sh"
+	serverChanAPI     = "https://sctapi.ftqq.com"
+	pushPlusAPI       = "https://www.pushplus.plus/send"
+)
+
+// serverField 构造推送服务器地址属性
+func serverField(description string) *Schema {
+	s := field("服务器地址", description)
+	s.Format = "uri"
+	return s
+}
+
+// serverChanDriver 是 Server酱 渠道
+type serverChanDriver struct{ sendsTest }
+
+func (serverChanDriver) Type() string { return "serverchan" }
+
+func (serverChanDriver) Schema() *Schema {
+	return &Schema{
+		Type:       "object",
+		Title:      "Server酱",
+		Properties: map[string]*Schema{"send_key": secretField("SendKey", "Server酱 Turbo 或 Server酱³ 的 SendKey")},
+		Required:   []string{"send_key"},
+		Order:      []string{"send_key"},
+	}
+}
+
+func (serverChanDriver) Validate(config NotificationConfig) error {
+	var sc ServerChanConfig
+	config.decode(&sc)
+	if strings.TrimSpace(sc.SendKey) == "" {
+		return fmt.Errorf("Server酱 SendKey 不能为空")
+	}
+	return nil
+}
+
+func (serverChanDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendServerChan(config, ev.Title, ev.Content)
+}
+
+func (serverChanDriver) Endpoint(config NotificationConfig) string {
+	var sc ServerChanConfig
+	config.decode(&sc)
+	if key := strings.TrimSpace(sc.SendKey); key != "" {
+		return serverChanURL(key)
+	}
+	return ""
+}
+
+// pushPlusDriver 是 PushPlus 渠道
+type pushPlusDriver struct{ sendsTest }
+
+func (pushPlusDriver) Type() string { return "pushplus" }
+
+func (pushPlusDriver) Schema() *Schema {
+	return &Schema{
+		Type:  "object",
+		Title: "PushPlus",
+		Properties: map[string]*Schema{
+			"token": secretField("token", "PushPlus 用户 token"),
+			"topic": field("群组编码", "填写后一对多推送给群组成员"),
+		},
+		Required: []string{"token"},
+		Order:    []string{"token", "topic"},
+	}
+}
+
+func (pushPlusDriver) Validate(config NotificationConfig) error {
+	var pp PushPlusConfig
+	config.decode(&pp)
+	if pp.Token == "" {
+		return fmt.Errorf("PushPlus token 不能为空")
+	}
+	return nil
+}
+
+func (pushPlusDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendPushPlus(config, ev.Title, ev.Content)
+}
+
+func (pushPlusDriver) Endpoint(NotificationConfig) string { return pushPlusAPI }
+
+// barkDriver 是 Bark 渠道
+type barkDriver struct{ sendsTest }
+
+func (barkDriver) Type() string { return "bark" }
+
+func (barkDriver) Schema() *Schema {
+	return &Schema{
+		Type:  "object",
+		Title: "Bark",
+		Properties: map[string]*Schema{
+			"server":     serverField("留空使用 " + defaultBarkServer + "，可填自建服务器"),
+			"device_key": secretField("设备 key", "Bark App 中显示的 key"),
+			"group":      field("分组", ""),
+			"sound":      field("提示音", ""),
+		},
+		Required: []string{"device_key"},
+		Order:    []string{"device_key", "server", "group", "sound"},
+	}
+}
+
+func (barkDriver) Validate(config NotificationConfig) error {
+	var bk BarkConfig
+	config.decode(&bk)
+	if bk.DeviceKey == "" {
+		return fmt.Errorf("Bark 设备 key 不能为空")
+	}
+	return validateAPIBase(bk.Server)
+}
+
+func (barkDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendBark(config, ev.Title, ev.Content)
+}
+
+func (barkDriver) Endpoint(config NotificationConfig) string {
+	var bk BarkConfig
+	config.decode(&bk)
+	return serverBase(bk.Server, defaultBarkServer)
+}
+
+// ntfyDriver 是 ntfy 渠道
+type ntfyDriver struct{ sendsTest }
+
+func (ntfyDriver) Type() string { return "ntfy" }
+
+func (ntfyDriver) Schema() *Schema {
+	return &Schema{
+		Type:  "object",
+		Title: "ntfy",
+		Properties: map[string]*Schema{
+			"server":   serverField("留空使用公共服务 " + defaultNtfyServer + "，可填自建服务器"),
+			"topic":    field("主题", ""),
+			"priority": {Type: "integer", Title: "优先级", Description: "1 ~ 5，0 为默认（3）", Minimum: floatPtr(0), Maximum: floatPtr(5)},
+			"tags":     field("标签", "可为 emoji 短码，多个用逗号分隔"),
+			"token":    secretField("访问令牌", "受保护的主题需要填写"),
+		},
+		Required: []string{"topic"},
+		Order:    []string{"topic", "server", "priority", "tags", "token"},
+	}
+}
+
+func (ntfyDriver) Validate(config NotificationConfig) error {
+	var nt NtfyConfig
+	config.decode(&nt)
+	switch {
+	case strings.TrimSpace(nt.Topic) == "":
+		return fmt.Errorf("ntfy 主题不能为空")
+	case nt.Priority < 0 || nt.Priority > 5:
+		return fmt.Errorf("ntfy 优先级应为 1 ~ 5")
+	}
+	return validateAPIBase(nt.Server)
+}
+
+func (ntfyDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendNtfy(config, ev.Title, ev.Content)
+}
+
+func (ntfyDriver) Endpoint(config NotificationConfig) string {
+	var nt NtfyConfig
+	config.decode(&nt)
+	return serverBase(nt.Server, defaultNtfyServer)
+}
+
+// gotifyDriver 是 Gotify 渠道
+type gotifyDriver struct{ sendsTest }
+
+func (gotifyDriver) Type() string { return "gotify" }
+
+func (gotifyDriver) Schema() *Schema {
+	return &Schema{
+		Type:  "object",
+		Title: "Gotify",
+		Properties: map[string]*Schema{
+			"server":    serverField("Gotify 服务器地址"),
+			"app_token": secretField("应用 token", "在 Gotify 的 Apps 中创建应用后得到"),
+			"priority":  {Type: "integer", Title: "优先级", Description: "0 为服务端默认", Minimum: floatPtr(0), Maximum: floatPtr(10)},
+		},
+		Required: []string{"server", "app_token"},
+		Order:    []string{"server", "app_token", "priority"},
+	}
+}
+
+func (gotifyDriver) Validate(config NotificationConfig) error {
+	var gt GotifyConfig
+	config.decode(&gt)
+	switch {
+	case serverBase(gt.Server, "") == "":
+		return fmt.Errorf("Gotify 服务器地址不能为空")
+	case gt.AppToken == "":
+		return fmt.Errorf("Gotify 应用 token 不能为空")
+	}
+	return validateAPIBase(gt.Server)
+}
+
+func (gotifyDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendGotify(config, ev.Title, ev.Content)
+}
+
+func (gotifyDriver) Endpoint(config NotificationConfig) string {
+	var gt GotifyConfig
+	config.decode(&gt)
+	return serverBase(gt.Server, "")
+}
+
+// pushRequest 是一次推送服务 HTTP 请求
+type pushRequest struct {
+	Service string            // 服务名称，用于日志与错误信息
+	URL     string            // 请求地址
+	Secret  string            // 可能被响应回显的密钥，返回错误前替换为 ***（地址中的密钥由 redactURL 处理）
+	Header  map[string]string // 额外请求头
+	Body    interface{}       // 请求体，编码为 JSON
+	Success successCondition  // 成功条件
+	Network netconf.Options   // 网络选项
+}
+
+// sendPush 经 postWebhook 发送推送请求，并按 Success 判断响应是否成功
+func (n *Notifier) sendPush(p pushRequest) error {
+	if err := n.postWebhook(p.Network, p.URL, p.Header, p.Body, p.Success); err != nil {
+		msg := err.Error()
+		if p.Secret != "" {
+			msg = strings.ReplaceAll(msg, p.Secret, "***")
+		}
+		return fmt.Errorf("%s %s", p.Service, msg)
+	}
+	return nil
+}
+
+// serverChan3Key 匹配 Server酱³ 的 SendKey（sctp<uid>t...），uid 决定推送地址
+var serverChan3Key = regexp.MustCompile(`^sctp(\d+)t`)
+
+// serverChanURL 按 SendKey 返回推送地址
+func serverChanURL(key string) string {
+	if m := serverChan3Key.FindStringSubmatch(key); m != nil {
+		return fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], key)
+	}
+	return serverChanAPI + "/" + key + ".send"
+}
+
+// sendServerChan 发送 Server酱 通知，响应 code 为 0 表示成功
+func (n *Notifier) sendServerChan(config NotificationConfig, title, content string) error {
+	var sc ServerChanConfig
+	config.decode(&sc)
+	key := strings.TrimSpace(sc.SendKey)
+	if key == "" {
+		return fmt.Errorf("Server酱 SendKey 不能为空")
+	}
+	return n.sendPush(pushRequest{
+		Service: "Server酱",
+		URL:     serverChanURL(key),
+		Secret:  key,
+		Body:    map[string]string{"title": title, "desp": content},
+		Network: config.Network,
+		Success: successCondition{JSONPath: "$.code", JSONValue: "0", MessagePath: "$.message"},
+	})
+}
+
+// sendPushPlus 发送 PushPlus 通知，响应 code 为 200 表示成功
+func (n *Notifier) sendPushPlus(config NotificationConfig, title, content string) error {
+	var pp PushPlusConfig
+	config.decode(&pp)
+	if pp.Token == "" {
+		return fmt.Errorf("PushPlus token 不能为空")
+	}
+	body := map[string]string{
+		"token":    pp.Token,
+		"title":    title,
+		"content":  content,
+		"template": "txt",
+	}
+	if pp.Topic != "" {
+		body["topic"] = pp.Topic
+	}
+	return n.sendPush(pushRequest{
+		Service: "PushPlus",
+		URL:     pushPlusAPI,
+		Secret:  pp.Token,
+		Body:    body,
+		Network: config.Network,
+		Success: successCondition{JSONPath: "$.code", JSONValue: "200", MessagePath: "$.msg"},
+	})
+}
+
+// sendBark 发送 Bark 通知，响应 code 为 200 表示成功
+func (n *Notifier) sendBark(config NotificationConfig, title, content string) error {
+	var bk BarkConfig
+	config.decode(&bk)
+	if bk.DeviceKey == "" {
+		return fmt.Errorf("Bark 设备 key 不能为空")
+	}
+	body := map[string]string{
+		"device_key": bk.DeviceKey,
+		"title":      title,
+		"body":       content,
+	}
+	if bk.Group != "" {
+		body["group"] = bk.Group
+	}
+	if bk.Sound != "" {
+		body["sound"] = bk.Sound
+	}
+	return n.sendPush(pushRequest{
+		Service: "Bark",
+		URL:     serverBase(bk.Server, defaultBarkServer) + "/push",
+		Secret:  bk.DeviceKey,
+		Body:    body,
+		Network: config.Network,
+		Success: successCondition{JSONPath: "$.code", JSONValue: "200", MessagePath: "$.message"},
+	})
+}
+
+// sendNtfy 以 JSON 方式发布到 ntfy 主题，2xx 表示成功
+func (n *Notifier) sendNtfy(config NotificationConfig, title, content string) error {
+	var nt NtfyConfig
+	config.decode(&nt)
+	topic := strings.TrimSpace(nt.Topic)
+	if topic == "" {
+		return fmt.Errorf("ntfy 主题不能为空")
+	}
+	body := map[string]interface{}{
+		"topic":   topic,
+		"title":   title,
+		"message": content,
+	}
+	if nt.Priority >= 1 && nt.Priority <= 5 {
+		body["priority"] = nt.Priority
+	}
+	if tags := parseRecipients(nt.Tags); len(tags) > 0 {
+		body["tags"] = tags
+	}
+	var header map[string]string
+	if nt.Token != "" {
+		header = map[string]string{"Authorization": "Bearer " + nt.Token}
+	}
+	return n.sendPush(pushRequest{
+		Service: "ntfy",
+		URL:     serverBase(nt.Server, defaultNtfyServer),
+		Secret:  nt.Token,
+		Header:  header,
+		Body:    body,
+		Network: config.Network,
+	})
+}
+
+// sendGotify 发送 Gotify 消息，2xx 表示成功
+func (n *Notifier) sendGotify(config NotificationConfig, title, content string) error {
+	var gt GotifyConfig
+	config.decode(&gt)
+	server := serverBase(gt.Server, "")
+	if server == "" {
+		return fmt.Errorf("Gotify 服务器地址不能为空")
+	}
+	if gt.AppToken == "" {
+		return fmt.Errorf("Gotify 应用 token 不能为空")
+	}
+	body := map[string]interface{}{
+		"title":   title,
+		"message": content,
+	}
+	if gt.Priority > 0 {
+		body["priority"] = gt.Priority
+	}
+	return n.sendPush(pushRequest{
+		Service: "Gotify",
+		URL:     server + "/message",
+		Secret:  gt.AppToken,
+		Header:  map[string]string{"X-Gotify-Key": gt.AppToken},
+		Body:    body,
+		Network: config.Network,
+	})
+}
+
+// serverBase 规范化服务器地址（去掉末尾斜杠），留空时返回默认地址
+func serverBase(server, def string) string {
+	server = strings.TrimRight(strings.TrimSpace(server), "/")
+	if server == "" {
+		return def
+	}
+	return server
+}
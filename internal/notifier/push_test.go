@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSendBark(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"code":200,"message":"success"}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "bark", Settings: settingsOf(BarkConfig{Server: fake.URL + "/", DeviceKey: "devkey", Group: "RemoteKnown"})}
+	if err := n.sendNotification(cfg, "远程控制告警", "ToDesk 正在运行"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	rec := fake.Last()
+	if rec.Path != "/push" || rec.Body["device_key"] != "devkey" || rec.Body["body"] != "ToDesk 正在运行" || rec.Body["group"] != "RemoteKnown" {
+		t.Errorf("Bark 请求不正确: %s %v", rec.Path, rec.Body)
+	}
+	if _, ok := rec.Body["sound"]; ok {
+		t.Errorf("未配置提示音时不应发送 sound 字段")
+	}
+
+	cfg.Settings["server"] = startFakeHTTP(t, http.StatusBadRequest, `{"code":400,"message":"failed to get device token: devkey"}`).URL
+	err := n.sendNotification(cfg, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "failed to get device token") {
+		t.Errorf("Bark 返回错误时应报错，实际 %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "devkey") {
+		t.Errorf("错误信息不应包含设备 key: %v", err)
+	}
+}
+
+func TestSendNtfy(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"id":"abc","event":"message"}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "ntfy", Settings: settingsOf(NtfyConfig{Server: fake.URL, Topic: "office-pc", Priority: 4, Tags: "warning, computer", Token: "tk_1"})}
+	if err := n.sendNotification(cfg, "远程控制告警", "正文"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	rec := fake.Last()
+	if rec.Path != "/" || rec.Body["topic"] != "office-pc" || rec.Body["message"] != "正文" || rec.Body["priority"] != 4.0 {
+		t.Errorf("ntfy 请求不正确: %s %v", rec.Path, rec.Body)
+	}
+	if tags, _ := rec.Body["tags"].([]interface{}); len(tags) != 2 || tags[1] != "computer" {
+		t.Errorf("标签应拆分为数组，实际 %v", rec.Body["tags"])
+	}
+	if got := rec.Header.Get("Authorization"); got != "Bearer tk_1" {
+		t.Errorf("应携带访问令牌，实际 %q", got)
+	}
+
+	cfg.Settings["server"] = startFakeHTTP(t, http.StatusForbidden, `{"code":40301,"http":403,"error":"forbidden"}`).URL
+	if err := n.sendNotification(cfg, "t", "c"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("ntfy 返回 403 时应报错，实际 %v", err)
+	}
+}
+
+func TestSendGotify(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"id":1}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "gotify", Settings: settingsOf(GotifyConfig{Server: fake.URL, AppToken: "AppTok", Priority: 8})}
+	if err := n.sendNotification(cfg, "远程控制告警", "正文"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	rec := fake.Last()
+	if rec.Path != "/message" || rec.Header.Get("X-Gotify-Key") != "AppTok" || rec.Body["priority"] != 8.0 {
+		t.Errorf("Gotify 请求不正确: %s %v %v", rec.Path, rec.Header, rec.Body)
+	}
+
+	if err := n.sendNotification(NotificationConfig{Type: "gotify", Settings: settingsOf(GotifyConfig{AppToken: "x"})}, "t", "c"); err == nil {
+		t.Errorf("未填写服务器地址时应报错")
+	}
+}
+
+func TestServerChanURL(t *testing.T) {
+	if got := serverChanURL("SCT123abc"); got != "https://sctapi.ftqq.com/SCT123abc.send" {
+		t.Errorf("Turbo 版地址不正确: %s", got)
+	}
+	if got := serverChanURL("sctp42tXYZ"); got != "https://42.push.ft07.com/send/sctp42tXYZ.send" {
+		t.Errorf("Server酱³ 地址不正确: %s", got)
+	}
+}
+
+// TestPushChannelSecrets 验证推送渠道的敏感字段加密落库，读取配置时解密
+func TestPushChannelSecrets(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	err := n.SaveChannels([]Channel{{
+		ID: "pp", Name: "PushPlus", Type: "pushplus", Enabled: true,
+		Settings: map[string]interface{}{"token": "pp-token", "topic": "ops"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mustChannel(t, n, "pp")
+	if v := stringField(c.Settings, "token"); v == "pp-token" {
+		t.Errorf("token 应加密保存")
+	}
+	cfg := n.channelConfig(c)
+	if cfg.Settings["token"] != "pp-token" || cfg.Settings["topic"] != "ops" {
+		t.Errorf("读取配置时应解密 token，实际 %+v", cfg.Settings)
+	}
+}
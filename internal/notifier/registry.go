@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChannelDriver 是一种通知渠道类型的实现。实现者在 init 中调用 RegisterChannel 注册，
+// 渠道设置按 Schema 描述的结构保存（敏感字段由 Schema 中的 writeOnly 标出，自动加密与打码），
+// 发送时解密后放在 config.Settings 中，渠道用 config.decode 反序列化为自己的设置结构。
+type ChannelDriver interface {
+	// Type 返回渠道类型标识，即渠道设置的键（如 "feishu"）
+	Type() string
+	// Schema 返回渠道设置的 JSON Schema，Title 为界面显示名称
+	Schema() *Schema
+	// Validate 检查设置是否完整有效
+	Validate(config NotificationConfig) error
+	// Send 发送事件
+	Send(n *Notifier, config NotificationConfig, ev *Event) error
+	// Test 发送测试通知
+	Test(n *Notifier, config NotificationConfig) error
+}
+
+// Schema 是 JSON Schema（draft 2020-12）的子集，足以描述渠道设置表单
+type Schema struct {
+	Type                 string             `json:"type"` // object / string / integer / boolean
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"` // object 中任意键的值（如请求头）
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Format               string             `json:"format,omitempty"`    // uri / email / textarea
+	Minimum              *float64           `json:"minimum,omitempty"`   // integer 的最小值
+	Maximum              *float64           `json:"maximum,omitempty"`   // integer 的最大值
+	WriteOnly            bool               `json:"writeOnly,omitempty"` // 敏感字段：加密保存，读取时打码
+	Order                []string           `json:"x-order,omitempty"`   // 属性在表单中的显示顺序
+}
+
+// Defaults 返回 object 类型各属性的默认值（未声明默认值的字符串为空串），用于初始化空表单
+func (s *Schema) Defaults() map[string]interface{} {
+	out := make(map[string]interface{}, len(s.Properties))
+	for name, p := range s.Properties {
+		switch {
+		case p.Default != nil:
+			out[name] = p.Default
+		case p.Type == "string":
+			out[name] = ""
+		case p.Type == "integer":
+			out[name] = 0
+		case p.Type == "boolean":
+			out[name] = false
+		}
+	}
+	return out
+}
+
+// sensitive 返回标记为 writeOnly 的属性名（按名称排序）
+func (s *Schema) sensitive() []string {
+	var out []string
+	for name, p := range s.Properties {
+		if p.WriteOnly {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// field 构造一个字符串属性
+func field(title, description string) *Schema {
+	return &Schema{Type: "string", Title: title, Description: description}
+}
+
+// secretField 构造一个敏感的字符串属性（加密保存，读取时打码）
+func secretField(title, description string) *Schema {
+	s := field(title, description)
+	s.WriteOnly = true
+	return s
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]ChannelDriver)
+)
+
+// RegisterChannel 注册渠道实现：登记其敏感字段，并加入渠道类型列表（已在列表中的类型保持原有顺序）。
+// 重复注册同一类型会 panic。
+func RegisterChannel(d ChannelDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	typ := d.Type()
+	if _, dup := drivers[typ]; dup {
+		panic(fmt.Sprintf("notifier: 渠道类型 %s 重复注册", typ))
+	}
+	drivers[typ] = d
+
+	schema := d.Schema()
+	sensitiveFields[typ] = schema.sensitive()
+	for i := range channelTypes {
+		if channelTypes[i].Type == typ {
+			channelTypes[i].Label = schema.Title
+			channelTypes[i].Schema = schema
+			return
+		}
+	}
+	channelTypes = append(channelTypes, ChannelType{Type: typ, Label: schema.Title, Schema: schema})
+}
+
+// channelDriver 返回已注册的渠道实现，未注册时返回 nil
+func channelDriver(typ string) ChannelDriver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return drivers[typ]
+}
+
+// ChannelDrivers 返回已注册的渠道实现（按渠道类型列表的顺序）
+func ChannelDrivers() []ChannelDriver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	var out []ChannelDriver
+	for _, t := range channelTypes {
+		if d, ok := drivers[t.Type]; ok {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// sendsTest 提供 ChannelDriver.Test 的默认实现：校验设置后发送一条测试通知
+type sendsTest struct{}
+
+func (sendsTest) Test(n *Notifier, config NotificationConfig) error {
+	if d := channelDriver(config.Type); d != nil {
+		if err := d.Validate(config); err != nil {
+			return err
+		}
+	}
+	return n.SendTestNotification(config)
+}
+
+// BotConfig 是群机器人类渠道（飞书、钉钉、Slack、Teams、Discord）的设置
+type BotConfig struct {
+	WebhookURL string `json:"webhook_url"` // Webhook 地址，带有机器人令牌
+	Secret     string `json:"secret"`      // 签名密钥（可选，飞书/钉钉）
+}
+
+// botSettings 返回群机器人类渠道的设置
+func botSettings(config NotificationConfig) BotConfig {
+	var b BotConfig
+	config.decode(&b)
+	return b
+}
+
+// botEndpoint 为群机器人类渠道提供 ChannelEndpoint：请求地址即 Webhook 地址
+type botEndpoint struct{}
+
+func (botEndpoint) Endpoint(config NotificationConfig) string { return botSettings(config).WebhookURL }
+
+// webhookSchema 返回群机器人类渠道（Webhook 地址 + 可选签名密钥）的设置结构；
+// secretDescription 为空表示该平台不支持签名，不提供签名密钥字段
+func webhookSchema(title, urlDescription, secretDescription string) *Schema {
+	webhookURL := field("Webhook 地址", urlDescription)
+	webhookURL.Format = "uri"
+	webhookURL.WriteOnly = true // 地址中带有机器人令牌
+	s := &Schema{
+		Type:       "object",
+		Title:      title,
+		Properties: map[string]*Schema{"webhook_url": webhookURL},
+		Required:   []string{"webhook_url"},
+		Order:      []string{"webhook_url"},
+	}
+	if secretDescription != "" {
+		secret := field("签名密钥", secretDescription)
+		secret.WriteOnly = true
+		s.Properties["secret"] = secret
+		s.Order = append(s.Order, "secret")
+	}
+	return s
+}
+
+// validateWebhookURL 检查 Webhook 地址已填写且为 http(s) 地址
+func validateWebhookURL(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return fmt.Errorf("Webhook 地址不能为空")
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("Webhook 地址无效，应以 http:// 或 https:// 开头")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingDriver 是只在测试中注册的渠道：设置保存在 config.Settings 中，记录收到的事件
+type recordingDriver struct {
+	sendsTest
+	mu   sync.Mutex
+	sent []string // "token|标题"
+}
+
+func (*recordingDriver) Type() string { return "test_recording" }
+
+func (*recordingDriver) Schema() *Schema {
+	token := field("令牌", "")
+	token.WriteOnly = true
+	return &Schema{
+		Type:       "object",
+		Title:      "测试渠道",
+		Properties: map[string]*Schema{"room": field("房间", ""), "token": token, "level": {Type: "integer", Default: 3}},
+		Required:   []string{"room"},
+	}
+}
+
+func (*recordingDriver) Validate(config NotificationConfig) error {
+	if room, _ := config.Settings["room"].(string); room == "" {
+		return fmt.Errorf("房间不能为空")
+	}
+	return nil
+}
+
+func (d *recordingDriver) Send(_ *Notifier, config NotificationConfig, ev *Event) error {
+	token, _ := config.Settings["token"].(string)
+	d.mu.Lock()
+	d.sent = append(d.sent, token+"|"+ev.Title)
+	d.mu.Unlock()
+	return nil
+}
+
+var testDriver = &recordingDriver{}
+
+func init() {
+	RegisterChannel(testDriver)
+}
+
+// TestRegisteredDriver 验证注册的渠道只需实现 ChannelDriver：出现在类型列表中并附带 Schema，
+// 敏感字段按 writeOnly 自动加密，设置经通用存储交给 Send。
+func TestRegisteredDriver(t *testing.T) {
+	var found *ChannelType
+	for _, ct := range ChannelTypes() {
+		if ct.Type == "test_recording" {
+			found = &ct
+		}
+	}
+	if found == nil || found.Label != "测试渠道" || found.Schema == nil {
+		t.Fatalf("注册的渠道应出现在类型列表中并附带 Schema: %+v", found)
+	}
+
+	// testDriver 是包级变量，清空之前（如 -count=2）的记录
+	testDriver.mu.Lock()
+	testDriver.sent = nil
+	testDriver.mu.Unlock()
+
+	n, st := newTestNotifier(t)
+	c := Channel{ID: "rec", Name: "测试", Type: "test_recording", Enabled: true, Settings: map[string]interface{}{"token": "tk-1"}}
+	if err := n.ValidateChannel(c); err == nil || !strings.Contains(err.Error(), "房间不能为空") {
+		t.Errorf("启用的渠道缺少必填项时应校验失败，实际 %v", err)
+	}
+	c.Settings["room"] = "ops"
+	if err := n.SaveChannels([]Channel{c}); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := st.GetConfig("notification_channels"); strings.Contains(raw, "tk-1") {
+		t.Fatalf("writeOnly 字段应加密落库: %s", raw)
+	}
+
+	n.dispatch(sampleEvent())
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	if len(testDriver.sent) != 1 || !strings.HasPrefix(testDriver.sent[0], "tk-1|") {
+		t.Errorf("渠道应收到解密后的设置与事件: %v", testDriver.sent)
+	}
+}
+
+func TestBuiltinDrivers(t *testing.T) {
+	for _, ct := range ChannelTypes() {
+		if channelDriver(ct.Type) == nil || ct.Schema == nil || ct.Label == "" {
+			t.Errorf("%s 应注册为 ChannelDriver 并提供设置结构: %+v", ct.Type, ct)
+		}
+	}
+	for typ, want := range map[string]string{
+		"email":    "oauth2_client_secret,oauth2_refresh_token,password",
+		"telegram": "bot_token",
+		"webhook":  "secret",
+		"mqtt":     "password",
+		"gotify":   "app_token",
+		"wecom":    "webhook_url",
+	} {
+		if got := strings.Join(sensitiveFields[typ], ","); got != want {
+			t.Errorf("%s 的敏感字段应取自 Schema: %s", typ, got)
+		}
+	}
+	for _, typ := range []string{"wecom", "slack", "webhook", "telegram", "bark", "pagerduty"} {
+		if _, ok := channelDriver(typ).(ChannelEndpoint); !ok {
+			t.Errorf("%s 应提供请求地址用于分步诊断", typ)
+		}
+	}
+	if d := channelDriver("feishu").Schema().Defaults(); d["webhook_url"] != "" || d["secret"] != "" {
+		t.Errorf("飞书默认设置不正确: %v", d)
+	}
+	if d := channelDriver("email").Schema().Defaults(); d["encryption"] != "none" || d["smtp_port"] != 0 {
+		t.Errorf("邮件默认设置不正确: %v", d)
+	}
+
+	if err := channelDriver("dingtalk").Validate(NotificationConfig{Settings: map[string]interface{}{"webhook_url": "oapi.dingtalk.com/robot/send"}}); err == nil {
+		t.Errorf("缺少协议的 Webhook 地址应校验失败")
+	}
+	err := channelDriver("email").Validate(NotificationConfig{Settings: settingsOf(EmailConfig{SMTPHost: "h", From: "a@b.c", To: "d@e.f", AuthMode: EmailAuthOAuth2})})
+	if err == nil || !strings.Contains(err.Error(), "OAuth2") {
+		t.Errorf("OAuth2 认证缺少令牌设置时应校验失败，实际 %v", err)
+	}
+}
+
+func TestRegisterChannelDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("重复注册同一类型应 panic")
+		}
+	}()
+	RegisterChannel(feishuDriver{})
+}
+
+// settingsOf 把渠道的设置结构转换为 NotificationConfig.Settings
+func settingsOf(v interface{}) map[string]interface{} {
+	var settings map[string]interface{}
+	b, _ := json.Marshal(v)
+	json.Unmarshal(b, &settings)
+	return settings
+}
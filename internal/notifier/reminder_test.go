@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/settings"
+)
+
+func TestReminderEventDuration(t *testing.T) {
+	ev := &Event{Type: EventRemoteReminder, Time: time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)}
+	ev.setSession(detector.SessionEvent{
+		SessionID: "s1",
+		StartTime: ev.Time.Add(-(2*time.Hour + 30*time.Minute)),
+		Signals:   []detector.Signal{{Name: "ToDesk (进程存在)", Tool: "ToDesk"}},
+	})
+	if ev.Duration != "02:30:00" {
+		t.Errorf("提醒事件应按事件时间计算已持续时长，实际 %q", ev.Duration)
+	}
+	_, content, err := renderMessage(defaultTemplates[EventRemoteReminder], ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "已持续 02:30:00") || !strings.Contains(content, "远程工具：ToDesk") {
+		t.Errorf("提醒正文应包含时长与工具: %s", content)
+	}
+}
+
+// TestEscalationReachesEscalationChannels 验证升级通知额外发送到配置的升级渠道（不受其订阅条件限制），
+// 且提醒不参与去重。
+func TestEscalationReachesEscalationChannels(t *testing.T) {
+	n, _ := newTestNotifier(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	err := n.SaveChannels([]Channel{
+		{ID: "team", Name: "值班群", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL + "/team"}},
+		{ID: "security", Name: "安全主管", Type: "slack", Enabled: true, Settings: map[string]interface{}{"webhook_url": srv.URL + "/security"},
+			Filter: ChannelFilter{Events: []string{EventRemoteStart}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.settings.Set(settings.KeySessionEscalationChannels, `["security"]`); err != nil {
+		t.Fatal(err)
+	}
+
+	se := detector.SessionEvent{SessionID: "s1", StartTime: time.Now().Add(-3 * time.Hour)}
+	n.NotifyRemoteReminder(se)
+	n.NotifyRemoteReminder(se)
+	n.NotifyRemoteEscalation(se)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/team"] != 3 {
+		t.Errorf("值班群应收到 2 条提醒 + 1 条升级，实际 %d", hits["/team"])
+	}
+	if hits["/security"] != 1 {
+		t.Errorf("升级渠道应只收到升级通知，实际 %d", hits["/security"])
+	}
+}
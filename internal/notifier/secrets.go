@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/settings"
+)
+
+// sensitiveFields 列出各通知类型需加密落库、API 返回时打码的字段，由 RegisterChannel 按 Schema 中的 writeOnly 登记。
+// 群机器人的 Webhook 地址本身带有令牌（如企业微信的 ?key=），整个地址按密钥处理。
+var sensitiveFields = make(map[string][]string)
+
+// LoadConfigs 读取旧版 notification_configs 的原始结构（仅用于迁移）。未配置时返回 (nil, nil)。
+func (n *Notifier) LoadConfigs() (map[string]interface{}, error) {
+	var allConfigs map[string]interface{}
+	if err := n.settings.GetJSON(settings.KeyNotificationConfigs, &allConfigs); err != nil {
+		return nil, err
+	}
+	return allConfigs, nil
+}
+
+// sealSettings 加密某类型配置子项中的敏感字段（原地修改）。
+// 字段值为掩码时表示前端未修改，沿用 previous 中已有的值。
+func (n *Notifier) sealSettings(typ string, sub, previous map[string]interface{}) error {
+	for _, field := range sensitiveFields[typ] {
+		v := stringField(sub, field)
+		if v == secret.Mask {
+			v = stringField(previous, field)
+		}
+		sealed, err := n.seal(v)
+		if err != nil {
+			return fmt.Errorf("加密 %s.%s 失败: %w", typ, field, err)
+		}
+		if sealed != "" || sub[field] != nil {
+			sub[field] = sealed
+		}
+	}
+	return nil
+}
+
+// openSettings 解密某类型配置子项中的敏感字段（原地修改）。
+func (n *Notifier) openSettings(typ string, sub map[string]interface{}) {
+	for _, field := range sensitiveFields[typ] {
+		if v := stringField(sub, field); v != "" {
+			sub[field] = n.open(v)
+		}
+	}
+}
+
+// maskSettings 把某类型配置子项中非空的敏感字段替换为掩码（原地修改）。
+func maskSettings(typ string, sub map[string]interface{}) {
+	for _, field := range sensitiveFields[typ] {
+		if stringField(sub, field) != "" {
+			sub[field] = secret.Mask
+		}
+	}
+}
+
+func stringField(m map[string]interface{}, field string) string {
+	v, _ := m[field].(string)
+	return v
+}
+
+// sealStoredSecrets 迁移旧版通知配置，并把以明文保存的敏感字段加密落库（启动时执行一次）。
+func (n *Notifier) sealStoredSecrets() error {
+	if err := n.migrateLegacyConfigs(); err != nil {
+		return fmt.Errorf("迁移旧版通知配置失败: %w", err)
+	}
+
+	channels, err := n.LoadChannels()
+	if err != nil {
+		return err
+	}
+	for _, c := range channels {
+		if hasPlaintextSecret(c) {
+			if err := n.SaveChannels(channels); err != nil {
+				return err
+			}
+			log.Printf("[通知器] 已加密通知渠道中的明文密钥")
+			break
+		}
+	}
+	return nil
+}
+
+func hasPlaintextSecret(c Channel) bool {
+	for _, field := range sensitiveFields[c.Type] {
+		if v := stringField(c.Settings, field); v != "" && !secret.IsSealed(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// seal / open 在未配置密钥时（单元测试）按明文处理。
+func (n *Notifier) seal(v string) (string, error) {
+	if n.box == nil {
+		return v, nil
+	}
+	return n.box.Seal(v)
+}
+
+func (n *Notifier) open(v string) string {
+	if n.box == nil {
+		return v
+	}
+	plain, err := n.box.Open(v)
+	if err != nil {
+		log.Printf("[通知器] %v", err)
+		return ""
+	}
+	return plain
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/storage"
+)
+
+func newTestNotifier(t *testing.T) (*Notifier, *storage.Storage) {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := storage.NewStorage(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("初始化存储失败: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	box, err := secret.LoadOrCreateKey(filepath.Join(dir, "secret.key"))
+	if err != nil {
+		t.Fatalf("初始化密钥失败: %v", err)
+	}
+	return NewNotifier(st, box), st
+}
+
+// TestSaveChannelsSealsAndKeepsMasked 验证敏感字段加密落库、打码返回，且回传掩码时保留原值。
+func TestSaveChannelsSealsAndKeepsMasked(t *testing.T) {
+	n, st := newTestNotifier(t)
+
+	err := n.SaveChannels([]Channel{
+		{ID: "dingtalk", Type: "dingtalk", Enabled: true, Settings: map[string]interface{}{"webhook_url": "https://example.com/hook", "secret": "SECabc"}},
+		{ID: "email", Type: "email", Settings: map[string]interface{}{"smtp_host": "smtp.example.com", "password": "p@ss"}},
+	})
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	raw, _ := st.GetConfig("notification_channels")
+	if strings.Contains(raw, "SECabc") || strings.Contains(raw, "p@ss") {
+		t.Fatalf("敏感字段以明文落库: %s", raw)
+	}
+
+	channels, err := n.LoadChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	MaskChannels(channels)
+	if got := channels[0].Settings["secret"]; got != secret.Mask {
+		t.Errorf("secret 应被打码，实际 %v", got)
+	}
+
+	// 前端原样回传掩码：保留原值
+	if err := n.SaveChannels(channels); err != nil {
+		t.Fatal(err)
+	}
+	channels, _ = n.LoadChannels()
+	if cfg := n.channelConfig(channels[0]); cfg.Settings["secret"] != "SECabc" {
+		t.Errorf("回传掩码后 secret = %q, 期望保留原值", cfg.Settings["secret"])
+	}
+
+	test := Channel{ID: "email", Type: "email", Settings: map[string]interface{}{"password": secret.Mask}}
+	if err := n.FillChannelSecrets(&test); err != nil {
+		t.Fatal(err)
+	}
+	if test.Settings["password"] != "p@ss" {
+		t.Errorf("测试通知的掩码密码应被替换为真实值，实际 %q", test.Settings["password"])
+	}
+}
+
+// TestSealStoredSecretsMigratesPlaintext 验证旧版本明文保存的通知配置在启动时迁移为渠道并加密。
+func TestSealStoredSecretsMigratesPlaintext(t *testing.T) {
+	n, st := newTestNotifier(t)
+	st.SetConfig("notification_configs", `{"enabled":true,"type":"feishu","feishu":{"webhook_url":"u","secret":"plain"},"email":{"smtp_host":"h","password":"pw"},"dingtalk":{"webhook_url":""}}`)
+
+	if err := n.sealStoredSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := st.GetConfig("notification_channels")
+	if strings.Contains(raw, `"plain"`) || strings.Contains(raw, `"pw"`) {
+		t.Fatalf("明文密钥未被加密: %s", raw)
+	}
+	if legacy, _ := st.GetConfig("notification_configs"); legacy != "" {
+		t.Errorf("迁移后旧配置应被清空，实际 %s", legacy)
+	}
+
+	channels, err := n.LoadChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 2 {
+		t.Fatalf("应迁移出 2 个渠道（未填写的钉钉跳过），实际 %d", len(channels))
+	}
+	if c := channels[0]; c.ID != "feishu" || !c.Enabled || n.channelConfig(c).Settings["secret"] != "plain" {
+		t.Errorf("飞书渠道迁移结果不正确: %+v", c)
+	}
+	if c := channels[1]; c.ID != "email" || c.Enabled {
+		t.Errorf("未选中的邮件渠道应迁移为停用状态: %+v", c)
+	}
+}
+
+// TestWebhookURLIsSecret 验证群机器人 Webhook 地址（含令牌）加密落库、打码返回，回传掩码时按已保存的地址校验与发送
+func TestWebhookURLIsSecret(t *testing.T) {
+	n, st := newTestNotifier(t)
+	const wecomURL = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=693a91f6-7xxx"
+	err := n.SaveChannels([]Channel{
+		{ID: "w", Type: "wecom", Enabled: true, Settings: map[string]interface{}{"webhook_url": wecomURL}},
+		{ID: "f", Type: "feishu", Enabled: true, Settings: map[string]interface{}{"webhook_url": "https://open.feishu.cn/open-apis/bot/v2/hook/tok-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := st.GetConfig("notification_channels"); strings.Contains(raw, "693a91f6") || strings.Contains(raw, "tok-1") {
+		t.Fatalf("Webhook 地址中的令牌不应明文落库: %s", raw)
+	}
+
+	channels, _ := n.LoadChannels()
+	if got := n.channelConfig(channels[0]).Settings["webhook_url"]; got != wecomURL {
+		t.Errorf("发送时应解密出原地址，实际 %q", got)
+	}
+	MaskChannels(channels)
+	for _, c := range channels {
+		if c.Settings["webhook_url"] != secret.Mask {
+			t.Errorf("%s 的 Webhook 地址应打码返回，实际 %v", c.Type, c.Settings["webhook_url"])
+		}
+	}
+
+	masked := Channel{ID: "f", Type: "feishu", Enabled: true, Settings: map[string]interface{}{"webhook_url": secret.Mask}}
+	if err := n.ValidateChannel(masked); err != nil {
+		t.Errorf("回传掩码时应按已保存的地址校验: %v", err)
+	}
+	if masked.Settings["webhook_url"] != secret.Mask {
+		t.Errorf("校验不应修改传入的渠道设置")
+	}
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterChannel(slackDriver{})
+}
+
+// slackDriver 是 Slack 渠道
+type slackDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (slackDriver) Type() string { return "slack" }
+
+func (slackDriver) Schema() *Schema {
+	return webhookSchema("Slack", "Slack App 的 Incoming Webhook 地址（https://hooks.slack.com/services/...）", "")
+}
+
+func (slackDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (slackDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendSlackNotification(config, ev.Title, ev.Content)
+}
+
+// Block Kit 的长度上限：header 的 plain_text 150 字符，section 的文本 3000 字符（按字节截断，不会超出）
+const (
+	slackHeaderLimit  = 150
+	slackSectionLimit = 3000
+)
+
+// sendSlackNotification 通过 Incoming Webhook 发送 Slack 通知（Block Kit）。
+// Slack 成功时返回纯文本 "ok"，失败时返回 4xx 与错误码文本（如 invalid_payload、no_service）。
+func (n *Notifier) sendSlackNotification(config NotificationConfig, title, content string) error {
+	title = truncateUTF8(title, slackHeaderLimit)
+	message := map[string]interface{}{
+		"text": title, // 通知栏 / 不支持 Block Kit 的客户端显示的摘要
+		"blocks": []map[string]interface{}{
+			{
+				"type": "header",
+				"text": map[string]interface{}{"type": "plain_text", "text": title, "emoji": true},
+			},
+			{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": truncateSlackText(escapeSlackText(content), slackSectionLimit)},
+			},
+		},
+	}
+
+	status, body, err := n.postJSON(config.Network, botSettings(config).WebhookURL, nil, message)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK || strings.TrimSpace(string(body)) != "ok" {
+		err := fmt.Errorf("Slack 通知发送失败，状态码: %d，响应: %s", status, summarizeBody(body))
+		if status == http.StatusBadRequest { // invalid_payload 等，消息本身无效
+			return &permanentError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// truncateSlackText 把已转义的 mrkdwn 文本截断到 limit 字节，不留下被截断的 &amp; 等转义序列
+func truncateSlackText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = truncateUTF8(s, limit)
+	if i := strings.LastIndexByte(s, '&'); i >= 0 && !strings.Contains(s[i:], ";") {
+		s = s[:i]
+	}
+	return s
+}
+
+// escapeSlackText 转义 Slack mrkdwn 中的控制字符 &、<、>
+func escapeSlackText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
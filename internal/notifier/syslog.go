@@ -0,0 +1,355 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/version"
+)
+
+func init() {
+	RegisterChannel(syslogDriver{})
+}
+
+// SyslogConfig 是 Syslog（SIEM）渠道配置：RFC 5424 消息经 UDP / TCP / TLS 发送，
+// 消息体可选纯文本、ArcSight CEF 或 IBM QRadar LEEF 格式。
+// TCP / TLS 连接按渠道的网络选项经代理建立，TLS 的 CA 证书、客户端证书与证书校验同样取自网络选项；
+// UDP 不经代理（HTTP CONNECT 与 SOCKS5 隧道只承载 TCP）。
+type SyslogConfig struct {
+	Address  string `json:"address"`  // 接收端地址 host:port；省略端口时 UDP/TCP 为 514、TLS 为 6514
+	Protocol string `json:"protocol"` // "udp"（默认） / "tcp" / "tls"
+	Format   string `json:"format"`   // 消息体格式："rfc5424"（默认，纯文本） / "cef" / "leef"
+	Facility string `json:"facility"` // 设施名，如 local0（默认） / auth / authpriv / user
+	AppName  string `json:"app_name"` // APP-NAME 字段，默认 RemoteKnown
+}
+
+// syslogTimeout 是写入 syslog 接收端的默认超时时间（网络选项未设置整体超时时）
+const syslogTimeout = 10 * time.Second
+
+// syslogEnterpriseID 是结构化数据 SD-ID 中的企业号（RFC 5612 文档保留号）
+const syslogEnterpriseID = "32473"
+
+// syslogFacilities 是设施名到编号的映射（RFC 5424 第 6.2.1 节）
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14, "clock": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogEvent 是事件类型在 SIEM 中的固定标识：ID 与名称在各版本间保持不变，便于编写关联规则
+type syslogEvent struct {
+	ID       int    // 事件 ID（CEF Signature ID / LEEF Event ID / 结构化数据 eventId）
+	Name     string // CEF Name
+	Severity int    // syslog 严重级别（0 紧急 ~ 7 调试）
+	Level    int    // CEF / LEEF 严重级别（0 ~ 10）
+}
+
+// syslogEvents 是各事件类型的固定标识
+var syslogEvents = map[string]syslogEvent{
+	EventRemoteStart:      {1001, "Remote control session started", 4, 7},
+	EventRemoteEnd:        {1002, "Remote control session ended", 5, 3},
+	EventRemoteReminder:   {1003, "Remote control session still active", 5, 5},
+	EventRemoteEscalation: {1004, "Remote control session exceeded escalation threshold", 3, 9},
+	EventRulesUpdated:     {2001, "Detection rules updated", 5, 3},
+	EventAppStart:         {3001, "RemoteKnown daemon started", 6, 2},
+	EventAppExit:          {3002, "RemoteKnown daemon stopped", 4, 5},
+	EventDigest:           {4001, "Remote control digest report", 6, 1},
+	EventSummary:          {4002, "Duplicate notifications summarized", 5, 3},
+	EventTest:             {9001, "Test notification", 6, 0},
+}
+
+// syslogEventFor 返回事件类型的固定标识；未登记的类型使用 9999
+func syslogEventFor(eventType string) syslogEvent {
+	if e, ok := syslogEvents[eventType]; ok {
+		return e
+	}
+	return syslogEvent{9999, eventType, 6, 1}
+}
+
+// syslogDriver 是 Syslog（SIEM）渠道
+type syslogDriver struct{ sendsTest }
+
+func (syslogDriver) Type() string { return "syslog" }
+
+func (syslogDriver) Schema() *Schema {
+	facilities := make([]interface{}, 0, len(syslogFacilities))
+	for name := range syslogFacilities {
+		facilities = append(facilities, name)
+	}
+	sort.Slice(facilities, func(i, j int) bool {
+		return syslogFacilities[facilities[i].(string)] < syslogFacilities[facilities[j].(string)]
+	})
+	return &Schema{
+		Type:        "object",
+		Title:       "Syslog（SIEM）",
+		Description: "以 RFC 5424 消息发送到 SIEM，消息体可选纯文本、ArcSight CEF 或 QRadar LEEF",
+		Properties: map[string]*Schema{
+			"address": field("接收端地址", "host:port，省略端口时 UDP/TCP 为 514、TLS 为 6514"),
+			"protocol": {Type: "string", Title: "传输协议", Enum: []interface{}{"udp", "tcp", "tls"}, Default: "udp",
+				Description: "TLS 的 CA 证书、客户端证书与代理在渠道的网络选项中设置；UDP 不经代理"},
+			"format":   {Type: "string", Title: "消息格式", Enum: []interface{}{"rfc5424", "cef", "leef"}, Default: "rfc5424"},
+			"facility": {Type: "string", Title: "设施", Enum: facilities, Default: "local0"},
+			"app_name": field("APP-NAME", "留空为 RemoteKnown"),
+		},
+		Required: []string{"address"},
+		Order:    []string{"address", "protocol", "format", "facility", "app_name"},
+	}
+}
+
+func (syslogDriver) Validate(config NotificationConfig) error {
+	_, err := syslogSettings(config).check()
+	return err
+}
+
+func (syslogDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendSyslog(config, ev)
+}
+
+// syslogSettings 返回渠道的 Syslog 设置
+func syslogSettings(config NotificationConfig) SyslogConfig {
+	var sc SyslogConfig
+	config.decode(&sc)
+	return sc
+}
+
+// check 检查设置是否有效，返回设施编号
+func (sc SyslogConfig) check() (int, error) {
+	if strings.TrimSpace(sc.Address) == "" {
+		return 0, fmt.Errorf("Syslog 接收端地址不能为空")
+	}
+	facility := 16
+	if sc.Facility != "" {
+		f, ok := syslogFacilities[strings.ToLower(sc.Facility)]
+		if !ok {
+			return 0, fmt.Errorf("不支持的 Syslog 设施: %s", sc.Facility)
+		}
+		facility = f
+	}
+	switch sc.Format {
+	case "", "rfc5424", "cef", "leef":
+	default:
+		return 0, fmt.Errorf("不支持的 Syslog 消息格式: %s", sc.Format)
+	}
+	switch strings.ToLower(sc.Protocol) {
+	case "", "udp", "tcp", "tls":
+	default:
+		return 0, fmt.Errorf("不支持的 Syslog 传输协议: %s", sc.Protocol)
+	}
+	return facility, nil
+}
+
+// sendSyslog 把事件格式化为 RFC 5424 消息并发送到 syslog 接收端（TCP / TLS 按网络选项建立连接）
+func (n *Notifier) sendSyslog(config NotificationConfig, ev *Event) error {
+	sc := syslogSettings(config)
+	facility, err := sc.check()
+	if err != nil {
+		return err
+	}
+
+	msg := formatSyslog(sc, facility, ev, os.Getpid())
+	proto := strings.ToLower(sc.Protocol)
+	addr := syslogAddress(sc.Address, proto)
+
+	opts := config.Network
+	var conn net.Conn
+	switch proto {
+	case "", "udp":
+		conn, err = net.DialTimeout("udp", addr, opts.ConnectTimeoutDuration())
+	case "tcp":
+		conn, err = opts.Dial(addr)
+	case "tls":
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = opts.DialTLS(addr, host)
+	default:
+		return fmt.Errorf("不支持的 Syslog 传输协议: %s", sc.Protocol)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 Syslog 接收端 %s 失败: %w", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(opts.TimeoutOr(syslogTimeout)))
+
+	frame := []byte(msg)
+	if proto == "tcp" || proto == "tls" {
+		// TCP / TLS 使用 RFC 6587 / RFC 5425 的八位组计数分帧：MSG-LEN SP SYSLOG-MSG
+		frame = []byte(strconv.Itoa(len(msg)) + " " + msg)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		return fmt.Errorf("写入 Syslog 消息失败: %w", err)
+	}
+	return nil
+}
+
+// syslogAddress 在地址未带端口时补全协议的默认端口
+func syslogAddress(address, proto string) string {
+	address = strings.TrimSpace(address)
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	port := "514"
+	if proto == "tls" {
+		port = "6514"
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), port)
+}
+
+// formatSyslog 生成 RFC 5424 消息：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func formatSyslog(sc SyslogConfig, facility int, ev *Event, pid int) string {
+	se := syslogEventFor(ev.Type)
+	appName := sc.AppName
+	if appName == "" {
+		appName = "RemoteKnown"
+	}
+
+	var body string
+	switch sc.Format {
+	case "cef":
+		body = formatCEF(ev, se)
+	case "leef":
+		body = formatLEEF(ev, se)
+	default:
+		body = flattenMessage(ev.Title, ev.Content)
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		facility*8+se.Severity,
+		ev.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		syslogHeaderField(ev.Hostname, 255),
+		syslogHeaderField(appName, 48),
+		pid,
+		syslogHeaderField(ev.Type, 32),
+		syslogStructuredData(ev, se),
+		body)
+}
+
+// syslogHeaderField 把头部字段限制为可打印 ASCII 且不含空格；为空时返回 NILVALUE "-"
+func syslogHeaderField(s string, limit int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+		if b.Len() == limit {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// syslogStructuredData 生成 [remoteknown@32473 eventId="1001" ...]，省略值为空的参数
+func syslogStructuredData(ev *Event, se syslogEvent) string {
+	params := [][2]string{
+		{"eventId", strconv.Itoa(se.ID)},
+		{"eventType", ev.Type},
+		{"device", ev.DeviceName},
+		{"tool", strings.Join(ev.Tools, ",")},
+		{"peer", strings.Join(ev.Peers, ",")},
+		{"sessionId", ev.SessionID},
+	}
+	if ev.DurationSeconds > 0 {
+		params = append(params, [2]string{"durationSeconds", strconv.FormatInt(ev.DurationSeconds, 10)})
+	}
+	if ev.RulesVersion != "" {
+		params = append(params, [2]string{"rulesVersion", ev.RulesVersion})
+	}
+
+	var b strings.Builder
+	b.WriteString("[remoteknown@" + syslogEnterpriseID)
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(p[1])
+		fmt.Fprintf(&b, ` %s="%s"`, p[0], v)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// flattenMessage 把标题与多行正文合并为一行，行之间以 " | " 分隔
+func flattenMessage(title, content string) string {
+	parts := []string{title}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// formatCEF 生成 ArcSight CEF 消息体：CEF:0|Vendor|Product|Version|SignatureID|Name|Severity|Extension
+func formatCEF(ev *Event, se syslogEvent) string {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	ext := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+	fields := [][2]string{
+		{"rt", strconv.FormatInt(ev.Time.UnixMilli(), 10)},
+		{"dvchost", ev.Hostname},
+		{"cat", ev.Type},
+		{"src", firstIP(ev.Peers)},
+		{"cs1Label", "tool"}, {"cs1", strings.Join(ev.Tools, ",")},
+		{"cs2Label", "sessionId"}, {"cs2", ev.SessionID},
+		{"cs3Label", "deviceName"}, {"cs3", ev.DeviceName},
+	}
+	if ev.DurationSeconds > 0 {
+		fields = append(fields, [2]string{"cn1Label", "durationSeconds"}, [2]string{"cn1", strconv.FormatInt(ev.DurationSeconds, 10)})
+	}
+	fields = append(fields, [2]string{"msg", flattenMessage(ev.Title, ev.Content)})
+
+	var parts []string
+	for i, f := range fields {
+		if f[1] == "" || (strings.HasSuffix(f[0], "Label") && i+1 < len(fields) && fields[i+1][1] == "") {
+			continue
+		}
+		parts = append(parts, f[0]+"="+ext.Replace(f[1]))
+	}
+	return fmt.Sprintf("CEF:0|RemoteKnown|RemoteKnown|%s|%d|%s|%d|%s",
+		header.Replace(version.Version), se.ID, header.Replace(se.Name), se.Level, strings.Join(parts, " "))
+}
+
+// formatLEEF 生成 QRadar LEEF 1.0 消息体：LEEF:1.0|Vendor|Product|Version|EventID|属性（制表符分隔）
+func formatLEEF(ev *Event, se syslogEvent) string {
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", "|", "/")
+	fields := [][2]string{
+		{"devTime", ev.Time.Format("Jan 02 2006 15:04:05")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss"},
+		{"sev", strconv.Itoa(se.Level)},
+		{"cat", ev.Type},
+		{"identHostName", ev.Hostname},
+		{"src", firstIP(ev.Peers)},
+		{"tool", strings.Join(ev.Tools, ",")},
+		{"sessionId", ev.SessionID},
+		{"deviceName", ev.DeviceName},
+		{"msg", flattenMessage(ev.Title, ev.Content)},
+	}
+	if ev.DurationSeconds > 0 {
+		fields = append(fields, [2]string{"durationSeconds", strconv.FormatInt(ev.DurationSeconds, 10)})
+	}
+
+	var parts []string
+	for _, f := range fields {
+		if f[1] != "" {
+			parts = append(parts, f[0]+"="+clean.Replace(f[1]))
+		}
+	}
+	return fmt.Sprintf("LEEF:1.0|RemoteKnown|RemoteKnown|%s|%d|%s",
+		clean.Replace(version.Version), se.ID, strings.Join(parts, "\t"))
+}
+
+// firstIP 返回第一个合法的 IP 地址（CEF / LEEF 的 src 字段要求 IP 格式）
+func firstIP(peers []string) string {
+	for _, p := range peers {
+		if net.ParseIP(p) != nil {
+			return p
+		}
+	}
+	return ""
+}
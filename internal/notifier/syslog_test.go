@@ -0,0 +1,212 @@
+package notifier
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+)
+
+// syslogSampleEvent 返回用于 Syslog 测试的远程控制开始事件
+func syslogSampleEvent() *Event {
+	return &Event{
+		Type:       EventRemoteStart,
+		Title:      "⚠️ 远程控制检测告警",
+		Content:    "主机：财务PC\n\n检测到远程控制连接已建立",
+		SessionID:  "s1",
+		DeviceName: `财务"PC"`,
+		Hostname:   "FIN-01",
+		Tool:       "ToDesk",
+		Tools:      []string{"ToDesk"},
+		Peers:      []string{"203.0.113.7"},
+		Time:       time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local),
+	}
+}
+
+func TestFormatSyslogRFC5424(t *testing.T) {
+	ev := syslogSampleEvent()
+	msg := formatSyslog(SyslogConfig{}, 16, ev, 42)
+
+	// local0(16)*8 + warning(4) = 132
+	prefix := "<132>1 " + ev.Time.Format("2006-01-02T15:04:05.000Z07:00") + " FIN-01 RemoteKnown 42 remote_start "
+	if !strings.HasPrefix(msg, prefix) {
+		t.Fatalf("RFC 5424 头部不正确:\n%s", msg)
+	}
+	sd := `[remoteknown@32473 eventId="1001" eventType="remote_start" device="财务\"PC\"" tool="ToDesk" peer="203.0.113.7" sessionId="` + ev.SessionID + `"`
+	if !strings.Contains(msg, sd) {
+		t.Errorf("结构化数据不正确（双引号应转义）:\n%s", msg)
+	}
+	if !strings.HasSuffix(msg, "] ⚠️ 远程控制检测告警 | 主机：财务PC | 检测到远程控制连接已建立") {
+		t.Errorf("纯文本消息应合并为一行:\n%s", msg)
+	}
+}
+
+func TestFormatCEFAndLEEF(t *testing.T) {
+	ev := syslogSampleEvent()
+	ev.Content = "a=b|c\nd"
+
+	cef := formatCEF(ev, syslogEventFor(ev.Type))
+	if !strings.HasPrefix(cef, "CEF:0|RemoteKnown|RemoteKnown|") || !strings.Contains(cef, "|1001|Remote control session started|7|") {
+		t.Errorf("CEF 头部不正确: %s", cef)
+	}
+	for _, want := range []string{"src=203.0.113.7", "cs1Label=tool cs1=ToDesk", `msg=⚠️ 远程控制检测告警 | a\=b|c | d`} {
+		if !strings.Contains(cef, want) {
+			t.Errorf("CEF 扩展字段缺少 %q: %s", want, cef)
+		}
+	}
+	if strings.Contains(cef, "cn1Label") && ev.DurationSeconds == 0 {
+		t.Errorf("值为空的字段及其标签应省略: %s", cef)
+	}
+
+	leef := formatLEEF(ev, syslogEventFor(ev.Type))
+	if !strings.Contains(leef, "|1001|devTime=") || !strings.Contains(leef, "\tsrc=203.0.113.7\ttool=ToDesk\t") {
+		t.Errorf("LEEF 格式不正确: %q", leef)
+	}
+	if strings.Count(leef, "|") != 5 {
+		t.Errorf("LEEF 属性值中的竖线应替换，避免破坏头部: %q", leef)
+	}
+
+	if syslogEventFor("unknown").ID != 9999 {
+		t.Error("未登记的事件类型应使用 9999")
+	}
+}
+
+func TestSendSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "syslog", Settings: settingsOf(SyslogConfig{Address: pc.LocalAddr().String(), Format: "cef", Facility: "authpriv"})}
+	if err := n.deliver(cfg, syslogSampleEvent()); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	buf := make([]byte, 8192)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	k, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:k])
+	// authpriv(10)*8 + warning(4) = 84
+	if !strings.HasPrefix(msg, "<84>1 ") || !strings.Contains(msg, "] CEF:0|RemoteKnown|") {
+		t.Errorf("UDP 消息不正确: %s", msg)
+	}
+}
+
+func TestSendSyslogTCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		length, _ := r.ReadString(' ')
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err == nil {
+			received <- string(body)
+		}
+	}()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "syslog", Settings: settingsOf(SyslogConfig{Address: ln.Addr().String(), Protocol: "tcp", Format: "leef"})}
+	if err := n.deliver(cfg, syslogSampleEvent()); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg, "] LEEF:1.0|RemoteKnown|") {
+			t.Errorf("TCP 消息不正确: %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到 TCP 消息")
+	}
+
+	bad := NotificationConfig{Type: "syslog", Settings: settingsOf(SyslogConfig{Address: ln.Addr().String(), Facility: "nope"})}
+	if err := n.deliver(bad, syslogSampleEvent()); err == nil {
+		t.Error("未知设施应报错")
+	}
+}
+
+// TestSendSyslogTLSUsesNetworkOptions 验证 TLS 连接按渠道网络选项中的 CA 证书校验接收端证书
+func TestSendSyslogTLSUsesNetworkOptions(t *testing.T) {
+	srv := httptest.NewTLSServer(nil) // 仅借用自签名证书
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString(']')
+				if err == nil {
+					received <- line
+				}
+			}()
+		}
+	}()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "syslog", Settings: settingsOf(SyslogConfig{Address: ln.Addr().String(), Protocol: "tls"})}
+	if err := n.deliver(cfg, syslogSampleEvent()); err == nil {
+		t.Fatal("未信任接收端的自签名证书时应报错")
+	}
+
+	cfg.Network = netconf.Options{CACert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))}
+	if err := n.deliver(cfg, syslogSampleEvent()); err != nil {
+		t.Fatalf("网络选项中配置 CA 后应发送成功: %v", err)
+	}
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg, ">1 ") {
+			t.Errorf("TLS 消息不正确: %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到 TLS 消息")
+	}
+}
+
+func TestSyslogAddressDefaultPort(t *testing.T) {
+	cases := map[[2]string]string{
+		{"siem.example.com", "udp"}:      "siem.example.com:514",
+		{"siem.example.com", "tls"}:      "siem.example.com:6514",
+		{"10.0.0.1:1514", "tcp"}:         "10.0.0.1:1514",
+		{"2001:db8::1", "udp"}:           "[2001:db8::1]:514",
+		{"[2001:db8::1]:601", "tcp"}:     "[2001:db8::1]:601",
+		{" siem.example.com ", "tcp"}:    "siem.example.com:514",
+		{"siem.example.com:6514", "tls"}: "siem.example.com:6514",
+	}
+	for in, want := range cases {
+		if got := syslogAddress(in[0], in[1]); got != want {
+			t.Errorf("syslogAddress(%q, %q) = %q，应为 %q", in[0], in[1], got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterChannel(teamsDriver{})
+}
+
+// teamsDriver 是 Microsoft Teams 渠道
+type teamsDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (teamsDriver) Type() string { return "teams" }
+
+func (teamsDriver) Schema() *Schema {
+	return webhookSchema("Microsoft Teams", "Power Automate Workflows 或旧版 Incoming Webhook 的地址", "")
+}
+
+func (teamsDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (teamsDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendTeamsNotification(config, ev)
+}
+
+// sendTeamsNotification 发送 Microsoft Teams 通知（Adaptive Card）。
+// 同时兼容 Power Automate Workflows（成功返回 202）与旧版 Incoming Webhook（成功返回 200 和 "1"）。
+func (n *Notifier) sendTeamsNotification(config NotificationConfig, ev *Event) error {
+	var body []map[string]interface{}
+	body = append(body, map[string]interface{}{
+		"type":   "TextBlock",
+		"text":   ev.Title,
+		"weight": "Bolder",
+		"size":   "Medium",
+		"color":  getTeamsColor(ev.Type),
+		"wrap":   true,
+	})
+	for _, para := range strings.Split(ev.Content, "\n\n") {
+		body = append(body, map[string]interface{}{
+			"type": "TextBlock",
+			"text": para,
+			"wrap": true,
+		})
+	}
+
+	message := map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+
+	status, resp, err := n.postJSON(config.Network, botSettings(config).WebhookURL, nil, message)
+	if err != nil {
+		return err
+	}
+	// Workflows 接受后返回 202；旧版 Incoming Webhook 出错时也可能返回 200，只有正文为 "1" 才表示成功
+	ok := status == http.StatusAccepted || (status == http.StatusOK && strings.TrimSpace(string(resp)) == "1")
+	if !ok {
+		return fmt.Errorf("Teams 通知发送失败，状态码: %d，响应: %s", status, summarizeBody(resp))
+	}
+	return nil
+}
+
+// getTeamsColor 返回各事件类型 Adaptive Card 标题的文本颜色
+func getTeamsColor(eventType string) string {
+	switch eventType {
+	case EventRemoteStart, EventRemoteEscalation:
+		return "Attention"
+	case EventRemoteReminder:
+		return "Warning"
+	case EventRemoteEnd:
+		return "Good"
+	case EventAppStart, EventAppExit:
+		return "Default"
+	default:
+		return "Accent"
+	}
+}
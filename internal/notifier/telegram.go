@@ -0,0 +1,173 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/netconf"
+)
+
+func init() {
+	RegisterChannel(telegramDriver{})
+}
+
+// TelegramConfig Telegram 机器人通知配置
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"` // BotFather 颁发的机器人 token
+	ChatIDs  string `json:"chat_ids"`  // 接收消息的 chat id（用户/群组/频道），多个用逗号分隔
+	APIBase  string `json:"api_base"`  // Bot API 地址；留空使用官方 https://api.telegram.org，可填自建 Bot API 服务器
+}
+
+// defaultTelegramAPIBase 是官方 Bot API 地址
+const defaultTelegramAPIBase = "https://api.telegram.org"
+
+// telegramDriver 是 Telegram 机器人渠道
+type telegramDriver struct{ sendsTest }
+
+func (telegramDriver) Type() string { return "telegram" }
+
+func (telegramDriver) Schema() *Schema {
+	apiBase := field("Bot API 地址", "留空使用 "+defaultTelegramAPIBase+"，可填自建 Bot API 服务器")
+	apiBase.Format = "uri"
+	return &Schema{
+		Type:  "object",
+		Title: "Telegram",
+		Properties: map[string]*Schema{
+			"bot_token": secretField("机器人 token", "向 @BotFather 创建机器人后得到的 token"),
+			"chat_ids":  field("Chat ID", "接收消息的用户、群组或频道 ID，多个用逗号分隔"),
+			"api_base":  apiBase,
+		},
+		Required: []string{"bot_token", "chat_ids"},
+		Order:    []string{"bot_token", "chat_ids", "api_base"},
+	}
+}
+
+func (telegramDriver) Validate(config NotificationConfig) error {
+	tg := telegramSettings(config)
+	switch {
+	case tg.BotToken == "":
+		return fmt.Errorf("Telegram 机器人 token 不能为空")
+	case len(parseRecipients(tg.ChatIDs)) == 0:
+		return fmt.Errorf("Telegram chat id 不能为空")
+	}
+	return validateAPIBase(tg.APIBase)
+}
+
+func (telegramDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendTelegramNotification(config, ev)
+}
+
+func (telegramDriver) Endpoint(config NotificationConfig) string {
+	return serverBase(telegramSettings(config).APIBase, defaultTelegramAPIBase)
+}
+
+// telegramSettings 返回渠道的 Telegram 设置
+func telegramSettings(config NotificationConfig) TelegramConfig {
+	var tg TelegramConfig
+	config.decode(&tg)
+	return tg
+}
+
+// telegramResponse 是 Bot API 的统一响应结构
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// sendTelegramNotification 通过 Bot API sendMessage 向每个 chat id 发送 MarkdownV2 消息。
+// 发件箱重试时跳过上次已送达的 chat id。
+func (n *Notifier) sendTelegramNotification(config NotificationConfig, ev *Event) error {
+	tg := telegramSettings(config)
+	if tg.BotToken == "" {
+		return fmt.Errorf("Telegram 机器人 token 不能为空")
+	}
+	chatIDs := parseRecipients(tg.ChatIDs)
+	if len(chatIDs) == 0 {
+		return fmt.Errorf("Telegram chat id 不能为空")
+	}
+
+	base := strings.TrimRight(tg.APIBase, "/")
+	if base == "" {
+		base = defaultTelegramAPIBase
+	}
+	endpoint := base + "/bot" + tg.BotToken + "/sendMessage"
+	text := "*" + escapeTelegramMarkdown(ev.Title) + "*\n\n" + escapeTelegramMarkdown(ev.Content)
+
+	var failed []string
+	var wait time.Duration
+	for _, chatID := range chatIDs {
+		if ev.progress.done(chatID) {
+			continue
+		}
+		message := map[string]interface{}{
+			"chat_id":    chatID,
+			"text":       text,
+			"parse_mode": "MarkdownV2",
+		}
+		if err := n.postTelegram(config.Network, endpoint, message); err != nil {
+			failed = append(failed, fmt.Sprintf("chat %s: %v", chatID, err))
+			if d := retryAfter(err); d > wait {
+				wait = d
+			}
+			continue
+		}
+		ev.progress.markDone(chatID)
+	}
+	if len(failed) > 0 {
+		err := fmt.Errorf("Telegram 通知发送失败: %s", strings.Join(failed, "; "))
+		if wait > 0 {
+			return &retryAfterError{err: err, after: wait}
+		}
+		return err
+	}
+	return nil
+}
+
+// postTelegram 调用一次 Bot API。遇到 429 时不在发送路径中等待，
+// 而是返回带 retry_after 的错误，由发件箱推迟到限流解除后重试。
+func (n *Notifier) postTelegram(opts netconf.Options, endpoint string, message map[string]interface{}) error {
+	status, body, err := n.postJSON(opts, endpoint, nil, message)
+	if err != nil {
+		return err
+	}
+
+	var result telegramResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("状态码 %d，响应无法解析: %s", status, summarizeBody(body))
+	}
+	if result.OK {
+		return nil
+	}
+
+	if result.Description == "" {
+		result.Description = summarizeBody(body)
+	}
+	err = fmt.Errorf("%s (error_code=%d)", result.Description, result.ErrorCode)
+	if status == http.StatusTooManyRequests && result.Parameters.RetryAfter > 0 {
+		return &retryAfterError{err: err, after: time.Duration(result.Parameters.RetryAfter) * time.Second}
+	}
+	return err
+}
+
+// telegramSpecialChars 是 MarkdownV2 中必须转义的字符
+const telegramSpecialChars = "_*[]()~`>#+-=|{}.!\\"
+
+// escapeTelegramMarkdown 按 MarkdownV2 规则转义文本（中文等非 ASCII 字符无需处理）
+func escapeTelegramMarkdown(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(telegramSpecialChars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
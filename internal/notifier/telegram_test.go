@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"RemoteKnown/internal/storage"
+)
+
+func TestEscapeTelegramMarkdown(t *testing.T) {
+	in := "主机：PC-01 (ToDesk.exe) 192.168.1.10! a_b*c"
+	want := `主机：PC\-01 \(ToDesk\.exe\) 192\.168\.1\.10\! a\_b\*c`
+	if got := escapeTelegramMarkdown(in); got != want {
+		t.Errorf("转义结果错误:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	var chats []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("请求路径错误: %s", r.URL.Path)
+		}
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+		if msg["parse_mode"] != "MarkdownV2" {
+			t.Errorf("parse_mode 应为 MarkdownV2，实际 %v", msg["parse_mode"])
+		}
+		if text, _ := msg["text"].(string); !strings.HasPrefix(text, "*⚠️ 告警*") || !strings.Contains(text, `PC\-01`) {
+			t.Errorf("消息内容未按 MarkdownV2 组织: %q", text)
+		}
+		chats = append(chats, msg["chat_id"].(string))
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "telegram", Settings: settingsOf(TelegramConfig{
+		BotToken: "123:abc",
+		ChatIDs:  "1001, -1002",
+		APIBase:  srv.URL + "/",
+	})}
+	if err := n.sendNotification(cfg, "⚠️ 告警", "主机：PC-01"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if len(chats) != 2 || chats[0] != "1001" || chats[1] != "-1002" {
+		t.Errorf("应向每个 chat id 各发送一次，实际 %v", chats)
+	}
+}
+
+// TestSendTelegramRetryAfter 验证 429 时不在发送路径中等待，而是返回带 retry_after 的错误交给发件箱重试
+func TestSendTelegramRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 120","parameters":{"retry_after":120}}`))
+	}))
+	defer srv.Close()
+
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "telegram", Settings: settingsOf(TelegramConfig{BotToken: "t", ChatIDs: "1", APIBase: srv.URL})}
+	start := time.Now()
+	err := n.sendNotification(cfg, "标题", "内容")
+	if err == nil || !strings.Contains(err.Error(), "error_code=429") {
+		t.Fatalf("429 应返回错误，实际 %v", err)
+	}
+	if time.Since(start) > 5*time.Second || calls != 1 {
+		t.Errorf("不应在发送路径中等待重试，请求 %d 次", calls)
+	}
+	if wait := retryAfter(err); wait != 120*time.Second {
+		t.Errorf("错误应携带 retry_after，实际 %v", wait)
+	}
+}
+
+// TestTelegramRetrySkipsDeliveredChats 验证部分 chat 发送失败时，发件箱重试只补发失败的 chat，已收到的不会重复收到
+func TestTelegramRetrySkipsDeliveredChats(t *testing.T) {
+	var mu sync.Mutex
+	var chats []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+		chatID, _ := msg["chat_id"].(string)
+		mu.Lock()
+		chats = append(chats, chatID)
+		first := len(chats) <= 3
+		mu.Unlock()
+		if chatID == "-1002" && first {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	n, st := newTestNotifier(t)
+	n.SaveChannels([]Channel{{Name: "值班群", Type: "telegram", Enabled: true, Settings: map[string]interface{}{
+		"bot_token": "t", "chat_ids": "1001, -1002, 1003", "api_base": srv.URL,
+	}}})
+	n.dispatch(sampleEvent())
+	n.processOutbox(time.Now().Add(2 * time.Minute))
+
+	mu.Lock()
+	got := strings.Join(chats, ", ")
+	mu.Unlock()
+	if got != "1001, -1002, 1003, -1002" {
+		t.Errorf("重试应只补发失败的 chat，实际请求顺序 %s", got)
+	}
+	if sent, _, _ := st.ListOutbox(storage.OutboxSent, 1, 10); len(sent) != 1 || sent[0].Attempts != 2 {
+		t.Errorf("重试后消息应投递成功: %+v", sent)
+	}
+}
+
+func TestTelegramTestNotificationReportsDescription(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	}))
+	defer srv.Close()
+
+	n, _ := newTestNotifier(t)
+	err := n.SendTestNotification(NotificationConfig{Type: "telegram", Settings: settingsOf(TelegramConfig{
+		BotToken: "secret-token", ChatIDs: "42", APIBase: srv.URL,
+	})})
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("测试通知应返回 Telegram 的 description，实际 %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("错误信息不应包含机器人 token: %v", err)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	got := redactURL("https://api.telegram.org/bot123:abc/sendMessage")
+	if got != "https://api.telegram.org/bot***/sendMessage" {
+		t.Errorf("token 未脱敏: %s", got)
+	}
+	if got := redactURL(serverChanURL("sctp42tKEY")); got != "https://42.push.ft07.com/send/***.send" {
+		t.Errorf("Server酱 SendKey 未脱敏: %s", got)
+	}
+}
@@ -0,0 +1,260 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/settings"
+)
+
+// MessageTemplate 是某类事件的通知标题与正文模板（Go text/template，数据为 Event）。
+// 字段为空表示沿用上一级（渠道 → 全局 → 内置默认）的模板。
+type MessageTemplate struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// defaultTemplates 是内置的默认模板，与早期版本硬编码的通知文案一致
+var defaultTemplates = map[string]MessageTemplate{
+	EventRemoteStart: {
+		Title: "⚠️ 远程控制检测告警",
+		Body:  "主机：{{.DeviceName}}\n\n检测到远程控制连接已建立\n\n检测信号：\n{{join .Signals \"\\n\"}}\n\n时间：{{datetime .Time}}",
+	},
+	EventRemoteEnd: {
+		Title: "✅ 远程控制已断开",
+		Body:  "主机：{{.DeviceName}}\n\n远程控制会话已结束\n\n上次检测信号：\n{{join .Signals \"\\n\"}}\n\n时间：{{datetime .Time}}",
+	},
+	EventRemoteReminder: {
+		Title: "⏰ 远程控制仍在进行",
+		Body:  "主机：{{.DeviceName}}\n\n远程控制会话已持续 {{.Duration}}\n\n远程工具：{{join .Tools \", \"}}\n\n开始时间：{{datetime .StartTime}}\n时间：{{datetime .Time}}",
+	},
+	EventRemoteEscalation: {
+		Title: "🚨 远程控制持续时间过长",
+		Body:  "主机：{{.DeviceName}}\n\n远程控制会话已持续 {{.Duration}}，超过升级阈值，请尽快确认\n\n远程工具：{{join .Tools \", \"}}\n\n检测信号：\n{{join .Signals \"\\n\"}}\n\n开始时间：{{datetime .StartTime}}\n时间：{{datetime .Time}}",
+	},
+	EventAppStart: {
+		Title: "🟢 RemoteKnown 服务已启动",
+		Body:  "主机：{{.DeviceName}}\n\nRemoteKnown 守护进程已启动\n\n时间：{{datetime .Time}}",
+	},
+	EventAppExit: {
+		Title: "🔴 RemoteKnown 服务已退出",
+		Body:  "主机：{{.DeviceName}}\n\nRemoteKnown 守护进程已停止运行\n\n时间：{{datetime .Time}}",
+	},
+	EventRulesUpdated: {
+		Title: "🔄 检测规则已更新",
+		Body:  "主机：{{.DeviceName}}\n\n检测规则已切换到 v{{.RulesVersion}}（来源：{{.RulesSource}}）\n\n时间：{{datetime .Time}}",
+	},
+}
+
+// TemplateEvent 描述一种可自定义模板的事件
+type TemplateEvent struct {
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+// templateEvents 是可自定义模板的事件（按界面展示顺序）
+var templateEvents = []TemplateEvent{
+	{EventRemoteStart, "远程控制开始"},
+	{EventRemoteEnd, "远程控制结束"},
+	{EventRemoteReminder, "会话持续提醒"},
+	{EventRemoteEscalation, "会话升级通知"},
+	{EventAppStart, "服务启动"},
+	{EventAppExit, "服务退出"},
+	{EventRulesUpdated, "检测规则更新"},
+}
+
+// TemplateVariable 是模板中可引用的变量或函数说明
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// templateVariables 是模板变量说明，供设置界面展示
+var templateVariables = []TemplateVariable{
+	{"{{.DeviceName}}", "设备标识名（未配置时为主机名）"},
+	{"{{.Hostname}}", "主机名"},
+	{"{{.Tool}}", "主要远程工具名（多个时为第一个）"},
+	{"{{join .Tools \", \"}}", "涉及的全部远程工具"},
+	{"{{join .Signals \"\\n\"}}", "检测信号，每行一个"},
+	{"{{join .Peers \", \"}}", "对端 IP 地址（可获取时）"},
+	{"{{datetime .StartTime}}", "会话开始时间"},
+	{"{{datetime .EndTime}}", "会话结束时间（仅结束事件）"},
+	{"{{.Duration}}", "会话时长，如 01:02:03（结束、提醒与升级事件）"},
+	{"{{.DurationSeconds}}", "会话时长（秒）"},
+	{"{{.SessionID}}", "会话 ID"},
+	{"{{.Authorized}}", "会话是否已被确认（true / false）"},
+	{"{{datetime .Time}}", "事件发生时间"},
+	{"{{.RulesVersion}} / {{.RulesSource}}", "规则版本与来源（仅检测规则更新事件）"},
+	{"{{formatTime .Time \"15:04\"}}", "按 Go 时间格式自定义时间显示"},
+	{"{{json .Tools}}", "编码为 JSON 字面量"},
+}
+
+// TemplateEvents 返回可自定义模板的事件列表
+func TemplateEvents() []TemplateEvent {
+	out := make([]TemplateEvent, len(templateEvents))
+	copy(out, templateEvents)
+	return out
+}
+
+// TemplateVariables 返回模板变量说明
+func TemplateVariables() []TemplateVariable {
+	out := make([]TemplateVariable, len(templateVariables))
+	copy(out, templateVariables)
+	return out
+}
+
+// DefaultTemplates 返回内置默认模板
+func DefaultTemplates() map[string]MessageTemplate {
+	out := make(map[string]MessageTemplate, len(defaultTemplates))
+	for k, v := range defaultTemplates {
+		out[k] = v
+	}
+	return out
+}
+
+// LoadTemplates 读取全局自定义模板（事件类型 → 模板）
+func (n *Notifier) LoadTemplates() (map[string]MessageTemplate, error) {
+	templates := make(map[string]MessageTemplate)
+	if err := n.settings.GetJSON(settings.KeyNotificationTemplates, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// SaveTemplates 校验并保存全局自定义模板；标题与正文都为空的事件视为恢复默认并移除
+func (n *Notifier) SaveTemplates(templates map[string]MessageTemplate) error {
+	if err := ValidateTemplates(templates); err != nil {
+		return err
+	}
+	cleaned := make(map[string]MessageTemplate, len(templates))
+	for eventType, t := range templates {
+		if strings.TrimSpace(t.Title) != "" || strings.TrimSpace(t.Body) != "" {
+			cleaned[eventType] = t
+		}
+	}
+	return n.settings.SetJSON(settings.KeyNotificationTemplates, cleaned)
+}
+
+// ValidateTemplates 校验一组模板：事件类型必须可自定义，且模板能用示例数据渲染
+func ValidateTemplates(templates map[string]MessageTemplate) error {
+	for eventType, t := range templates {
+		if _, ok := defaultTemplates[eventType]; !ok {
+			return fmt.Errorf("事件 %s 不支持自定义模板", eventType)
+		}
+		if _, _, err := renderMessage(t, previewEvent(eventType)); err != nil {
+			return fmt.Errorf("事件 %s 的模板无效: %w", eventType, err)
+		}
+	}
+	return nil
+}
+
+// PreviewTemplate 用示例数据渲染模板；字段为空时使用内置默认模板
+func PreviewTemplate(eventType string, t MessageTemplate) (title, content string, err error) {
+	def, ok := defaultTemplates[eventType]
+	if !ok {
+		return "", "", fmt.Errorf("事件 %s 不支持自定义模板", eventType)
+	}
+	return renderMessage(overlayTemplate(def, t), previewEvent(eventType))
+}
+
+// overlayTemplate 用 over 中非空的字段覆盖 base
+func overlayTemplate(base, over MessageTemplate) MessageTemplate {
+	if strings.TrimSpace(over.Title) != "" {
+		base.Title = over.Title
+	}
+	if strings.TrimSpace(over.Body) != "" {
+		base.Body = over.Body
+	}
+	return base
+}
+
+// renderMessage 渲染标题与正文；标题中的换行会被替换为空格
+func renderMessage(t MessageTemplate, ev *Event) (title, content string, err error) {
+	if title, err = renderText("title", t.Title, ev); err != nil {
+		return "", "", fmt.Errorf("标题: %w", err)
+	}
+	if content, err = renderText("body", t.Body, ev); err != nil {
+		return "", "", fmt.Errorf("正文: %w", err)
+	}
+	title = strings.Join(strings.Fields(strings.ReplaceAll(title, "\n", " ")), " ")
+	return title, content, nil
+}
+
+func renderText(name, text string, ev *Event) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("模板解析失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// applyTemplate 按渠道模板 → 全局模板 → 内置默认的优先级渲染事件的标题与正文，
+// 返回事件副本；自定义模板渲染失败时记录日志并回退到内置默认模板。
+func (n *Notifier) applyTemplate(ev *Event, c Channel, global map[string]MessageTemplate) *Event {
+	def, ok := defaultTemplates[ev.Type]
+	if !ok {
+		return ev
+	}
+	out := *ev
+	t := overlayTemplate(overlayTemplate(def, global[ev.Type]), c.Templates[ev.Type])
+	title, content, err := renderMessage(t, &out)
+	if err != nil {
+		log.Printf("[通知器] 渠道「%s」的 %s 模板渲染失败，使用默认模板: %v", c.Name, ev.Type, err)
+		title, content, _ = renderMessage(def, &out)
+	}
+	out.Title, out.Content = title, content
+	return &out
+}
+
+// sampleSession 返回用于测试通知与模板预览的示例会话
+func sampleSession(now time.Time) detector.SessionEvent {
+	return detector.SessionEvent{
+		SessionID: "test",
+		StartTime: now.Add(-5 * time.Minute),
+		EndTime:   now,
+		Signals: []detector.Signal{
+			{Name: "示例工具 (测试)", Tool: "示例工具", Peer: "192.0.2.1"},
+		},
+	}
+}
+
+// previewEvent 构造模板预览用的示例事件
+func previewEvent(eventType string) *Event {
+	ev := &Event{
+		Type:       eventType,
+		DeviceName: "示例设备",
+		Hostname:   "DESKTOP-EXAMPLE",
+		Time:       time.Now(),
+	}
+	ev.setSample()
+	return ev
+}
+
+// setSample 按事件类型填入示例会话或规则版本
+func (ev *Event) setSample() {
+	switch ev.Type {
+	case EventRemoteStart:
+		se := sampleSession(ev.Time)
+		se.EndTime = time.Time{}
+		ev.setSession(se)
+	case EventRemoteEnd:
+		ev.setSession(sampleSession(ev.Time))
+	case EventRemoteReminder, EventRemoteEscalation:
+		se := sampleSession(ev.Time)
+		se.StartTime = ev.Time.Add(-2 * time.Hour)
+		se.EndTime = time.Time{}
+		ev.setSession(se)
+	case EventRulesUpdated:
+		ev.RulesVersion = "1.0.0"
+		ev.RulesSource = "github"
+	}
+}
@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDefaultTemplatesMatchLegacyText 验证内置默认模板与早期硬编码文案一致。
+func TestDefaultTemplatesMatchLegacyText(t *testing.T) {
+	ev := sampleEvent()
+	title, content, err := renderMessage(defaultTemplates[EventRemoteEnd], ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "主机：财务\"PC\"\n\n远程控制会话已结束\n\n上次检测信号：\n" + ev.signalList() +
+		"\n\n时间：" + ev.Time.Format("2006-01-02 15:04:05")
+	if title != "✅ 远程控制已断开" || content != want {
+		t.Errorf("默认模板渲染结果不一致:\n%s\n%s", title, content)
+	}
+
+	for eventType := range defaultTemplates {
+		if _, _, err := PreviewTemplate(eventType, MessageTemplate{}); err != nil {
+			t.Errorf("%s 默认模板预览失败: %v", eventType, err)
+		}
+	}
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	title, content, err := PreviewTemplate(EventRemoteEnd, MessageTemplate{
+		Title: "{{.DeviceName}}\n会话结束",
+		Body:  "{{join .Tools \",\"}} {{join .Peers \",\"}} {{.Duration}} {{.SessionID}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "示例设备 会话结束" {
+		t.Errorf("标题中的换行应替换为空格，实际 %q", title)
+	}
+	if content != "示例工具 192.0.2.1 00:05:00 test" {
+		t.Errorf("正文渲染结果不正确: %q", content)
+	}
+
+	if _, _, err := PreviewTemplate(EventRemoteStart, MessageTemplate{Body: "{{.Unknown}}"}); err == nil {
+		t.Error("引用不存在的变量应报错")
+	}
+	if _, _, err := PreviewTemplate(EventRemoteStart, MessageTemplate{Body: "{{if}}"}); err == nil {
+		t.Error("语法错误的模板应报错")
+	}
+	if _, _, err := PreviewTemplate(EventTest, MessageTemplate{}); err == nil {
+		t.Error("测试通知不支持自定义模板，应报错")
+	}
+}
+
+// TestApplyTemplatePriority 验证渠道模板 → 全局模板 → 内置默认的优先级，以及渲染失败时回退默认模板。
+func TestApplyTemplatePriority(t *testing.T) {
+	n := &Notifier{}
+	ev := sampleEvent()
+	originalTitle := ev.Title
+	global := map[string]MessageTemplate{EventRemoteEnd: {Title: "全局：{{.Hostname}}", Body: "全局正文"}}
+
+	out := n.applyTemplate(ev, Channel{}, global)
+	if out.Title != "全局：FIN-01" || out.Content != "全局正文" {
+		t.Errorf("应使用全局模板，实际 %q / %q", out.Title, out.Content)
+	}
+
+	ch := Channel{Name: "值班群", Templates: map[string]MessageTemplate{EventRemoteEnd: {Body: "渠道：{{.Tool}}"}}}
+	out = n.applyTemplate(ev, ch, global)
+	if out.Title != "全局：FIN-01" || out.Content != "渠道：ToDesk" {
+		t.Errorf("渠道模板应只覆盖正文，实际 %q / %q", out.Title, out.Content)
+	}
+	if ev.Title != originalTitle {
+		t.Error("applyTemplate 不应修改原事件")
+	}
+
+	broken := Channel{Name: "坏模板", Templates: map[string]MessageTemplate{EventRemoteEnd: {Body: "{{.Missing}}"}}}
+	out = n.applyTemplate(ev, broken, nil)
+	if !strings.Contains(out.Content, "远程控制会话已结束") {
+		t.Errorf("渲染失败时应回退到默认模板，实际 %q", out.Content)
+	}
+}
+
+func TestSaveTemplatesValidates(t *testing.T) {
+	n, _ := newTestNotifier(t)
+
+	if err := n.SaveTemplates(map[string]MessageTemplate{EventRemoteStart: {Title: "{{"}}); err == nil {
+		t.Error("无效模板应保存失败")
+	}
+	if err := n.SaveTemplates(map[string]MessageTemplate{"unknown": {Title: "x"}}); err == nil {
+		t.Error("未知事件类型应保存失败")
+	}
+	err := n.SaveTemplates(map[string]MessageTemplate{
+		EventRemoteStart: {Title: "告警：{{.DeviceName}}"},
+		EventAppExit:     {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := n.LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 || templates[EventRemoteStart].Title != "告警：{{.DeviceName}}" {
+		t.Errorf("保存结果不正确（空模板应被移除）: %+v", templates)
+	}
+
+	bad := []Channel{{Type: "slack", Templates: map[string]MessageTemplate{EventRemoteEnd: {Body: "{{end}}"}}}}
+	if err := n.SaveChannels(bad); err == nil {
+		t.Error("渠道模板无效时应保存失败")
+	}
+}
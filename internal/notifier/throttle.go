@@ -0,0 +1,225 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 渠道限流的默认参数
+const (
+	defaultDedupMinutes = 10 // 去重窗口（分钟）
+	defaultBurst        = 10 // 令牌桶容量：短时间内最多连续发送的条数
+	defaultPerMinute    = 1  // 令牌补充速度（条/分钟）
+)
+
+// ChannelThrottle 是渠道的去重与限流设置，数值为 0 表示使用默认值。
+// 去重窗口内同一事件类型 + 工具的重复事件只发送第一条，其余计数，窗口结束时发送一条汇总；
+// 令牌桶耗尽时的事件同样计入汇总。
+type ChannelThrottle struct {
+	Disabled     bool    `json:"disabled"`      // 关闭去重与限流
+	DedupMinutes int     `json:"dedup_minutes"` // 去重窗口（分钟），默认 10
+	Burst        int     `json:"burst"`         // 令牌桶容量，默认 10
+	PerMinute    float64 `json:"per_minute"`    // 每分钟补充的令牌数，默认 1
+}
+
+// normalized 返回填充默认值后的设置
+func (t ChannelThrottle) normalized() ChannelThrottle {
+	if t.DedupMinutes <= 0 {
+		t.DedupMinutes = defaultDedupMinutes
+	}
+	if t.Burst <= 0 {
+		t.Burst = defaultBurst
+	}
+	if t.PerMinute <= 0 {
+		t.PerMinute = defaultPerMinute
+	}
+	return t
+}
+
+func (t ChannelThrottle) validate() error {
+	if t.DedupMinutes < 0 || t.Burst < 0 || t.PerMinute < 0 {
+		return fmt.Errorf("去重窗口与限流参数不能为负数")
+	}
+	return nil
+}
+
+// tokenBucket 是按时间连续补充的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take 按 now 补充令牌后尝试取出一个
+func (b *tokenBucket) take(now time.Time, t ChannelThrottle) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(t.Burst)
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Minutes() * t.PerMinute
+	}
+	if b.tokens > float64(t.Burst) {
+		b.tokens = float64(t.Burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dedupWindow 记录一个去重窗口内的事件。只记录渠道 ID：窗口结束时按 ID 取渠道当前的配置发送汇总，
+// 窗口期间渠道被停用、删除或修改地址与密钥时不会发往旧的目标
+type dedupWindow struct {
+	channelID  string
+	eventType  string
+	tool       string
+	minutes    int
+	count      int // 窗口内事件总数（含已发送的第一条）
+	suppressed int // 未单独发送的条数
+	last       *Event
+	timer      *time.Timer
+}
+
+// throttle 维护各渠道的令牌桶与去重窗口（仅在内存中，重启后重新计数）
+type throttle struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	windows map[string]*dedupWindow
+	flush   func(w *dedupWindow) // 窗口结束且有被合并的事件时调用
+}
+
+func newThrottle(flush func(w *dedupWindow)) *throttle {
+	return &throttle{
+		buckets: make(map[string]*tokenBucket),
+		windows: make(map[string]*dedupWindow),
+		flush:   flush,
+	}
+}
+
+// allow 判断事件是否应立即发送到渠道；不发送的事件计入去重窗口，窗口结束时汇总
+func (th *throttle) allow(c Channel, ev *Event, now time.Time) bool {
+	// 提醒与升级本身按配置的间隔发送，面向程序的渠道需要每一条事件，均不参与去重与限流
+	if c.Throttle.Disabled || machineSinks[c.Type] || ev.Type == EventRemoteReminder || ev.Type == EventRemoteEscalation {
+		return true
+	}
+	t := c.Throttle.normalized()
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	key := c.ID + "|" + ev.Type + "|" + ev.Tool
+	w, inWindow := th.windows[key]
+	if !inWindow {
+		w = &dedupWindow{channelID: c.ID, eventType: ev.Type, tool: ev.Tool, minutes: t.DedupMinutes}
+		th.windows[key] = w
+		w.timer = time.AfterFunc(time.Duration(t.DedupMinutes)*time.Minute, func() { th.closeWindow(key) })
+	}
+	w.count++
+	w.last = ev
+
+	bucket := th.buckets[c.ID]
+	if bucket == nil {
+		bucket = &tokenBucket{}
+		th.buckets[c.ID] = bucket
+	}
+	if inWindow || !bucket.take(now, t) {
+		w.suppressed++
+		return false
+	}
+	return true
+}
+
+// closeWindow 结束去重窗口，有被合并的事件时发送汇总
+func (th *throttle) closeWindow(key string) {
+	th.mu.Lock()
+	w, ok := th.windows[key]
+	if ok {
+		delete(th.windows, key)
+		w.timer.Stop()
+	}
+	th.mu.Unlock()
+
+	if ok && w.suppressed > 0 && th.flush != nil {
+		th.flush(w)
+	}
+}
+
+// stop 停止所有去重窗口的计时器并丢弃窗口（通知器停止时调用，之后不会再发送汇总）
+func (th *throttle) stop() {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	dropped := 0
+	for key, w := range th.windows {
+		w.timer.Stop()
+		if w.suppressed > 0 {
+			dropped++
+		}
+		delete(th.windows, key)
+	}
+	if dropped > 0 {
+		log.Printf("[通知器] 通知器停止，%d 个去重窗口的汇总不再发送", dropped)
+	}
+}
+
+// summaryText 返回汇总消息正文中的统计句，如 "ToDesk 会话在 10 分钟内开始 7 次"
+func (w *dedupWindow) summaryText() string {
+	switch w.eventType {
+	case EventRemoteStart, EventRemoteEnd:
+		tool := w.tool
+		if tool == "" {
+			tool = "远程控制"
+		}
+		verb := "开始"
+		if w.eventType == EventRemoteEnd {
+			verb = "结束"
+		}
+		return fmt.Sprintf("%s 会话在 %d 分钟内%s %d 次", tool, w.minutes, verb, w.count)
+	}
+	label := w.eventType
+	for _, e := range templateEvents {
+		if e.Type == w.eventType {
+			label = e.Label
+		}
+	}
+	return fmt.Sprintf("「%s」事件在 %d 分钟内发生 %d 次", label, w.minutes, w.count)
+}
+
+// sendSummary 把去重窗口的汇总消息经发件箱发送到对应渠道（不受限流约束）；
+// 渠道按 ID 重新读取，已删除或已停用时不发送
+func (n *Notifier) sendSummary(w *dedupWindow) {
+	channels, err := n.LoadChannels()
+	if err != nil {
+		log.Printf("[通知器] 读取通知渠道失败，汇总未发送: %v", err)
+		return
+	}
+	var c *Channel
+	for i := range channels {
+		if channels[i].ID == w.channelID {
+			c = &channels[i]
+		}
+	}
+	if c == nil || !c.Enabled {
+		log.Printf("[通知器] 渠道 %s 已删除或已停用，不发送汇总: %s", w.channelID, w.summaryText())
+		return
+	}
+
+	ev := *w.last
+	ev.Type = EventSummary
+	ev.Time = time.Now()
+	ev.Title = "🔁 重复通知汇总"
+	ev.Content = fmt.Sprintf("主机：%s\n\n%s（其中 %d 次未单独通知）\n\n时间：%s",
+		ev.DeviceName,
+		w.summaryText(),
+		w.suppressed,
+		ev.Time.Format("2006-01-02 15:04:05"))
+
+	log.Printf("[通知器] 渠道「%s」%s，发送汇总", c.Name, w.summaryText())
+	msg, err := n.enqueueOutbox(*c, &ev)
+	if err != nil {
+		log.Printf("[通知器] 写入发件箱失败: %v", err)
+		return
+	}
+	n.attemptOutbox(*msg, *c, ev.Time)
+}
@@ -0,0 +1,167 @@
+package notifier
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	cfg := ChannelThrottle{Burst: 2, PerMinute: 1}
+	var b tokenBucket
+	now := time.Now()
+	if !b.take(now, cfg) || !b.take(now, cfg) {
+		t.Fatal("桶满时应允许连续取出 Burst 个令牌")
+	}
+	if b.take(now.Add(30*time.Second), cfg) {
+		t.Error("令牌耗尽且未补满 1 个时不应放行")
+	}
+	if !b.take(now.Add(90*time.Second), cfg) {
+		t.Error("补充 1 个令牌后应放行")
+	}
+}
+
+// TestThrottleDedupAndSummary 验证去重窗口内的重复事件只发送第一条，窗口结束时发送一条汇总。
+func TestThrottleDedupAndSummary(t *testing.T) {
+	var mu sync.Mutex
+	var flushed []*dedupWindow
+	th := newThrottle(func(w *dedupWindow) {
+		mu.Lock()
+		flushed = append(flushed, w)
+		mu.Unlock()
+	})
+
+	c := Channel{ID: "c1", Name: "值班群"}
+	start := &Event{Type: EventRemoteStart, Tool: "ToDesk"}
+	now := time.Now()
+
+	sent := 0
+	for i := 0; i < 7; i++ {
+		if th.allow(c, start, now.Add(time.Duration(i)*time.Second)) {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Errorf("窗口内 7 次重复事件应只发送 1 次，实际 %d", sent)
+	}
+	// 不同工具使用独立的窗口
+	if !th.allow(c, &Event{Type: EventRemoteStart, Tool: "AnyDesk"}, now) {
+		t.Error("不同工具的事件不应被去重")
+	}
+
+	th.closeWindow("c1|remote_start|ToDesk")
+	th.closeWindow("c1|remote_start|AnyDesk")
+	if len(flushed) != 1 {
+		t.Fatalf("只有存在合并事件的窗口才发送汇总，实际 %d 条", len(flushed))
+	}
+	if got := flushed[0].summaryText(); got != "ToDesk 会话在 10 分钟内开始 7 次" {
+		t.Errorf("汇总文案不正确: %q", got)
+	}
+
+	// 窗口结束后重新发送
+	if !th.allow(c, start, now.Add(11*time.Minute)) {
+		t.Error("窗口结束后的事件应重新发送")
+	}
+}
+
+func TestThrottleRateLimitAcrossEvents(t *testing.T) {
+	th := newThrottle(nil)
+	c := Channel{ID: "c1", Throttle: ChannelThrottle{Burst: 2, PerMinute: 1}}
+	now := time.Now()
+	allowed := 0
+	for _, tool := range []string{"A", "B", "C", "D"} {
+		if th.allow(c, &Event{Type: EventRemoteStart, Tool: tool}, now) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("令牌桶容量为 2 时应只放行 2 条，实际 %d", allowed)
+	}
+
+	off := Channel{ID: "c2", Throttle: ChannelThrottle{Disabled: true}}
+	for i := 0; i < 3; i++ {
+		if !th.allow(off, &Event{Type: EventRemoteStart, Tool: "A"}, now) {
+			t.Fatal("关闭限流的渠道不应被去重")
+		}
+	}
+
+	// 面向程序的渠道默认不去重、不限流
+	for _, typ := range []string{"syslog", "mqtt", "webhook", "pagerduty", "opsgenie"} {
+		sink := Channel{ID: typ, Type: typ, Throttle: ChannelThrottle{Burst: 1, PerMinute: 1}}
+		for i := 0; i < 3; i++ {
+			if !th.allow(sink, &Event{Type: EventRemoteStart, Tool: "A"}, now) {
+				t.Fatalf("%s 渠道不应被去重或限流", typ)
+			}
+		}
+	}
+}
+
+// TestDispatchSendsSummary 验证经 dispatch 合并的事件在窗口结束时发送汇总消息。
+func TestDispatchSendsSummary(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	fake := startFakeHTTP(t, 200, "ok")
+	n.SaveChannels([]Channel{{ID: "c1", Name: "值班群", Type: "discord", Enabled: true, Settings: map[string]interface{}{"webhook_url": fake.URL}}})
+
+	for i := 0; i < 3; i++ {
+		n.dispatch(sampleEvent())
+	}
+	n.throttle.closeWindow("c1|remote_end|ToDesk")
+
+	embeds, _ := fake.Last().Body["embeds"].([]interface{})
+	if len(embeds) == 0 {
+		t.Fatalf("应发送汇总消息，实际 %v", fake.Last().Body)
+	}
+	description, _ := embeds[0].(map[string]interface{})["description"].(string)
+	if !strings.Contains(description, "ToDesk 会话在 10 分钟内结束 3 次（其中 2 次未单独通知）") {
+		t.Errorf("应发送汇总消息，实际 %v", fake.Last().Body)
+	}
+}
+
+// TestSummaryUsesCurrentChannel 验证汇总按渠道 ID 取当前配置：窗口期间修改地址时发往新地址，停用后不发送
+func TestSummaryUsesCurrentChannel(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	old, current := startFakeHTTP(t, 200, "ok"), startFakeHTTP(t, 200, "ok")
+	c := Channel{ID: "c1", Name: "值班群", Type: "discord", Enabled: true, Settings: map[string]interface{}{"webhook_url": old.URL}}
+	n.SaveChannels([]Channel{c})
+	for i := 0; i < 3; i++ {
+		n.dispatch(sampleEvent())
+	}
+
+	c.Settings = map[string]interface{}{"webhook_url": current.URL}
+	n.SaveChannels([]Channel{c})
+	n.throttle.closeWindow("c1|remote_end|ToDesk")
+	if len(old.Requests()) != 1 || len(current.Requests()) != 1 {
+		t.Errorf("汇总应发往修改后的地址，旧地址 %d 次、新地址 %d 次", len(old.Requests()), len(current.Requests()))
+	}
+
+	for i := 0; i < 3; i++ {
+		n.dispatch(sampleEvent())
+	}
+	c.Enabled = false
+	n.SaveChannels([]Channel{c})
+	n.throttle.closeWindow("c1|remote_end|ToDesk")
+	if got := len(current.Requests()); got != 2 {
+		t.Errorf("渠道停用后不应发送汇总，实际共 %d 次请求", got)
+	}
+}
+
+// TestThrottleStop 验证停止后去重窗口的计时器均已停止且窗口被丢弃
+func TestThrottleStop(t *testing.T) {
+	flushed := 0
+	th := newThrottle(func(*dedupWindow) { flushed++ })
+	c := Channel{ID: "c1"}
+	ev := &Event{Type: EventRemoteStart, Tool: "ToDesk"}
+	th.allow(c, ev, time.Now())
+	th.allow(c, ev, time.Now())
+	w := th.windows["c1|remote_start|ToDesk"]
+
+	th.stop()
+	if len(th.windows) != 0 || w.timer.Stop() {
+		t.Error("停止后应丢弃窗口并停止计时器")
+	}
+	th.closeWindow("c1|remote_start|ToDesk")
+	if flushed != 0 {
+		t.Error("停止后不应再发送汇总")
+	}
+}
@@ -0,0 +1,177 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// recordedRequest 是替身 HTTP 服务收到的一次请求
+type recordedRequest struct {
+	Path   string // 路径与查询串
+	Header http.Header
+	Body   map[string]interface{} // 解析后的 JSON 请求体
+}
+
+// fakeHTTP 是以固定状态码与正文响应的替身 HTTP 服务（Webhook、推送服务、值班平台），记录收到的每个请求
+type fakeHTTP struct {
+	URL string
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+// startFakeHTTP 启动替身 HTTP 服务，以 status 与 response 响应每个请求
+func startFakeHTTP(t *testing.T, status int, response string) *fakeHTTP {
+	t.Helper()
+	f := &fakeHTTP{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type 应为 application/json，实际 %q", ct)
+		}
+		req := recordedRequest{Path: r.URL.RequestURI(), Header: r.Header.Clone()}
+		json.NewDecoder(r.Body).Decode(&req.Body)
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	f.URL = srv.URL
+	return f
+}
+
+// Requests 返回按顺序收到的全部请求
+func (f *fakeHTTP) Requests() []recordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]recordedRequest(nil), f.requests...)
+}
+
+// Last 返回最后一次收到的请求，尚未收到请求时返回零值
+func (f *fakeHTTP) Last() recordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return recordedRequest{}
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func TestSendSlack(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, "ok")
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "slack", Settings: map[string]interface{}{"webhook_url": fake.URL}}
+	if err := n.sendNotification(cfg, "⚠️ 远程控制检测告警", "主机：<PC-01>"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	blocks, _ := fake.Last().Body["blocks"].([]interface{})
+	if len(blocks) != 2 {
+		t.Fatalf("应包含 header + section 两个 block，实际 %d", len(blocks))
+	}
+	section := blocks[1].(map[string]interface{})["text"].(map[string]interface{})
+	if section["text"] != "主机：&lt;PC-01&gt;" {
+		t.Errorf("mrkdwn 正文应转义尖括号，实际 %v", section["text"])
+	}
+
+	fake = startFakeHTTP(t, http.StatusNotFound, "no_service")
+	err := n.sendNotification(NotificationConfig{Type: "slack", Settings: map[string]interface{}{"webhook_url": fake.URL}}, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "no_service") {
+		t.Errorf("Slack 返回错误码时应报错并包含响应文本，实际 %v", err)
+	}
+}
+
+// TestSlackDiscordTruncate 验证模板渲染出的超长标题与正文按平台上限截断，不会被整条拒绝
+func TestSlackDiscordTruncate(t *testing.T) {
+	n := &Notifier{}
+	title, content := strings.Repeat("标题", 200), strings.Repeat("<正文>", 2000)
+
+	fake := startFakeHTTP(t, http.StatusOK, "ok")
+	if err := n.sendNotification(NotificationConfig{Type: "slack", Settings: map[string]interface{}{"webhook_url": fake.URL}}, title, content); err != nil {
+		t.Fatal(err)
+	}
+	blocks, _ := fake.Last().Body["blocks"].([]interface{})
+	header := blocks[0].(map[string]interface{})["text"].(map[string]interface{})["text"].(string)
+	section := blocks[1].(map[string]interface{})["text"].(map[string]interface{})["text"].(string)
+	if len(header) > slackHeaderLimit || len(section) > slackSectionLimit || !utf8.ValidString(header) {
+		t.Errorf("Slack 标题 %d 字节、正文 %d 字节，应截断到 %d / %d", len(header), len(section), slackHeaderLimit, slackSectionLimit)
+	}
+	if !strings.HasSuffix(section, ";") {
+		t.Errorf("截断后不应留下不完整的转义序列: %q", section[len(section)-10:])
+	}
+
+	fake = startFakeHTTP(t, http.StatusNoContent, "")
+	if err := n.sendNotification(NotificationConfig{Type: "discord", Settings: map[string]interface{}{"webhook_url": fake.URL}}, title, content); err != nil {
+		t.Fatal(err)
+	}
+	embed := fake.Last().Body["embeds"].([]interface{})[0].(map[string]interface{})
+	if len(embed["title"].(string)) > discordTitleLimit || len(embed["description"].(string)) > discordDescriptionLimit {
+		t.Errorf("Discord embed 应截断到 %d / %d: %d / %d", discordTitleLimit, discordDescriptionLimit,
+			len(embed["title"].(string)), len(embed["description"].(string)))
+	}
+}
+
+func TestSendTeams(t *testing.T) {
+	n := &Notifier{}
+	for _, tc := range []struct {
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{http.StatusAccepted, "", false}, // Workflows
+		{http.StatusOK, "1", false},      // 旧版 Incoming Webhook
+		{http.StatusOK, "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 413", true},
+		{http.StatusOK, "Microsoft Teams endpoint returned HTTP 429", true},
+		{http.StatusOK, "", true},
+		{http.StatusBadRequest, "Bad payload", true},
+	} {
+		fake := startFakeHTTP(t, tc.status, tc.body)
+		config := NotificationConfig{Type: "teams", Settings: map[string]interface{}{"webhook_url": fake.URL}}
+		err := teamsDriver{}.Send(n, config, n.newEvent(EventRemoteEnd, "远程控制已断开", "主机：PC-01\n\n时间：now"))
+		if (err != nil) != tc.wantErr {
+			t.Errorf("状态码 %d 响应 %q: err=%v, wantErr=%v", tc.status, tc.body, err, tc.wantErr)
+		}
+		attachments, _ := fake.Last().Body["attachments"].([]interface{})
+		if len(attachments) != 1 {
+			t.Fatalf("应包含 1 个 Adaptive Card 附件")
+		}
+		card := attachments[0].(map[string]interface{})["content"].(map[string]interface{})
+		body := card["body"].([]interface{})
+		if len(body) != 3 || body[0].(map[string]interface{})["color"] != "Good" {
+			t.Errorf("卡片内容错误: %v", body)
+		}
+	}
+}
+
+func TestSendDiscord(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusNoContent, "")
+	n := &Notifier{}
+	config := NotificationConfig{Type: "discord", Settings: map[string]interface{}{"webhook_url": fake.URL}}
+	// 颜色按事件类型而非标题中的符号：自定义模板的标题不带默认符号时颜色不变
+	for typ, want := range map[string]int{EventRemoteStart: 0xE74C3C, EventRemoteReminder: 0xE67E22, EventRemoteEnd: 0x27AE60, EventTest: 0x3498DB} {
+		if err := (discordDriver{}).Send(n, config, n.newEvent(typ, "办公室电脑", "内容")); err != nil {
+			t.Fatalf("204 应视为成功: %v", err)
+		}
+		embeds, _ := fake.Last().Body["embeds"].([]interface{})
+		if len(embeds) != 1 || embeds[0].(map[string]interface{})["color"] != float64(want) {
+			t.Errorf("%s 的 embed 颜色应为 %06X: %v", typ, want, embeds)
+		}
+	}
+
+	fake = startFakeHTTP(t, http.StatusTooManyRequests, `{"message":"You are being rate limited.","retry_after":1.5}`)
+	err := n.sendNotification(NotificationConfig{Type: "discord", Settings: map[string]interface{}{"webhook_url": fake.URL}}, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "1.5") {
+		t.Errorf("限流时应返回包含重试时间的错误，实际 %v", err)
+	}
+
+	fake = startFakeHTTP(t, http.StatusUnauthorized, `{"message":"Invalid Webhook Token","code":50027}`)
+	err = n.sendNotification(NotificationConfig{Type: "discord", Settings: map[string]interface{}{"webhook_url": fake.URL}}, "t", "c")
+	if err == nil || !strings.Contains(err.Error(), "Invalid Webhook Token") {
+		t.Errorf("应返回 Discord 的错误信息，实际 %v", err)
+	}
+}
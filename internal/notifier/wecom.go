@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	RegisterChannel(wecomDriver{})
+}
+
+// WeComConfig 企业微信群机器人配置
+type WeComConfig struct {
+	WebhookURL       string `json:"webhook_url"`       // Webhook 地址（含 key）
+	MentionedMobiles string `json:"mentioned_mobiles"` // 按手机号 @ 成员，多个用逗号分隔；"@all" 表示所有人
+	MentionedUserIDs string `json:"mentioned_userids"` // 按企业微信 userid @ 成员，多个用逗号分隔
+}
+
+// wecomDriver 是企业微信群机器人渠道
+type wecomDriver struct {
+	sendsTest
+	botEndpoint
+}
+
+func (wecomDriver) Type() string { return "wecom" }
+
+func (wecomDriver) Schema() *Schema {
+	s := webhookSchema("企业微信", "企业微信群机器人的 Webhook 地址（含 key）", "")
+	s.Properties["mentioned_mobiles"] = field("@ 手机号", "按手机号提醒群成员，多个用逗号分隔；填写 @all 提醒所有人")
+	s.Properties["mentioned_userids"] = field("@ 成员 userid", "按企业微信 userid 提醒群成员，多个用逗号分隔")
+	s.Order = append(s.Order, "mentioned_mobiles", "mentioned_userids")
+	return s
+}
+
+func (wecomDriver) Validate(config NotificationConfig) error {
+	return validateWebhookURL(botSettings(config).WebhookURL)
+}
+
+func (wecomDriver) Send(n *Notifier, config NotificationConfig, ev *Event) error {
+	return n.sendWeComNotification(config, ev, ev.Content)
+}
+
+// wecomMarkdownLimit 是企业微信 markdown 消息内容的字节上限
+const wecomMarkdownLimit = 4096
+
+// sendWeComNotification 发送企业微信群机器人通知（markdown 消息），content 为正文（统计报告为 markdown 排版）。
+// markdown 消息只支持在正文中用 <@userid> 提醒成员，按手机号 @ 需要额外发一条 text 消息；
+// 只有追加消息失败时，发件箱重试不再重发 markdown 消息。
+func (n *Notifier) sendWeComNotification(config NotificationConfig, ev *Event, content string) error {
+	var wc WeComConfig
+	config.decode(&wc)
+	var body strings.Builder
+	body.WriteString("### " + ev.Title + "\n")
+	body.WriteString(getWeComColorLine(ev.Type, truncateUTF8(content, wecomMarkdownLimit-512)))
+	userIDs := parseRecipients(wc.MentionedUserIDs)
+	if len(userIDs) > 0 {
+		body.WriteString("\n")
+		for _, id := range userIDs {
+			body.WriteString("<@" + id + ">")
+		}
+	}
+
+	message := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"content": truncateUTF8(body.String(), wecomMarkdownLimit),
+		},
+	}
+	if !ev.progress.done("markdown") {
+		if err := n.postWebhook(config.Network, wc.WebhookURL, nil, message, errcodeSuccess); err != nil {
+			return err
+		}
+		ev.progress.markDone("markdown")
+	}
+
+	mobiles := parseRecipients(wc.MentionedMobiles)
+	if len(mobiles) == 0 {
+		return nil
+	}
+	mention := map[string]interface{}{
+		"msgtype": "text",
+		"text": map[string]interface{}{
+			"content":               ev.Title,
+			"mentioned_mobile_list": mobiles,
+		},
+	}
+	return n.postWebhook(config.Network, wc.WebhookURL, nil, mention, errcodeSuccess)
+}
+
+// getWeComColorLine 按事件类型把正文包进企业微信 markdown 的字体颜色标签（warning 橙红 / info 绿 / comment 灰）
+func getWeComColorLine(eventType, content string) string {
+	color := "comment"
+	switch eventType {
+	case EventRemoteStart, EventRemoteEscalation, EventRemoteReminder:
+		color = "warning"
+	case EventRemoteEnd:
+		color = "info"
+	}
+	return fmt.Sprintf("<font color=\"%s\">%s</font>", color, content)
+}
@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wecomKeyPath 是企业微信群机器人地址中的路径与 key，key 需原样传递
+const wecomKeyPath = "/cgi-bin/webhook/send?key=test-key"
+
+func TestSendWeComMarkdownWithMentions(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{
+		Type: "wecom",
+		Settings: settingsOf(WeComConfig{
+			WebhookURL:       fake.URL + wecomKeyPath,
+			MentionedUserIDs: "zhangsan, lisi",
+			MentionedMobiles: "13800000000",
+		}),
+	}
+	if err := (wecomDriver{}).Send(n, cfg, n.newEvent(EventRemoteStart, "⚠️ 远程控制检测告警", "主机：PC-01")); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	reqs := fake.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("应发送 markdown + text 两条消息，实际 %d 条", len(reqs))
+	}
+	if reqs[0].Path != wecomKeyPath {
+		t.Errorf("webhook 地址中的 key 未原样传递: %s", reqs[0].Path)
+	}
+	msgs := []map[string]interface{}{reqs[0].Body, reqs[1].Body}
+	if msgs[0]["msgtype"] != "markdown" {
+		t.Errorf("第一条应为 markdown 消息，实际 %v", msgs[0]["msgtype"])
+	}
+	content, _ := msgs[0]["markdown"].(map[string]interface{})["content"].(string)
+	for _, want := range []string{"### ⚠️ 远程控制检测告警", `<font color="warning">`, "主机：PC-01", "<@zhangsan><@lisi>"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("markdown 内容缺少 %q:\n%s", want, content)
+		}
+	}
+	text, _ := msgs[1]["text"].(map[string]interface{})
+	mobiles, _ := text["mentioned_mobile_list"].([]interface{})
+	if len(mobiles) != 1 || mobiles[0] != "13800000000" {
+		t.Errorf("手机号提醒列表错误: %v", text["mentioned_mobile_list"])
+	}
+}
+
+// TestWeComRetrySendsOnlyMention 验证只有按手机号 @ 的追加消息失败时，发件箱重试不再重发 markdown 消息
+func TestWeComRetrySendsOnlyMention(t *testing.T) {
+	var mu sync.Mutex
+	var types []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		types = append(types, fmt.Sprint(msg["msgtype"]))
+		failed := len(types) == 2
+		mu.Unlock()
+		if failed {
+			w.Write([]byte(`{"errcode":-1,"errmsg":"system busy"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	n, _ := newTestNotifier(t)
+	n.SaveChannels([]Channel{{Name: "值班群", Type: "wecom", Enabled: true, Settings: map[string]interface{}{
+		"webhook_url": srv.URL + wecomKeyPath, "mentioned_mobiles": "13800000000",
+	}}})
+	n.dispatch(sampleEvent())
+	n.processOutbox(time.Now().Add(2 * time.Minute))
+
+	mu.Lock()
+	got := strings.Join(types, ", ")
+	mu.Unlock()
+	if got != "markdown, text, text" {
+		t.Errorf("重试应只补发 @ 提醒，实际请求顺序 %s", got)
+	}
+}
+
+func TestSendWeComWithoutMobilesSendsOneMessage(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{Type: "wecom", Settings: map[string]interface{}{"webhook_url": fake.URL + wecomKeyPath}}
+	if err := n.sendNotification(cfg, "✅ 远程控制已断开", "结束"); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if got := len(fake.Requests()); got != 1 {
+		t.Errorf("未配置手机号时只应发送 1 条消息，实际 %d 条", got)
+	}
+}
+
+func TestSendWeComErrcode(t *testing.T) {
+	fake := startFakeHTTP(t, http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	n := &Notifier{}
+	cfg := NotificationConfig{
+		Type:     "wecom",
+		Settings: settingsOf(WeComConfig{WebhookURL: fake.URL + wecomKeyPath, MentionedMobiles: "@all"}),
+	}
+	err := n.sendNotification(cfg, "测试", "内容")
+	if err == nil {
+		t.Fatal("errcode 非 0 时应返回错误")
+	}
+	if !strings.Contains(err.Error(), "93000") || !strings.Contains(err.Error(), "invalid webhook url") {
+		t.Errorf("错误信息应包含 errcode 与 errmsg: %v", err)
+	}
+	if got := len(fake.Requests()); got != 1 {
+		t.Errorf("markdown 消息失败后不应继续发送 @ 提醒，实际发送 %d 条", got)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	s := strings.Repeat("中", 10) // 每个字 3 字节
+	if got := truncateUTF8(s, 10); got != strings.Repeat("中", 3) {
+		t.Errorf("应按字符边界截断，实际 %q", got)
+	}
+	if got := truncateUTF8("abc", 10); got != "abc" {
+		t.Errorf("未超长时应原样返回，实际 %q", got)
+	}
+}
@@ -9,11 +9,29 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"RemoteKnown/internal/netconf"
+)
+
+// httpClient 带超时，避免 GitHub 不可达时长时间阻塞；UseNetwork 可替换为经代理 / 自定义证书的客户端。
+var (
+	httpClientMu sync.RWMutex
+	httpClient   = &http.Client{Timeout: 15 * time.Second}
 )
 
-// httpClient 带超时，避免 GitHub 不可达时长时间阻塞。
-var httpClient = &http.Client{Timeout: 15 * time.Second}
+// UseNetwork 让后续的规则下载使用给定的网络选项（代理、CA 证书、超时等）。
+func UseNetwork(opts netconf.Options) error {
+	c, err := opts.HTTPClient()
+	if err != nil {
+		return err
+	}
+	httpClientMu.Lock()
+	httpClient = c
+	httpClientMu.Unlock()
+	return nil
+}
 
 // VersionInfo 对应 data/version.json。
 type VersionInfo struct {
@@ -77,7 +95,10 @@ func ParseRulesContent(data []byte) (version, minAppVersion, rulesJSON string, e
 }
 
 func httpGet(url string) ([]byte, error) {
-	resp, err := httpClient.Get(url)
+	httpClientMu.RLock()
+	client := httpClient
+	httpClientMu.RUnlock()
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("请求 %s 失败: %w", url, err)
 	}
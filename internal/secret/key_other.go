@@ -0,0 +1,10 @@
+//go:build !windows
+
+package secret
+
+import "os"
+
+// restrictToOwner 把文件权限设为仅所有者可读写
+func restrictToOwner(path string) error {
+	return os.Chmod(path, 0600)
+}
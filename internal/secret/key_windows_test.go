@@ -0,0 +1,36 @@
+package secret
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+// TestKeyFileOwnerOnlyDACL 验证密钥文件的 DACL 受保护（不继承 AppData 的访问控制项）且只授权当前用户
+func TestKeyFileOwnerOnlyDACL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.key")
+	if _, err := LoadOrCreateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, _, err := sd.Control()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if control&windows.SE_DACL_PROTECTED == 0 {
+		t.Error("密钥文件的 DACL 应为受保护（不继承上级目录）")
+	}
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sddl := sd.String()
+	if strings.Count(sddl, "(") != 1 || !strings.Contains(sddl, user.User.Sid.String()) {
+		t.Errorf("密钥文件应只授权当前用户访问，实际 %s", sddl)
+	}
+}
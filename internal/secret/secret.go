@@ -0,0 +1,123 @@
+// Package secret 用 AES-256-GCM 加密落库的敏感配置（Webhook 签名密钥、SMTP 密码等）。
+// 密钥保存在数据库之外的密钥文件中，只拿到 RemoteKnown.db 无法还原明文。
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mask 是 API 返回敏感字段时使用的掩码。前端原样回传掩码表示"保持原值不变"。
+const Mask = "******"
+
+// sealedPrefix 标记已加密的值，未带前缀的视为旧版明文（读取时原样返回，下次保存时加密）。
+const sealedPrefix = "enc:v1:"
+
+const keySize = 32 // AES-256
+
+// Box 持有加解密所用的 AEAD。零值不可用，须通过 LoadOrCreateKey / NewBox 创建。
+type Box struct {
+	aead cipher.AEAD
+}
+
+// LoadOrCreateKey 读取 path 处的密钥文件；不存在时生成随机密钥写入。
+// 密钥文件仅当前用户可访问：Windows 上设置只含当前用户的受保护 DACL（不继承 AppData 目录的访问控制项，
+// 文件模式位在 Windows 上不起作用），其他系统上为 0600 权限。已有的密钥文件在读取时同样收紧权限。
+func LoadOrCreateKey(path string) (*Box, error) {
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("生成密钥失败: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("创建密钥目录失败: %w", err)
+		}
+		// O_EXCL：并发启动时只允许一个进程写入，另一个重新读取
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			return LoadOrCreateKey(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("写入密钥文件失败: %w", err)
+		}
+		// 写入密钥前收紧权限，文件在任何时刻都不会以继承的权限保存密钥
+		if err := restrictToOwner(path); err != nil {
+			f.Close()
+			os.Remove(path)
+			return nil, fmt.Errorf("设置密钥文件权限失败: %w", err)
+		}
+		if _, err := f.Write(key); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("写入密钥文件失败: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, fmt.Errorf("写入密钥文件失败: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	} else if err := restrictToOwner(path); err != nil {
+		return nil, fmt.Errorf("设置密钥文件权限失败: %w", err)
+	}
+	return NewBox(key)
+}
+
+// NewBox 用给定的 32 字节密钥创建 Box。
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("密钥长度应为 %d 字节，实际 %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// IsSealed 报告 s 是否为 Seal 产出的密文。
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix)
+}
+
+// Seal 加密 plain。空串与已加密的值原样返回，便于对整份配置重复调用。
+func (b *Box) Seal(plain string) (string, error) {
+	if plain == "" || IsSealed(plain) {
+		return plain, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open 解密 Seal 产出的密文；未加密的旧版明文原样返回。
+func (b *Box) Open(s string) (string, error) {
+	if !IsSealed(s) {
+		return s, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("密文格式无效: %w", err)
+	}
+	ns := b.aead.NonceSize()
+	if len(raw) < ns {
+		return "", fmt.Errorf("密文长度无效")
+	}
+	plain, err := b.aead.Open(nil, raw[:ns], raw[ns:], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败（密钥文件可能已更换）: %w", err)
+	}
+	return string(plain), nil
+}
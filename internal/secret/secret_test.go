@@ -0,0 +1,64 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.key")
+	box, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("创建密钥失败: %v", err)
+	}
+
+	sealed, err := box.Seal("SEC飞书签名")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "SEC") {
+		t.Fatalf("密文格式不正确: %q", sealed)
+	}
+	if again, _ := box.Seal(sealed); again != sealed {
+		t.Errorf("已加密的值不应被重复加密")
+	}
+
+	// 重新载入同一密钥文件后仍能解密
+	box2, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("载入密钥失败: %v", err)
+	}
+	if plain, err := box2.Open(sealed); err != nil || plain != "SEC飞书签名" {
+		t.Errorf("Open = %q, %v", plain, err)
+	}
+
+	// 旧版明文原样返回
+	if plain, err := box2.Open("legacy"); err != nil || plain != "legacy" {
+		t.Errorf("明文 Open = %q, %v", plain, err)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("密钥文件权限 = %o, 期望 600", perm)
+		}
+	}
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	a, _ := LoadOrCreateKey(filepath.Join(t.TempDir(), "a.key"))
+	b, _ := LoadOrCreateKey(filepath.Join(t.TempDir(), "b.key"))
+	sealed, err := a.Seal("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Open(sealed); err == nil {
+		t.Errorf("使用其他密钥解密应失败")
+	}
+}
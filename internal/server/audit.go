@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"RemoteKnown/internal/storage"
+)
+
+// audit 追加一条审计记录；失败只记日志，不影响业务请求本身。
+func (s *Server) audit(kind string, detail map[string]interface{}) {
+	if _, err := s.storage.AppendAuditEvent(kind, detail); err != nil {
+		log.Printf("[审计] 写入审计日志失败: %v", err)
+	}
+}
+
+// handleAuditVerify 校验整条审计哈希链，返回记录数、链头哈希与首个断链位置。
+func (s *Server) handleAuditVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := s.storage.VerifyAuditChain()
+	if err != nil {
+		log.Printf("[审计] 校验审计链失败: %v", err)
+		writeJSONError(w, "校验审计日志失败", http.StatusInternalServerError)
+		return
+	}
+	if !result.OK {
+		log.Printf("[审计] 审计链在第 %d 条断开: %s", result.BrokenSeq, result.Reason)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// handleHistoryAck 确认（知悉）一次远程会话，并记入审计日志。
+func (s *Server) handleHistoryAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeJSONError(w, "请指定要确认的会话", http.StatusBadRequest)
+		return
+	}
+	session, err := s.storage.AcknowledgeSession(req.ID)
+	if err != nil {
+		writeJSONError(w, "确认会话失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(storage.AuditSessionAck, map[string]interface{}{"session_id": session.ID})
+	log.Printf("[会话] 已确认会话 %s", session.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"session": session,
+	})
+}
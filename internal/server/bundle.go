@@ -0,0 +1,505 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/notifier"
+	"RemoteKnown/internal/ruleupdate"
+	"RemoteKnown/internal/secret"
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+	"RemoteKnown/internal/version"
+)
+
+// 配置包（bundle）用于批量部署：在一台机器上导出与设备无关的配置，导入到其他机器。
+const (
+	bundleFormat  = "remoteknown-bundle"
+	bundleVersion = 1
+)
+
+// bundleSettingKeys 是配置包包含的设备无关配置项（不含 device_name 等单机配置）。
+var bundleSettingKeys = []string{
+	settings.KeyRulesUpdateURL,
+	settings.KeyCustomTools,
+	settings.KeyToolOverrides,
+	settings.KeyDisabledTools,
+	settings.KeyNotificationTemplates,
+	settings.KeyOutboxMaxAgeHours,
+	settings.KeySessionReminderMinutes,
+	settings.KeySessionEscalationMinutes,
+	settings.KeySessionEscalationChannels,
+	settings.KeyDigestFrequency,
+	settings.KeyDigestTime,
+	settings.KeyDigestWeekday,
+	settings.KeyBusinessHours,
+}
+
+// configBundle 是配置包的 JSON 结构。
+type configBundle struct {
+	Format          string                     `json:"format"`
+	Version         int                        `json:"version"`
+	AppVersion      string                     `json:"app_version"`
+	ExportedAt      string                     `json:"exported_at"`
+	IncludesSecrets bool                       `json:"includes_secrets"`
+	Settings        map[string]json.RawMessage `json:"settings"`
+	Channels        []notifier.Channel         `json:"notification_channels,omitempty"`
+	RuleSet         *bundleRuleSet             `json:"rule_set,omitempty"`
+}
+
+// bundleRuleSet 是配置包中的当前生效规则集。
+type bundleRuleSet struct {
+	Version       string          `json:"version"`
+	MinAppVersion string          `json:"min_app_version"`
+	Rules         json.RawMessage `json:"rules"`
+}
+
+// bundleChange 描述导入时某一项将发生的变化（供预览）。
+type bundleChange struct {
+	Item    string `json:"item"`
+	Action  string `json:"action"` // unchanged | update | reset
+	Summary string `json:"summary,omitempty"`
+}
+
+// bundlePlan 是导入配置包计算出的目标状态，预览与实际应用共用。
+type bundlePlan struct {
+	changes  []bundleChange
+	settings map[string]string  // 需写入的配置项（存储编码）
+	channels []notifier.Channel // 需写入的通知渠道（明文，保存时加密）；nil 表示不变
+	ruleSet  *bundleRuleSet     // 需切换到的规则集；nil 表示不变
+}
+
+// handleBundle 导出 / 导入配置包。
+//
+//	GET  ?secrets=1 时包含明文密钥，否则密钥以掩码导出（导入时保留目标机已有值）
+//	POST {"mode":"merge|replace","preview":true,"bundle":{...}}；preview 为 true 时只返回变更不写入
+func (s *Server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		includeSecrets := r.URL.Query().Get("secrets") == "1"
+		b, err := s.exportBundle(includeSecrets)
+		if err != nil {
+			log.Printf("[配置包] 导出失败: %v", err)
+			writeJSONError(w, "导出配置包失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="remoteknown-bundle.json"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(b)
+
+	case http.MethodPost:
+		var req struct {
+			Mode    string       `json:"mode"`
+			Preview bool         `json:"preview"`
+			Bundle  configBundle `json:"bundle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+			return
+		}
+		if req.Mode == "" {
+			req.Mode = "merge"
+		}
+		if req.Mode != "merge" && req.Mode != "replace" {
+			writeJSONError(w, "mode 只能是 merge 或 replace", http.StatusBadRequest)
+			return
+		}
+
+		plan, err := s.planBundleImport(&req.Bundle, req.Mode == "replace")
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !req.Preview {
+			if err := s.applyBundlePlan(plan); err != nil {
+				log.Printf("[配置包] 导入失败: %v", err)
+				writeJSONError(w, "导入配置包失败: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("[配置包] 已按 %s 模式导入配置包", req.Mode)
+			s.audit(storage.AuditConfigChange, map[string]interface{}{"key": "bundle", "mode": req.Mode})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"applied": !req.Preview,
+			"mode":    req.Mode,
+			"changes": plan.changes,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// exportBundle 汇总当前机器上与设备无关的配置。
+func (s *Server) exportBundle(includeSecrets bool) (*configBundle, error) {
+	b := &configBundle{
+		Format:          bundleFormat,
+		Version:         bundleVersion,
+		AppVersion:      version.Version,
+		ExportedAt:      time.Now().Format(time.RFC3339),
+		IncludesSecrets: includeSecrets,
+		Settings:        make(map[string]json.RawMessage),
+	}
+	for _, key := range bundleSettingKeys {
+		def, _ := settings.Lookup(key)
+		value, err := s.settings.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(def.Decode(value))
+		if err != nil {
+			return nil, err
+		}
+		b.Settings[key] = raw
+	}
+
+	channels, err := s.notifier.ExportChannels(includeSecrets)
+	if err != nil {
+		return nil, err
+	}
+	b.Channels = channels
+
+	active, err := s.storage.GetActiveRuleSet()
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		b.RuleSet = &bundleRuleSet{
+			Version:       active.Version,
+			MinAppVersion: active.MinAppVersion,
+			Rules:         json.RawMessage(active.Rules),
+		}
+	}
+	return b, nil
+}
+
+// planBundleImport 计算导入后的目标配置与变更清单，不写入任何数据。
+//   - merge：包内出现的项与本机合并（工具规则按进程名合并，通知配置按渠道合并），未出现的项保持不变
+//   - replace：包内出现的项整体覆盖，包内缺失的配置项恢复默认
+//
+// 两种模式下值为掩码的密钥都保留本机已有值。
+func (s *Server) planBundleImport(b *configBundle, replace bool) (*bundlePlan, error) {
+	if b.Format != bundleFormat {
+		return nil, fmt.Errorf("不是 RemoteKnown 配置包（format=%q）", b.Format)
+	}
+	if b.Version > bundleVersion {
+		return nil, fmt.Errorf("配置包版本 %d 高于当前程序支持的版本 %d，请先升级主程序", b.Version, bundleVersion)
+	}
+
+	plan := &bundlePlan{settings: make(map[string]string)}
+
+	for _, key := range bundleSettingKeys {
+		def, _ := settings.Lookup(key)
+		current, err := s.settings.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, present := b.Settings[key]
+		var target string
+		switch {
+		case !present && !replace:
+			plan.changes = append(plan.changes, bundleChange{Item: key, Action: "unchanged"})
+			continue
+		case !present:
+			target = def.Default
+		default:
+			if target, err = def.Encode(raw); err != nil {
+				return nil, err
+			}
+			if err := def.Validate(target); err != nil {
+				return nil, err
+			}
+			if !replace {
+				if target, err = mergeSettingValue(key, current, target); err != nil {
+					return nil, fmt.Errorf("合并 %s 失败: %w", key, err)
+				}
+			}
+		}
+
+		if jsonEqual(current, target) {
+			plan.changes = append(plan.changes, bundleChange{Item: key, Action: "unchanged"})
+			continue
+		}
+		action := "update"
+		if !present {
+			action = "reset"
+		}
+		plan.settings[key] = target
+		plan.changes = append(plan.changes, bundleChange{Item: key, Action: action, Summary: summarizeSetting(key, current, target)})
+	}
+
+	if err := s.planChannels(plan, b.Channels, replace); err != nil {
+		return nil, err
+	}
+	if err := s.planRuleSet(plan, b.RuleSet); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planChannels 计算导入后的通知渠道（在明文上合并，保存时再加密）。
+// merge 按渠道 ID 覆盖或追加；replace 以配置包中的渠道列表为准。
+func (s *Server) planChannels(plan *bundlePlan, incoming []notifier.Channel, replace bool) error {
+	if incoming == nil {
+		plan.changes = append(plan.changes, bundleChange{Item: "notification_channels", Action: "unchanged"})
+		return nil
+	}
+	current, err := s.notifier.ExportChannels(true)
+	if err != nil {
+		return err
+	}
+	currentByID := make(map[string]notifier.Channel, len(current))
+	for _, c := range current {
+		currentByID[c.ID] = c
+	}
+
+	var target []notifier.Channel
+	index := make(map[string]int)
+	if !replace {
+		for _, c := range current {
+			index[c.ID] = len(target)
+			target = append(target, c)
+		}
+	}
+	for _, c := range incoming {
+		// 未携带密钥的配置包：掩码字段保留本机同 ID 渠道的已有值
+		for f, v := range c.Settings {
+			if v == secret.Mask {
+				c.Settings[f] = currentByID[c.ID].Settings[f]
+			}
+		}
+		if i, ok := index[c.ID]; ok && c.ID != "" {
+			target[i] = c
+		} else {
+			index[c.ID] = len(target)
+			target = append(target, c)
+		}
+	}
+	if target == nil {
+		target = []notifier.Channel{}
+	}
+
+	before, _ := json.Marshal(current)
+	after, _ := json.Marshal(target)
+	if bytes.Equal(before, after) {
+		plan.changes = append(plan.changes, bundleChange{Item: "notification_channels", Action: "unchanged"})
+		return nil
+	}
+	plan.channels = target
+	enabled := 0
+	for _, c := range target {
+		if c.Enabled {
+			enabled++
+		}
+	}
+	summary := fmt.Sprintf("%d 个渠道 → %d 个渠道（启用 %d 个）", len(current), len(target), enabled)
+	plan.changes = append(plan.changes, bundleChange{Item: "notification_channels", Action: "update", Summary: summary})
+	return nil
+}
+
+// planRuleSet 判断是否需要切换到配置包中的规则集版本。
+func (s *Server) planRuleSet(plan *bundlePlan, rs *bundleRuleSet) error {
+	if rs == nil {
+		plan.changes = append(plan.changes, bundleChange{Item: "rule_set", Action: "unchanged"})
+		return nil
+	}
+	if rs.Version == "" {
+		return fmt.Errorf("配置包中的规则集缺少版本号")
+	}
+	if rs.MinAppVersion != "" && ruleupdate.CompareVersions(version.Version, rs.MinAppVersion) < 0 {
+		return fmt.Errorf("配置包中的规则 v%s 要求主程序 v%s 以上，请先升级主程序", rs.Version, rs.MinAppVersion)
+	}
+	if _, err := detector.ParseRules(string(rs.Rules)); err != nil {
+		return fmt.Errorf("配置包中的规则格式无效: %w", err)
+	}
+
+	// 同一版本号只能对应一份规则内容：本机已有同版本但内容不同时拒绝导入，避免静默沿用本机规则
+	existing, err := s.storage.GetRuleSetByVersion(rs.Version)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !jsonEqual(existing.Rules, string(rs.Rules)) {
+		return fmt.Errorf("配置包中的规则 v%s 与本机已有的同版本规则内容不同，请为修改后的规则使用新的版本号", rs.Version)
+	}
+
+	active, err := s.storage.GetActiveRuleSet()
+	if err != nil {
+		return err
+	}
+	if active != nil && active.Version == rs.Version {
+		plan.changes = append(plan.changes, bundleChange{Item: "rule_set", Action: "unchanged", Summary: "v" + rs.Version})
+		return nil
+	}
+	from := "无"
+	if active != nil {
+		from = "v" + active.Version
+	}
+	plan.ruleSet = rs
+	plan.changes = append(plan.changes, bundleChange{Item: "rule_set", Action: "update", Summary: from + " → v" + rs.Version})
+	return nil
+}
+
+// applyBundlePlan 在一个事务中写入导入计划中的全部变更（任一项失败则全部回滚），提交后热重载检测规则。
+func (s *Server) applyBundlePlan(plan *bundlePlan) error {
+	var activated *storage.DetectionRuleSet
+	err := s.storage.Transaction(func(tx *storage.Storage) error {
+		store := settings.New(tx)
+		for key, value := range plan.settings {
+			if err := store.Set(key, value); err != nil {
+				return fmt.Errorf("写入配置 %s 失败: %w", key, err)
+			}
+		}
+		if plan.channels != nil {
+			if err := s.notifier.SaveChannelsIn(tx, plan.channels); err != nil {
+				return err
+			}
+		}
+		if plan.ruleSet != nil {
+			ruleSet, err := tx.SaveRuleSet(plan.ruleSet.Version, plan.ruleSet.MinAppVersion, string(plan.ruleSet.Rules), "bundle")
+			if err != nil {
+				return err
+			}
+			if err := tx.SetActiveRuleSet(ruleSet.ID); err != nil {
+				return err
+			}
+			activated = ruleSet
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if activated != nil {
+		go s.notifier.NotifyRulesUpdated(activated.Version, "bundle")
+	}
+	return s.detector.ReloadRules()
+}
+
+// mergeSettingValue 按配置项语义合并本机值与配置包中的值（均为存储编码）。
+func mergeSettingValue(key, current, incoming string) (string, error) {
+	switch key {
+	case settings.KeyCustomTools:
+		var cur, in []map[string]interface{}
+		if err := unmarshalOrEmpty(current, &cur); err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal([]byte(incoming), &in); err != nil {
+			return "", err
+		}
+		// 按进程名合并，配置包中的同名规则覆盖本机规则
+		index := make(map[string]int, len(cur))
+		for i, t := range cur {
+			index[toolKey(t)] = i
+		}
+		for _, t := range in {
+			if i, ok := index[toolKey(t)]; ok {
+				cur[i] = t
+			} else {
+				index[toolKey(t)] = len(cur)
+				cur = append(cur, t)
+			}
+		}
+		return marshalString(cur)
+
+	case settings.KeyToolOverrides, settings.KeyNotificationTemplates:
+		var cur, in map[string]interface{}
+		if err := unmarshalOrEmpty(current, &cur); err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal([]byte(incoming), &in); err != nil {
+			return "", err
+		}
+		if cur == nil {
+			cur = make(map[string]interface{})
+		}
+		for k, v := range in {
+			cur[k] = v
+		}
+		return marshalString(cur)
+
+	case settings.KeyDisabledTools:
+		var cur, in []string
+		if err := unmarshalOrEmpty(current, &cur); err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal([]byte(incoming), &in); err != nil {
+			return "", err
+		}
+		seen := make(map[string]bool, len(cur))
+		for _, n := range cur {
+			seen[strings.ToLower(n)] = true
+		}
+		for _, n := range in {
+			if !seen[strings.ToLower(n)] {
+				seen[strings.ToLower(n)] = true
+				cur = append(cur, strings.ToLower(n))
+			}
+		}
+		return marshalString(cur)
+
+	default:
+		// 标量配置项：配置包中的值直接生效
+		return incoming, nil
+	}
+}
+
+// summarizeSetting 生成配置项变更的简短描述。
+func summarizeSetting(key, before, after string) string {
+	switch key {
+	case settings.KeyCustomTools, settings.KeyDisabledTools:
+		var b, a []interface{}
+		unmarshalOrEmpty(before, &b)
+		unmarshalOrEmpty(after, &a)
+		return fmt.Sprintf("%d 项 → %d 项", len(b), len(a))
+	case settings.KeyToolOverrides, settings.KeyNotificationTemplates:
+		var b, a map[string]interface{}
+		unmarshalOrEmpty(before, &b)
+		unmarshalOrEmpty(after, &a)
+		return fmt.Sprintf("%d 项 → %d 项", len(b), len(a))
+	default:
+		return fmt.Sprintf("%q → %q", before, after)
+	}
+}
+
+func toolKey(t map[string]interface{}) string {
+	name, _ := t["processName"].(string)
+	return strings.ToLower(name)
+}
+
+func unmarshalOrEmpty(s string, v interface{}) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+func marshalString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// jsonEqual 判断两个存储值是否等价（JSON 按语义比较，其余按字符串比较）。
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var av, bv interface{}
+	if json.Unmarshal([]byte(a), &av) != nil || json.Unmarshal([]byte(b), &bv) != nil {
+		return false
+	}
+	ab, _ := json.Marshal(av)
+	bb, _ := json.Marshal(bv)
+	return bytes.Equal(ab, bb)
+}
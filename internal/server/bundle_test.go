@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+func TestMergeSettingValue(t *testing.T) {
+	got, err := mergeSettingValue(settings.KeyCustomTools,
+		`[{"processName":"a.exe","name":"A"},{"processName":"b.exe"}]`,
+		`[{"processName":"A.EXE","name":"A2"},{"processName":"c.exe"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"A2","processName":"A.EXE"},{"processName":"b.exe"},{"processName":"c.exe"}]`
+	if got != want {
+		t.Errorf("自定义工具合并结果错误:\n got %s\nwant %s", got, want)
+	}
+
+	got, err = mergeSettingValue(settings.KeyDisabledTools, `["a.exe"]`, `["A.exe","b.exe"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `["a.exe","b.exe"]` {
+		t.Errorf("取消关注列表应去重合并，实际 %s", got)
+	}
+
+	got, err = mergeSettingValue(settings.KeyToolOverrides, `{"a.exe":{"processName":"a.exe"}}`, `{"b.exe":{"processName":"b.exe"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"a.exe":{"processName":"a.exe"},"b.exe":{"processName":"b.exe"}}` {
+		t.Errorf("规则覆盖应按键合并，实际 %s", got)
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	if !jsonEqual(`{"a":1,"b":2}`, `{ "b":2, "a":1 }`) {
+		t.Error("语义相同的 JSON 应视为相等")
+	}
+	if jsonEqual(`[]`, `["x"]`) {
+		t.Error("不同的 JSON 不应相等")
+	}
+}
+
+// TestPlanRuleSetVersionConflict 验证本机已有同版本但内容不同的规则时拒绝导入，内容相同（仅格式不同）时视为同一规则
+func TestPlanRuleSetVersionConflict(t *testing.T) {
+	st, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, err := st.SaveRuleSet("2.0.0", "", `[{"processName":"a.exe","name":"A"}]`, "github"); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{storage: st}
+
+	plan := &bundlePlan{}
+	same := &bundleRuleSet{Version: "2.0.0", Rules: json.RawMessage(`[ { "name": "A", "processName": "a.exe" } ]`)}
+	if err := s.planRuleSet(plan, same); err != nil || plan.ruleSet == nil {
+		t.Errorf("内容相同的同版本规则应可切换: %v", err)
+	}
+
+	changed := &bundleRuleSet{Version: "2.0.0", Rules: json.RawMessage(`[{"processName":"b.exe","name":"B"}]`)}
+	if err := s.planRuleSet(&bundlePlan{}, changed); err == nil || !strings.Contains(err.Error(), "内容不同") {
+		t.Errorf("同版本不同内容的规则应拒绝导入，实际 %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"RemoteKnown/internal/detector"
+	"RemoteKnown/internal/notifier"
+	"RemoteKnown/internal/storage"
+)
+
+// handleNotificationChannels 读取 / 保存通知渠道列表。
+//
+//	GET 返回渠道列表（敏感字段打码）与支持的渠道类型
+//	PUT {"channels":[...]} 整体保存；敏感字段回传掩码表示保持原值
+func (s *Server) handleNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		channels, err := s.notifier.LoadChannels()
+		if err != nil {
+			writeJSONError(w, "读取通知渠道失败", http.StatusInternalServerError)
+			return
+		}
+		if channels == nil {
+			channels = []notifier.Channel{}
+		}
+		notifier.MaskChannels(channels)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"channels": channels,
+			"types":    notifier.ChannelTypes(),
+		})
+
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Channels []notifier.Channel `json:"channels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+			return
+		}
+		if req.Channels == nil {
+			req.Channels = []notifier.Channel{}
+		}
+		for _, c := range req.Channels {
+			if err := s.notifier.ValidateChannel(c); err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.notifier.SaveChannels(req.Channels); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		enabled := 0
+		for _, c := range req.Channels {
+			if c.Enabled {
+				enabled++
+			}
+		}
+		log.Printf("[通知渠道] 已保存 %d 个渠道（启用 %d 个）", len(req.Channels), enabled)
+		s.audit(storage.AuditConfigChange, map[string]interface{}{
+			"key":     "notification_channels",
+			"count":   len(req.Channels),
+			"enabled": enabled,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTestChannel 向单个渠道发送测试通知，请求体 {"channel":{...}}（可为未保存的渠道）。
+func (s *Server) handleTestChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Channel notifier.Channel `json:"channel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Channel.Type == "" {
+		writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+		return
+	}
+	if req.Channel.Settings == nil {
+		req.Channel.Settings = map[string]interface{}{}
+	}
+
+	// 表单中的密钥是 GET 返回的掩码时，取同 ID 渠道保存的真实值
+	if err := s.notifier.FillChannelSecrets(&req.Channel); err != nil {
+		writeJSONError(w, "读取已保存的通知渠道失败", http.StatusInternalServerError)
+		return
+	}
+	if err := s.notifier.TestChannel(req.Channel); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "测试通知发送成功",
+	})
+}
+
+// handleChannelTypes 列出支持的渠道类型及其设置的 JSON Schema，界面据此渲染表单。
+func (s *Server) handleChannelTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"types":   notifier.ChannelTypes(),
+	})
+}
+
+// handleDiagnoseChannel 分步诊断渠道的连通性（DNS、TCP、TLS、HTTP 响应 / SMTP 握手与认证），
+// 请求体同 handleTestChannel；诊断本身完成即返回 200，各步骤结果见 report。
+func (s *Server) handleDiagnoseChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Channel notifier.Channel `json:"channel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Channel.Type == "" {
+		writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+		return
+	}
+	if req.Channel.Settings == nil {
+		req.Channel.Settings = map[string]interface{}{}
+	}
+	if err := s.notifier.FillChannelSecrets(&req.Channel); err != nil {
+		writeJSONError(w, "读取已保存的通知渠道失败", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := s.notifier.DiagnoseChannel(req.Channel)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[通知器] 渠道「%s」诊断完成: 通过=%v 耗时=%dms", req.Channel.Name, report.Success, report.Duration)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// handlePreviewChannel 渲染某个事件在渠道上将要发送的完整内容（飞书卡片 JSON、钉钉 markdown、邮件 MIME），不发送，密钥打码。
+// 请求体 {"event":"remote_start","source":"sample|live","channel":{...}}；channel 同 handleTestChannel，
+// source 为 live 时使用当前检测状态，默认使用示例数据。
+func (s *Server) handlePreviewChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Event   string           `json:"event"`
+		Source  string           `json:"source"`
+		Channel notifier.Channel `json:"channel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Channel.Type == "" || req.Event == "" {
+		writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+		return
+	}
+	if req.Channel.Settings == nil {
+		req.Channel.Settings = map[string]interface{}{}
+	}
+	if err := s.notifier.FillChannelSecrets(&req.Channel); err != nil {
+		writeJSONError(w, "读取已保存的通知渠道失败", http.StatusInternalServerError)
+		return
+	}
+
+	var live *detector.SessionEvent
+	switch req.Source {
+	case "", "sample":
+	case "live":
+		live = s.liveSessionEvent()
+	default:
+		writeJSONError(w, "数据来源应为 sample 或 live", http.StatusBadRequest)
+		return
+	}
+
+	preview, err := s.notifier.PreviewChannel(req.Channel, req.Event, live)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"preview": preview,
+	})
+}
+
+// liveSessionEvent 以当前检测状态构造会话事件，有进行中的会话时取其 ID 与开始时间
+func (s *Server) liveSessionEvent() *detector.SessionEvent {
+	status := s.detector.GetStatus()
+	event := &detector.SessionEvent{
+		StartTime: status.StartTime,
+		Signals:   status.Signals,
+	}
+	if session, err := s.storage.GetOpenSession(); err == nil && session != nil {
+		event.SessionID = session.ID
+		event.StartTime = session.StartTime
+	}
+	return event
+}
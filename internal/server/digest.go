@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// handleNotificationDigest 预览或立即发送统计报告。
+//
+//	GET ?period=daily|weekly 返回截至当前的最近一期报告（默认按设置的频率，未启用时为日报）
+//	POST {"period":"weekly"} 立即生成并通过订阅 digest 事件的渠道发送
+func (s *Server) handleNotificationDigest(w http.ResponseWriter, r *http.Request) {
+	var period string
+	switch r.Method {
+	case http.MethodGet:
+		period = r.URL.Query().Get("period")
+	case http.MethodPost:
+		var req struct {
+			Period string `json:"period"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+				return
+			}
+		}
+		period = req.Period
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if period == "" {
+		period = s.notifier.DigestFrequency()
+	}
+	if period != "weekly" {
+		period = "daily"
+	}
+
+	d, err := s.notifier.BuildCurrentDigest(period, time.Now())
+	if err != nil {
+		writeJSONError(w, "生成统计报告失败", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.notifier.SendDigest(d)
+		log.Printf("[统计报告] 已手动发送%s报告：%d 次会话", period, d.Sessions)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"digest":  d,
+	})
+}
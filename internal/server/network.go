@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"RemoteKnown/internal/netconf"
+	"RemoteKnown/internal/ruleupdate"
+)
+
+// handleNetwork 读取或保存出站连接的全局网络选项（代理、CA 证书、客户端证书、超时）。
+//
+//	GET  返回当前选项，代理密码与客户端私钥为掩码
+//	PUT  保存选项，请求体为 netconf.Options；掩码字段沿用已保存的值
+func (s *Server) handleNetwork(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		opts, err := s.notifier.MaskedNetworkOptions()
+		if err != nil {
+			writeJSONError(w, "读取网络选项失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"network": opts,
+		})
+	case http.MethodPut, http.MethodPost:
+		var opts netconf.Options
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+			return
+		}
+		if err := s.notifier.SaveNetworkOptions(opts); err != nil {
+			writeJSONError(w, "保存网络选项失败: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.applyRulesNetwork()
+		log.Printf("[网络] 已更新出站连接的网络选项")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "网络选项已保存",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// applyRulesNetwork 让规则下载使用当前的全局网络选项；选项无效时保留原客户端
+func (s *Server) applyRulesNetwork() {
+	if err := ruleupdate.UseNetwork(s.notifier.NetworkOptions()); err != nil {
+		log.Printf("[规则更新] 网络选项无效，使用默认连接: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"RemoteKnown/internal/storage"
+)
+
+// handleOutbox 列出通知发件箱中的消息。
+//
+//	GET ?status=pending|sent|failed&page=1&pageSize=20，status 为空表示全部
+func (s *Server) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", storage.OutboxPending, storage.OutboxSent, storage.OutboxFailed:
+	default:
+		writeJSONError(w, "status 只能是 pending、sent 或 failed", http.StatusBadRequest)
+		return
+	}
+	page := 1
+	pageSize := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && ps > 0 && ps <= 200 {
+		pageSize = ps
+	}
+
+	msgs, total, err := s.storage.ListOutbox(status, page, pageSize)
+	if err != nil {
+		writeJSONError(w, "读取发件箱失败", http.StatusInternalServerError)
+		return
+	}
+	if msgs == nil {
+		msgs = []storage.OutboxMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"messages":   msgs,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (int(total) + pageSize - 1) / pageSize,
+	})
+}
+
+// handleOutboxRetry 手动重试一条等待重试或失败的消息（正在投递的消息不能重试），请求体 {"id":"..."}。
+func (s *Server) handleOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+		return
+	}
+	if err := s.notifier.RetryOutboxMessage(req.ID); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[发件箱] 已手动重试消息 %s", req.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "已重新排入发件箱",
+	})
+}
+
+// handleNotificationHistory 分页列出通知发送记录（每次发送尝试一条）。
+//
+//	GET ?channel_id=&event_type=&session_id=&success=true|false&page=1&pageSize=20，参数为空表示不过滤
+func (s *Server) handleNotificationHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := storage.DeliveryFilter{
+		ChannelID: q.Get("channel_id"),
+		EventType: q.Get("event_type"),
+		SessionID: q.Get("session_id"),
+	}
+	if v := q.Get("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, "success 只能是 true 或 false", http.StatusBadRequest)
+			return
+		}
+		filter.Success = &success
+	}
+	page := 1
+	pageSize := 20
+	if p, err := strconv.Atoi(q.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(q.Get("pageSize")); err == nil && ps > 0 && ps <= 200 {
+		pageSize = ps
+	}
+
+	records, total, err := s.storage.ListDeliveries(filter, page, pageSize)
+	if err != nil {
+		writeJSONError(w, "读取发送记录失败", http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []storage.NotificationDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"records":    records,
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (int(total) + pageSize - 1) / pageSize,
+	})
+}
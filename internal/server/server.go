@@ -13,20 +13,19 @@ import (
 	"RemoteKnown/internal/detector"
 	"RemoteKnown/internal/notifier"
 	"RemoteKnown/internal/ruleupdate"
+	"RemoteKnown/internal/settings"
 	"RemoteKnown/internal/storage"
 	"RemoteKnown/internal/version"
 )
 
 const (
 	DefaultPort = 18080
-
-	// defaultRulesUpdateURL 是检测规则的 GitHub raw 发布源（可通过配置项 rules_update_url 覆盖）。
-	defaultRulesUpdateURL = "https://raw.githubusercontent.com/samwafgo/RemoteKnown/main/data"
 )
 
 type Server struct {
 	detector *detector.Detector
 	storage  *storage.Storage
+	settings *settings.Store
 	notifier *notifier.Notifier
 	port     int
 	running  bool
@@ -50,6 +49,7 @@ func NewServer(detector *detector.Detector, storage *storage.Storage, notifier *
 	return &Server{
 		detector: detector,
 		storage:  storage,
+		settings: settings.New(storage),
 		notifier: notifier,
 		port:     DefaultPort,
 		clients:  make(map[string]chan []byte),
@@ -59,10 +59,26 @@ func NewServer(detector *detector.Detector, storage *storage.Storage, notifier *
 func (s *Server) Start() error {
 	http.HandleFunc("/api/status", s.handleStatus)
 	http.HandleFunc("/api/history", s.handleHistory)
+	http.HandleFunc("/api/history/ack", s.handleHistoryAck)
+	http.HandleFunc("/api/audit/verify", s.handleAuditVerify)
 	http.HandleFunc("/api/config", s.handleConfig)
+	http.HandleFunc("/api/settings", s.handleSettings)
+	http.HandleFunc("/api/bundle", s.handleBundle)
 	http.HandleFunc("/api/notification", s.handleNotification)
 	http.HandleFunc("/api/notification/test", s.handleTestNotification)
+	http.HandleFunc("/api/notification/channels", s.handleNotificationChannels)
+	http.HandleFunc("/api/notification/channels/test", s.handleTestChannel)
+	http.HandleFunc("/api/notification/channel-types", s.handleChannelTypes)
+	http.HandleFunc("/api/notification/diagnose", s.handleDiagnoseChannel)
+	http.HandleFunc("/api/notification/preview", s.handlePreviewChannel)
+	http.HandleFunc("/api/notification/templates", s.handleNotificationTemplates)
+	http.HandleFunc("/api/notification/templates/preview", s.handlePreviewTemplate)
+	http.HandleFunc("/api/notifications/outbox", s.handleOutbox)
+	http.HandleFunc("/api/notifications/outbox/retry", s.handleOutboxRetry)
+	http.HandleFunc("/api/notifications/history", s.handleNotificationHistory)
+	http.HandleFunc("/api/notification/digest", s.handleNotificationDigest)
 	http.HandleFunc("/api/notify", s.handleNotify)
+	http.HandleFunc("/api/network", s.handleNetwork)
 	http.HandleFunc("/api/device-name", s.handleDeviceName)
 	http.HandleFunc("/api/rules/version", s.handleRulesVersion)
 	http.HandleFunc("/api/rules/check", s.handleRulesCheck)
@@ -145,19 +161,33 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 各会话已发送的通知（会话 ID → 发送记录），证明告警确已发出
+	ids := make([]string, 0, len(sessions))
+	for _, se := range sessions {
+		ids = append(ids, se.ID)
+	}
+	notifications, err := s.storage.DeliveriesForSessions(ids)
+	if err != nil {
+		http.Error(w, "获取通知发送记录失败", http.StatusInternalServerError)
+		return
+	}
+
 	// 返回分页结果
 	response := map[string]interface{}{
-		"sessions":   sessions,
-		"total":      total,
-		"page":       page,
-		"pageSize":   pageSize,
-		"totalPages": (int(total) + pageSize - 1) / pageSize, // 向上取整
+		"sessions":      sessions,
+		"notifications": notifications,
+		"total":         total,
+		"page":          page,
+		"pageSize":      pageSize,
+		"totalPages":    (int(total) + pageSize - 1) / pageSize, // 向上取整
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleConfig 按存储编码（字符串）读写单个配置项，仅限 settings 登记处中公开的配置项。
+// 新代码请使用类型化的 /api/settings。
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -166,7 +196,11 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "key is required", http.StatusBadRequest)
 			return
 		}
-		value, err := s.storage.GetConfig(key)
+		if def, ok := settings.Lookup(key); !ok || def.Internal {
+			http.Error(w, "未知的配置项: "+key, http.StatusBadRequest)
+			return
+		}
+		value, err := s.settings.Get(key)
 		if err != nil {
 			http.Error(w, "获取配置失败", http.StatusInternalServerError)
 			return
@@ -179,11 +213,16 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if key, ok := req["key"]; ok {
-			if value, ok := req["value"]; ok {
-				s.storage.SetConfig(key, value)
-			}
+		key, value := req["key"], req["value"]
+		if def, ok := settings.Lookup(key); !ok || def.Internal {
+			http.Error(w, "未知的配置项: "+key, http.StatusBadRequest)
+			return
 		}
+		if err := s.settings.Set(key, value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.audit(storage.AuditConfigChange, map[string]interface{}{"key": key})
 		w.WriteHeader(http.StatusOK)
 
 	default:
@@ -194,162 +233,54 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		// 尝试获取新格式的通知配置
-		allConfigJSON, err := s.storage.GetConfig("notification_configs")
+		// 旧版表单的兼容视图：以类型名为 ID 的通知渠道（旧配置已在启动时迁移为渠道列表）
+		allConfigs, err := s.notifier.LegacyView()
 		if err != nil {
 			log.Printf("获取通知配置失败: %v", err)
 			http.Error(w, "获取通知配置失败", http.StatusInternalServerError)
 			return
 		}
 
-		var allConfigs map[string]interface{}
-
-		// 如果新格式配置不存在，尝试从旧格式迁移
-		if allConfigJSON == "" {
-			oldConfigJSON, _ := s.storage.GetConfig("notification_config")
-			if oldConfigJSON != "" {
-				log.Printf("检测到旧格式配置，开始迁移...")
-				var oldConfig map[string]interface{}
-				if err := json.Unmarshal([]byte(oldConfigJSON), &oldConfig); err == nil {
-					// 从旧格式迁移到新格式
-					allConfigs = make(map[string]interface{})
-					allConfigs["enabled"] = oldConfig["enabled"]
-					allConfigs["type"] = oldConfig["type"]
-
-					// 将旧配置保存到对应类型的子项中
-					configType := "feishu"
-					if t, ok := oldConfig["type"].(string); ok {
-						configType = t
-					}
-
-					allConfigs[configType] = map[string]interface{}{
-						"webhook_url": oldConfig["webhook_url"],
-						"secret":      oldConfig["secret"],
-					}
-
-					// 初始化其他类型的默认配置
-					if configType != "feishu" {
-						allConfigs["feishu"] = map[string]interface{}{
-							"webhook_url": "",
-							"secret":      "",
-						}
-					}
-					if configType != "dingtalk" {
-						allConfigs["dingtalk"] = map[string]interface{}{
-							"webhook_url": "",
-							"secret":      "",
-						}
-					}
-
-					// 保存迁移后的新格式配置
-					if newJSON, err := json.Marshal(allConfigs); err == nil {
-						s.storage.SetConfig("notification_configs", string(newJSON))
-						log.Printf("配置迁移完成")
-					}
-				}
-			}
-		} else {
-			// 解析现有配置
-			if err := json.Unmarshal([]byte(allConfigJSON), &allConfigs); err != nil {
-				log.Printf("解析配置失败: %v", err)
-				http.Error(w, "解析配置失败", http.StatusInternalServerError)
-				return
+		// 确保包含所有必需的字段：未配置的类型取其设置结构的默认值
+		for _, d := range notifier.ChannelDrivers() {
+			if allConfigs[d.Type()] == nil {
+				allConfigs[d.Type()] = d.Schema().Defaults()
 			}
 		}
 
-		// 如果还是没有配置，返回默认配置
-		if allConfigs == nil {
-			allConfigs = map[string]interface{}{
-				"enabled": false,
-				"type":    "feishu",
-				"feishu": map[string]interface{}{
-					"webhook_url": "",
-					"secret":      "",
-				},
-				"dingtalk": map[string]interface{}{
-					"webhook_url": "",
-					"secret":      "",
-				},
-			}
-		} else {
-			// 确保包含所有必需的字段
-			if allConfigs["feishu"] == nil {
-				allConfigs["feishu"] = map[string]interface{}{
-					"webhook_url": "",
-					"secret":      "",
-				}
-			}
-			if allConfigs["dingtalk"] == nil {
-				allConfigs["dingtalk"] = map[string]interface{}{
-					"webhook_url": "",
-					"secret":      "",
-				}
-			}
-		}
-
-		// 返回完整配置结构
+		// 返回完整配置结构（敏感字段已打码，POST 回传掩码时保留原值）
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(allConfigs)
 
 	case http.MethodPost:
-		// 保存通知配置
-		var newConfig struct {
-			Enabled    bool                   `json:"enabled"`
-			Type       string                 `json:"type"`
-			WebhookURL string                 `json:"webhook_url"`
-			Secret     string                 `json:"secret"`
-			Email      map[string]interface{} `json:"email"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
+		// 保存通知配置：type 对应的子项为该类型的设置，群机器人的 webhook_url / secret 在顶层
+		var form map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
 			log.Printf("解析通知配置请求失败: %v", err)
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-
-		// 读取现有的完整配置
-		allConfigJSON, _ := s.storage.GetConfig("notification_configs")
-		var allConfigs map[string]interface{}
-
-		if allConfigJSON != "" {
-			json.Unmarshal([]byte(allConfigJSON), &allConfigs)
-		}
-
-		if allConfigs == nil {
-			allConfigs = make(map[string]interface{})
-		}
-
-		// 更新基本配置
-		allConfigs["enabled"] = newConfig.Enabled
-		allConfigs["type"] = newConfig.Type
-
-		// 更新对应类型的具体配置：邮件存到 email 子项，飞书/钉钉存 webhook_url+secret
-		if newConfig.Type == "email" {
-			if newConfig.Email != nil {
-				allConfigs["email"] = newConfig.Email
-			}
-		} else {
-			allConfigs[newConfig.Type] = map[string]interface{}{
-				"webhook_url": newConfig.WebhookURL,
-				"secret":      newConfig.Secret,
-			}
-		}
-
-		// 保存完整配置
-		configJSON, err := json.Marshal(allConfigs)
+		typ, _ := form["type"].(string)
+		enabled, _ := form["enabled"].(bool)
+		typeConfig, err := notifier.LegacyTypeSettings(typ, form)
 		if err != nil {
-			log.Printf("通知配置序列化失败: %v", err)
-			http.Error(w, "配置序列化失败", http.StatusInternalServerError)
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if err := s.storage.SetConfig("notification_configs", string(configJSON)); err != nil {
+		// 保存到对应类型的通知渠道（敏感字段加密落库）
+		if err := s.notifier.SaveLegacyView(enabled, typ, typeConfig); err != nil {
 			log.Printf("保存通知配置到数据库失败: %v", err)
-			http.Error(w, "保存通知配置失败", http.StatusInternalServerError)
+			writeJSONError(w, "保存通知配置失败: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("通知配置保存成功: type=%s, enabled=%v", newConfig.Type, newConfig.Enabled)
+		log.Printf("通知配置保存成功: type=%s, enabled=%v", typ, enabled)
+		s.audit(storage.AuditConfigChange, map[string]interface{}{
+			"key":     "notification_channels",
+			"type":    typ,
+			"enabled": enabled,
+		})
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 
@@ -364,39 +295,31 @@ func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var req struct {
-		Type       string               `json:"type"`
-		WebhookURL string               `json:"webhook_url"`
-		Secret     string               `json:"secret"`
-		Email      notifier.EmailConfig `json:"email"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var form map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	// 按类型校验必填项
-	if req.Type == "email" {
-		if req.Email.SMTPHost == "" || req.Email.From == "" || req.Email.To == "" {
-			http.Error(w, "请填写 SMTP 服务器、发件人和收件人", http.StatusBadRequest)
-			return
-		}
-	} else if req.WebhookURL == "" {
-		http.Error(w, "Webhook URL不能为空", http.StatusBadRequest)
+	typ, _ := form["type"].(string)
+	typeSettings, err := notifier.LegacyTypeSettings(typ, form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	config := notifier.NotificationConfig{
-		Type:       req.Type,
-		WebhookURL: req.WebhookURL,
-		Secret:     req.Secret,
-		Email:      req.Email,
-		Enabled:    true,
+	// 旧版表单的渠道以类型名为 ID；表单中的密钥/密码是 GET 返回的掩码时，取库中保存的真实值
+	c := notifier.Channel{ID: typ, Type: typ, Enabled: true, Settings: typeSettings}
+	if err := s.notifier.FillChannelSecrets(&c); err != nil {
+		writeJSONError(w, "读取已保存的通知配置失败", http.StatusInternalServerError)
+		return
+	}
+	// 按类型校验必填项
+	if err := s.notifier.ValidateChannel(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	err := s.notifier.SendTestNotification(config)
-	if err != nil {
+	if err := s.notifier.TestChannel(c); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -432,21 +355,19 @@ func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
 
 	// 获取当前检测状态以获取信号信息
 	status := s.detector.GetStatus()
-	signals := status.Signals
-
-	// 转换为 detector.NotifierSignal 接口
-	notifierSignals := make([]detector.NotifierSignal, len(signals))
-	for i, sig := range signals {
-		notifierSignals[i] = notifierSignal{name: sig.Name}
+	event := detector.SessionEvent{
+		StartTime: status.StartTime,
+		Signals:   status.Signals,
 	}
 
 	switch req.Type {
 	case "remote_start":
 		log.Printf("[通知API] 处理远程开始通知")
-		s.notifier.NotifyRemoteStart(notifierSignals)
+		s.notifier.NotifyRemoteStart(event)
 	case "remote_end":
 		log.Printf("[通知API] 处理远程结束通知")
-		s.notifier.NotifyRemoteEnd(notifierSignals)
+		event.EndTime = time.Now()
+		s.notifier.NotifyRemoteEnd(event)
 	case "app_exit":
 		log.Printf("[通知API] 处理程序退出通知")
 		s.notifier.NotifyAppExit()
@@ -466,20 +387,11 @@ func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// notifierSignal 实现 detector.NotifierSignal 接口
-type notifierSignal struct {
-	name string
-}
-
-func (n notifierSignal) GetName() string {
-	return n.name
-}
-
 func (s *Server) handleDeviceName(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	switch r.Method {
 	case http.MethodGet:
-		name, _ := s.storage.GetConfig("device_name")
+		name := s.settings.DeviceName()
 		hostname, _ := os.Hostname()
 		json.NewEncoder(w).Encode(map[string]string{
 			"name":     name,
@@ -494,12 +406,13 @@ func (s *Server) handleDeviceName(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if err := s.storage.SetConfig("device_name", req.Name); err != nil {
+		if err := s.settings.SetDeviceName(req.Name); err != nil {
 			log.Printf("[设备名] 保存失败: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "保存失败"})
 			return
 		}
+		s.audit(storage.AuditConfigChange, map[string]interface{}{"key": "device_name", "value": req.Name})
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 
 	default:
@@ -509,10 +422,7 @@ func (s *Server) handleDeviceName(w http.ResponseWriter, r *http.Request) {
 
 // rulesUpdateBaseURL 读取规则更新源地址（配置项 rules_update_url，缺省用默认）。
 func (s *Server) rulesUpdateBaseURL() string {
-	if url, _ := s.storage.GetConfig("rules_update_url"); url != "" {
-		return url
-	}
-	return defaultRulesUpdateURL
+	return s.settings.RulesUpdateURL()
 }
 
 // writeJSONError 按项目约定输出 {"success":false,"error":"中文"} + 指定状态码。
@@ -577,6 +487,7 @@ func (s *Server) handleRulesCheck(w http.ResponseWriter, r *http.Request) {
 
 	currentVersion, _ := s.detector.GetActiveRuleVersion()
 
+	s.applyRulesNetwork()
 	info, err := ruleupdate.FetchVersion(s.rulesUpdateBaseURL())
 	if err != nil {
 		log.Printf("[规则更新] 检查更新失败: %v", err)
@@ -608,6 +519,7 @@ func (s *Server) handleRulesApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.applyRulesNetwork()
 	ruleVersion, minAppVersion, rulesJSON, err := ruleupdate.FetchRules(s.rulesUpdateBaseURL())
 	if err != nil {
 		log.Printf("[规则更新] 下载规则失败: %v", err)
@@ -642,6 +554,8 @@ func (s *Server) handleRulesApply(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[规则更新] 已应用规则 v%s", ruleVersion)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "apply", "version": ruleVersion, "source": "github"})
+	go s.notifier.NotifyRulesUpdated(ruleVersion, "github")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -703,6 +617,8 @@ func (s *Server) handleRulesUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[规则更新] 已手工导入规则 v%s", ruleVersion)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "upload", "version": ruleVersion, "source": "manual"})
+	go s.notifier.NotifyRulesUpdated(ruleVersion, "manual")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -746,6 +662,8 @@ func (s *Server) handleRulesRollback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[规则更新] 已回滚到规则 v%s", req.Version)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "rollback", "version": req.Version})
+	go s.notifier.NotifyRulesUpdated(req.Version, "rollback")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -791,6 +709,7 @@ func (s *Server) handleToolsToggle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("[监控工具] %s 监控状态=%v", req.ProcessName, req.Enabled)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "toggle", "process": req.ProcessName, "enabled": req.Enabled})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
@@ -858,6 +777,7 @@ func (s *Server) handleToolsCustom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("[监控工具] 已新增自定义工具：%s (%s)", tool.ToolName, tool.ProcessName)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "custom_add", "process": tool.ProcessName, "tool": tool.ToolName})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -883,6 +803,7 @@ func (s *Server) handleToolsCustomRemove(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	log.Printf("[监控工具] 已删除自定义工具：%s", req.ProcessName)
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "custom_remove", "process": req.ProcessName})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
@@ -936,6 +857,7 @@ func (s *Server) handleToolsRulesRaw(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		log.Printf("[监控工具] 已手动保存全部规则：%d 条", len(tools))
+		s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "editor_save", "count": len(tools)})
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -959,6 +881,7 @@ func (s *Server) handleToolsRulesReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("[监控工具] 已恢复内置规则默认")
+	s.audit(storage.AuditRuleChange, map[string]interface{}{"action": "reset_overrides"})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"RemoteKnown/internal/settings"
+	"RemoteKnown/internal/storage"
+)
+
+// settingView 是 /api/settings 中单个配置项的展示结构。
+type settingView struct {
+	Key         string        `json:"key"`
+	Type        settings.Kind `json:"type"`
+	Description string        `json:"description"`
+	Default     interface{}   `json:"default"`
+	Value       interface{}   `json:"value"`
+}
+
+// handleSettings 列出/修改登记处中的公开配置项。
+//
+//	GET 返回全部配置项的类型、说明、默认值与当前值
+//	PUT 以 {"键": 值, ...} 批量修改：全部校验通过才写入，未知键直接拒绝
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		views := make([]settingView, 0)
+		for _, def := range settings.All() {
+			if def.Internal {
+				continue
+			}
+			value, err := s.settings.Get(def.Key)
+			if err != nil {
+				writeJSONError(w, "读取配置失败", http.StatusInternalServerError)
+				return
+			}
+			views = append(views, settingView{
+				Key:         def.Key,
+				Type:        def.Kind,
+				Description: def.Description,
+				Default:     def.Decode(def.Default),
+				Value:       def.Decode(value),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"settings": views,
+		})
+
+	case http.MethodPut:
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req) == 0 {
+			writeJSONError(w, "请求格式无效，应为 {\"配置项\": 值} 对象", http.StatusBadRequest)
+			return
+		}
+
+		// 先整体校验，避免部分写入
+		keys := make([]string, 0, len(req))
+		encoded := make(map[string]string, len(req))
+		for key, raw := range req {
+			def, ok := settings.Lookup(key)
+			if !ok || def.Internal {
+				writeJSONError(w, "未知的配置项: "+key, http.StatusBadRequest)
+				return
+			}
+			value, err := def.Encode(raw)
+			if err == nil {
+				err = def.Validate(value)
+			}
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			keys = append(keys, key)
+			encoded[key] = value
+		}
+		sort.Strings(keys)
+
+		reloadRules := false
+		for _, key := range keys {
+			if err := s.settings.Set(key, encoded[key]); err != nil {
+				writeJSONError(w, "保存配置 "+key+" 失败", http.StatusInternalServerError)
+				return
+			}
+			s.audit(storage.AuditConfigChange, map[string]interface{}{"key": key})
+			switch key {
+			case settings.KeyCustomTools, settings.KeyDisabledTools, settings.KeyToolOverrides:
+				reloadRules = true
+			}
+		}
+		if reloadRules {
+			if err := s.detector.ReloadRules(); err != nil {
+				writeJSONError(w, "重载规则失败", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		log.Printf("[配置] 已更新配置项: %v", keys)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"updated": keys,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"RemoteKnown/internal/notifier"
+	"RemoteKnown/internal/storage"
+)
+
+// handleNotificationTemplates 读取 / 保存全局通知消息模板。
+//
+//	GET 返回全局模板、内置默认模板、可自定义的事件与变量说明
+//	PUT {"templates":{"remote_start":{"title":"...","body":"..."}}} 整体保存；标题与正文均为空的事件恢复默认
+func (s *Server) handleNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := s.notifier.LoadTemplates()
+		if err != nil {
+			writeJSONError(w, "读取通知模板失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"templates": templates,
+			"defaults":  notifier.DefaultTemplates(),
+			"events":    notifier.TemplateEvents(),
+			"variables": notifier.TemplateVariables(),
+		})
+
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Templates map[string]notifier.MessageTemplate `json:"templates"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+			return
+		}
+		if err := s.notifier.SaveTemplates(req.Templates); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[通知模板] 已保存全局通知模板")
+		s.audit(storage.AuditConfigChange, map[string]interface{}{"key": "notification_templates"})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePreviewTemplate 校验模板并用示例数据渲染，请求体 {"event":"remote_start","title":"...","body":"..."}；
+// 标题或正文为空时使用内置默认模板。模板无效时返回 400 与错误原因。
+func (s *Server) handlePreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Event string `json:"event"`
+		notifier.MessageTemplate
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Event == "" {
+		writeJSONError(w, "请求格式无效", http.StatusBadRequest)
+		return
+	}
+	title, content, err := notifier.PreviewTemplate(req.Event, req.MessageTemplate)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"title":   title,
+		"content": content,
+	})
+}
@@ -0,0 +1,557 @@
+// Package settings 是守护进程全部配置项的登记处：每项配置的类型、默认值、校验与说明集中定义在这里，
+// 各包通过 Store 的类型化访问器读写，而不是直接拼 configs 表的字符串键。
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"RemoteKnown/internal/storage"
+)
+
+// 配置项键名（即 configs 表的 key）
+const (
+	KeyDeviceName                = "device_name"
+	KeyRulesUpdateURL            = "rules_update_url"
+	KeyCustomTools               = "custom_tools"
+	KeyDisabledTools             = "disabled_tools"
+	KeyToolOverrides             = "tool_overrides"
+	KeyNotificationChannels      = "notification_channels"
+	KeyNotificationTemplates     = "notification_templates"
+	KeyNotificationConfigs       = "notification_configs"
+	KeyNotificationLegacy        = "notification_config"
+	KeyOutboxMaxAgeHours         = "notification_outbox_max_age_hours"
+	KeySessionReminderMinutes    = "session_reminder_minutes"
+	KeySessionEscalationMinutes  = "session_escalation_minutes"
+	KeySessionEscalationChannels = "session_escalation_channels"
+	KeyDigestFrequency           = "digest_frequency"
+	KeyDigestTime                = "digest_time"
+	KeyDigestWeekday             = "digest_weekday"
+	KeyDigestLastSent            = "digest_last_sent"
+	KeyBusinessHours             = "business_hours"
+	KeyNetworkOptions            = "network_options"
+)
+
+// DefaultRulesUpdateURL 是检测规则的 GitHub raw 发布源（可通过 rules_update_url 覆盖）。
+const DefaultRulesUpdateURL = "https://raw.githubusercontent.com/samwafgo/RemoteKnown/main/data"
+
+// Kind 是配置值的类型，决定 API 中的 JSON 表示与存储编码。
+type Kind string
+
+const (
+	KindString Kind = "string"
+	KindBool   Kind = "bool"
+	KindInt    Kind = "int"
+	KindJSON   Kind = "json" // 任意 JSON 文档，按原样存储
+)
+
+// ErrUnknownKey 表示配置项未在登记处定义。
+var ErrUnknownKey = errors.New("未知的配置项")
+
+// Setting 描述一个配置项。
+type Setting struct {
+	Key         string `json:"key"`
+	Kind        Kind   `json:"type"`
+	Default     string `json:"-"` // 存储编码形式的默认值
+	Description string `json:"description"`
+	// Internal 表示由专用接口维护（如含加密字段的通知配置），不在 /api/settings 中列出或修改
+	Internal bool `json:"-"`
+
+	validate func(string) error
+}
+
+var registry = []Setting{
+	{
+		Key:         KeyDeviceName,
+		Kind:        KindString,
+		Description: "设备标识名，显示在通知中；留空使用主机名",
+		validate:    validateDeviceName,
+	},
+	{
+		Key:         KeyRulesUpdateURL,
+		Kind:        KindString,
+		Default:     DefaultRulesUpdateURL,
+		Description: "检测规则更新源（version.json / rules.json 所在目录的 URL）",
+		validate:    validateHTTPURL,
+	},
+	{
+		Key:         KeyCustomTools,
+		Kind:        KindJSON,
+		Default:     "[]",
+		Description: "用户录制的自定义远程工具规则（RemoteTool 数组）",
+		validate:    validateCustomTools,
+	},
+	{
+		Key:         KeyDisabledTools,
+		Kind:        KindJSON,
+		Default:     "[]",
+		Description: "取消关注的工具进程名（小写字符串数组）",
+		validate:    validateStringArray,
+	},
+	{
+		Key:         KeyToolOverrides,
+		Kind:        KindJSON,
+		Default:     "{}",
+		Description: "用户对内置规则的修改（进程名小写 → RemoteTool）",
+		validate:    validateToolOverrides,
+	},
+	{
+		Key:         KeyNotificationChannels,
+		Kind:        KindJSON,
+		Description: "通知渠道列表（敏感字段加密存储，通过 /api/notification/channels 维护）",
+		Internal:    true,
+		validate:    validateJSONArray,
+	},
+	{
+		Key:         KeyNotificationTemplates,
+		Kind:        KindJSON,
+		Default:     "{}",
+		Description: "全局通知消息模板（事件类型 → 标题 / 正文模板），通过 /api/notification/templates 维护",
+		Internal:    true,
+		validate:    validateJSONObject,
+	},
+	{
+		Key:         KeyOutboxMaxAgeHours,
+		Kind:        KindInt,
+		Default:     "24",
+		Description: "通知投递失败后自动重试的最长时间（小时），超过后标记为失败",
+		validate:    intRange(1, 720),
+	},
+	{
+		Key:         KeySessionReminderMinutes,
+		Kind:        KindInt,
+		Default:     "0",
+		Description: "远程会话持续期间每隔多少分钟发送一次提醒；0 表示不提醒",
+		validate:    intRange(0, 1440),
+	},
+	{
+		Key:         KeySessionEscalationMinutes,
+		Kind:        KindInt,
+		Default:     "0",
+		Description: "远程会话持续超过多少分钟后升级通知；0 表示不升级",
+		validate:    intRange(0, 10080),
+	},
+	{
+		Key:         KeySessionEscalationChannels,
+		Kind:        KindJSON,
+		Default:     "[]",
+		Description: "升级通知额外发送到的渠道 ID（不受渠道订阅条件限制）",
+		validate:    validateStringArray,
+	},
+	{
+		Key:         KeyDigestFrequency,
+		Kind:        KindString,
+		Default:     "off",
+		Description: "统计报告发送频率：off（不发送）/ daily（每天）/ weekly（每周）",
+		validate:    oneOf("off", "daily", "weekly"),
+	},
+	{
+		Key:         KeyDigestTime,
+		Kind:        KindString,
+		Default:     "09:00",
+		Description: "统计报告发送时间（HH:MM），报告覆盖截至该时间的前一天 / 前一周",
+		validate:    validateClock,
+	},
+	{
+		Key:         KeyDigestWeekday,
+		Kind:        KindInt,
+		Default:     "1",
+		Description: "周报发送日（0 为周日，1 为周一，以此类推）",
+		validate:    intRange(0, 6),
+	},
+	{
+		Key:         KeyDigestLastSent,
+		Kind:        KindString,
+		Description: "上一次统计报告对应的计划发送时间（RFC3339），用于避免重复发送",
+		Internal:    true,
+	},
+	{
+		Key:         KeyBusinessHours,
+		Kind:        KindString,
+		Default:     "09:00-18:00",
+		Description: "工作时间（HH:MM-HH:MM，周一至周五），统计报告据此标出非工作时间的会话",
+		validate:    validateClockRange,
+	},
+	{
+		Key:         KeyNetworkOptions,
+		Kind:        KindJSON,
+		Default:     "{}",
+		Description: "全局出站网络选项（代理、CA 证书、客户端证书、超时），作用于通知与规则下载；含加密字段，通过 /api/network 维护",
+		Internal:    true,
+		validate:    validateJSONObject,
+	},
+	{
+		Key:         KeyNotificationConfigs,
+		Kind:        KindJSON,
+		Description: "旧版按类型保存的通知配置（仅用于迁移到 notification_channels）",
+		Internal:    true,
+		validate:    validateJSONObject,
+	},
+	{
+		Key:         KeyNotificationLegacy,
+		Kind:        KindJSON,
+		Description: "旧版单渠道通知配置（仅用于迁移）",
+		Internal:    true,
+		validate:    validateJSONObject,
+	},
+}
+
+// Lookup 按键名查找配置项定义。
+func Lookup(key string) (Setting, bool) {
+	for _, s := range registry {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// All 返回全部配置项定义（按登记顺序）。
+func All() []Setting {
+	out := make([]Setting, len(registry))
+	copy(out, registry)
+	return out
+}
+
+// Validate 校验存储编码形式的值是否符合该配置项的类型与约束。
+func (s Setting) Validate(value string) error {
+	switch s.Kind {
+	case KindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s 应为布尔值", s.Key)
+		}
+	case KindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s 应为整数", s.Key)
+		}
+	case KindJSON:
+		if value != "" && !json.Valid([]byte(value)) {
+			return fmt.Errorf("%s 不是合法的 JSON", s.Key)
+		}
+	}
+	// JSON 配置写入空串表示恢复默认值，无需再校验结构
+	if s.validate != nil && !(s.Kind == KindJSON && value == "") {
+		if err := s.validate(value); err != nil {
+			return fmt.Errorf("%s: %w", s.Key, err)
+		}
+	}
+	return nil
+}
+
+// Encode 把 API 传入的 JSON 值转换为存储编码形式（字符串原文、布尔/整数的文本、JSON 的紧凑文本）。
+func (s Setting) Encode(raw json.RawMessage) (string, error) {
+	switch s.Kind {
+	case KindString:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", fmt.Errorf("%s 应为字符串", s.Key)
+		}
+		return v, nil
+	case KindBool:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", fmt.Errorf("%s 应为布尔值", s.Key)
+		}
+		return strconv.FormatBool(v), nil
+	case KindInt:
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", fmt.Errorf("%s 应为整数", s.Key)
+		}
+		return strconv.Itoa(v), nil
+	default:
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", fmt.Errorf("%s 不是合法的 JSON", s.Key)
+		}
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// Decode 把存储编码形式的值转换为 API 返回用的 JSON 值。
+func (s Setting) Decode(value string) interface{} {
+	switch s.Kind {
+	case KindBool:
+		v, _ := strconv.ParseBool(value)
+		return v
+	case KindInt:
+		v, _ := strconv.Atoi(value)
+		return v
+	case KindJSON:
+		if value == "" {
+			return nil
+		}
+		return json.RawMessage(value)
+	default:
+		return value
+	}
+}
+
+// Store 通过登记处读写配置，底层仍是 storage 的 configs 表。
+type Store struct {
+	storage *storage.Storage
+}
+
+// New 创建配置访问器。
+func New(st *storage.Storage) *Store {
+	return &Store{storage: st}
+}
+
+// Get 返回配置项的存储值；未设置时返回默认值。
+func (s *Store) Get(key string) (string, error) {
+	def, ok := Lookup(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	value, err := s.storage.GetConfig(key)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return def.Default, nil
+	}
+	return value, nil
+}
+
+// Set 校验后写入配置项（存储编码形式）。
+func (s *Store) Set(key, value string) error {
+	def, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	if err := def.Validate(value); err != nil {
+		return err
+	}
+	return s.storage.SetConfig(key, value)
+}
+
+// GetJSON 把 JSON 类型配置项反序列化到 v；未设置且无默认值时不修改 v。
+func (s *Store) GetJSON(key string, v interface{}) error {
+	value, err := s.Get(key)
+	if err != nil || value == "" {
+		return err
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+// SetJSON 把 v 序列化后写入 JSON 类型配置项。
+func (s *Store) SetJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Set(key, string(b))
+}
+
+// DeviceName 返回用户配置的设备标识名（未配置时为空串）。
+func (s *Store) DeviceName() string {
+	name, _ := s.Get(KeyDeviceName)
+	return name
+}
+
+// SetDeviceName 设置设备标识名。
+func (s *Store) SetDeviceName(name string) error {
+	return s.Set(KeyDeviceName, name)
+}
+
+// RulesUpdateURL 返回检测规则更新源地址（未配置时为默认 GitHub 源）。
+func (s *Store) RulesUpdateURL() string {
+	if u, _ := s.Get(KeyRulesUpdateURL); u != "" {
+		return u
+	}
+	return DefaultRulesUpdateURL
+}
+
+// OutboxMaxAge 返回通知自动重试的最长时间。
+func (s *Store) OutboxMaxAge() time.Duration {
+	return time.Duration(s.intValue(KeyOutboxMaxAgeHours)) * time.Hour
+}
+
+// SessionReminderInterval 返回长时间会话的提醒间隔；0 表示不提醒。
+func (s *Store) SessionReminderInterval() time.Duration {
+	return time.Duration(s.intValue(KeySessionReminderMinutes)) * time.Minute
+}
+
+// SessionEscalationAfter 返回会话升级通知的时长阈值；0 表示不升级。
+func (s *Store) SessionEscalationAfter() time.Duration {
+	return time.Duration(s.intValue(KeySessionEscalationMinutes)) * time.Minute
+}
+
+// SessionEscalationChannels 返回升级通知额外发送到的渠道 ID。
+func (s *Store) SessionEscalationChannels() []string {
+	var ids []string
+	s.GetJSON(KeySessionEscalationChannels, &ids)
+	return ids
+}
+
+// DigestSchedule 是统计报告的发送计划
+type DigestSchedule struct {
+	Frequency string // off / daily / weekly
+	Hour      int
+	Minute    int
+	Weekday   time.Weekday // 仅 weekly 使用
+}
+
+// DigestSchedule 返回统计报告的发送计划。
+func (s *Store) DigestSchedule() DigestSchedule {
+	sched := DigestSchedule{Weekday: time.Weekday(s.intValue(KeyDigestWeekday))}
+	sched.Frequency, _ = s.Get(KeyDigestFrequency)
+	clock, _ := s.Get(KeyDigestTime)
+	if validateClock(clock) != nil {
+		clock = "09:00"
+	}
+	sched.Hour, sched.Minute, _ = parseClock(clock)
+	return sched
+}
+
+// BusinessHours 返回工作时间的起止（距当天零点的时长）。
+func (s *Store) BusinessHours() (start, end time.Duration) {
+	v, _ := s.Get(KeyBusinessHours)
+	if validateClockRange(v) != nil {
+		v = "09:00-18:00"
+	}
+	from, to, _ := strings.Cut(v, "-")
+	h1, m1, _ := parseClock(from)
+	h2, m2, _ := parseClock(to)
+	return time.Duration(h1)*time.Hour + time.Duration(m1)*time.Minute,
+		time.Duration(h2)*time.Hour + time.Duration(m2)*time.Minute
+}
+
+// intValue 读取整数配置项，值无效时使用默认值。
+func (s *Store) intValue(key string) int {
+	def, _ := Lookup(key)
+	v, _ := s.Get(key)
+	if def.Validate(v) != nil {
+		v = def.Default
+	}
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+func validateDeviceName(v string) error {
+	if utf8.RuneCountInString(v) > 64 {
+		return fmt.Errorf("设备名不能超过 64 个字符")
+	}
+	return nil
+}
+
+func validateHTTPURL(v string) error {
+	if v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("应为 http(s) 地址")
+	}
+	return nil
+}
+
+// oneOf 返回校验取值属于给定集合的校验函数
+func oneOf(values ...string) func(string) error {
+	return func(v string) error {
+		for _, allowed := range values {
+			if v == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("只能是 %s", strings.Join(values, " / "))
+	}
+}
+
+// parseClock 解析 HH:MM 格式的时刻
+func parseClock(v string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, 0, fmt.Errorf("时间格式应为 HH:MM")
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func validateClock(v string) error {
+	_, _, err := parseClock(v)
+	return err
+}
+
+func validateClockRange(v string) error {
+	from, to, ok := strings.Cut(v, "-")
+	if !ok {
+		return fmt.Errorf("格式应为 HH:MM-HH:MM")
+	}
+	h1, m1, err1 := parseClock(from)
+	h2, m2, err2 := parseClock(to)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("格式应为 HH:MM-HH:MM")
+	}
+	if h1*60+m1 >= h2*60+m2 {
+		return fmt.Errorf("结束时间应晚于开始时间")
+	}
+	return nil
+}
+
+// intRange 返回校验整数取值范围 [min, max] 的校验函数
+func intRange(min, max int) func(string) error {
+	return func(v string) error {
+		n, _ := strconv.Atoi(v)
+		if n < min || n > max {
+			return fmt.Errorf("应在 %d 到 %d 之间", min, max)
+		}
+		return nil
+	}
+}
+
+func validateStringArray(v string) error {
+	var arr []string
+	if err := json.Unmarshal([]byte(v), &arr); err != nil {
+		return fmt.Errorf("应为字符串数组")
+	}
+	return nil
+}
+
+func validateJSONArray(v string) error {
+	var arr []json.RawMessage
+	if err := json.Unmarshal([]byte(v), &arr); err != nil {
+		return fmt.Errorf("应为 JSON 数组")
+	}
+	return nil
+}
+
+func validateJSONObject(v string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(v), &obj); err != nil {
+		return fmt.Errorf("应为 JSON 对象")
+	}
+	return nil
+}
+
+// toolRule 只取规则中校验所需的字段；完整结构见 detector.RemoteTool。
+type toolRule struct {
+	ProcessName string `json:"processName"`
+}
+
+func validateCustomTools(v string) error {
+	var tools []toolRule
+	if err := json.Unmarshal([]byte(v), &tools); err != nil {
+		return fmt.Errorf("应为工具规则数组")
+	}
+	for i, t := range tools {
+		if strings.TrimSpace(t.ProcessName) == "" {
+			return fmt.Errorf("第 %d 条规则缺少 processName", i+1)
+		}
+	}
+	return nil
+}
+
+func validateToolOverrides(v string) error {
+	var ov map[string]toolRule
+	if err := json.Unmarshal([]byte(v), &ov); err != nil {
+		return fmt.Errorf("应为 进程名 → 工具规则 的对象")
+	}
+	return nil
+}
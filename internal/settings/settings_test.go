@@ -0,0 +1,56 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"RemoteKnown/internal/storage"
+)
+
+func TestStoreDefaultsAndValidation(t *testing.T) {
+	st, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("初始化存储失败: %v", err)
+	}
+	defer st.Close()
+	s := New(st)
+
+	if got := s.RulesUpdateURL(); got != DefaultRulesUpdateURL {
+		t.Errorf("未配置时应返回默认更新源，实际 %q", got)
+	}
+	var disabled []string
+	if err := s.GetJSON(KeyDisabledTools, &disabled); err != nil || len(disabled) != 0 {
+		t.Errorf("disabled_tools 默认应为空数组: %v, %v", disabled, err)
+	}
+
+	if err := s.Set("no_such_key", "x"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("未知配置项应返回 ErrUnknownKey，实际 %v", err)
+	}
+	if err := s.Set(KeyRulesUpdateURL, "ftp://example.com"); err == nil {
+		t.Errorf("非 http(s) 更新源应被拒绝")
+	}
+	if err := s.Set(KeyCustomTools, `[{"toolName":"x"}]`); err == nil {
+		t.Errorf("缺少 processName 的自定义工具应被拒绝")
+	}
+	if err := s.SetJSON(KeyDisabledTools, []string{"todesk.exe"}); err != nil {
+		t.Fatalf("写入 disabled_tools 失败: %v", err)
+	}
+	if err := s.GetJSON(KeyDisabledTools, &disabled); err != nil || len(disabled) != 1 {
+		t.Errorf("读取 disabled_tools = %v, %v", disabled, err)
+	}
+}
+
+func TestSettingEncode(t *testing.T) {
+	def, _ := Lookup(KeyToolOverrides)
+	v, err := def.Encode(json.RawMessage(`{ "todesk.exe" : {"processName":"todesk.exe"} }`))
+	if err != nil || v != `{"todesk.exe":{"processName":"todesk.exe"}}` {
+		t.Errorf("Encode = %q, %v", v, err)
+	}
+
+	def, _ = Lookup(KeyDeviceName)
+	if _, err := def.Encode(json.RawMessage(`123`)); err == nil {
+		t.Errorf("字符串配置项传入数字应报错")
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计事件类型
+const (
+	AuditSessionStart = "session_start" // 远程会话开始
+	AuditSessionEnd   = "session_end"   // 远程会话结束
+	AuditSessionAck   = "session_ack"   // 用户确认（知悉）某次远程会话
+	AuditRuleChange   = "rule_change"   // 检测规则 / 监控工具变更
+	AuditConfigChange = "config_change" // 配置变更（通知、设备名等）
+	AuditNotification = "notification"  // 通知已成功发送到某个渠道
+)
+
+// auditGenesisHash 是链上第一条记录的 PrevHash。
+const auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// AuditEvent 是哈希链审计日志中的一条记录（只追加，不修改）。
+// Hash = SHA-256(Seq, Kind, Detail, CreatedAt, PrevHash)，PrevHash 指向上一条记录的 Hash，
+// 因此改动、删除或插入任意一行都会让之后的链校验失败。
+type AuditEvent struct {
+	Seq       int64     `gorm:"primaryKey;autoIncrement:false" json:"seq"`
+	Kind      string    `gorm:"type:text;not null;index" json:"kind"`
+	Detail    string    `gorm:"type:text" json:"detail"` // 事件详情 JSON
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	PrevHash  string    `gorm:"type:text;not null" json:"prev_hash"`
+	Hash      string    `gorm:"type:text;not null;uniqueIndex" json:"hash"`
+}
+
+// AuditVerifyResult 是审计链校验结果。BrokenSeq 为首个断链记录的序号（完好时为 0）。
+type AuditVerifyResult struct {
+	OK        bool   `json:"ok"`
+	Count     int64  `json:"count"`
+	HeadHash  string `json:"head_hash"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// computeHash 计算一条审计记录的哈希。各字段以换行分隔，时间统一为 UTC 纳秒精度。
+func (e *AuditEvent) computeHash() string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatInt(e.Seq, 10) + "\n"))
+	h.Write([]byte(e.Kind + "\n"))
+	h.Write([]byte(e.Detail + "\n"))
+	h.Write([]byte(e.CreatedAt.UTC().Format(time.RFC3339Nano) + "\n"))
+	h.Write([]byte(e.PrevHash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AppendAuditEvent 追加一条审计记录，detail 会被序列化为 JSON。
+func (s *Storage) AppendAuditEvent(kind string, detail interface{}) (*AuditEvent, error) {
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return nil, fmt.Errorf("序列化审计详情失败: %w", err)
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	var event AuditEvent
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var last AuditEvent
+		prevHash := auditGenesisHash
+		var seq int64 = 1
+		err := tx.Order("seq DESC").First(&last).Error
+		if err == nil {
+			prevHash = last.Hash
+			seq = last.Seq + 1
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		event = AuditEvent{
+			Seq:    seq,
+			Kind:   kind,
+			Detail: string(detailJSON),
+			// 截断到微秒，避免时间在存取过程中丢失精度导致哈希不一致
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			PrevHash:  prevHash,
+		}
+		event.Hash = event.computeHash()
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// VerifyAuditChain 按序号遍历整条审计链，返回首个断链位置。
+// 注意：仅删除链尾记录无法从链内发现，需借助外部保存的 HeadHash 比对。
+func (s *Storage) VerifyAuditChain() (*AuditVerifyResult, error) {
+	result := &AuditVerifyResult{OK: true, HeadHash: auditGenesisHash}
+	expectedSeq := int64(1)
+	prevHash := auditGenesisHash
+
+	var batch []AuditEvent
+	err := s.db.Order("seq ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			e := &batch[i]
+			switch {
+			case e.Seq != expectedSeq:
+				result.Reason = fmt.Sprintf("序号不连续：期望 %d，实际 %d（记录可能被删除或插入）", expectedSeq, e.Seq)
+			case e.PrevHash != prevHash:
+				result.Reason = "prev_hash 与上一条记录的哈希不一致"
+			case e.computeHash() != e.Hash:
+				result.Reason = "记录内容与哈希不一致（记录可能被篡改）"
+			}
+			if result.Reason != "" {
+				result.OK = false
+				result.BrokenSeq = expectedSeq
+				return errAuditChainBroken
+			}
+			result.Count++
+			prevHash = e.Hash
+			expectedSeq++
+		}
+		return nil
+	}).Error
+	if err != nil && err != errAuditChainBroken {
+		return nil, err
+	}
+	result.HeadHash = prevHash
+	return result, nil
+}
+
+// errAuditChainBroken 用于在发现断链后提前结束批量遍历。
+var errAuditChainBroken = fmt.Errorf("审计链已断开")
+
+// AcknowledgeSession 标记某次远程会话已被用户确认（知悉），重复确认保留首次时间。
+func (s *Storage) AcknowledgeSession(sessionID string) (*RemoteSession, error) {
+	var session RemoteSession
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("会话不存在: %s", sessionID)
+		}
+		return nil, err
+	}
+	if session.AcknowledgedAt == nil {
+		now := time.Now()
+		if err := s.db.Model(&session).Update("acknowledged_at", now).Error; err != nil {
+			return nil, err
+		}
+		session.AcknowledgedAt = &now
+	}
+	return &session, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("初始化存储失败: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestAuditChainDetectsTampering 验证哈希链能发现被绕过触发器直接改写的记录。
+func TestAuditChainDetectsTampering(t *testing.T) {
+	s := newTestStorage(t)
+	for i := 0; i < 3; i++ {
+		if _, err := s.AppendAuditEvent(AuditConfigChange, map[string]interface{}{"key": "device_name", "i": i}); err != nil {
+			t.Fatalf("追加审计记录失败: %v", err)
+		}
+	}
+
+	result, err := s.VerifyAuditChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK || result.Count != 3 {
+		t.Fatalf("完好的链校验结果异常: %+v", result)
+	}
+
+	// 触发器应拒绝直接修改
+	if err := s.db.Exec("UPDATE audit_events SET detail = '{}' WHERE seq = 2").Error; err == nil {
+		t.Fatalf("审计记录不应允许 UPDATE")
+	}
+
+	// 模拟有文件访问权限的人删掉触发器后改写记录
+	s.db.Exec("DROP TRIGGER audit_events_no_update")
+	if err := s.db.Exec(`UPDATE audit_events SET detail = '{"key":"forged"}' WHERE seq = 2`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = s.VerifyAuditChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OK || result.BrokenSeq != 2 || result.Count != 1 {
+		t.Errorf("应在第 2 条断链，实际 %+v", result)
+	}
+}
+
+// TestAuditChainDetectsDeletion 验证删除中间记录会被序号/前序哈希检查发现。
+func TestAuditChainDetectsDeletion(t *testing.T) {
+	s := newTestStorage(t)
+	for i := 0; i < 3; i++ {
+		s.AppendAuditEvent(AuditRuleChange, map[string]interface{}{"i": i})
+	}
+	s.db.Exec("DROP TRIGGER audit_events_no_delete")
+	s.db.Exec("DELETE FROM audit_events WHERE seq = 2")
+
+	result, err := s.VerifyAuditChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OK || result.BrokenSeq != 2 {
+		t.Errorf("删除中间记录应在第 2 条断链，实际 %+v", result)
+	}
+}
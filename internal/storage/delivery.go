@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationDelivery 是一次通知发送尝试的记录（成功或失败）。
+// 发件箱重试时每次尝试各记一行，用于证明某条告警确实已发出。
+type NotificationDelivery struct {
+	ID          string    `gorm:"primaryKey;type:text" json:"id"`
+	OutboxID    string    `gorm:"type:text;index" json:"outbox_id,omitempty"` // 对应的发件箱消息；发件箱不可用时直接发送则为空
+	ChannelID   string    `gorm:"type:text;index" json:"channel_id"`
+	ChannelName string    `gorm:"type:text" json:"channel_name"`
+	ChannelType string    `gorm:"type:text" json:"channel_type"`
+	EventType   string    `gorm:"type:text;index" json:"event_type"`
+	SessionID   string    `gorm:"type:text;index" json:"session_id,omitempty"`
+	Title       string    `gorm:"type:text" json:"title"` // 渲染后的标题
+	Attempt     int       `json:"attempt"`                // 第几次尝试（从 1 开始）
+	Success     bool      `gorm:"index" json:"success"`
+	LatencyMs   int64     `json:"latency_ms"`
+	Error       string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定发送记录表名
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// DeliveryFilter 是发送记录的查询条件，零值字段不参与过滤
+type DeliveryFilter struct {
+	ChannelID string
+	EventType string
+	SessionID string
+	Success   *bool
+}
+
+// RecordDelivery 写入一条发送记录
+func (s *Storage) RecordDelivery(d *NotificationDelivery) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	return s.db.Create(d).Error
+}
+
+// ListDeliveries 分页列出发送记录（按时间倒序）
+func (s *Storage) ListDeliveries(f DeliveryFilter, page, pageSize int) ([]NotificationDelivery, int64, error) {
+	var records []NotificationDelivery
+	var total int64
+
+	q := s.db.Model(&NotificationDelivery{})
+	if f.ChannelID != "" {
+		q = q.Where("channel_id = ?", f.ChannelID)
+	}
+	if f.EventType != "" {
+		q = q.Where("event_type = ?", f.EventType)
+	}
+	if f.SessionID != "" {
+		q = q.Where("session_id = ?", f.SessionID)
+	}
+	if f.Success != nil {
+		q = q.Where("success = ?", *f.Success)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&records).Error
+	return records, total, err
+}
+
+// DeliveriesForSessions 返回各会话的发送记录（按时间正序），键为会话 ID
+func (s *Storage) DeliveriesForSessions(sessionIDs []string) (map[string][]NotificationDelivery, error) {
+	out := make(map[string][]NotificationDelivery, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return out, nil
+	}
+	var records []NotificationDelivery
+	err := s.db.Where("session_id IN ?", sessionIDs).Order("created_at ASC").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		out[r.SessionID] = append(out[r.SessionID], r)
+	}
+	return out, nil
+}
+
+// PruneDeliveries 删除早于 before 的发送记录
+func (s *Storage) PruneDeliveries(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&NotificationDelivery{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveryHistory(t *testing.T) {
+	s := newTestStorage(t)
+	base := time.Now().Add(-time.Hour)
+
+	records := []NotificationDelivery{
+		{ChannelID: "c1", EventType: "remote_start", SessionID: "s1", Attempt: 1, Success: false, Error: "连接超时", CreatedAt: base},
+		{ChannelID: "c1", EventType: "remote_start", SessionID: "s1", Attempt: 2, Success: true, CreatedAt: base.Add(time.Minute)},
+		{ChannelID: "c2", EventType: "remote_end", SessionID: "s1", Attempt: 1, Success: true, CreatedAt: base.Add(2 * time.Minute)},
+		{ChannelID: "c2", EventType: "app_start", Attempt: 1, Success: true, CreatedAt: base.Add(3 * time.Minute)},
+	}
+	for i := range records {
+		if err := s.RecordDelivery(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, total, err := s.ListDeliveries(DeliveryFilter{}, 1, 3)
+	if err != nil || total != 4 || len(all) != 3 || all[0].EventType != "app_start" {
+		t.Fatalf("分页列表应按时间倒序: %+v, %d, %v", all, total, err)
+	}
+	failed := false
+	list, total, _ := s.ListDeliveries(DeliveryFilter{ChannelID: "c1", Success: &failed}, 1, 10)
+	if total != 1 || list[0].Error != "连接超时" {
+		t.Errorf("按渠道与结果过滤不正确: %+v", list)
+	}
+
+	bySession, err := s.DeliveriesForSessions([]string{"s1", "s2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bySession["s1"]; len(got) != 3 || got[0].Attempt != 1 || got[2].EventType != "remote_end" {
+		t.Errorf("会话 s1 的发送记录应按时间正序: %+v", got)
+	}
+	if len(bySession["s2"]) != 0 {
+		t.Errorf("会话 s2 不应有发送记录")
+	}
+
+	if n, err := s.PruneDeliveries(base.Add(90 * time.Second)); err != nil || n != 2 {
+		t.Errorf("应清理 2 条过期记录，实际 %d, %v", n, err)
+	}
+}
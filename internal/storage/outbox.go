@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 通知发件箱消息状态
+const (
+	OutboxPending = "pending" // 等待（重新）投递
+	OutboxSent    = "sent"    // 已投递成功
+	OutboxFailed  = "failed"  // 超过最长保留时间仍未成功，不再自动重试
+)
+
+// OutboxMessage 是通知发件箱中的一条消息：每个渠道的每次通知一行，
+// 投递失败时按退避时间重试，守护进程重启后继续投递。
+type OutboxMessage struct {
+	ID            string     `gorm:"primaryKey;type:text" json:"id"`
+	ChannelID     string     `gorm:"type:text;index" json:"channel_id"`
+	ChannelName   string     `gorm:"type:text" json:"channel_name"`
+	ChannelType   string     `gorm:"type:text" json:"channel_type"`
+	EventType     string     `gorm:"type:text" json:"event_type"`
+	Title         string     `gorm:"type:text" json:"title"`
+	Payload       string     `gorm:"type:text" json:"-"`                         // 已渲染的事件 JSON（不含渠道密钥，投递时读取渠道当前配置）
+	OrderKey      string     `gorm:"type:text;index" json:"order_key,omitempty"` // 顺序键：同一渠道同一顺序键的消息按创建顺序逐条投递，为空表示不限顺序
+	Delivered     string     `gorm:"type:text" json:"delivered,omitempty"`       // 已送达的部分（JSON 数组，如 Telegram 的 chat id）：一条通知需多次请求时，重试只补发其余部分
+	Status        string     `gorm:"type:text;not null;index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LeaseUntil    *time.Time `json:"lease_until"` // 投递者领取后的租约到期时间，投递结束后清空；租约内消息正在投递
+	SentAt        *time.Time `json:"sent_at"`
+	RetriedAt     *time.Time `json:"retried_at"` // 最近一次手动重试的时间，最长重试时间从此重新计算
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName 指定发件箱表名
+func (OutboxMessage) TableName() string {
+	return "notification_outbox"
+}
+
+// RetryWindowStart 返回计算最长重试时间的起点：手动重试过的消息从最近一次手动重试算起，否则从创建时算起
+func (m *OutboxMessage) RetryWindowStart() time.Time {
+	if m.RetriedAt != nil {
+		return *m.RetriedAt
+	}
+	return m.CreatedAt
+}
+
+// DeliveredParts 返回已送达的部分
+func (m *OutboxMessage) DeliveredParts() []string {
+	var parts []string
+	if m.Delivered != "" {
+		json.Unmarshal([]byte(m.Delivered), &parts)
+	}
+	return parts
+}
+
+// EnqueueOutbox 写入一条待投递消息
+func (s *Storage) EnqueueOutbox(msg *OutboxMessage) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.Status == "" {
+		msg.Status = OutboxPending
+	}
+	if msg.NextAttemptAt.IsZero() {
+		msg.NextAttemptAt = time.Now()
+	}
+	return s.db.Create(msg).Error
+}
+
+// DueOutbox 返回到期需要投递的待发消息（按计划时间排序）
+func (s *Storage) DueOutbox(now time.Time, limit int) ([]OutboxMessage, error) {
+	var msgs []OutboxMessage
+	err := s.db.Where("status = ? AND next_attempt_at <= ?", OutboxPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&msgs).Error
+	return msgs, err
+}
+
+// OutboxHeld 返回消息是否须等待：同一渠道、同一顺序键下有更早创建且仍待发的消息时返回 true。
+// 没有顺序键的消息不等待。
+func (s *Storage) OutboxHeld(msg *OutboxMessage) (bool, error) {
+	if msg.OrderKey == "" {
+		return false, nil
+	}
+	var count int64
+	err := s.db.Model(&OutboxMessage{}).
+		Where("channel_id = ? AND order_key = ? AND status = ? AND created_at < ? AND id <> ?",
+			msg.ChannelID, msg.OrderKey, OutboxPending, msg.CreatedAt, msg.ID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ClaimOutbox 领取一条待发消息准备投递：仅当消息仍为待发且尝试次数仍为 attempts 时成功，
+// 同时把尝试次数加一并把下次尝试时间与租约推迟到 until，避免其他投递者在投递期间再次取到它。
+// 返回 false 表示消息已被领取、已投递或已不再待发。
+func (s *Storage) ClaimOutbox(id string, attempts int, until time.Time) (bool, error) {
+	result := s.db.Model(&OutboxMessage{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, OutboxPending, attempts).
+		Updates(map[string]interface{}{
+			"attempts":        attempts + 1,
+			"next_attempt_at": until,
+			"lease_until":     until,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// MarkOutboxSent 记录一次成功投递
+func (s *Storage) MarkOutboxSent(id string, attempts int) error {
+	now := time.Now()
+	return s.db.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      OutboxSent,
+		"attempts":    attempts,
+		"last_error":  "",
+		"sent_at":     now,
+		"lease_until": nil,
+	}).Error
+}
+
+// SetOutboxDelivered 记录消息已送达的部分（部分接收方失败时），重试时跳过这些部分
+func (s *Storage) SetOutboxDelivered(id string, parts []string) error {
+	raw, err := json.Marshal(parts)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&OutboxMessage{}).Where("id = ?", id).Update("delivered", string(raw)).Error
+}
+
+// MarkOutboxRetry 记录一次失败投递并安排下次重试
+func (s *Storage) MarkOutboxRetry(id string, attempts int, lastErr string, next time.Time) error {
+	return s.db.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      lastErr,
+		"next_attempt_at": next,
+		"lease_until":     nil,
+	}).Error
+}
+
+// MarkOutboxFailed 把消息标记为最终失败（不再自动重试）
+func (s *Storage) MarkOutboxFailed(id string, attempts int, lastErr string) error {
+	return s.db.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      OutboxFailed,
+		"attempts":    attempts,
+		"last_error":  lastErr,
+		"lease_until": nil,
+	}).Error
+}
+
+// RetryOutbox 把失败或等待重试的消息重新排入队列、立即投递（手动重试），记录重试时间以重新计算最长重试时间。
+// 与 ClaimOutbox 一样以条件更新进行：正在投递（租约未到期）的消息不允许重试，避免被另一个投递者再次领取而重复发送。
+func (s *Storage) RetryOutbox(id string) (*OutboxMessage, error) {
+	now := time.Now()
+	result := s.db.Model(&OutboxMessage{}).
+		Where("id = ? AND (status = ? OR (status = ? AND (lease_until IS NULL OR lease_until <= ?)))",
+			id, OutboxFailed, OutboxPending, now).
+		Updates(map[string]interface{}{
+			"status":          OutboxPending,
+			"next_attempt_at": now,
+			"retried_at":      now,
+			"lease_until":     nil,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var msg OutboxMessage
+	if err := s.db.Where("id = ?", id).First(&msg).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("消息不存在: %s", id)
+		}
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		if msg.Status == OutboxSent {
+			return nil, fmt.Errorf("消息已投递成功，无需重试")
+		}
+		return nil, fmt.Errorf("消息正在投递中，请稍后再试")
+	}
+	return &msg, nil
+}
+
+// ListOutbox 分页列出发件箱消息（按创建时间倒序），status 为空表示全部
+func (s *Storage) ListOutbox(status string, page, pageSize int) ([]OutboxMessage, int64, error) {
+	var msgs []OutboxMessage
+	var total int64
+
+	q := s.db.Model(&OutboxMessage{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&msgs).Error
+	return msgs, total, err
+}
+
+// PruneOutbox 删除早于 before 的已投递消息，避免发件箱无限增长
+func (s *Storage) PruneOutbox(before time.Time) (int64, error) {
+	result := s.db.Where("status = ? AND created_at < ?", OutboxSent, before).Delete(&OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
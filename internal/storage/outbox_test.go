@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxLifecycle(t *testing.T) {
+	s := newTestStorage(t)
+	now := time.Now()
+
+	msg := &OutboxMessage{ChannelID: "c1", EventType: "remote_start", Payload: "{}"}
+	if err := s.EnqueueOutbox(msg); err != nil {
+		t.Fatal(err)
+	}
+	due, err := s.DueOutbox(now.Add(time.Second), 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("应有 1 条到期消息，实际 %d, %v", len(due), err)
+	}
+
+	// 只有一方能领取同一条消息，领取后在租约内不再到期
+	if ok, err := s.ClaimOutbox(msg.ID, 0, now.Add(time.Minute)); !ok || err != nil {
+		t.Fatalf("首次领取应成功: %v, %v", ok, err)
+	}
+	if ok, _ := s.ClaimOutbox(msg.ID, 0, now.Add(time.Minute)); ok {
+		t.Errorf("已领取的消息不应被再次领取")
+	}
+	if due, _ := s.DueOutbox(now.Add(time.Second), 10); len(due) != 0 {
+		t.Errorf("领取后的消息在租约内不应到期")
+	}
+
+	// 安排 1 分钟后重试：此刻不应到期
+	if err := s.MarkOutboxRetry(msg.ID, 1, "连接超时", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if due, _ := s.DueOutbox(now.Add(time.Second), 10); len(due) != 0 {
+		t.Errorf("未到重试时间的消息不应到期")
+	}
+
+	if err := s.MarkOutboxFailed(msg.ID, 2, "连接超时"); err != nil {
+		t.Fatal(err)
+	}
+	failed, total, err := s.ListOutbox(OutboxFailed, 1, 10)
+	if err != nil || total != 1 || failed[0].Attempts != 2 || failed[0].LastError != "连接超时" {
+		t.Fatalf("失败消息列表不正确: %+v, %d, %v", failed, total, err)
+	}
+
+	// 手动重试：重新排入队列并立即到期
+	if _, err := s.RetryOutbox(msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	due, _ = s.DueOutbox(time.Now().Add(time.Second), 10)
+	if len(due) != 1 {
+		t.Fatalf("手动重试后消息应立即到期")
+	}
+	if due[0].RetriedAt == nil || !due[0].CreatedAt.Equal(msg.CreatedAt) || !due[0].RetryWindowStart().Equal(*due[0].RetriedAt) {
+		t.Errorf("手动重试应记录重试时间而不改变创建时间: %+v", due[0])
+	}
+
+	if err := s.MarkOutboxSent(msg.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RetryOutbox(msg.ID); err == nil {
+		t.Error("已投递成功的消息不应允许重试")
+	}
+	if n, err := s.PruneOutbox(time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("应清理 1 条已投递消息，实际 %d, %v", n, err)
+	}
+}
+
+// TestOutboxHeld 验证同一渠道、同一顺序键的消息在更早的消息仍待发时等待，送达或失败后放行
+func TestOutboxHeld(t *testing.T) {
+	s := newTestStorage(t)
+	trigger := &OutboxMessage{ChannelID: "pd", EventType: "remote_start", Payload: "{}", OrderKey: "s-1"}
+	resolve := &OutboxMessage{ChannelID: "pd", EventType: "remote_end", Payload: "{}", OrderKey: "s-1"}
+	other := &OutboxMessage{ChannelID: "pd", EventType: "remote_end", Payload: "{}", OrderKey: "s-2"}
+	for _, m := range []*OutboxMessage{trigger, resolve, other} {
+		if err := s.EnqueueOutbox(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if held, err := s.OutboxHeld(trigger); held || err != nil {
+		t.Errorf("最早的消息不应等待: %v, %v", held, err)
+	}
+	if held, _ := s.OutboxHeld(resolve); !held {
+		t.Error("trigger 仍待发时 resolve 应等待")
+	}
+	if held, _ := s.OutboxHeld(other); held {
+		t.Error("不同顺序键的消息不应等待")
+	}
+
+	s.MarkOutboxFailed(trigger.ID, 5, "超过最长重试时间")
+	if held, _ := s.OutboxHeld(resolve); held {
+		t.Error("前一条消息不再待发后应放行")
+	}
+}
+
+// TestRetryOutboxSkipsClaimed 验证正在投递（租约未到期）的消息不能手动重试，等待重试或租约已过期的消息可以
+func TestRetryOutboxSkipsClaimed(t *testing.T) {
+	s := newTestStorage(t)
+	msg := &OutboxMessage{ChannelID: "c1", EventType: "remote_start", Payload: "{}"}
+	if err := s.EnqueueOutbox(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := s.ClaimOutbox(msg.ID, 0, time.Now().Add(time.Minute)); !ok {
+		t.Fatal("领取应成功")
+	}
+	if _, err := s.RetryOutbox(msg.ID); err == nil {
+		t.Fatal("投递中的消息不应允许手动重试")
+	}
+	if due, _ := s.DueOutbox(time.Now(), 10); len(due) != 0 {
+		t.Error("手动重试失败时不应把投递中的消息重新排入队列")
+	}
+
+	// 投递失败、等待重试：租约已释放，可手动重试
+	s.MarkOutboxRetry(msg.ID, 1, "超时", time.Now().Add(time.Hour))
+	if _, err := s.RetryOutbox(msg.ID); err != nil {
+		t.Errorf("等待重试的消息应允许手动重试: %v", err)
+	}
+
+	// 投递者中途退出，租约已过期：可手动重试
+	if ok, _ := s.ClaimOutbox(msg.ID, 1, time.Now().Add(-time.Second)); !ok {
+		t.Fatal("领取应成功")
+	}
+	if _, err := s.RetryOutbox(msg.ID); err != nil {
+		t.Errorf("租约过期的消息应允许手动重试: %v", err)
+	}
+	if _, err := s.RetryOutbox("no-such-id"); err == nil {
+		t.Error("不存在的消息应返回错误")
+	}
+}
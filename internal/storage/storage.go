@@ -3,6 +3,8 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-gormigrate/gormigrate/v2"
@@ -12,7 +14,8 @@ import (
 )
 
 type Storage struct {
-	db *gorm.DB
+	db      *gorm.DB
+	auditMu sync.Mutex // 串行化审计日志追加，保证哈希链不分叉
 }
 
 type RemoteSession struct {
@@ -23,6 +26,8 @@ type RemoteSession struct {
 	Signals    string     `gorm:"type:text" json:"signals"`
 	Confidence float64    `gorm:"type:real" json:"confidence"`
 	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	AcknowledgedAt *time.Time `json:"acknowledged_at"` // 用户确认（知悉）该会话的时间，nil 表示未确认
 }
 
 // MarshalJSON 自定义 JSON 序列化，正确处理 time.Duration
@@ -120,6 +125,90 @@ func (s *Storage) runMigrations() error {
 				return tx.Migrator().DropTable(&DetectionRuleSet{})
 			},
 		},
+		{
+			ID: "20261018000001",
+			Migrate: func(tx *gorm.DB) error {
+				// 哈希链审计日志（只追加）+ 会话确认时间
+				if err := tx.AutoMigrate(&AuditEvent{}); err != nil {
+					return err
+				}
+				if err := tx.AutoMigrate(&RemoteSession{}); err != nil {
+					return err
+				}
+				// 数据库层面拒绝修改/删除审计记录；绕过触发器的篡改由哈希链校验发现
+				for _, op := range []string{"UPDATE", "DELETE"} {
+					stmt := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS audit_events_no_%s BEFORE %s ON audit_events
+BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END`, strings.ToLower(op), op)
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&AuditEvent{})
+			},
+		},
+		{
+			ID: "20261018000002",
+			Migrate: func(tx *gorm.DB) error {
+				// 通知发件箱（持久化待投递通知，失败后退避重试）
+				return tx.AutoMigrate(&OutboxMessage{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&OutboxMessage{})
+			},
+		},
+		{
+			ID: "20261018000003",
+			Migrate: func(tx *gorm.DB) error {
+				// 通知发送记录（每次发送尝试一行）
+				return tx.AutoMigrate(&NotificationDelivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&NotificationDelivery{})
+			},
+		},
+		{
+			ID: "20261018000004",
+			Migrate: func(tx *gorm.DB) error {
+				// 发件箱记录手动重试时间（不再覆盖创建时间）
+				return tx.AutoMigrate(&OutboxMessage{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&OutboxMessage{}, "retried_at")
+			},
+		},
+		{
+			ID: "20261018000005",
+			Migrate: func(tx *gorm.DB) error {
+				// 发件箱消息的顺序键（值班平台同一事件的触发与关闭按顺序投递）
+				return tx.AutoMigrate(&OutboxMessage{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&OutboxMessage{}, "order_key")
+			},
+		},
+		{
+			ID: "20261018000006",
+			Migrate: func(tx *gorm.DB) error {
+				// 发件箱消息的领取租约（投递中的消息不允许手动重试）
+				return tx.AutoMigrate(&OutboxMessage{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&OutboxMessage{}, "lease_until")
+			},
+		},
+		{
+			ID: "20261018000007",
+			Migrate: func(tx *gorm.DB) error {
+				// 发件箱消息已送达的部分（多接收方的通知重试时不重复发送）
+				return tx.AutoMigrate(&OutboxMessage{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&OutboxMessage{}, "delivered")
+			},
+		},
 	})
 
 	return m.Migrate()
@@ -165,6 +254,26 @@ func (s *Storage) GetOpenSession() (*RemoteSession, error) {
 	return &session, nil
 }
 
+// GetSession 按 ID 获取会话；不存在时返回 (nil, nil)。
+func (s *Storage) GetSession(id string) (*RemoteSession, error) {
+	var session RemoteSession
+	err := s.db.Where("id = ?", id).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionsBetween 返回开始时间在 [from, to) 内的会话（按开始时间正序），供统计报告使用。
+func (s *Storage) GetSessionsBetween(from, to time.Time) ([]RemoteSession, error) {
+	var sessions []RemoteSession
+	err := s.db.Where("start_time >= ? AND start_time < ?", from, to).Order("start_time ASC").Find(&sessions).Error
+	return sessions, err
+}
+
 func (s *Storage) GetRecentSessions(limit int) ([]RemoteSession, error) {
 	var sessions []RemoteSession
 	err := s.db.Order("start_time DESC").Limit(limit).Find(&sessions).Error
@@ -208,6 +317,14 @@ func (s *Storage) SaveRawSignal(signal *RawSignal) error {
 	return s.db.Create(signal).Error
 }
 
+// Transaction 在单个数据库事务中执行 fn，fn 返回错误（或 panic）时全部回滚。
+// fn 内须通过传入的 tx 读写，不要再使用外层的 Storage。
+func (s *Storage) Transaction(fn func(tx *Storage) error) error {
+	return s.db.Transaction(func(db *gorm.DB) error {
+		return fn(&Storage{db: db})
+	})
+}
+
 func (s *Storage) GetConfig(key string) (string, error) {
 	var config Config
 	err := s.db.Where("key = ?", key).First(&config).Error
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestTransactionRollback 验证事务中任一步失败时此前的写入全部回滚
+func TestTransactionRollback(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.SetConfig("device_name", "旧名称"); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("中途失败")
+	err := s.Transaction(func(tx *Storage) error {
+		if err := tx.SetConfig("device_name", "新名称"); err != nil {
+			return err
+		}
+		if _, err := tx.SaveRuleSet("9.9.9", "", "[]", "bundle"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("应返回 fn 的错误，实际 %v", err)
+	}
+	if v, _ := s.GetConfig("device_name"); v != "旧名称" {
+		t.Errorf("配置应回滚，实际 %q", v)
+	}
+	if rs, _ := s.GetRuleSetByVersion("9.9.9"); rs != nil {
+		t.Errorf("规则集应回滚")
+	}
+
+	if err := s.Transaction(func(tx *Storage) error { return tx.SetConfig("device_name", "新名称") }); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.GetConfig("device_name"); v != "新名称" {
+		t.Errorf("事务提交后应可读到新值，实际 %q", v)
+	}
+}